@@ -3,10 +3,37 @@ package main
 import (
 	"context"
 	"crm-backend/internal/admin"
+	"crm-backend/internal/apikey"
+	"crm-backend/internal/audit"
 	"crm-backend/internal/auth"
+	"crm-backend/internal/campaign"
+	"crm-backend/internal/checklist"
+	"crm-backend/internal/commission"
+	"crm-backend/internal/customer"
 	"crm-backend/internal/db"
 	"crm-backend/internal/employee"
+	"crm-backend/internal/fiscal"
+	"crm-backend/internal/giftcard"
+	"crm-backend/internal/impersonation"
+	"crm-backend/internal/lockout"
+	"crm-backend/internal/loyalty"
+	"crm-backend/internal/notify"
+	"crm-backend/internal/payment"
+	"crm-backend/internal/performance"
+	"crm-backend/internal/pos"
+	"crm-backend/internal/privacy"
+	"crm-backend/internal/profile"
+	"crm-backend/internal/promo"
+	"crm-backend/internal/recovery"
+	"crm-backend/internal/reservation"
+	"crm-backend/internal/sale"
+	"crm-backend/internal/segment"
+	"crm-backend/internal/shift"
 	"crm-backend/internal/shop"
+	"crm-backend/internal/task"
+	"crm-backend/internal/timesheet"
+	"crm-backend/internal/twofactor"
+	"crm-backend/internal/waitlist"
 	"fmt"
 	"log"
 	"net/http"
@@ -51,9 +78,14 @@ func main() {
 		log.Fatal("Ошибка миграции:", err)
 	}
 
+	lockoutService := lockout.NewService(lockout.NewRepository(database))
+	lockoutHandler := lockout.NewHandler(lockoutService)
+
 	adminRepo := admin.NewRepository(database)
 	adminService := admin.NewService(adminRepo)
-	adminHandler := admin.NewHandler(adminService)
+	twoFactorService := twofactor.NewService(twofactor.NewRepository(database), lockoutService, os.Getenv("TWO_FACTOR_ROLES"))
+	twoFactorHandler := twofactor.NewHandler(twoFactorService)
+	adminHandler := admin.NewHandler(adminService, lockoutService, twoFactorService)
 
 	if err := adminRepo.InitSuperAdmin(); err != nil {
 		log.Fatal("Ошибка создания супер-админа:", err)
@@ -71,8 +103,143 @@ func main() {
 	authRepo := auth.NewRepository(database)
 	authService := auth.NewService(authRepo)
 	authHandler := auth.NewHandler(authService)
-
-	r := setupRoutes(adminHandler, shopHandler, employeeHandler, authHandler)
+	auth.UseSessions(authRepo)
+
+	posRepo := pos.NewRepository(database)
+	posHandler := pos.NewHandler(pos.NewService(posRepo, employeeRepo))
+	auth.UseDevices(posRepo)
+
+	apiKeyService := apikey.NewService(apikey.NewRepository(database), employeeRepo)
+	apiKeyHandler := apikey.NewHandler(apiKeyService)
+	auth.UseAPIKeys(apiKeyService)
+
+	paymentRepo := payment.NewRepository(database)
+	paymentService := payment.NewService(paymentRepo, employeeRepo)
+	// Пока нет договора с эквайером — карта и Kaspi QR проходят через локальный провайдер
+	paymentService.RegisterProvider(payment.MethodCard, payment.NewFakeProvider("card"))
+	paymentService.RegisterProvider(payment.MethodKaspiQR, payment.NewFakeProvider("kaspi"))
+	paymentHandler := payment.NewHandler(paymentService)
+
+	giftCardRepo := giftcard.NewRepository(database)
+	giftCardService := giftcard.NewService(giftCardRepo, employeeRepo)
+	paymentService.RegisterProvider(payment.MethodGiftCard, giftcard.NewProvider(giftCardRepo, employeeRepo))
+	giftCardHandler := giftcard.NewHandler(giftCardService)
+
+	fiscalDir := os.Getenv("FISCAL_MOCK_DIR")
+	if fiscalDir == "" {
+		fiscalDir = "fiscal_mock"
+	}
+	// Пока нет подключения к реальному ОФД — документы пишутся в файлы
+	fiscalProvider, err := fiscal.NewFileProvider(fiscalDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fiscalRepo := fiscal.NewRepository(database)
+	fiscalService := fiscal.NewService(fiscalRepo, fiscalProvider, employeeRepo)
+	fiscalHandler := fiscal.NewHandler(fiscalService)
+
+	shiftRepo := shift.NewRepository(database)
+	shiftService := shift.NewService(shiftRepo, employeeRepo, fiscalService)
+	shiftHandler := shift.NewHandler(shiftService)
+
+	promoRepo := promo.NewRepository(database)
+	promoService := promo.NewService(promoRepo, shopRepo, employeeRepo)
+	promoHandler := promo.NewHandler(promoService)
+
+	customerRepo := customer.NewRepository(database)
+	customerService := customer.NewService(customerRepo, employeeRepo)
+	customerHandler := customer.NewHandler(customerService)
+
+	taskRepo := task.NewRepository(database)
+	taskService := task.NewService(taskRepo, employeeRepo, customerRepo, shopRepo)
+	taskHandler := task.NewHandler(taskService)
+
+	loyaltyRepo := loyalty.NewRepository(database)
+	loyaltyService := loyalty.NewService(loyaltyRepo, employeeRepo, customerRepo)
+	paymentService.RegisterProvider(payment.MethodLoyalty, loyalty.NewProvider(loyaltyService))
+	loyaltyHandler := loyalty.NewHandler(loyaltyService)
+
+	segmentRepo := segment.NewRepository(database)
+	segmentService := segment.NewService(segmentRepo)
+	segmentHandler := segment.NewHandler(segmentService)
+
+	// Пока нет подключения к SMS-шлюзу, SMTP и Telegram Bot API — сообщения пишутся в лог
+	notifier := notify.NewDispatcher()
+	notifier.Register(notify.ChannelSMS, notify.NewLogSender("sms"))
+	notifier.Register(notify.ChannelEmail, notify.NewLogSender("email"))
+	notifier.Register(notify.ChannelTelegram, notify.NewLogSender("telegram"))
+
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
+	campaignRepo := campaign.NewRepository(database)
+	campaignService := campaign.NewService(campaignRepo, segmentService, customerRepo, loyaltyRepo, notifier, publicURL)
+	campaignHandler := campaign.NewHandler(campaignService)
+
+	waitlistRepo := waitlist.NewRepository(database)
+	waitlistService := waitlist.NewService(waitlistRepo, employeeRepo, customerRepo, shopRepo, taskService, notifier)
+	waitlistHandler := waitlist.NewHandler(waitlistService)
+
+	saleRepo := sale.NewRepository(database)
+	saleService := sale.NewService(saleRepo, shopRepo, employeeRepo, paymentService, shiftService, fiscalService, promoService,
+		customerRepo, loyaltyService, waitlistService)
+	saleHandler := sale.NewHandler(saleService)
+
+	reservationRepo := reservation.NewRepository(database)
+	reservationService := reservation.NewService(reservationRepo, employeeRepo, shopRepo, customerRepo, saleService, waitlistService)
+	reservationHandler := reservation.NewHandler(reservationService)
+
+	timesheetRepo := timesheet.NewRepository(database)
+	timesheetService := timesheet.NewService(timesheetRepo, employeeRepo)
+	timesheetHandler := timesheet.NewHandler(timesheetService)
+
+	commissionRepo := commission.NewRepository(database)
+	commissionService := commission.NewService(commissionRepo, employeeRepo, shopRepo)
+	commissionHandler := commission.NewHandler(commissionService)
+
+	performanceService := performance.NewService(performance.NewRepository(database), employeeRepo)
+	performanceHandler := performance.NewHandler(performanceService)
+
+	checklistRepo := checklist.NewRepository(database)
+	checklistService := checklist.NewService(checklistRepo, employeeRepo)
+	checklistHandler := checklist.NewHandler(checklistService)
+
+	profileService := profile.NewService(profile.NewRepository(database), notifier)
+	profileHandler := profile.NewHandler(profileService)
+
+	recoveryService := recovery.NewService(recovery.NewRepository(database), notifier, lockoutService, publicURL)
+	recoveryHandler := recovery.NewHandler(recoveryService)
+
+	auditRepo := audit.NewRepository(database)
+	auditHandler := audit.NewHandler(auditRepo)
+
+	privacyService := privacy.NewService(customerRepo, saleRepo, loyaltyRepo, campaignRepo, auditRepo)
+	privacyHandler := privacy.NewHandler(privacyService)
+
+	impersonationService := impersonation.NewService(impersonation.NewRepository(database), auditRepo)
+	impersonationHandler := impersonation.NewHandler(impersonationService)
+	auth.UseImpersonations(impersonationService)
+
+	r := setupRoutes(adminHandler, shopHandler, employeeHandler, authHandler, paymentHandler, saleHandler, shiftHandler, fiscalHandler, promoHandler, giftCardHandler, customerHandler,
+		loyaltyHandler, segmentHandler, campaignHandler, auditHandler, privacyHandler,
+		taskHandler, waitlistHandler, reservationHandler, timesheetHandler,
+		commissionHandler, performanceHandler, checklistHandler, profileHandler,
+		recoveryHandler, lockoutHandler, twoFactorHandler, posHandler, apiKeyHandler, impersonationHandler)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go fiscalService.RunRetries(jobsCtx, time.Minute)
+	go loyaltyService.RunExpiry(jobsCtx, time.Hour)
+	go segmentService.RunRecompute(jobsCtx, 6*time.Hour)
+	go campaignService.RunDispatcher(jobsCtx, time.Minute)
+	go customerService.RunDuplicateDetection(jobsCtx, 24*time.Hour)
+	go waitlistService.RunExpiry(jobsCtx, 5*time.Minute)
+	go reservationService.RunExpiry(jobsCtx, 5*time.Minute)
+	go checklistService.RunScheduler(jobsCtx, 5*time.Minute)
+	go recoveryService.RunCleanup(jobsCtx, time.Hour)
+	go lockoutService.RunCleanup(jobsCtx, time.Hour)
+	go twoFactorService.RunCleanup(jobsCtx, time.Hour)
 
 	srv := &http.Server{
 		Addr:    ":8080",
@@ -91,6 +258,7 @@ func main() {
 	<-quit
 
 	fmt.Println("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -123,6 +291,31 @@ func runMigrations(database *db.DB) error {
 		return fmt.Errorf("Ошибка миграции admin/users: %w", err)
 	}
 
+	authRepo := auth.NewRepository(database)
+	if err := authRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции sessions: %w", err)
+	}
+
+	profileRepo := profile.NewRepository(database)
+	if err := profileRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции email_changes: %w", err)
+	}
+
+	recoveryRepo := recovery.NewRepository(database)
+	if err := recoveryRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции password_resets: %w", err)
+	}
+
+	lockoutRepo := lockout.NewRepository(database)
+	if err := lockoutRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции login_attempts: %w", err)
+	}
+
+	twoFactorRepo := twofactor.NewRepository(database)
+	if err := twoFactorRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции user_totp: %w", err)
+	}
+
 	// Миграция таблицы shops и items
 	shopRepo := shop.NewRepository(database)
 	if err := shopRepo.Migrate(); err != nil {
@@ -137,6 +330,106 @@ func runMigrations(database *db.DB) error {
 		return fmt.Errorf("Ошибка миграции employees: %w", err)
 	}
 
+	posRepo := pos.NewRepository(database)
+	if err := posRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции pos_devices: %w", err)
+	}
+
+	apiKeyRepo := apikey.NewRepository(database)
+	if err := apiKeyRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции api_keys: %w", err)
+	}
+
+	paymentRepo := payment.NewRepository(database)
+	if err := paymentRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции payment methods: %w", err)
+	}
+
+	shiftRepo := shift.NewRepository(database)
+	if err := shiftRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции shifts: %w", err)
+	}
+
+	giftCardRepo := giftcard.NewRepository(database)
+	if err := giftCardRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции gift cards: %w", err)
+	}
+
+	promoRepo := promo.NewRepository(database)
+	if err := promoRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции promotions: %w", err)
+	}
+
+	customerRepo := customer.NewRepository(database)
+	if err := customerRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции customers: %w", err)
+	}
+
+	taskRepo := task.NewRepository(database)
+	if err := taskRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции tasks: %w", err)
+	}
+
+	waitlistRepo := waitlist.NewRepository(database)
+	if err := waitlistRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции waitlist: %w", err)
+	}
+
+	saleRepo := sale.NewRepository(database)
+	if err := saleRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции sales: %w", err)
+	}
+
+	reservationRepo := reservation.NewRepository(database)
+	if err := reservationRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции reservations: %w", err)
+	}
+
+	timesheetRepo := timesheet.NewRepository(database)
+	if err := timesheetRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции timesheet: %w", err)
+	}
+
+	commissionRepo := commission.NewRepository(database)
+	if err := commissionRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции commissions: %w", err)
+	}
+
+	checklistRepo := checklist.NewRepository(database)
+	if err := checklistRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции checklists: %w", err)
+	}
+
+	loyaltyRepo := loyalty.NewRepository(database)
+	if err := loyaltyRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции loyalty: %w", err)
+	}
+
+	segmentRepo := segment.NewRepository(database)
+	if err := segmentRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции segments: %w", err)
+	}
+
+	campaignRepo := campaign.NewRepository(database)
+	if err := campaignRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции campaigns: %w", err)
+	}
+
+	auditRepo := audit.NewRepository(database)
+	if err := auditRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции audit log: %w", err)
+	}
+
+	impersonationRepo := impersonation.NewRepository(database)
+	if err := impersonationRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции impersonations: %w", err)
+	}
+
+	fiscalRepo := fiscal.NewRepository(database)
+	if err := fiscalRepo.Migrate(); err != nil {
+		return fmt.Errorf("Ошибка миграции fiscal documents: %w", err)
+	}
+
 	return nil
 }
 
@@ -145,6 +438,32 @@ func setupRoutes(
 	shopHandler *shop.Handler,
 	employeeHandler *employee.Handler,
 	authHandler *auth.Handler,
+	paymentHandler *payment.Handler,
+	saleHandler *sale.Handler,
+	shiftHandler *shift.Handler,
+	fiscalHandler *fiscal.Handler,
+	promoHandler *promo.Handler,
+	giftCardHandler *giftcard.Handler,
+	customerHandler *customer.Handler,
+	loyaltyHandler *loyalty.Handler,
+	segmentHandler *segment.Handler,
+	campaignHandler *campaign.Handler,
+	auditHandler *audit.Handler,
+	privacyHandler *privacy.Handler,
+	taskHandler *task.Handler,
+	waitlistHandler *waitlist.Handler,
+	reservationHandler *reservation.Handler,
+	timesheetHandler *timesheet.Handler,
+	commissionHandler *commission.Handler,
+	performanceHandler *performance.Handler,
+	checklistHandler *checklist.Handler,
+	profileHandler *profile.Handler,
+	recoveryHandler *recovery.Handler,
+	lockoutHandler *lockout.Handler,
+	twoFactorHandler *twofactor.Handler,
+	posHandler *pos.Handler,
+	apiKeyHandler *apikey.Handler,
+	impersonationHandler *impersonation.Handler,
 ) *chi.Mux {
 	r := chi.NewRouter()
 
@@ -152,7 +471,7 @@ func setupRoutes(
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:5173"}, // Фронтенд
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", pos.DeviceHeader},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -166,9 +485,35 @@ func setupRoutes(
 	))
 
 	r.Post("/auth/login", adminHandler.Login)
+	r.Post("/auth/login/2fa", twoFactorHandler.CompleteLogin)
+	r.Post("/auth/login/2fa/setup", twoFactorHandler.LoginSetup)
+	// Касса авторизуется своим ключом в заголовке X-Device-Token
+	r.Get("/pos/staff", posHandler.GetStaff)
+	r.Post("/pos/login", posHandler.Login)
+	r.Post("/auth/password-reset", recoveryHandler.RequestReset)
+	r.Post("/auth/password-reset/confirm", recoveryHandler.ConfirmReset)
 	r.Group(func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
 		r.Get("/auth/me", authHandler.Me)
+		r.Get("/me/profile", profileHandler.GetProfile)
+		r.Put("/me/profile", profileHandler.UpdateProfile)
+		r.With(auth.DenyImpersonation).Post("/me/email", profileHandler.RequestEmailChange)
+		r.With(auth.DenyImpersonation).Post("/me/email/confirm", profileHandler.ConfirmEmail)
+		r.With(auth.DenyImpersonation).Post("/me/password", profileHandler.ChangePassword)
+		r.Get("/me/2fa", twoFactorHandler.GetStatus)
+		r.With(auth.DenyImpersonation).Post("/me/2fa/setup", twoFactorHandler.Setup)
+		r.With(auth.DenyImpersonation).Post("/me/2fa/enable", twoFactorHandler.Enable)
+		r.With(auth.DenyImpersonation).Post("/me/2fa/disable", twoFactorHandler.Disable)
+		r.With(auth.DenyImpersonation).Post("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+		r.With(auth.DenyImpersonation).Put("/me/pin", posHandler.SetPIN)
+		r.With(auth.DenyImpersonation).Post("/me/api-keys", apiKeyHandler.CreateKey)
+		r.Get("/me/api-keys", apiKeyHandler.GetMyKeys)
+		r.With(auth.DenyImpersonation).Delete("/me/api-keys/{key_id}", apiKeyHandler.RevokeMyKey)
+		r.Get("/me/api-keys/{key_id}/usage", apiKeyHandler.GetMyKeyUsage)
+		r.Get("/me/tasks", taskHandler.MyTasks)
+		r.Get("/me/timeclock", timesheetHandler.CurrentEntry)
+		r.Get("/me/commissions", commissionHandler.MyStatements)
+		r.Get("/me/checklists", checklistHandler.MyChecklists)
 	})
 
 	r.Route("/admin/users", func(r chi.Router) {
@@ -177,6 +522,34 @@ func setupRoutes(
 		r.Post("/", adminHandler.CreateUser)
 		r.Put("/{id}", adminHandler.UpdateUser)
 		r.Delete("/{id}", adminHandler.DeleteUser)
+		r.Post("/{id}/unlock", lockoutHandler.UnlockUser)
+		r.Get("/{id}/login-attempts", lockoutHandler.GetUserLoginAttempts)
+		r.Delete("/{id}/2fa", twoFactorHandler.ResetUser)
+		r.Post("/{id}/impersonate", impersonationHandler.Start)
+	})
+
+	r.Route("/admin/login-attempts", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", lockoutHandler.GetLoginAttempts)
+	})
+
+	r.Route("/admin/login-locks", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", lockoutHandler.GetLocks)
+		r.Delete("/ip/{ip}", lockoutHandler.UnlockIP)
+	})
+
+	r.Route("/admin/api-keys", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", apiKeyHandler.GetAllKeys)
+		r.Delete("/{key_id}", apiKeyHandler.RevokeKey)
+	})
+
+	r.Route("/admin/impersonations", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", impersonationHandler.GetSessions)
+		r.Get("/{impersonation_id}/requests", impersonationHandler.GetRequests)
+		r.Post("/{impersonation_id}/end", impersonationHandler.End)
 	})
 
 	r.Route("/admin/shops", func(r chi.Router) {
@@ -194,6 +567,205 @@ func setupRoutes(
 			r.Get("/", employeeHandler.GetEmployeesByShop)
 			r.Delete("/{employee_id}", employeeHandler.RemoveEmployee)
 		})
+
+		r.Put("/{id}/payment-methods", paymentHandler.SetShopMethods)
+		r.Get("/{id}/fiscal-documents", fiscalHandler.GetDocuments)
+		r.Post("/{id}/fiscal-documents/{doc_id}/retry", fiscalHandler.RetryDocument)
+
+		r.Route("/{id}/pos-devices", func(r chi.Router) {
+			r.With(auth.DenyImpersonation).Post("/", posHandler.CreateDevice)
+			r.Get("/", posHandler.GetDevices)
+			r.Delete("/{device_id}", posHandler.RevokeDevice)
+		})
+	})
+
+	r.Route("/owner/promotions", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Post("/", promoHandler.CreatePromotion)
+		r.Get("/", promoHandler.GetPromotions)
+		r.Put("/{promo_id}", promoHandler.UpdatePromotion)
+		r.Delete("/{promo_id}", promoHandler.DeletePromotion)
+	})
+
+	r.Route("/owner/loyalty", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", loyaltyHandler.GetProgram)
+		r.Put("/", loyaltyHandler.SaveProgram)
+		r.Post("/earn-rates", loyaltyHandler.CreateEarnRate)
+		r.Get("/earn-rates", loyaltyHandler.GetEarnRates)
+		r.Delete("/earn-rates/{rate_id}", loyaltyHandler.DeleteEarnRate)
+		r.Post("/bonuses", loyaltyHandler.CreateBonus)
+		r.Get("/bonuses", loyaltyHandler.GetBonuses)
+		r.Delete("/bonuses/{bonus_id}", loyaltyHandler.DeleteBonus)
+	})
+
+	r.Route("/owner/segments", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Post("/", segmentHandler.CreateSegment)
+		r.Get("/", segmentHandler.GetSegments)
+		r.Post("/preview", segmentHandler.PreviewRules)
+		r.Put("/{segment_id}", segmentHandler.UpdateSegment)
+		r.Delete("/{segment_id}", segmentHandler.DeleteSegment)
+		r.Get("/{segment_id}/members", segmentHandler.GetMembers)
+		r.Post("/{segment_id}/recompute", segmentHandler.RecomputeSegment)
+		r.With(auth.DenyImpersonation).Get("/{segment_id}/export", segmentHandler.ExportMembers)
+	})
+
+	r.Route("/owner/campaigns", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Post("/", campaignHandler.CreateCampaign)
+		r.Get("/", campaignHandler.GetCampaigns)
+		r.Get("/{campaign_id}", campaignHandler.GetCampaign)
+		r.Put("/{campaign_id}", campaignHandler.UpdateCampaign)
+		r.Post("/{campaign_id}/schedule", campaignHandler.ScheduleCampaign)
+		r.Post("/{campaign_id}/cancel", campaignHandler.CancelCampaign)
+	})
+
+	// Публичные ссылки из сообщений рассылок: переход и отписка
+	r.Get("/c/{token}", campaignHandler.Click)
+	r.Get("/c/{token}/unsubscribe", campaignHandler.Unsubscribe)
+
+	r.Route("/owner/customers", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/rfm", segmentHandler.GetRFM)
+		r.With(auth.DenyImpersonation).Get("/{customer_id}/export", privacyHandler.ExportCustomer)
+		r.With(auth.DenyImpersonation).Post("/{customer_id}/anonymize", privacyHandler.AnonymizeCustomer)
+	})
+
+	r.Route("/owner/audit", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", auditHandler.GetLog)
+	})
+
+	r.Route("/owner/commissions", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Post("/plans", commissionHandler.CreatePlan)
+		r.Get("/plans", commissionHandler.GetPlans)
+		r.Put("/plans/{plan_id}", commissionHandler.UpdatePlan)
+		r.Get("/assignments", commissionHandler.GetAssignments)
+		r.Put("/assignments", commissionHandler.SetAssignment)
+		r.Get("/targets", commissionHandler.GetTargets)
+		r.Put("/targets", commissionHandler.SetTarget)
+		r.Get("/statements", commissionHandler.GetReport)
+	})
+
+	r.Route("/owner/performance", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/leaderboard", performanceHandler.GetLeaderboard)
+	})
+
+	r.Route("/owner/checklists", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/overdue", checklistHandler.GetOverdue)
+	})
+
+	r.Route("/owner/waitlist", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/settings", waitlistHandler.GetSettings)
+		r.Put("/settings", waitlistHandler.SaveSettings)
+	})
+
+	r.Route("/owner/gift-cards", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+		r.Get("/", giftCardHandler.GetCards)
+	})
+
+	// Операции магазина — доступны владельцу и сотрудникам магазина
+	r.Route("/shops/{id}", func(r chi.Router) {
+		// Кассовые операции — в том числе по токену, выданному на кассе этого магазина.
+		// Токену кассы доступны только маршруты этой группы.
+		r.Group(func(r chi.Router) {
+			r.Use(auth.AllowDevice("id"))
+			r.Use(auth.AuthMiddleware)
+			r.Get("/payment-methods", paymentHandler.GetShopMethods)
+			r.Post("/prices/evaluate", promoHandler.EvaluatePrices)
+			r.Get("/gift-cards/{code}", giftCardHandler.CheckBalance)
+
+			r.Post("/customers", customerHandler.CreateCustomer)
+			r.Get("/customers", customerHandler.SearchCustomers)
+			r.Get("/customers/{customer_id}", customerHandler.GetCustomer)
+			r.Put("/customers/{customer_id}", customerHandler.UpdateCustomer)
+			r.Post("/customers/{customer_id}/interactions", customerHandler.AddInteraction)
+			r.Get("/customers/{customer_id}/loyalty", loyaltyHandler.GetAccount)
+
+			r.Get("/waitlist", waitlistHandler.GetEntries)
+			r.Get("/availability", reservationHandler.GetAvailability)
+			r.Get("/reservations", reservationHandler.GetReservations)
+			r.Post("/reservations/{reservation_id}/collect", reservationHandler.CollectReservation)
+
+			r.Route("/timeclock", func(r chi.Router) {
+				r.Post("/clock-in", timesheetHandler.ClockIn)
+				r.Post("/clock-out", timesheetHandler.ClockOut)
+				r.Post("/break-start", timesheetHandler.StartBreak)
+				r.Post("/break-end", timesheetHandler.EndBreak)
+			})
+
+			r.Route("/sales", func(r chi.Router) {
+				r.Post("/", saleHandler.CreateSale)
+				r.Get("/", saleHandler.GetSales)
+				r.Get("/{sale_id}", saleHandler.GetSale)
+				r.Post("/{sale_id}/returns", saleHandler.ReturnSale)
+			})
+
+			r.Route("/shifts", func(r chi.Router) {
+				r.Post("/", shiftHandler.OpenShift)
+				r.Get("/", shiftHandler.GetShifts)
+				r.Get("/current", shiftHandler.GetCurrentShift)
+				r.Post("/{shift_id}/cash-movements", shiftHandler.AddCashMovement)
+				r.Get("/{shift_id}/cash-movements", shiftHandler.GetCashMovements)
+				r.Get("/{shift_id}/report", shiftHandler.GetReport)
+				r.Post("/{shift_id}/close", shiftHandler.CloseShift)
+			})
+		})
+
+		// Управление магазином — только при обычном входе
+		r.Group(func(r chi.Router) {
+			r.Use(auth.AuthMiddleware)
+			r.Post("/gift-cards", giftCardHandler.IssueCard)
+			r.Get("/gift-cards/{code}/ledger", giftCardHandler.GetLedger)
+
+			r.Get("/customers/{customer_id}/timeline", customerHandler.GetTimeline)
+			r.Post("/customers/{customer_id}/merge", customerHandler.MergeCustomers)
+			r.Get("/customers/duplicates", customerHandler.GetDuplicates)
+			r.Post("/customers/duplicates/{pair_id}/dismiss", customerHandler.DismissDuplicate)
+			r.Post("/customers/merges/{merge_id}/undo", customerHandler.UndoMerge)
+			r.Get("/customers/{customer_id}/loyalty/ledger", loyaltyHandler.GetLedger)
+
+			r.Route("/tasks", func(r chi.Router) {
+				r.Post("/", taskHandler.CreateTask)
+				r.Get("/", taskHandler.GetShopTasks)
+				r.Put("/{task_id}", taskHandler.UpdateTask)
+				r.Post("/{task_id}/status", taskHandler.SetTaskStatus)
+			})
+
+			r.Post("/waitlist", waitlistHandler.AddEntry)
+			r.Post("/waitlist/{entry_id}/fulfill", waitlistHandler.FulfillEntry)
+			r.Post("/waitlist/{entry_id}/cancel", waitlistHandler.CancelEntry)
+			r.Post("/items/{item_id}/receive", waitlistHandler.ReceiveStock)
+
+			r.Post("/reservations", reservationHandler.CreateReservation)
+			r.Post("/reservations/{reservation_id}/ship", reservationHandler.ShipReservation)
+			r.Post("/reservations/{reservation_id}/receive", reservationHandler.ReceiveReservation)
+			r.Post("/reservations/{reservation_id}/cancel", reservationHandler.CancelReservation)
+
+			r.Get("/schedule", timesheetHandler.GetSchedule)
+			r.Put("/schedule/{user_id}", timesheetHandler.SetSchedule)
+			r.Get("/timesheet", timesheetHandler.GetTimesheet)
+			r.Get("/performance", performanceHandler.GetShopPerformance)
+			r.Get("/performance/{user_id}", performanceHandler.GetEmployeePerformance)
+
+			r.Route("/checklist-templates", func(r chi.Router) {
+				r.Post("/", checklistHandler.CreateTemplate)
+				r.Get("/", checklistHandler.GetTemplates)
+				r.Put("/{template_id}", checklistHandler.UpdateTemplate)
+				r.Delete("/{template_id}", checklistHandler.DeactivateTemplate)
+			})
+			r.Route("/checklists", func(r chi.Router) {
+				r.Get("/", checklistHandler.GetChecklists)
+				r.Put("/{checklist_id}/assignee", checklistHandler.AssignChecklist)
+				r.Post("/{checklist_id}/items/{item_id}/complete", checklistHandler.CompleteItem)
+			})
+		})
 	})
 
 	return r
@@ -2,7 +2,11 @@ package admin
 
 import (
 	"crm-backend/internal/auth"
+	"crm-backend/internal/lockout"
+	"crm-backend/internal/twofactor"
 	"encoding/json"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -11,15 +15,17 @@ import (
 )
 
 type Handler struct {
-	service *Service
+	service   *Service
+	guard     *lockout.Service
+	twoFactor *twofactor.Service
 }
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, guard *lockout.Service, twoFactor *twofactor.Service) *Handler {
+	return &Handler{service: service, guard: guard, twoFactor: twoFactor}
 }
 
 // CreateUser godoc
@@ -146,17 +152,22 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Пользователь обновлён"))
 }
 
+// dummyHash — bcrypt-хэш, с которым сравнивается пароль, если email не найден:
+// так ответ для несуществующего адреса не приходит заметно быстрее
+const dummyHash = "$2a$10$loTPB0w5AqnUIYAxc6HV8.YrrHLrsbCbVT2zLwCRv0zr.Iv.3mBk6"
+
 // Login godoc
 // @Summary Authenticate user
-// @Description Log in with username and password to get access token
+// @Description Log in with username and password to get access token. Ответ одинаковый для несуществующего email и неверного пароля. После 5 неудачных попыток подряд вход по email блокируется с удвоением времени (30 с, 1 мин, 2 мин... до 30 минут), после 20 — с одного адреса. Если у пользователя включена 2FA (или она обязательна для его роли), вместо токена возвращается challenge_token для второго шага /auth/login/2fa.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Login credentials"
 // @Success 200 {object} map[string]string "token"
+// @Success 202 {object} twofactor.Challenge
 // @Failure 400 {string} string "неправильный формат данных"
-// @Failure 401 {string} string "пользователь не найден"
-// @Failure 401 {string} string "неверный пароль"
+// @Failure 401 {string} string "неверный email или пароль"
+// @Failure 429 {string} string "слишком много неудачных попыток входа, попробуйте позже"
 // @Failure 500 {string} string "ошибка генерации токена"
 // @Router /auth/login [post]
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -165,15 +176,42 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
 		return
 	}
+	ip := auth.ClientIP(r)
+
+	wait, err := h.guard.Check(r.Context(), req.Email, ip)
+	if err != nil {
+		http.Error(w, "ошибка проверки попыток входа", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, lockout.ErrLocked.Error(), http.StatusTooManyRequests)
+		return
+	}
 
 	user, err := h.service.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
-		http.Error(w, "пользователь не найден", http.StatusUnauthorized)
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(req.Password))
+		h.loginFailed(w, r, 0, req.Email, ip, lockout.ReasonUnknownEmail)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		http.Error(w, "неверный пароль", http.StatusUnauthorized)
+		h.loginFailed(w, r, user.ID, req.Email, ip, lockout.ReasonWrongPassword)
+		return
+	}
+
+	challenge, err := h.twoFactor.Begin(r.Context(), user.ID, user.Role)
+	if err != nil {
+		http.Error(w, "ошибка проверки двухфакторной аутентификации", http.StatusInternalServerError)
+		return
+	}
+	if challenge != nil {
+		// Пароль верный, но счётчик неудач не сбрасывается до второго шага:
+		// иначе подбор кода можно было бы бесконечно продолжать, заново вводя пароль
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
 		return
 	}
 
@@ -183,7 +221,18 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "ошибка генерации токена", http.StatusInternalServerError)
 		return
 	}
+	if err := h.guard.Success(r.Context(), user.ID, req.Email, ip); err != nil {
+		log.Printf("ошибка записи входа пользователя ID=%d: %v", user.ID, err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"token": token})
 }
+
+// loginFailed учитывает неудачную попытку и отвечает одинаково, найден email или нет
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, userID int, email, ip string, reason lockout.Reason) {
+	if err := h.guard.Failure(r.Context(), userID, email, ip, reason); err != nil {
+		log.Printf("ошибка учёта неудачного входа: %v", err)
+	}
+	http.Error(w, "неверный email или пароль", http.StatusUnauthorized)
+}
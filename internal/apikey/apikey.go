@@ -0,0 +1,112 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// usageDays — за сколько последних дней отдаётся статистика запросов
+	usageDays = 30
+	// maxTTL — ключ без срока действия не выдаётся: не дольше года
+	maxTTL = 365 * 24 * time.Hour
+)
+
+// Разделы, к которым можно выдать доступ: совпадают с сегментом пути после /shops/{id}/, /owner/ и /admin/
+var (
+	ownerResources = []string{
+		// /shops/{id}/...
+		"payment-methods", "prices", "gift-cards", "customers", "tasks", "waitlist", "items", "availability",
+		"reservations", "schedule", "timeclock", "timesheet", "performance", "checklist-templates", "checklists",
+		"sales", "shifts",
+		// /owner/...
+		"shops", "promotions", "loyalty", "segments", "campaigns", "audit", "commissions",
+	}
+	adminResources = []string{"users", "shops", "login-attempts", "login-locks"}
+)
+
+var (
+	ErrAccessDenied = errors.New("доступ запрещён")
+	ErrKeyNotFound  = errors.New("API-ключ не найден")
+)
+
+// Key — API-ключ. Сам ключ показывается один раз при создании; хранится его хэш,
+// а по префиксу ключ можно узнать в списке и в настройках интеграции.
+type Key struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	ShopID       *int       `json:"shop_id,omitempty"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	RequestCount int64      `json:"request_count"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CreateRequest — новый ключ. scopes — "<раздел>:read" или "<раздел>:write", например "sales:read".
+// shop_id — ключ действует только на маршрутах /shops/{id} этого магазина.
+type CreateRequest struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	ShopID    *int      `json:"shop_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (r *CreateRequest) validate(role string, now time.Time) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("название ключа обязательно")
+	}
+	if utf8.RuneCountInString(r.Name) > 100 {
+		return errors.New("название ключа слишком длинное")
+	}
+	if len(r.Scopes) == 0 {
+		return errors.New("укажите хотя бы одну область доступа")
+	}
+	allowed := ownerResources
+	if role == "superadmin" {
+		allowed = adminResources
+	}
+	seen := map[string]bool{}
+	scopes := r.Scopes[:0]
+	for _, scope := range r.Scopes {
+		resource, action, ok := strings.Cut(scope, ":")
+		if !ok || (action != "read" && action != "write") || !slices.Contains(allowed, resource) {
+			return fmt.Errorf("неизвестная область доступа %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	r.Scopes = scopes
+	if r.ShopID != nil && role != "owner" {
+		return errors.New("привязать ключ к магазину может только владелец")
+	}
+	if !r.ExpiresAt.After(now) {
+		return errors.New("срок действия ключа должен быть в будущем")
+	}
+	if r.ExpiresAt.After(now.Add(maxTTL)) {
+		return errors.New("срок действия ключа — не больше года")
+	}
+	return nil
+}
+
+// CreatedKey — ответ на создание ключа; key больше не будет показан
+type CreatedKey struct {
+	Key
+	Secret string `json:"key"`
+}
+
+// Usage — число запросов с ключом за день
+type Usage struct {
+	Day      time.Time `json:"day"`
+	Requests int64     `json:"requests"`
+}
@@ -0,0 +1,226 @@
+package apikey
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrKeyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func superadmin(w http.ResponseWriter, r *http.Request) bool {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil || claims.Role != "superadmin" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func keyID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "key_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID ключа", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateKey godoc
+// @Summary Create API key
+// @Description Выдаёт API-ключ для интеграций (только owner и superadmin). Ключ передаётся как Authorization: Bearer crm_... и показывается один раз. scopes — "<раздел>:read" (GET) или "<раздел>:write" (остальные запросы), где раздел — сегмент пути после /shops/{id}/, /owner/ или /admin/, например sales:read. shop_id ограничивает ключ маршрутами одного магазина. Срок действия обязателен, не больше года.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body CreateRequest true "Название, области доступа, магазин и срок"
+// @Success 201 {object} CreatedKey
+// @Failure 400 {string} string "неизвестная область доступа"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /me/api-keys [post]
+func (h *Handler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.service.Create(r.Context(), claims.ID, claims.Role, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(key)
+}
+
+// GetMyKeys godoc
+// @Summary List my API keys
+// @Description API-ключи пользователя, включая отозванные и истёкшие: префикс, области доступа, время последнего запроса и число запросов.
+// @Tags api-keys
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Key
+// @Failure 401 {string} string "не авторизован"
+// @Router /me/api-keys [get]
+func (h *Handler) GetMyKeys(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.service.List(r.Context(), claims.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeMyKey godoc
+// @Summary Revoke my API key
+// @Description Отзывает API-ключ; запросы с ним сразу перестают приниматься.
+// @Tags api-keys
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param key_id path int true "ID ключа"
+// @Success 200 {object} map[string]string
+// @Failure 404 {string} string "API-ключ не найден"
+// @Router /me/api-keys/{key_id} [delete]
+func (h *Handler) RevokeMyKey(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), id, claims.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// GetMyKeyUsage godoc
+// @Summary Get my API key usage
+// @Description Число запросов с ключом по дням за последние 30 дней.
+// @Tags api-keys
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param key_id path int true "ID ключа"
+// @Success 200 {array} Usage
+// @Failure 404 {string} string "API-ключ не найден"
+// @Router /me/api-keys/{key_id}/usage [get]
+func (h *Handler) GetMyKeyUsage(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	usage, err := h.service.Usage(r.Context(), id, claims.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(usage)
+}
+
+// GetAllKeys godoc
+// @Summary List all API keys
+// @Description API-ключи всех пользователей или одного (user_id). Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param user_id query int false "ID пользователя"
+// @Success 200 {array} Key
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /admin/api-keys [get]
+func (h *Handler) GetAllKeys(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+	userID, _ := strconv.Atoi(r.URL.Query().Get("user_id"))
+
+	keys, err := h.service.List(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeKey godoc
+// @Summary Revoke any API key
+// @Description Отзывает API-ключ любого пользователя, например при утечке. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param key_id path int true "ID ключа"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "API-ключ не найден"
+// @Router /admin/api-keys/{key_id} [delete]
+func (h *Handler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), id, 0); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
@@ -0,0 +1,183 @@
+package apikey
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT REFERENCES shops(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			prefix VARCHAR(20) NOT NULL UNIQUE,
+			key_hash VARCHAR(64) NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			request_count BIGINT NOT NULL DEFAULT 0,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS api_keys_user ON api_keys (user_id);
+
+		CREATE TABLE IF NOT EXISTS api_key_usage (
+			key_id INT NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			requests BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (key_id, day)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции api_keys: %w", err)
+	}
+	fmt.Println("Миграция api_keys выполнена успешно")
+	return nil
+}
+
+const keyColumns = `id, user_id, shop_id, name, prefix, scopes, expires_at, last_used_at, request_count, revoked_at, created_at`
+
+func scanKey(row pgx.Row, k *Key) error {
+	return row.Scan(&k.ID, &k.UserID, &k.ShopID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt,
+		&k.LastUsedAt, &k.RequestCount, &k.RevokedAt, &k.CreatedAt)
+}
+
+func (r *Repository) Create(ctx context.Context, k *Key, keyHash string) error {
+	err := scanKey(r.db.Conn.QueryRow(ctx, `
+		INSERT INTO api_keys (user_id, shop_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+keyColumns,
+		k.UserID, k.ShopID, k.Name, k.Prefix, keyHash, k.Scopes, k.ExpiresAt), k)
+	if err != nil {
+		return fmt.Errorf("ошибка создания API-ключа: %w", err)
+	}
+	return nil
+}
+
+// List — ключи пользователя (userID = 0 — всех пользователей), новые сверху
+func (r *Repository) List(ctx context.Context, userID int) ([]Key, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+keyColumns+` FROM api_keys WHERE $1 = 0 OR user_id = $1 ORDER BY id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения API-ключей: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var k Key
+		if err := scanKey(rows, &k); err != nil {
+			return nil, fmt.Errorf("ошибка чтения API-ключа: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке API-ключей: %w", err)
+	}
+	return keys, nil
+}
+
+// Get — ключ пользователя (userID = 0 — любого)
+func (r *Repository) Get(ctx context.Context, keyID, userID int) (*Key, error) {
+	var k Key
+	err := scanKey(r.db.Conn.QueryRow(ctx, `
+		SELECT `+keyColumns+` FROM api_keys WHERE id = $1 AND ($2 = 0 OR user_id = $2)
+	`, keyID, userID), &k)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения API-ключа: %w", err)
+	}
+	return &k, nil
+}
+
+// Revoke отзывает ключ пользователя (userID = 0 — любого); повторный отзыв ничего не меняет
+func (r *Repository) Revoke(ctx context.Context, keyID, userID int) error {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 AND ($2 = 0 OR user_id = $2)
+	`, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва API-ключа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// FindActive — действующий ключ по хэшу вместе с email и ролью владельца
+func (r *Repository) FindActive(ctx context.Context, keyHash string, now time.Time) (*Key, string, string, error) {
+	var k Key
+	var email, role string
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT k.id, k.user_id, k.shop_id, k.scopes, u.email, u.role
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND k.expires_at > $2
+	`, keyHash, now).Scan(&k.ID, &k.UserID, &k.ShopID, &k.Scopes, &email, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", "", nil
+	}
+	if err != nil {
+		return nil, "", "", fmt.Errorf("ошибка проверки API-ключа: %w", err)
+	}
+	return &k, email, role, nil
+}
+
+// RecordUse обновляет время последнего запроса и счётчики ключа
+func (r *Repository) RecordUse(ctx context.Context, keyID int, at time.Time) error {
+	if _, err := r.db.Conn.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = $2, request_count = request_count + 1 WHERE id = $1
+	`, keyID, at); err != nil {
+		return fmt.Errorf("ошибка учёта запроса API-ключа: %w", err)
+	}
+	if _, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO api_key_usage (key_id, day, requests) VALUES ($1, $2::date, 1)
+		ON CONFLICT (key_id, day) DO UPDATE SET requests = api_key_usage.requests + 1
+	`, keyID, at); err != nil {
+		return fmt.Errorf("ошибка учёта запроса API-ключа: %w", err)
+	}
+	return nil
+}
+
+// Usage — число запросов с ключом по дням начиная с since
+func (r *Repository) Usage(ctx context.Context, keyID int, since time.Time) ([]Usage, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT day, requests FROM api_key_usage WHERE key_id = $1 AND day >= $2::date ORDER BY day
+	`, keyID, since)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики API-ключа: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []Usage{}
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(&u.Day, &u.Requests); err != nil {
+			return nil, fmt.Errorf("ошибка чтения статистики API-ключа: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке статистики API-ключа: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,110 @@
+package apikey
+
+import (
+	"context"
+	"crm-backend/internal/auth"
+	"crm-backend/internal/employee"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newKey — ключ вида crm_<префикс>_<секрет>; префикс хранится открыто, весь ключ — только хэшем
+func newKey() (string, string, error) {
+	b := make([]byte, 36)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("ошибка генерации API-ключа: %w", err)
+	}
+	prefix := auth.APIKeyPrefix + hex.EncodeToString(b[:4])
+	return prefix + "_" + hex.EncodeToString(b[4:]), prefix, nil
+}
+
+// Create выдаёт ключ владельцу или администратору. Владелец может привязать ключ к своему магазину.
+func (s *Service) Create(ctx context.Context, userID int, role string, req *CreateRequest) (*CreatedKey, error) {
+	if role != "owner" && role != "superadmin" {
+		return nil, ErrAccessDenied
+	}
+	if err := req.validate(role, time.Now()); err != nil {
+		return nil, err
+	}
+	if req.ShopID != nil {
+		ok, err := s.employees.IsOwner(ctx, *req.ShopID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrAccessDenied
+		}
+	}
+
+	secret, prefix, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	k := Key{UserID: userID, ShopID: req.ShopID, Name: req.Name, Prefix: prefix, Scopes: req.Scopes, ExpiresAt: req.ExpiresAt}
+	if err := s.repo.Create(ctx, &k, hash(secret)); err != nil {
+		return nil, err
+	}
+	log.Printf("пользователь ID=%d выпустил API-ключ %s", userID, prefix)
+	return &CreatedKey{Key: k, Secret: secret}, nil
+}
+
+func (s *Service) List(ctx context.Context, userID int) ([]Key, error) {
+	return s.repo.List(ctx, userID)
+}
+
+// Revoke отзывает ключ; userID = 0 — администратор отзывает чужой ключ
+func (s *Service) Revoke(ctx context.Context, keyID, userID int) error {
+	if err := s.repo.Revoke(ctx, keyID, userID); err != nil {
+		return err
+	}
+	log.Printf("API-ключ ID=%d отозван", keyID)
+	return nil
+}
+
+// Usage — запросы с ключом по дням за последние 30 дней
+func (s *Service) Usage(ctx context.Context, keyID, userID int) ([]Usage, error) {
+	if _, err := s.repo.Get(ctx, keyID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.Usage(ctx, keyID, time.Now().AddDate(0, 0, -usageDays+1))
+}
+
+// AuthenticateKey проверяет ключ из заголовка Authorization и учитывает запрос (auth.APIKeyStore).
+// Запрос получает права владельца ключа, ограниченные областями доступа и магазином ключа.
+func (s *Service) AuthenticateKey(ctx context.Context, key string) (*auth.Claims, error) {
+	now := time.Now()
+	k, email, role, err := s.repo.FindActive(ctx, hash(key), now)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if err := s.repo.RecordUse(ctx, k.ID, now); err != nil {
+		log.Printf("ошибка учёта запроса API-ключа ID=%d: %v", k.ID, err)
+	}
+
+	claims := &auth.Claims{ID: k.UserID, Email: email, Role: role, APIKeyID: k.ID, Scopes: k.Scopes}
+	if k.ShopID != nil {
+		claims.ShopID = *k.ShopID
+	}
+	return claims, nil
+}
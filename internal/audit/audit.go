@@ -0,0 +1,23 @@
+package audit
+
+import "time"
+
+// Entry — запись журнала действий: кто, что и над чем сделал.
+// OwnerID — организация, в журнале которой видна запись.
+type Entry struct {
+	ID         int            `json:"id"`
+	OwnerID    int            `json:"owner_id"`
+	ActorID    int            `json:"actor_id"`
+	Action     string         `json:"action"`
+	EntityType string         `json:"entity_type"`
+	EntityID   int            `json:"entity_id"`
+	Details    map[string]any `json:"details,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// Filter — отбор записей журнала; пустые поля не ограничивают выборку
+type Filter struct {
+	EntityType string
+	EntityID   int
+	Limit      int
+}
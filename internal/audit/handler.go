@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	repo *Repository
+}
+
+func NewHandler(repo *Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+// GetLog godoc
+// @Summary Get audit log
+// @Description Журнал действий организации (выгрузка и удаление данных покупателей и т.п.), новые сверху. Можно отобрать записи по объекту.
+// @Tags audit
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param entity_type query string false "Тип объекта, например customer"
+// @Param entity_id query int false "ID объекта"
+// @Param limit query int false "Сколько записей вернуть (до 100)"
+// @Success 200 {array} Entry
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/audit [get]
+func (h *Handler) GetLog(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	f := Filter{EntityType: q.Get("entity_type")}
+	f.EntityID, _ = strconv.Atoi(q.Get("entity_id"))
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	entries, err := h.repo.List(r.Context(), claims.ID, f)
+	if err != nil {
+		http.Error(w, "ошибка получения журнала действий", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+)
+
+const defaultLimit = 100
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+			action VARCHAR(100) NOT NULL,
+			entity_type VARCHAR(50) NOT NULL,
+			entity_id INT NOT NULL DEFAULT 0,
+			details JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (owner_id, entity_type, entity_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции audit_log: %w", err)
+	}
+	fmt.Println("Миграция audit_log выполнена успешно")
+	return nil
+}
+
+// Record добавляет запись в журнал. Журнал только дополняется — записи не меняются и не удаляются.
+func (r *Repository) Record(ctx context.Context, e *Entry) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO audit_log (owner_id, actor_id, action, entity_type, entity_id, details)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, e.OwnerID, e.ActorID, e.Action, e.EntityType, e.EntityID, e.Details).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал действий: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) List(ctx context.Context, ownerID int, f Filter) ([]Entry, error) {
+	if f.Limit <= 0 || f.Limit > defaultLimit {
+		f.Limit = defaultLimit
+	}
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, owner_id, COALESCE(actor_id, 0), action, entity_type, entity_id, details, created_at
+		FROM audit_log
+		WHERE owner_id = $1
+		  AND ($2 = '' OR entity_type = $2)
+		  AND ($3 = 0 OR entity_id = $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`, ownerID, f.EntityType, f.EntityID, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала действий: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.OwnerID, &e.ActorID, &e.Action, &e.EntityType, &e.EntityID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения записи журнала: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке журнала действий: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// APIKeyPrefix — с этого начинаются API-ключи; по нему middleware отличает ключ от JWT
+const APIKeyPrefix = "crm_"
+
+var (
+	ErrInvalidAPIKey = errors.New("недействительный API-ключ")
+	ErrAPIKeyScope   = errors.New("у API-ключа нет доступа к этому разделу")
+)
+
+// APIKeyStore проверяет API-ключ и возвращает права его владельца с областями доступа ключа
+type APIKeyStore interface {
+	AuthenticateKey(ctx context.Context, key string) (*Claims, error)
+}
+
+// apiKeys подключается при запуске через UseAPIKeys; без него API-ключи не принимаются
+var apiKeys APIKeyStore
+
+func UseAPIKeys(store APIKeyStore) {
+	apiKeys = store
+}
+
+// IsAPIKey — строка из заголовка Authorization похожа на API-ключ, а не на JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// APIScope — область доступа, нужная для запроса: раздел из пути и read (GET) или write.
+// API-ключи действуют только в разделах /shops/{id}/<раздел>, /owner/<раздел> и /admin/<раздел>;
+// area — shops, owner или admin, shopID — ID магазина для /shops/{id}.
+func APIScope(r *http.Request) (area, scope string, shopID int, ok bool) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var resource string
+	switch {
+	case len(parts) >= 3 && parts[0] == "shops":
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return "", "", 0, false
+		}
+		area, resource, shopID = parts[0], parts[2], id
+	case len(parts) >= 2 && (parts[0] == "owner" || parts[0] == "admin"):
+		area, resource = parts[0], parts[1]
+	default:
+		return "", "", 0, false
+	}
+	action := "write"
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		action = "read"
+	}
+	return area, resource + ":" + action, shopID, true
+}
+
+// checkAPIKey проверяет, что ключ даёт доступ к запрошенному разделу
+func checkAPIKey(r *http.Request, claims *Claims) error {
+	area, scope, shopID, ok := APIScope(r)
+	if !ok || !slices.Contains(claims.Scopes, scope) {
+		return ErrAPIKeyScope
+	}
+	if area == "admin" && claims.Role != "superadmin" {
+		return ErrAPIKeyScope
+	}
+	// Ключ, привязанный к магазину, действует только на маршрутах этого магазина
+	if claims.ShopID != 0 && (area != "shops" || shopID != claims.ShopID) {
+		return ErrAPIKeyScope
+	}
+	return nil
+}
+
+func authenticateAPIKey(r *http.Request, key string) (*Claims, error) {
+	if apiKeys == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	claims, err := apiKeys.AuthenticateKey(r.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAPIKey(r, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
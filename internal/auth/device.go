@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeviceRole — роль в токенах касс: вход по PIN даёт только права кассира
+const DeviceRole = "cashier"
+
+var (
+	ErrDeviceRevoked = errors.New("касса отключена, войдите заново")
+	ErrDeviceScope   = errors.New("вход на кассе не даёт доступа к этому разделу")
+)
+
+// DeviceStore проверяет, что кассовое устройство зарегистрировано в магазине и не отозвано
+type DeviceStore interface {
+	DeviceActive(ctx context.Context, deviceID, shopID int) (bool, error)
+}
+
+// devices подключается при запуске через UseDevices; без него токены касс отклоняются везде
+var devices DeviceStore
+
+func UseDevices(store DeviceStore) {
+	devices = store
+}
+
+// deviceRouteKey — имя параметра маршрута с ID магазина, на котором разрешены токены касс
+type deviceRouteKey struct{}
+
+// AllowDevice разрешает на маршрутах группы токены, выданные на кассе по PIN, — только для магазина
+// из параметра shopParam. Ставится перед AuthMiddleware; на остальных маршрутах такие токены не действуют.
+func AllowDevice(shopParam string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), deviceRouteKey{}, shopParam)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// checkDevice пропускает токен кассы только на разрешённом маршруте своего магазина и пока касса не отозвана
+func checkDevice(r *http.Request, claims *Claims) error {
+	shopParam, _ := r.Context().Value(deviceRouteKey{}).(string)
+	if shopParam == "" || chi.URLParam(r, shopParam) != strconv.Itoa(claims.ShopID) {
+		return ErrDeviceScope
+	}
+	if devices == nil {
+		return ErrDeviceRevoked
+	}
+	active, err := devices.DeviceActive(r.Context(), claims.DeviceID, claims.ShopID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrDeviceRevoked
+	}
+	return nil
+}
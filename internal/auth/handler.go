@@ -73,6 +73,11 @@ func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
 		"email": claims.Email,
 		"role":  claims.Role,
 	}
+	// При входе от имени пользователя клиент показывает, кто на самом деле работает в системе
+	if claims.IsImpersonated() {
+		userData["actor_id"] = claims.ActorID
+		userData["impersonation_id"] = claims.ImpersonationID
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userData)
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+var (
+	ErrImpersonationEnded  = errors.New("сеанс входа от имени пользователя завершён")
+	ErrImpersonationDenied = errors.New("действие недоступно при входе от имени пользователя")
+)
+
+// ImpersonationStore проверяет сеанс входа superadmin от имени пользователя и записывает каждый запрос в нём
+type ImpersonationStore interface {
+	ImpersonationActive(ctx context.Context, impersonationID, actorID int) (bool, error)
+	LogRequest(ctx context.Context, impersonationID int, method, path string, status int) error
+}
+
+// impersonations подключается при запуске через UseImpersonations; без него такие токены отклоняются везде
+var impersonations ImpersonationStore
+
+func UseImpersonations(store ImpersonationStore) {
+	impersonations = store
+}
+
+// IsImpersonated — запрос сделан superadmin от имени другого пользователя
+func (c *Claims) IsImpersonated() bool {
+	return c.ActorID != 0
+}
+
+// DenyImpersonation закрывает маршрут для входа от имени пользователя: смена пароля, email,
+// 2FA и выпуск ключей остаются только самому пользователю. Ставится после AuthMiddleware.
+func DenyImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, _ := GetUserFromContext(r.Context()); claims != nil && claims.IsImpersonated() {
+			http.Error(w, ErrImpersonationDenied.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func checkImpersonation(ctx context.Context, claims *Claims) error {
+	if impersonations == nil {
+		return ErrImpersonationEnded
+	}
+	active, err := impersonations.ImpersonationActive(ctx, claims.ImpersonationID, claims.ActorID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrImpersonationEnded
+	}
+	return nil
+}
+
+// statusRecorder запоминает код ответа, чтобы записать его в журнал сеанса
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// serveImpersonated выполняет запрос и записывает его в журнал сеанса вместе с кодом ответа
+func serveImpersonated(next http.Handler, w http.ResponseWriter, r *http.Request, claims *Claims) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, r)
+	if err := impersonations.LogRequest(context.WithoutCancel(r.Context()), claims.ImpersonationID, r.Method, r.URL.Path, rec.status); err != nil {
+		log.Printf("ошибка записи запроса сеанса ID=%d: %v", claims.ImpersonationID, err)
+	}
+}
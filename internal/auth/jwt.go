@@ -16,6 +16,17 @@ type Claims struct {
 	ID    int    `json:"id"`
 	Email string `json:"email"`
 	Role  string `json:"role"`
+	// ShopID и DeviceID заполнены в токенах, выданных на кассе по PIN:
+	// такой токен действует только на кассовых маршрутах этого магазина
+	ShopID   int `json:"shop_id,omitempty"`
+	DeviceID int `json:"device_id,omitempty"`
+	// APIKeyID и Scopes заполнены, если запрос пришёл с API-ключом, а не с JWT
+	APIKeyID int      `json:"api_key_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	// ActorID и ImpersonationID заполнены, когда superadmin вошёл от имени пользователя:
+	// ID, Email и Role — того, чьими глазами он смотрит, ActorID — кто на самом деле делает запрос
+	ActorID         int `json:"actor_id,omitempty"`
+	ImpersonationID int `json:"impersonation_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -27,9 +38,49 @@ func GenerateJWT(id int, email, role string) (string, error) {
 		Role:  role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 часа
+			// по времени выдачи middleware отсекает токены, выданные до отзыва сессий
+			IssuedAt: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
+	return sign(claims)
+}
+
+// GenerateDeviceJWT — токен сотрудника, вошедшего по PIN на кассе deviceID магазина shopID.
+// Роль в токене всегда DeviceRole, какой бы ни была роль пользователя.
+func GenerateDeviceJWT(id int, email string, shopID, deviceID int, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		ID:       id,
+		Email:    email,
+		Role:     DeviceRole,
+		ShopID:   shopID,
+		DeviceID: deviceID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return sign(claims)
+}
+
+// GenerateImpersonationJWT — токен superadmin actorID для входа от имени пользователя id в рамках сеанса impersonationID
+func GenerateImpersonationJWT(actorID, impersonationID, id int, email, role string, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		ID:              id,
+		Email:           email,
+		Role:            role,
+		ActorID:         actorID,
+		ImpersonationID: impersonationID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return sign(claims)
+}
+
+func sign(claims Claims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, err := token.SignedString(SecretKey)
 	if err != nil {
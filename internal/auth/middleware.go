@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -17,12 +19,67 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if IsAPIKey(tokenString) {
+			claims, err := authenticateAPIKey(r, tokenString)
+			if err != nil {
+				switch {
+				case errors.Is(err, ErrInvalidAPIKey):
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+				case errors.Is(err, ErrAPIKeyScope):
+					http.Error(w, err.Error(), http.StatusForbidden)
+				default:
+					http.Error(w, "ошибка проверки API-ключа", http.StatusInternalServerError)
+				}
+				return
+			}
+			ctx := context.WithValue(r.Context(), UserContextKey{}, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		claims, err := ParseJWT(tokenString)
 		if err != nil {
 			http.Error(w, "недействительный токен", http.StatusUnauthorized)
 			return
 		}
 
+		if err := checkSession(r.Context(), claims); err != nil {
+			if errors.Is(err, ErrSessionRevoked) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "ошибка проверки сессии", http.StatusInternalServerError)
+			return
+		}
+
+		if claims.IsImpersonated() {
+			if err := checkImpersonation(r.Context(), claims); err != nil {
+				if errors.Is(err, ErrImpersonationEnded) {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				http.Error(w, "ошибка проверки сеанса входа от имени пользователя", http.StatusInternalServerError)
+				return
+			}
+			ctx := context.WithValue(r.Context(), UserContextKey{}, claims)
+			serveImpersonated(next, w, r.WithContext(ctx), claims)
+			return
+		}
+
+		if claims.DeviceID != 0 {
+			if err := checkDevice(r, claims); err != nil {
+				switch {
+				case errors.Is(err, ErrDeviceRevoked):
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+				case errors.Is(err, ErrDeviceScope):
+					http.Error(w, err.Error(), http.StatusForbidden)
+				default:
+					http.Error(w, "ошибка проверки кассы", http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), UserContextKey{}, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -32,3 +89,13 @@ func GetUserFromContext(ctx context.Context) (*Claims, bool) {
 	claims, ok := ctx.Value(UserContextKey{}).(*Claims)
 	return claims, ok
 }
+
+// ClientIP — адрес клиента для ограничения частоты запросов.
+// Берётся из соединения: за обратным прокси нужно подключить middleware.RealIP.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt учитывает только первые 72 байта пароля
+	maxPasswordBytes = 72
+)
+
+// ValidatePassword проверяет требования к паролю: не короче 8 символов, буквы и цифры,
+// не совпадает с email
+func ValidatePassword(password, email string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return errors.New("пароль должен быть не короче 8 символов")
+	}
+	if len(password) > maxPasswordBytes {
+		return errors.New("пароль слишком длинный")
+	}
+	var letter, digit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			letter = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsSpace(c) || unicode.IsControl(c):
+			return errors.New("пароль не должен содержать пробелы")
+		}
+	}
+	if !letter || !digit {
+		return errors.New("пароль должен содержать буквы и цифры")
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		return errors.New("пароль не должен совпадать с email")
+	}
+	return nil
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
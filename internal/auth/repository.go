@@ -3,7 +3,11 @@ package auth
 import (
 	"context"
 	"crm-backend/internal/db"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 type User struct {
@@ -52,3 +56,27 @@ func (r *Repository) FindByID(ctx context.Context, userID int) (*User, error) {
 	}
 	return &u, nil
 }
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMP WITH TIME ZONE;
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции sessions: %w", err)
+	}
+	fmt.Println("Миграция sessions выполнена успешно")
+	return nil
+}
+
+// SessionsRevokedAt — когда пользователь в последний раз завершил все сессии; nil — не завершал
+func (r *Repository) SessionsRevokedAt(ctx context.Context, userID int) (*time.Time, error) {
+	var revokedAt *time.Time
+	err := r.db.Conn.QueryRow(ctx, `SELECT sessions_revoked_at FROM users WHERE id = $1`, userID).Scan(&revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки сессии: %w", err)
+	}
+	return revokedAt, nil
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrSessionRevoked = errors.New("сессия завершена, войдите заново")
+
+// sessions хранит время отзыва сессий пользователей; подключается при запуске через UseSessions.
+// Без него middleware проверяет только подпись и срок токена.
+var sessions *Repository
+
+func UseSessions(r *Repository) {
+	sessions = r
+}
+
+// checkSession отклоняет токены, выданные раньше, чем пользователь завершил все сессии (например, сменил пароль)
+func checkSession(ctx context.Context, claims *Claims) error {
+	if sessions == nil {
+		return nil
+	}
+	revokedAt, err := sessions.SessionsRevokedAt(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	if revokedAt == nil {
+		return nil
+	}
+	if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(*revokedAt) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// RevokeTime — момент отзыва сессий. Время выдачи в JWT хранится с точностью до секунды,
+// поэтому отзыв округляется вниз: токен, выданный сразу после отзыва, остаётся действительным.
+func RevokeTime(now time.Time) time.Time {
+	return now.Truncate(time.Second)
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret — ключ из тестовых векторов RFC 6238 ("12345678901234567890") в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFCVectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Последние 6 цифр 8-значных кодов SHA1 из приложения B RFC 6238
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/30); got != tt.want {
+			t.Errorf("T=%d: код %s, ожидался %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / 30
+	code := func(step int64) string { return totpCode(key, step) }
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		step     int64
+		ok       bool
+	}{
+		{name: "текущий интервал", secret: rfcSecret, code: code(current), step: current, ok: true},
+		{name: "предыдущий интервал — отстающие часы", secret: rfcSecret, code: code(current - 1), step: current - 1, ok: true},
+		{name: "следующий интервал — спешащие часы", secret: rfcSecret, code: code(current + 1), step: current + 1, ok: true},
+		{name: "два интервала назад", secret: rfcSecret, code: code(current - 2)},
+		{name: "два интервала вперёд", secret: rfcSecret, code: code(current + 2)},
+		{name: "повтор уже принятого кода", secret: rfcSecret, code: code(current), lastStep: current},
+		{name: "код старше последнего принятого", secret: rfcSecret, code: code(current - 1), lastStep: current},
+		{name: "следующий код после принятого", secret: rfcSecret, code: code(current + 1), lastStep: current, step: current + 1, ok: true},
+		{name: "пробелы в коде", secret: rfcSecret, code: " 050 471 ", step: current, ok: true},
+		{name: "секрет в нижнем регистре", secret: strings.ToLower(rfcSecret), code: "050471", step: current, ok: true},
+		{name: "неверный код", secret: rfcSecret, code: "000000"},
+		{name: "короткий код", secret: rfcSecret, code: "05047"},
+		{name: "длинный код", secret: rfcSecret, code: "0504711"},
+		{name: "испорченный секрет", secret: "не base32", code: "050471"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(tt.secret, tt.code, now, tt.lastStep)
+			if ok != tt.ok || step != tt.step {
+				t.Errorf("VerifyTOTP = (%d, %v), ожидалось (%d, %v)", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecretRoundTrip(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("секрет %q не декодируется в 20 байт: %v", secret, err)
+	}
+	now := time.Now()
+	if _, ok := VerifyTOTP(secret, totpCode(key, now.Unix()/30), now, 0); !ok {
+		t.Error("код нового секрета не принят")
+	}
+}
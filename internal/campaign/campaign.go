@@ -0,0 +1,120 @@
+package campaign
+
+import (
+	"crm-backend/internal/notify"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusSending   = "sending"
+	StatusSent      = "sent"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped"
+)
+
+const (
+	defaultRatePerMinute = 60
+	maxRatePerMinute     = 1000
+)
+
+var (
+	ErrCampaignNotFound = errors.New("кампания не найдена")
+	ErrNotEditable      = errors.New("кампанию можно менять только в статусе draft")
+	ErrLinkNotFound     = errors.New("ссылка не найдена")
+)
+
+// Campaign — рассылка по сегменту покупателей.
+// В тексте можно использовать подстановки {{name}}, {{balance}} (баллы лояльности),
+// {{link}} (ссылка LinkURL с учётом переходов) и {{unsubscribe}} (ссылка для отписки).
+type Campaign struct {
+	ID            int            `json:"id"`
+	OwnerID       int            `json:"owner_id"`
+	Name          string         `json:"name"`
+	SegmentID     int            `json:"segment_id"`
+	Channel       notify.Channel `json:"channel"`
+	Subject       string         `json:"subject,omitempty"`
+	Body          string         `json:"body"`
+	LinkURL       string         `json:"link_url,omitempty"`
+	RatePerMinute int            `json:"rate_per_minute"`
+	Status        string         `json:"status"`
+	ScheduledAt   *time.Time     `json:"scheduled_at,omitempty"`
+	StartedAt     *time.Time     `json:"started_at,omitempty"`
+	FinishedAt    *time.Time     `json:"finished_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Stats         *Stats         `json:"stats,omitempty"`
+}
+
+func (c *Campaign) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	switch {
+	case c.Name == "":
+		return errors.New("название кампании обязательно")
+	case c.SegmentID == 0:
+		return errors.New("укажите сегмент")
+	case !c.Channel.Valid():
+		return errors.New("неизвестный канал: " + string(c.Channel))
+	case strings.TrimSpace(c.Body) == "":
+		return errors.New("текст сообщения обязателен")
+	case strings.Contains(c.Body, "{{link}}") && c.LinkURL == "":
+		return errors.New("в тексте есть {{link}}, но не указан link_url")
+	}
+	if c.RatePerMinute <= 0 {
+		c.RatePerMinute = defaultRatePerMinute
+	}
+	if c.RatePerMinute > maxRatePerMinute {
+		c.RatePerMinute = maxRatePerMinute
+	}
+	return nil
+}
+
+// Stats — статистика доставки и переходов
+type Stats struct {
+	Total        int     `json:"total"`
+	Pending      int     `json:"pending"`
+	Sent         int     `json:"sent"`
+	Failed       int     `json:"failed"`
+	Skipped      int     `json:"skipped"`
+	Clicked      int     `json:"clicked"`
+	Clicks       int     `json:"clicks"`
+	Unsubscribed int     `json:"unsubscribed"`
+	ClickRate    float64 `json:"click_rate"`
+}
+
+// Delivery — сообщение одному покупателю
+type Delivery struct {
+	ID             int        `json:"id"`
+	CampaignID     int        `json:"campaign_id"`
+	CustomerID     int        `json:"customer_id"`
+	Address        string     `json:"address"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	Token          string     `json:"-"`
+	ExternalID     string     `json:"external_id,omitempty"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	ClickedAt      *time.Time `json:"clicked_at,omitempty"`
+	UnsubscribedAt *time.Time `json:"unsubscribed_at,omitempty"`
+}
+
+type ScheduleRequest struct {
+	// ScheduledAt — время отправки; если не указано, кампания уходит сразу
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// render подставляет значения в шаблон
+func render(tmpl string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
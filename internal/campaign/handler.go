@@ -0,0 +1,293 @@
+package campaign
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/segment"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrCampaignNotFound), errors.Is(err, segment.ErrSegmentNotFound), errors.Is(err, ErrLinkNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNotEditable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ownerClaims — рассылки доступны только владельцу
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+func campaignID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "campaign_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID кампании", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// CreateCampaign godoc
+// @Summary Create marketing campaign
+// @Description Создаёт черновик рассылки по сегменту. Канал: sms, email или telegram. В тексте доступны подстановки {{name}}, {{balance}}, {{link}} и {{unsubscribe}}. rate_per_minute ограничивает скорость отправки (по умолчанию 60).
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param campaign body Campaign true "Кампания"
+// @Success 201 {object} Campaign
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "сегмент не найден"
+// @Router /owner/campaigns [post]
+func (h *Handler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var c Campaign
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateCampaign(r.Context(), claims.ID, &c); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// GetCampaigns godoc
+// @Summary List marketing campaigns
+// @Description Все рассылки владельца, новые сверху.
+// @Tags campaigns
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Campaign
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/campaigns [get]
+func (h *Handler) GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	campaigns, err := h.service.GetCampaigns(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения кампаний", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, campaigns)
+}
+
+// GetCampaign godoc
+// @Summary Get marketing campaign with statistics
+// @Description Кампания со статистикой: сколько сообщений отправлено, не доставлено, пропущено (нет согласия или адреса), сколько переходов и отписок.
+// @Tags campaigns
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param campaign_id path int true "Campaign ID"
+// @Success 200 {object} Campaign
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "кампания не найдена"
+// @Router /owner/campaigns/{campaign_id} [get]
+func (h *Handler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.service.GetCampaign(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// UpdateCampaign godoc
+// @Summary Update marketing campaign
+// @Description Меняет черновик рассылки. Запланированные и отправленные кампании менять нельзя.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param campaign_id path int true "Campaign ID"
+// @Param campaign body Campaign true "Кампания"
+// @Success 200 {object} Campaign
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 404 {string} string "кампания не найдена"
+// @Failure 409 {string} string "кампанию можно менять только в статусе draft"
+// @Router /owner/campaigns/{campaign_id} [put]
+func (h *Handler) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	var c Campaign
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	c.ID = id
+
+	if err := h.service.UpdateCampaign(r.Context(), claims.ID, &c); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// ScheduleCampaign godoc
+// @Summary Schedule marketing campaign
+// @Description Ставит черновик в очередь на отправку. Без scheduled_at рассылка начинается сразу. Получатели фиксируются в момент старта по актуальному составу сегмента.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param campaign_id path int true "Campaign ID"
+// @Param schedule body ScheduleRequest false "Время отправки"
+// @Success 200 {object} Campaign
+// @Failure 404 {string} string "кампания не найдена"
+// @Failure 409 {string} string "кампанию можно менять только в статусе draft"
+// @Router /owner/campaigns/{campaign_id}/schedule [post]
+func (h *Handler) ScheduleCampaign(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ScheduleRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+			return
+		}
+	}
+
+	c, err := h.service.Schedule(r.Context(), claims.ID, id, req.ScheduledAt)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// CancelCampaign godoc
+// @Summary Cancel marketing campaign
+// @Description Останавливает запланированную или идущую рассылку. Уже отправленные сообщения остаются в статистике, остальные помечаются пропущенными.
+// @Tags campaigns
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param campaign_id path int true "Campaign ID"
+// @Success 200 {object} Campaign
+// @Failure 400 {string} string "кампания уже завершена"
+// @Failure 404 {string} string "кампания не найдена"
+// @Router /owner/campaigns/{campaign_id}/cancel [post]
+func (h *Handler) CancelCampaign(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := campaignID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.service.Cancel(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Click godoc
+// @Summary Follow campaign link
+// @Description Публичная ссылка из сообщения: учитывает переход и перенаправляет на link_url кампании.
+// @Tags campaigns
+// @Param token path string true "Токен сообщения"
+// @Success 302
+// @Failure 404 {string} string "ссылка не найдена"
+// @Router /c/{token} [get]
+func (h *Handler) Click(w http.ResponseWriter, r *http.Request) {
+	target, err := h.service.Click(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// Unsubscribe godoc
+// @Summary Unsubscribe from campaigns
+// @Description Публичная ссылка отписки из сообщения: снимает согласие покупателя на рассылки.
+// @Tags campaigns
+// @Produce plain
+// @Param token path string true "Токен сообщения"
+// @Success 200 {string} string "вы отписались от рассылки"
+// @Failure 404 {string} string "ссылка не найдена"
+// @Router /c/{token}/unsubscribe [get]
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Unsubscribe(r.Context(), chi.URLParam(r, "token")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("вы отписались от рассылки"))
+}
@@ -0,0 +1,358 @@
+package campaign
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS campaigns (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			segment_id INT NOT NULL REFERENCES segments(id) ON DELETE RESTRICT,
+			channel VARCHAR(20) NOT NULL,
+			subject VARCHAR(255),
+			body TEXT NOT NULL,
+			link_url TEXT,
+			rate_per_minute INT NOT NULL DEFAULT 60,
+			status VARCHAR(20) NOT NULL DEFAULT 'draft',
+			scheduled_at TIMESTAMP WITH TIME ZONE,
+			started_at TIMESTAMP WITH TIME ZONE,
+			finished_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS campaign_deliveries (
+			id SERIAL PRIMARY KEY,
+			campaign_id INT NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			address VARCHAR(255) NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			error TEXT,
+			token VARCHAR(64) NOT NULL UNIQUE,
+			external_id VARCHAR(100),
+			sent_at TIMESTAMP WITH TIME ZONE,
+			clicks INT NOT NULL DEFAULT 0,
+			clicked_at TIMESTAMP WITH TIME ZONE,
+			unsubscribed_at TIMESTAMP WITH TIME ZONE,
+			UNIQUE (campaign_id, customer_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS campaign_deliveries_pending ON campaign_deliveries (campaign_id) WHERE status = 'pending';
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции campaigns: %w", err)
+	}
+	fmt.Println("Миграция campaigns выполнена успешно")
+	return nil
+}
+
+const campaignColumns = `
+	id, owner_id, name, segment_id, channel, COALESCE(subject, ''), body, COALESCE(link_url, ''),
+	rate_per_minute, status, scheduled_at, started_at, finished_at, created_at
+`
+
+func scanCampaigns(rows pgx.Rows) ([]Campaign, error) {
+	defer rows.Close()
+
+	var campaigns []Campaign
+	for rows.Next() {
+		var c Campaign
+		if err := rows.Scan(&c.ID, &c.OwnerID, &c.Name, &c.SegmentID, &c.Channel, &c.Subject, &c.Body, &c.LinkURL,
+			&c.RatePerMinute, &c.Status, &c.ScheduledAt, &c.StartedAt, &c.FinishedAt, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения кампании: %w", err)
+		}
+		campaigns = append(campaigns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке кампаний: %w", err)
+	}
+	return campaigns, nil
+}
+
+func (r *Repository) CreateCampaign(ctx context.Context, c *Campaign) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO campaigns (owner_id, name, segment_id, channel, subject, body, link_url, rate_per_minute)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), $8)
+		RETURNING id, status, created_at
+	`, c.OwnerID, c.Name, c.SegmentID, c.Channel, c.Subject, c.Body, c.LinkURL, c.RatePerMinute).Scan(&c.ID, &c.Status, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания кампании: %w", err)
+	}
+	return nil
+}
+
+// UpdateCampaign меняет черновик; отправленные и запланированные кампании не трогаем
+func (r *Repository) UpdateCampaign(ctx context.Context, c Campaign) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaigns
+		SET name = $1, segment_id = $2, channel = $3, subject = NULLIF($4, ''), body = $5,
+		    link_url = NULLIF($6, ''), rate_per_minute = $7
+		WHERE id = $8 AND owner_id = $9 AND status = $10
+	`, c.Name, c.SegmentID, c.Channel, c.Subject, c.Body, c.LinkURL, c.RatePerMinute, c.ID, c.OwnerID, StatusDraft)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления кампании (ID=%d): %w", c.ID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrNotEditable
+	}
+	return nil
+}
+
+func (r *Repository) GetCampaign(ctx context.Context, ownerID, id int) (*Campaign, error) {
+	rows, err := r.db.Conn.Query(ctx, `SELECT `+campaignColumns+` FROM campaigns WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения кампании: %w", err)
+	}
+	campaigns, err := scanCampaigns(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(campaigns) == 0 {
+		return nil, ErrCampaignNotFound
+	}
+	return &campaigns[0], nil
+}
+
+func (r *Repository) GetCampaignsByOwner(ctx context.Context, ownerID int) ([]Campaign, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+campaignColumns+` FROM campaigns WHERE owner_id = $1 ORDER BY created_at DESC
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения кампаний: %w", err)
+	}
+	return scanCampaigns(rows)
+}
+
+// GetDueCampaigns — кампании, которым пора начинать или продолжать отправку
+func (r *Repository) GetDueCampaigns(ctx context.Context, now time.Time) ([]Campaign, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+campaignColumns+`
+		FROM campaigns
+		WHERE status IN ($1, $2) AND scheduled_at <= $3
+		ORDER BY scheduled_at, id
+	`, StatusScheduled, StatusSending, now)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения кампаний к отправке: %w", err)
+	}
+	return scanCampaigns(rows)
+}
+
+// SetStatus переводит кампанию из одного статуса в другой; false — если кампания уже в другом статусе
+func (r *Repository) SetStatus(ctx context.Context, id int, from []string, to string, scheduledAt *time.Time) (bool, error) {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaigns
+		SET status = $1,
+		    scheduled_at = COALESCE($2, scheduled_at),
+		    started_at = CASE WHEN $1 = 'sending' THEN NOW() ELSE started_at END,
+		    finished_at = CASE WHEN $1 IN ('sent', 'cancelled') THEN NOW() ELSE finished_at END
+		WHERE id = $3 AND status = ANY($4)
+	`, to, scheduledAt, id, from)
+	if err != nil {
+		return false, fmt.Errorf("ошибка смены статуса кампании (ID=%d): %w", id, err)
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+func (r *Repository) CreateDeliveries(ctx context.Context, deliveries []Delivery) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, d := range deliveries {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO campaign_deliveries (campaign_id, customer_id, address, status, error, token)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			ON CONFLICT (campaign_id, customer_id) DO NOTHING
+		`, d.CampaignID, d.CustomerID, d.Address, d.Status, d.Error, d.Token)
+		if err != nil {
+			return fmt.Errorf("ошибка создания сообщения кампании: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка подготовки рассылки: %w", err)
+	}
+	return nil
+}
+
+const deliveryColumns = `
+	id, campaign_id, customer_id, address, status, COALESCE(error, ''), token, COALESCE(external_id, ''),
+	sent_at, clicked_at, unsubscribed_at
+`
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*Delivery, error) {
+	var d Delivery
+	err := row.Scan(&d.ID, &d.CampaignID, &d.CustomerID, &d.Address, &d.Status, &d.Error, &d.Token, &d.ExternalID,
+		&d.SentAt, &d.ClickedAt, &d.UnsubscribedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *Repository) GetPendingDeliveries(ctx context.Context, campaignID, limit int) ([]Delivery, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM campaign_deliveries
+		WHERE campaign_id = $1 AND status = $2
+		ORDER BY id
+		LIMIT $3
+	`, campaignID, DeliveryPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сообщений к отправке: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения сообщения кампании: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке сообщений кампании: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *Repository) MarkDelivery(ctx context.Context, id int, status, externalID, errText string) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaign_deliveries
+		SET status = $1, external_id = NULLIF($2, ''), error = NULLIF($3, ''),
+		    sent_at = CASE WHEN $1 = 'sent' THEN NOW() ELSE sent_at END
+		WHERE id = $4
+	`, status, externalID, errText, id)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления сообщения кампании (ID=%d): %w", id, err)
+	}
+	return nil
+}
+
+// SkipPending помечает неотправленные сообщения отменённой кампании
+func (r *Repository) SkipPending(ctx context.Context, campaignID int, reason string) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaign_deliveries SET status = $1, error = $2 WHERE campaign_id = $3 AND status = $4
+	`, DeliverySkipped, reason, campaignID, DeliveryPending)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены сообщений кампании: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetDeliveryByToken(ctx context.Context, token string) (*Delivery, error) {
+	d, err := scanDelivery(r.db.Conn.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM campaign_deliveries WHERE token = $1`, token))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сообщения кампании: %w", err)
+	}
+	return d, nil
+}
+
+// RegisterClick — переход по ссылке; clicked_at хранит первый переход
+func (r *Repository) RegisterClick(ctx context.Context, id int) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaign_deliveries SET clicks = clicks + 1, clicked_at = COALESCE(clicked_at, NOW()) WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка учёта перехода: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) MarkUnsubscribed(ctx context.Context, id int) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaign_deliveries SET unsubscribed_at = COALESCE(unsubscribed_at, NOW()) WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка учёта отписки: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetStats(ctx context.Context, campaignID int) (*Stats, error) {
+	var s Stats
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'pending'),
+		       COUNT(*) FILTER (WHERE status = 'sent'),
+		       COUNT(*) FILTER (WHERE status = 'failed'),
+		       COUNT(*) FILTER (WHERE status = 'skipped'),
+		       COUNT(*) FILTER (WHERE clicked_at IS NOT NULL),
+		       COALESCE(SUM(clicks), 0),
+		       COUNT(*) FILTER (WHERE unsubscribed_at IS NOT NULL)
+		FROM campaign_deliveries
+		WHERE campaign_id = $1
+	`, campaignID).Scan(&s.Total, &s.Pending, &s.Sent, &s.Failed, &s.Skipped, &s.Clicked, &s.Clicks, &s.Unsubscribed)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики кампании: %w", err)
+	}
+	if s.Sent > 0 {
+		s.ClickRate = float64(s.Clicked) / float64(s.Sent)
+	}
+	return &s, nil
+}
+
+func (r *Repository) GetLinkURL(ctx context.Context, campaignID int) (string, error) {
+	var linkURL string
+	err := r.db.Conn.QueryRow(ctx, `SELECT COALESCE(link_url, '') FROM campaigns WHERE id = $1`, campaignID).Scan(&linkURL)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения ссылки кампании: %w", err)
+	}
+	return linkURL, nil
+}
+
+func (r *Repository) GetDeliveriesByCustomer(ctx context.Context, customerID int) ([]Delivery, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+deliveryColumns+` FROM campaign_deliveries WHERE customer_id = $1 ORDER BY id DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сообщений покупателя: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения сообщения кампании: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке сообщений кампании: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ForgetCustomer стирает адреса покупателя в журнале рассылок; неотправленные сообщения отменяются
+func (r *Repository) ForgetCustomer(ctx context.Context, customerID int) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE campaign_deliveries
+		SET address = '',
+		    status = CASE WHEN status = 'pending' THEN 'skipped' ELSE status END,
+		    error = CASE WHEN status = 'pending' THEN 'данные покупателя удалены' ELSE error END
+		WHERE customer_id = $1
+	`, customerID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления адресов покупателя из рассылок: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package campaign
+
+import (
+	"context"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/loyalty"
+	"crm-backend/internal/notify"
+	"crm-backend/internal/segment"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Service struct {
+	repo      *Repository
+	segments  *segment.Service
+	customers *customer.Repository
+	loyalty   *loyalty.Repository
+	sender    *notify.Dispatcher
+	publicURL string
+}
+
+func NewService(repo *Repository, segments *segment.Service, customers *customer.Repository, loyaltyRepo *loyalty.Repository, sender *notify.Dispatcher, publicURL string) *Service {
+	return &Service{
+		repo:      repo,
+		segments:  segments,
+		customers: customers,
+		loyalty:   loyaltyRepo,
+		sender:    sender,
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}
+}
+
+func (s *Service) CreateCampaign(ctx context.Context, ownerID int, c *Campaign) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.segments.GetSegment(ctx, ownerID, c.SegmentID); err != nil {
+		return err
+	}
+	c.OwnerID = ownerID
+	return s.repo.CreateCampaign(ctx, c)
+}
+
+func (s *Service) UpdateCampaign(ctx context.Context, ownerID int, c *Campaign) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.repo.GetCampaign(ctx, ownerID, c.ID); err != nil {
+		return err
+	}
+	if _, err := s.segments.GetSegment(ctx, ownerID, c.SegmentID); err != nil {
+		return err
+	}
+	c.OwnerID = ownerID
+	if err := s.repo.UpdateCampaign(ctx, *c); err != nil {
+		return err
+	}
+	updated, err := s.repo.GetCampaign(ctx, ownerID, c.ID)
+	if err != nil {
+		return err
+	}
+	*c = *updated
+	return nil
+}
+
+func (s *Service) GetCampaigns(ctx context.Context, ownerID int) ([]Campaign, error) {
+	return s.repo.GetCampaignsByOwner(ctx, ownerID)
+}
+
+// GetCampaign — кампания со статистикой доставки и переходов
+func (s *Service) GetCampaign(ctx context.Context, ownerID, id int) (*Campaign, error) {
+	c, err := s.repo.GetCampaign(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	c.Stats, err = s.repo.GetStats(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Schedule ставит черновик в очередь; без времени — отправка начнётся на ближайшем проходе рассыльщика
+func (s *Service) Schedule(ctx context.Context, ownerID, id int, at *time.Time) (*Campaign, error) {
+	if _, err := s.repo.GetCampaign(ctx, ownerID, id); err != nil {
+		return nil, err
+	}
+	when := time.Now()
+	if at != nil {
+		when = *at
+	}
+	ok, err := s.repo.SetStatus(ctx, id, []string{StatusDraft}, StatusScheduled, &when)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotEditable
+	}
+	return s.GetCampaign(ctx, ownerID, id)
+}
+
+// Cancel останавливает запланированную или идущую рассылку; неотправленные сообщения помечаются пропущенными
+func (s *Service) Cancel(ctx context.Context, ownerID, id int) (*Campaign, error) {
+	if _, err := s.repo.GetCampaign(ctx, ownerID, id); err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.SetStatus(ctx, id, []string{StatusDraft, StatusScheduled, StatusSending}, StatusCancelled, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("кампания уже завершена")
+	}
+	if err := s.repo.SkipPending(ctx, id, "кампания отменена"); err != nil {
+		return nil, err
+	}
+	return s.GetCampaign(ctx, ownerID, id)
+}
+
+// address — куда отправлять сообщение покупателю по выбранному каналу
+func address(c customer.Customer, channel notify.Channel) string {
+	switch channel {
+	case notify.ChannelSMS:
+		return c.Phone
+	case notify.ChannelEmail:
+		return c.Email
+	case notify.ChannelTelegram:
+		return c.TelegramChatID
+	}
+	return ""
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// start фиксирует получателей: состав сегмента пересчитывается на момент отправки,
+// покупатели без согласия на рассылку или без адреса в канале сразу помечаются пропущенными
+func (s *Service) start(ctx context.Context, c *Campaign) error {
+	if _, err := s.segments.Recompute(ctx, c.OwnerID, c.SegmentID); err != nil {
+		return err
+	}
+	ids, err := s.segments.MemberIDs(ctx, c.OwnerID, c.SegmentID)
+	if err != nil {
+		return err
+	}
+	customers, err := s.customers.GetCustomersByIDs(ctx, c.OwnerID, ids)
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]Delivery, 0, len(customers))
+	for _, cust := range customers {
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		d := Delivery{
+			CampaignID: c.ID,
+			CustomerID: cust.ID,
+			Address:    address(cust, c.Channel),
+			Status:     DeliveryPending,
+			Token:      token,
+		}
+		switch {
+		case !cust.MarketingConsent:
+			d.Status, d.Error = DeliverySkipped, "нет согласия на рассылку"
+		case d.Address == "":
+			d.Status, d.Error = DeliverySkipped, "нет адреса для канала "+string(c.Channel)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := s.repo.CreateDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	_, err = s.repo.SetStatus(ctx, c.ID, []string{StatusScheduled}, StatusSending, nil)
+	return err
+}
+
+func (s *Service) message(ctx context.Context, c *Campaign, cust customer.Customer, d Delivery) (notify.Message, error) {
+	balance, err := s.loyalty.GetBalance(ctx, cust.ID)
+	if err != nil {
+		return notify.Message{}, err
+	}
+	vars := map[string]string{
+		"name":        cust.Name,
+		"balance":     strconv.FormatFloat(balance, 'f', -1, 64),
+		"link":        s.publicURL + "/c/" + d.Token,
+		"unsubscribe": s.publicURL + "/c/" + d.Token + "/unsubscribe",
+	}
+	return notify.Message{
+		Channel: c.Channel,
+		To:      d.Address,
+		Subject: render(c.Subject, vars),
+		Body:    render(c.Body, vars),
+	}, nil
+}
+
+// sendBatch отправляет не больше RatePerMinute сообщений кампании.
+// Согласие проверяется ещё раз: покупатель мог отписаться после старта рассылки.
+func (s *Service) sendBatch(ctx context.Context, c *Campaign) error {
+	pending, err := s.repo.GetPendingDeliveries(ctx, c.ID, c.RatePerMinute)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		_, err := s.repo.SetStatus(ctx, c.ID, []string{StatusSending}, StatusSent, nil)
+		return err
+	}
+
+	ids := make([]int, len(pending))
+	for i, d := range pending {
+		ids[i] = d.CustomerID
+	}
+	customers, err := s.customers.GetCustomersByIDs(ctx, c.OwnerID, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]customer.Customer, len(customers))
+	for _, cust := range customers {
+		byID[cust.ID] = cust
+	}
+
+	for _, d := range pending {
+		cust, ok := byID[d.CustomerID]
+		if !ok || !cust.MarketingConsent {
+			if err := s.repo.MarkDelivery(ctx, d.ID, DeliverySkipped, "", "нет согласия на рассылку"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		msg, err := s.message(ctx, c, cust, d)
+		if err != nil {
+			return err
+		}
+		externalID, sendErr := s.sender.Send(ctx, msg)
+		if sendErr != nil {
+			err = s.repo.MarkDelivery(ctx, d.ID, DeliveryFailed, "", sendErr.Error())
+		} else {
+			err = s.repo.MarkDelivery(ctx, d.ID, DeliverySent, externalID, "")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dispatch — один проход рассыльщика по всем кампаниям, которым пора отправляться
+func (s *Service) Dispatch(ctx context.Context) error {
+	campaigns, err := s.repo.GetDueCampaigns(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range campaigns {
+		c := &campaigns[i]
+		if c.Status == StatusScheduled {
+			if err := s.start(ctx, c); err != nil {
+				log.Printf("ошибка запуска кампании ID=%d: %v", c.ID, err)
+				continue
+			}
+		}
+		if err := s.sendBatch(ctx, c); err != nil {
+			log.Printf("ошибка отправки кампании ID=%d: %v", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// RunDispatcher — фоновая отправка рассылок; interval задаёт шаг ограничения скорости (RatePerMinute)
+func (s *Service) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Dispatch(ctx); err != nil {
+				log.Printf("ошибка рассылки: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) delivery(ctx context.Context, token string) (*Delivery, error) {
+	d, err := s.repo.GetDeliveryByToken(ctx, token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLinkNotFound
+	}
+	return d, err
+}
+
+// Click учитывает переход и возвращает адрес, куда перенаправить покупателя
+func (s *Service) Click(ctx context.Context, token string) (string, error) {
+	d, err := s.delivery(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	linkURL, err := s.repo.GetLinkURL(ctx, d.CampaignID)
+	if err != nil {
+		return "", err
+	}
+	if linkURL == "" {
+		return "", ErrLinkNotFound
+	}
+	if err := s.repo.RegisterClick(ctx, d.ID); err != nil {
+		return "", err
+	}
+	return linkURL, nil
+}
+
+// Unsubscribe — отписка по ссылке из сообщения; снимает согласие покупателя на все рассылки
+func (s *Service) Unsubscribe(ctx context.Context, token string) error {
+	d, err := s.delivery(ctx, token)
+	if err != nil {
+		return err
+	}
+	if err := s.customers.RevokeConsent(ctx, d.CustomerID); err != nil {
+		return err
+	}
+	return s.repo.MarkUnsubscribed(ctx, d.ID)
+}
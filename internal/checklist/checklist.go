@@ -0,0 +1,164 @@
+package checklist
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	clockLayout = "15:04"
+	dateLayout  = "2006-01-02"
+)
+
+var (
+	ErrAccessDenied      = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrNotEmployee       = errors.New("пользователь не работает в этом магазине")
+	ErrTemplateNotFound  = errors.New("шаблон чек-листа не найден")
+	ErrChecklistNotFound = errors.New("чек-лист не найден")
+	ErrItemNotFound      = errors.New("пункт чек-листа не найден")
+	ErrItemDone          = errors.New("пункт чек-листа уже выполнен")
+	ErrPhotoRequired     = errors.New("для этого пункта нужно фото")
+)
+
+// TemplateItem — пункт шаблона: «протереть витрину», «сменить выкладку в окне»
+type TemplateItem struct {
+	Title         string `json:"title"`
+	PhotoRequired bool   `json:"photo_required"`
+}
+
+// Template — повторяющийся чек-лист магазина. Каждый рабочий день по нему создаётся чек-лист
+// и назначается сотруднику на смене. Weekdays: 1 — понедельник … 7 — воскресенье, пусто — каждый день.
+// DueTime — до какого времени (местного времени сервера) чек-лист нужно закрыть.
+type Template struct {
+	ID        int            `json:"id"`
+	OwnerID   int            `json:"owner_id"`
+	ShopID    int            `json:"shop_id"`
+	Title     string         `json:"title"`
+	Weekdays  []int          `json:"weekdays"`
+	DueTime   string         `json:"due_time"`
+	Items     []TemplateItem `json:"items"`
+	Active    bool           `json:"active"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (t *Template) validate() error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return errors.New("название чек-листа обязательно")
+	}
+	if _, err := time.Parse(clockLayout, t.DueTime); err != nil {
+		return fmt.Errorf("неправильное время выполнения: %s", t.DueTime)
+	}
+	if len(t.Items) == 0 {
+		return errors.New("в чек-листе должен быть хотя бы один пункт")
+	}
+	for i := range t.Items {
+		t.Items[i].Title = strings.TrimSpace(t.Items[i].Title)
+		if t.Items[i].Title == "" {
+			return fmt.Errorf("пункт %d: название обязательно", i+1)
+		}
+	}
+
+	seen := make(map[int]bool, len(t.Weekdays))
+	weekdays := make([]int, 0, len(t.Weekdays))
+	for _, d := range t.Weekdays {
+		if d < 1 || d > 7 {
+			return fmt.Errorf("день недели должен быть от 1 до 7: %d", d)
+		}
+		if !seen[d] {
+			seen[d] = true
+			weekdays = append(weekdays, d)
+		}
+	}
+	sort.Ints(weekdays)
+	t.Weekdays = weekdays
+	return nil
+}
+
+// runsOn — создаётся ли чек-лист по шаблону в день day
+func (t *Template) runsOn(day time.Time) bool {
+	if len(t.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range t.Weekdays {
+		if d == isoWeekday(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// dueAt — срок чек-листа по шаблону в день day
+func (t *Template) dueAt(day time.Time) time.Time {
+	due, _ := time.Parse(clockLayout, t.DueTime)
+	return day.Add(time.Duration(due.Hour())*time.Hour + time.Duration(due.Minute())*time.Minute)
+}
+
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Item — пункт чек-листа дня. Отметка хранит, кто и когда его выполнил, и ссылку на фото.
+type Item struct {
+	ID            int        `json:"id"`
+	Position      int        `json:"position"`
+	Title         string     `json:"title"`
+	PhotoRequired bool       `json:"photo_required"`
+	DoneAt        *time.Time `json:"done_at,omitempty"`
+	DoneBy        *int       `json:"done_by,omitempty"`
+	DoneByName    string     `json:"done_by_name,omitempty"`
+	PhotoURL      string     `json:"photo_url,omitempty"`
+}
+
+// Checklist — чек-лист магазина на конкретный день
+type Checklist struct {
+	ID           int        `json:"id"`
+	TemplateID   int        `json:"template_id"`
+	ShopID       int        `json:"shop_id"`
+	Date         string     `json:"date"`
+	Title        string     `json:"title"`
+	AssigneeID   *int       `json:"assignee_id,omitempty"`
+	AssigneeName string     `json:"assignee_name,omitempty"`
+	DueAt        time.Time  `json:"due_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	Done         int        `json:"done"`
+	Total        int        `json:"total"`
+	Items        []Item     `json:"items"`
+	// Overdue — срок прошёл, а чек-лист выполнен не полностью
+	Overdue bool `json:"overdue"`
+}
+
+// CompleteRequest — отметка пункта; фото загружается в хранилище заранее, сюда передаётся ссылка
+type CompleteRequest struct {
+	PhotoURL string `json:"photo_url"`
+}
+
+func (c *CompleteRequest) validate(item *Item) error {
+	c.PhotoURL = strings.TrimSpace(c.PhotoURL)
+	if c.PhotoURL == "" {
+		if item.PhotoRequired {
+			return ErrPhotoRequired
+		}
+		return nil
+	}
+	u, err := url.Parse(c.PhotoURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("ссылка на фото должна быть http(s) URL")
+	}
+	return nil
+}
+
+type AssignRequest struct {
+	UserID int `json:"user_id"`
+}
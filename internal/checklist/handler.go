@@ -0,0 +1,404 @@
+package checklist
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrTemplateNotFound), errors.Is(err, ErrChecklistNotFound), errors.Is(err, ErrItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrItemDone):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+var paramErrors = map[string]string{
+	"template_id":  "неправильный ID шаблона",
+	"checklist_id": "неправильный ID чек-листа",
+	"item_id":      "неправильный ID пункта",
+}
+
+// pathIDs читает ID магазина и перечисленные ID из пути
+func pathIDs(w http.ResponseWriter, r *http.Request, names ...string) ([]int, bool) {
+	ids := make([]int, 0, len(names)+1)
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return nil, false
+	}
+	ids = append(ids, shopID)
+	for _, name := range names {
+		id, err := strconv.Atoi(chi.URLParam(r, name))
+		if err != nil {
+			http.Error(w, paramErrors[name], http.StatusBadRequest)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// ownerClaims — сводка просрочек доступна только владельцу
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// CreateTemplate godoc
+// @Summary Create checklist template
+// @Description Создаёт повторяющийся чек-лист магазина (открытие, смена витрины, порядок на складе). Каждый рабочий день по шаблону создаётся чек-лист и назначается сотруднику на смене. weekdays: 1 — пн … 7 — вс, пусто — каждый день; due_time — срок выполнения (HH:MM).
+// @Tags checklists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param template body Template true "Шаблон"
+// @Success 201 {object} Template
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/checklist-templates [post]
+func (h *Handler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var t Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateTemplate(r.Context(), claims.ID, ids[0], &t); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// GetTemplates godoc
+// @Summary List checklist templates
+// @Description Шаблоны чек-листов магазина: действующие сверху.
+// @Tags checklists
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {array} Template
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/checklist-templates [get]
+func (h *Handler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	templates, err := h.service.GetTemplates(r.Context(), claims.ID, ids[0])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(templates)
+}
+
+// UpdateTemplate godoc
+// @Summary Update checklist template
+// @Description Меняет шаблон и снова включает отключённый. Уже созданные чек-листы не меняются.
+// @Tags checklists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param template_id path int true "Template ID"
+// @Param template body Template true "Шаблон"
+// @Success 200 {object} Template
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "шаблон чек-листа не найден"
+// @Router /shops/{id}/checklist-templates/{template_id} [put]
+func (h *Handler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r, "template_id")
+	if !ok {
+		return
+	}
+
+	var t Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	t.ID = ids[1]
+
+	if err := h.service.UpdateTemplate(r.Context(), claims.ID, ids[0], &t); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// DeactivateTemplate godoc
+// @Summary Deactivate checklist template
+// @Description Отключает шаблон: новые чек-листы по нему не создаются, история выполнения сохраняется.
+// @Tags checklists
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param template_id path int true "Template ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "шаблон чек-листа не найден"
+// @Router /shops/{id}/checklist-templates/{template_id} [delete]
+func (h *Handler) DeactivateTemplate(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r, "template_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeactivateTemplate(r.Context(), claims.ID, ids[0], ids[1]); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "deactivated"})
+}
+
+// GetChecklists godoc
+// @Summary Shop checklists for a day
+// @Description Чек-листы магазина на день с пунктами, исполнителями и отметками. По умолчанию — сегодня.
+// @Tags checklists
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param date query string false "День (YYYY-MM-DD)"
+// @Success 200 {array} Checklist
+// @Failure 400 {string} string "неправильная дата"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/checklists [get]
+func (h *Handler) GetChecklists(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r)
+	if !ok {
+		return
+	}
+	day := time.Now()
+	if v := r.URL.Query().Get("date"); v != "" {
+		t, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			http.Error(w, "неправильная дата", http.StatusBadRequest)
+			return
+		}
+		day = t
+	}
+
+	lists, err := h.service.GetShopChecklists(r.Context(), claims.ID, ids[0], day)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(lists)
+}
+
+// AssignChecklist godoc
+// @Summary Reassign checklist
+// @Description Передаёт чек-лист дня другому сотруднику магазина.
+// @Tags checklists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param checklist_id path int true "Checklist ID"
+// @Param request body AssignRequest true "Исполнитель"
+// @Success 200 {object} Checklist
+// @Failure 400 {string} string "пользователь не работает в этом магазине"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "чек-лист не найден"
+// @Router /shops/{id}/checklists/{checklist_id}/assignee [put]
+func (h *Handler) AssignChecklist(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r, "checklist_id")
+	if !ok {
+		return
+	}
+
+	var req AssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.service.Assign(r.Context(), claims.ID, ids[0], ids[1], req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// CompleteItem godoc
+// @Summary Complete checklist item
+// @Description Отмечает пункт чек-листа выполненным: сохраняется сотрудник, время и ссылка на фото (для пунктов с photo_required фото обязательно). Когда выполнены все пункты, чек-лист закрывается.
+// @Tags checklists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param checklist_id path int true "Checklist ID"
+// @Param item_id path int true "Item ID"
+// @Param request body CompleteRequest false "Фото"
+// @Success 200 {object} Checklist
+// @Failure 400 {string} string "для этого пункта нужно фото"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "пункт чек-листа не найден"
+// @Failure 409 {string} string "пункт чек-листа уже выполнен"
+// @Router /shops/{id}/checklists/{checklist_id}/items/{item_id}/complete [post]
+func (h *Handler) CompleteItem(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	ids, ok := pathIDs(w, r, "checklist_id", "item_id")
+	if !ok {
+		return
+	}
+
+	var req CompleteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+			return
+		}
+	}
+
+	c, err := h.service.CompleteItem(r.Context(), claims.ID, ids[0], ids[1], ids[2], &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// MyChecklists godoc
+// @Summary My checklists
+// @Description Чек-листы, назначенные текущему сотруднику на сегодня, во всех магазинах. Невыполненные сверху.
+// @Tags checklists
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Checklist
+// @Failure 401 {string} string "не авторизован"
+// @Router /me/checklists [get]
+func (h *Handler) MyChecklists(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	lists, err := h.service.MyChecklists(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения чек-листов", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(lists)
+}
+
+// GetOverdue godoc
+// @Summary Overdue checklists
+// @Description Чек-листы магазинов владельца, не выполненные в срок, с невыполненными пунктами и исполнителями.
+// @Tags checklists
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param shop_id query int false "Магазин"
+// @Success 200 {array} Checklist
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/checklists/overdue [get]
+func (h *Handler) GetOverdue(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	shopID, _ := strconv.Atoi(r.URL.Query().Get("shop_id"))
+
+	lists, err := h.service.Overdue(r.Context(), claims.ID, shopID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(lists)
+}
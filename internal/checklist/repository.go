@@ -0,0 +1,389 @@
+package checklist
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS checklist_templates (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			title VARCHAR(255) NOT NULL,
+			weekdays INT[] NOT NULL DEFAULT '{}',
+			due_time TIME NOT NULL,
+			items JSONB NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS checklists (
+			id SERIAL PRIMARY KEY,
+			template_id INT NOT NULL REFERENCES checklist_templates(id) ON DELETE CASCADE,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			assignee_id INT REFERENCES users(id) ON DELETE SET NULL,
+			due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			completed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (template_id, day)
+		);
+
+		CREATE INDEX IF NOT EXISTS checklists_shop_day ON checklists (shop_id, day);
+		CREATE INDEX IF NOT EXISTS checklists_open ON checklists (due_at) WHERE completed_at IS NULL;
+
+		CREATE TABLE IF NOT EXISTS checklist_items (
+			id SERIAL PRIMARY KEY,
+			checklist_id INT NOT NULL REFERENCES checklists(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			photo_required BOOLEAN NOT NULL DEFAULT FALSE,
+			done_at TIMESTAMP WITH TIME ZONE,
+			done_by INT REFERENCES users(id) ON DELETE SET NULL,
+			photo_url TEXT
+		);
+
+		CREATE INDEX IF NOT EXISTS checklist_items_checklist ON checklist_items (checklist_id, position);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции checklists: %w", err)
+	}
+	fmt.Println("Миграция checklists выполнена успешно")
+	return nil
+}
+
+const employeeName = `TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))`
+
+const templateColumns = `id, owner_id, shop_id, title, weekdays, to_char(due_time, 'HH24:MI'), items, active, created_at`
+
+func scanTemplate(row pgx.Row, t *Template) error {
+	return row.Scan(&t.ID, &t.OwnerID, &t.ShopID, &t.Title, &t.Weekdays, &t.DueTime, &t.Items, &t.Active, &t.CreatedAt)
+}
+
+func (r *Repository) CreateTemplate(ctx context.Context, t *Template) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO checklist_templates (owner_id, shop_id, title, weekdays, due_time, items)
+		VALUES ($1, $2, $3, $4, $5::time, $6)
+		RETURNING id, active, created_at
+	`, t.OwnerID, t.ShopID, t.Title, t.Weekdays, t.DueTime, t.Items).Scan(&t.ID, &t.Active, &t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания шаблона чек-листа: %w", err)
+	}
+	return nil
+}
+
+// UpdateTemplate меняет шаблон; уже созданные чек-листы остаются как были
+func (r *Repository) UpdateTemplate(ctx context.Context, t *Template) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		UPDATE checklist_templates SET title = $1, weekdays = $2, due_time = $3::time, items = $4, active = TRUE
+		WHERE id = $5 AND shop_id = $6
+		RETURNING `+templateColumns+`
+	`, t.Title, t.Weekdays, t.DueTime, t.Items, t.ID, t.ShopID).Scan(&t.ID, &t.OwnerID, &t.ShopID, &t.Title, &t.Weekdays,
+		&t.DueTime, &t.Items, &t.Active, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTemplateNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка обновления шаблона чек-листа: %w", err)
+	}
+	return nil
+}
+
+// DeactivateTemplate останавливает создание чек-листов; история выполнения сохраняется
+func (r *Repository) DeactivateTemplate(ctx context.Context, shopID, templateID int) error {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE checklist_templates SET active = FALSE WHERE id = $1 AND shop_id = $2
+	`, templateID, shopID)
+	if err != nil {
+		return fmt.Errorf("ошибка отключения шаблона чек-листа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+func (r *Repository) queryTemplates(ctx context.Context, query string, args ...any) ([]Template, error) {
+	rows, err := r.db.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения шаблонов чек-листов: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var t Template
+		if err := scanTemplate(rows, &t); err != nil {
+			return nil, fmt.Errorf("ошибка чтения шаблона чек-листа: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке шаблонов чек-листов: %w", err)
+	}
+	return templates, nil
+}
+
+func (r *Repository) GetTemplates(ctx context.Context, shopID int) ([]Template, error) {
+	return r.queryTemplates(ctx, `
+		SELECT `+templateColumns+` FROM checklist_templates WHERE shop_id = $1 ORDER BY active DESC, id
+	`, shopID)
+}
+
+// GetPendingTemplates — действующие шаблоны, по которым на день day ещё нет чек-листа
+func (r *Repository) GetPendingTemplates(ctx context.Context, day time.Time) ([]Template, error) {
+	return r.queryTemplates(ctx, `
+		SELECT `+templateColumns+` FROM checklist_templates t
+		WHERE t.active AND (cardinality(t.weekdays) = 0 OR $2 = ANY(t.weekdays))
+		  AND NOT EXISTS (SELECT 1 FROM checklists c WHERE c.template_id = t.id AND c.day = $1)
+		ORDER BY t.shop_id, t.due_time, t.id
+	`, day, isoWeekday(day))
+}
+
+// PickAssignee выбирает сотрудника на смене для чек-листа: сначала отметившихся в табеле,
+// затем стоящих в графике на этот день; среди них — с наименьшим числом чек-листов за день.
+// nil — в магазине сейчас никого нет.
+func (r *Repository) PickAssignee(ctx context.Context, shopID int, day time.Time) (*int, error) {
+	var userID int
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT s.user_id
+		FROM (
+			SELECT user_id, 0 AS priority FROM time_entries WHERE shop_id = $1 AND clock_out IS NULL
+			UNION ALL
+			SELECT user_id, 1 FROM work_schedules WHERE shop_id = $1 AND weekday = $3
+		) s
+		LEFT JOIN checklists c ON c.shop_id = $1 AND c.day = $2 AND c.assignee_id = s.user_id
+		GROUP BY s.user_id
+		ORDER BY MIN(s.priority), COUNT(DISTINCT c.id), s.user_id
+		LIMIT 1
+	`, shopID, day, isoWeekday(day)).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выбора исполнителя чек-листа: %w", err)
+	}
+	return &userID, nil
+}
+
+// CreateChecklist создаёт чек-лист дня по шаблону вместе с пунктами.
+// Если чек-лист на этот день уже есть, ничего не делает.
+func (r *Repository) CreateChecklist(ctx context.Context, t *Template, day time.Time, assigneeID *int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO checklists (template_id, shop_id, day, title, assignee_id, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (template_id, day) DO NOTHING
+		RETURNING id
+	`, t.ID, t.ShopID, day, t.Title, assigneeID, t.dueAt(day)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка создания чек-листа: %w", err)
+	}
+	for i, item := range t.Items {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO checklist_items (checklist_id, position, title, photo_required) VALUES ($1, $2, $3, $4)
+		`, id, i+1, item.Title, item.PhotoRequired)
+		if err != nil {
+			return fmt.Errorf("ошибка создания пункта чек-листа: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка создания чек-листа: %w", err)
+	}
+	return nil
+}
+
+// GetUnassigned — невыполненные чек-листы дня без исполнителя (на момент создания никого не было на смене)
+func (r *Repository) GetUnassigned(ctx context.Context, day time.Time) ([]Checklist, error) {
+	return r.queryChecklists(ctx, `
+		WHERE c.day = $1 AND c.assignee_id IS NULL AND c.completed_at IS NULL
+		ORDER BY c.shop_id, c.due_at, c.id
+	`, day)
+}
+
+func (r *Repository) SetAssignee(ctx context.Context, shopID, checklistID int, userID *int) error {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE checklists SET assignee_id = $1 WHERE id = $2 AND shop_id = $3
+	`, userID, checklistID, shopID)
+	if err != nil {
+		return fmt.Errorf("ошибка назначения чек-листа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrChecklistNotFound
+	}
+	return nil
+}
+
+const checklistColumns = `
+	c.id, c.template_id, c.shop_id, to_char(c.day, 'YYYY-MM-DD'), c.title, c.assignee_id,
+	COALESCE(` + employeeName + `, ''), c.due_at, c.completed_at
+`
+
+// queryChecklists читает чек-листы с пунктами; where — условие и сортировка
+func (r *Repository) queryChecklists(ctx context.Context, where string, args ...any) ([]Checklist, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+checklistColumns+`
+		FROM checklists c LEFT JOIN users u ON u.id = c.assignee_id
+		`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения чек-листов: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	lists := []Checklist{}
+	for rows.Next() {
+		var c Checklist
+		if err := rows.Scan(&c.ID, &c.TemplateID, &c.ShopID, &c.Date, &c.Title, &c.AssigneeID, &c.AssigneeName,
+			&c.DueAt, &c.CompletedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения чек-листа: %w", err)
+		}
+		c.Overdue = c.CompletedAt == nil && c.DueAt.Before(now)
+		c.Items = []Item{}
+		lists = append(lists, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке чек-листов: %w", err)
+	}
+	if len(lists) == 0 {
+		return lists, nil
+	}
+	if err := r.loadItems(ctx, lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *Repository) loadItems(ctx context.Context, lists []Checklist) error {
+	ids := make([]int, len(lists))
+	index := make(map[int]int, len(lists))
+	for i, c := range lists {
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT ci.checklist_id, ci.id, ci.position, ci.title, ci.photo_required, ci.done_at, ci.done_by,
+			COALESCE(`+employeeName+`, ''), COALESCE(ci.photo_url, '')
+		FROM checklist_items ci LEFT JOIN users u ON u.id = ci.done_by
+		WHERE ci.checklist_id = ANY($1)
+		ORDER BY ci.checklist_id, ci.position
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("ошибка получения пунктов чек-листов: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var checklistID int
+		var it Item
+		if err := rows.Scan(&checklistID, &it.ID, &it.Position, &it.Title, &it.PhotoRequired, &it.DoneAt, &it.DoneBy,
+			&it.DoneByName, &it.PhotoURL); err != nil {
+			return fmt.Errorf("ошибка чтения пункта чек-листа: %w", err)
+		}
+		c := &lists[index[checklistID]]
+		c.Items = append(c.Items, it)
+		c.Total++
+		if it.DoneAt != nil {
+			c.Done++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при обработке пунктов чек-листов: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetChecklist(ctx context.Context, shopID, checklistID int) (*Checklist, error) {
+	lists, err := r.queryChecklists(ctx, `WHERE c.id = $1 AND c.shop_id = $2`, checklistID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, ErrChecklistNotFound
+	}
+	return &lists[0], nil
+}
+
+func (r *Repository) GetShopChecklists(ctx context.Context, shopID int, day time.Time) ([]Checklist, error) {
+	return r.queryChecklists(ctx, `
+		WHERE c.shop_id = $1 AND c.day = $2
+		ORDER BY c.due_at, c.id
+	`, shopID, day)
+}
+
+// GetAssigned — чек-листы сотрудника за день во всех магазинах
+func (r *Repository) GetAssigned(ctx context.Context, userID int, day time.Time) ([]Checklist, error) {
+	return r.queryChecklists(ctx, `
+		WHERE c.assignee_id = $1 AND c.day = $2
+		ORDER BY c.completed_at IS NOT NULL, c.due_at, c.id
+	`, userID, day)
+}
+
+// GetOverdue — невыполненные в срок чек-листы магазинов владельца; shopID = 0 — все магазины
+func (r *Repository) GetOverdue(ctx context.Context, ownerID, shopID int, now time.Time) ([]Checklist, error) {
+	return r.queryChecklists(ctx, `
+		JOIN shops sh ON sh.id = c.shop_id
+		WHERE sh.owner_id = $1 AND ($2 = 0 OR c.shop_id = $2) AND c.completed_at IS NULL AND c.due_at < $3
+		ORDER BY c.due_at, c.id
+	`, ownerID, shopID, now)
+}
+
+// CompleteItem отмечает пункт выполненным; когда выполнены все пункты, закрывает чек-лист
+func (r *Repository) CompleteItem(ctx context.Context, checklistID, itemID, userID int, photoURL string, at time.Time) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE checklist_items SET done_at = $1, done_by = $2, photo_url = NULLIF($3, '')
+		WHERE id = $4 AND checklist_id = $5 AND done_at IS NULL
+	`, at, userID, photoURL, itemID, checklistID)
+	if err != nil {
+		return fmt.Errorf("ошибка отметки пункта чек-листа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrItemDone
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE checklists SET completed_at = $1
+		WHERE id = $2 AND completed_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM checklist_items WHERE checklist_id = $2 AND done_at IS NULL)
+	`, at, checklistID)
+	if err != nil {
+		return fmt.Errorf("ошибка закрытия чек-листа: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка отметки пункта чек-листа: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package checklist
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"log"
+	"time"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+// checkOwner — шаблоны чек-листов ведёт владелец магазина
+func (s *Service) checkOwner(ctx context.Context, ownerID, shopID int) error {
+	ok, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *Service) checkAccess(ctx context.Context, userID, shopID int) error {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *Service) CreateTemplate(ctx context.Context, ownerID, shopID int, t *Template) error {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	t.OwnerID = ownerID
+	t.ShopID = shopID
+	if err := s.repo.CreateTemplate(ctx, t); err != nil {
+		return err
+	}
+	return s.createToday(ctx, t)
+}
+
+// UpdateTemplate меняет шаблон и снова включает отключённый; чек-листы, созданные раньше, не меняются
+func (s *Service) UpdateTemplate(ctx context.Context, ownerID, shopID int, t *Template) error {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	t.ShopID = shopID
+	if err := s.repo.UpdateTemplate(ctx, t); err != nil {
+		return err
+	}
+	return s.createToday(ctx, t)
+}
+
+func (s *Service) DeactivateTemplate(ctx context.Context, ownerID, shopID, templateID int) error {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return err
+	}
+	return s.repo.DeactivateTemplate(ctx, shopID, templateID)
+}
+
+func (s *Service) GetTemplates(ctx context.Context, ownerID, shopID int) ([]Template, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTemplates(ctx, shopID)
+}
+
+// createToday — новый шаблон начинает действовать сразу, не дожидаясь планировщика
+func (s *Service) createToday(ctx context.Context, t *Template) error {
+	day := startOfDay(time.Now())
+	if !t.runsOn(day) {
+		return nil
+	}
+	return s.create(ctx, t, day)
+}
+
+func (s *Service) create(ctx context.Context, t *Template, day time.Time) error {
+	assigneeID, err := s.repo.PickAssignee(ctx, t.ShopID, day)
+	if err != nil {
+		return err
+	}
+	return s.repo.CreateChecklist(ctx, t, day, assigneeID)
+}
+
+// Generate создаёт чек-листы на день now по всем шаблонам и назначает исполнителей тем,
+// у кого их ещё нет (например, утром никто не успел отметиться в табеле)
+func (s *Service) Generate(ctx context.Context, now time.Time) error {
+	day := startOfDay(now)
+	templates, err := s.repo.GetPendingTemplates(ctx, day)
+	if err != nil {
+		return err
+	}
+	for i := range templates {
+		if err := s.create(ctx, &templates[i], day); err != nil {
+			log.Printf("ошибка создания чек-листа по шаблону ID=%d: %v", templates[i].ID, err)
+		}
+	}
+
+	unassigned, err := s.repo.GetUnassigned(ctx, day)
+	if err != nil {
+		return err
+	}
+	for _, c := range unassigned {
+		assigneeID, err := s.repo.PickAssignee(ctx, c.ShopID, day)
+		if err != nil {
+			log.Printf("ошибка назначения чек-листа ID=%d: %v", c.ID, err)
+			continue
+		}
+		if assigneeID == nil {
+			continue
+		}
+		if err := s.repo.SetAssignee(ctx, c.ShopID, c.ID, assigneeID); err != nil {
+			log.Printf("ошибка назначения чек-листа ID=%d: %v", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// RunScheduler создаёт чек-листы дня и раздаёт их сотрудникам на смене каждые interval
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Generate(ctx, time.Now()); err != nil {
+			log.Printf("ошибка создания чек-листов: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) GetShopChecklists(ctx context.Context, userID, shopID int, day time.Time) ([]Checklist, error) {
+	if err := s.checkAccess(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetShopChecklists(ctx, shopID, startOfDay(day))
+}
+
+// MyChecklists — чек-листы сотрудника на сегодня
+func (s *Service) MyChecklists(ctx context.Context, userID int) ([]Checklist, error) {
+	return s.repo.GetAssigned(ctx, userID, startOfDay(time.Now()))
+}
+
+// Assign передаёт чек-лист другому сотруднику магазина
+func (s *Service) Assign(ctx context.Context, ownerID, shopID, checklistID, userID int) (*Checklist, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	if userID != ownerID {
+		ok, err := s.employees.IsEmployee(ctx, shopID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNotEmployee
+		}
+	}
+	if err := s.repo.SetAssignee(ctx, shopID, checklistID, &userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetChecklist(ctx, shopID, checklistID)
+}
+
+// CompleteItem отмечает пункт выполненным. Отметить может любой сотрудник магазина —
+// в чек-листе сохраняется, кто именно это сделал.
+func (s *Service) CompleteItem(ctx context.Context, userID, shopID, checklistID, itemID int, req *CompleteRequest) (*Checklist, error) {
+	if err := s.checkAccess(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	c, err := s.repo.GetChecklist(ctx, shopID, checklistID)
+	if err != nil {
+		return nil, err
+	}
+	var item *Item
+	for i := range c.Items {
+		if c.Items[i].ID == itemID {
+			item = &c.Items[i]
+		}
+	}
+	if item == nil {
+		return nil, ErrItemNotFound
+	}
+	if item.DoneAt != nil {
+		return nil, ErrItemDone
+	}
+	if err := req.validate(item); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CompleteItem(ctx, checklistID, itemID, userID, req.PhotoURL, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.repo.GetChecklist(ctx, shopID, checklistID)
+}
+
+// Overdue — просроченные чек-листы магазинов владельца; shopID = 0 — все магазины
+func (s *Service) Overdue(ctx context.Context, ownerID, shopID int) ([]Checklist, error) {
+	if shopID != 0 {
+		if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.GetOverdue(ctx, ownerID, shopID, time.Now())
+}
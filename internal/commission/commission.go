@@ -0,0 +1,223 @@
+package commission
+
+import (
+	"crm-backend/internal/payment"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Типы планов: единый процент, процент по ступеням месячного объёма, процент по категориям товаров
+const (
+	TypeFlat     = "flat"
+	TypeTiered   = "tiered"
+	TypeCategory = "category"
+)
+
+const monthLayout = "2006-01"
+
+var (
+	ErrPlanNotFound = errors.New("план комиссий не найден")
+	ErrShopNotFound = errors.New("магазин не найден")
+	ErrNotEmployee  = errors.New("пользователь не работает в этом магазине")
+)
+
+// Tier — ступень плана: при месячном чистом объёме продаж от From действует ставка Rate
+type Tier struct {
+	From float64 `json:"from"`
+	Rate float64 `json:"rate"`
+}
+
+// Plan — как считается комиссия продавца. Ставки — в процентах от чистых продаж (за вычетом возвратов).
+// Для ступенчатого плана ставка достигнутой ступени применяется ко всему объёму месяца.
+// Для плана по категориям Rate — ставка для категорий, которых нет в CategoryRates.
+type Plan struct {
+	ID            int                `json:"id"`
+	OwnerID       int                `json:"owner_id"`
+	Name          string             `json:"name"`
+	Type          string             `json:"type"`
+	Rate          float64            `json:"rate"`
+	Tiers         []Tier             `json:"tiers,omitempty"`
+	CategoryRates map[string]float64 `json:"category_rates,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+func validRate(rate float64) bool {
+	return rate >= 0 && rate <= 100
+}
+
+func (p *Plan) validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("укажите название плана")
+	}
+	if !validRate(p.Rate) {
+		return errors.New("ставка должна быть от 0 до 100%")
+	}
+	switch p.Type {
+	case TypeFlat:
+		p.Tiers, p.CategoryRates = nil, nil
+	case TypeTiered:
+		if len(p.Tiers) == 0 {
+			return errors.New("укажите ступени плана")
+		}
+		sort.Slice(p.Tiers, func(i, j int) bool { return p.Tiers[i].From < p.Tiers[j].From })
+		for i, t := range p.Tiers {
+			if t.From < 0 || !validRate(t.Rate) {
+				return fmt.Errorf("неправильная ступень плана: от %.2f, ставка %.2f%%", t.From, t.Rate)
+			}
+			if i > 0 && t.From == p.Tiers[i-1].From {
+				return fmt.Errorf("ступень от %.2f указана дважды", t.From)
+			}
+		}
+		p.CategoryRates = nil
+	case TypeCategory:
+		rates := make(map[string]float64, len(p.CategoryRates))
+		for category, rate := range p.CategoryRates {
+			if !validRate(rate) {
+				return fmt.Errorf("ставка для категории %s должна быть от 0 до 100%%", category)
+			}
+			rates[strings.ToLower(strings.TrimSpace(category))] = rate
+		}
+		p.CategoryRates = rates
+		p.Tiers = nil
+	default:
+		return errors.New("неизвестный тип плана: " + p.Type)
+	}
+	return nil
+}
+
+// rateFor — ставка для категории при месячном чистом объёме net
+func (p *Plan) rateFor(category string, net float64) float64 {
+	switch p.Type {
+	case TypeTiered:
+		rate := 0.0
+		for _, t := range p.Tiers {
+			if net >= t.From {
+				rate = t.Rate
+			}
+		}
+		return rate
+	case TypeCategory:
+		if rate, ok := p.CategoryRates[strings.ToLower(category)]; ok {
+			return rate
+		}
+	}
+	return p.Rate
+}
+
+// Assignment — план комиссий сотрудника в магазине. Без UserID — план по умолчанию для всех сотрудников магазина.
+type Assignment struct {
+	ShopID int  `json:"shop_id"`
+	UserID *int `json:"user_id,omitempty"`
+	PlanID int  `json:"plan_id"`
+}
+
+// Target — месячный план продаж сотрудника в магазине. Без UserID — план магазина целиком.
+type Target struct {
+	ShopID int     `json:"shop_id"`
+	UserID *int    `json:"user_id,omitempty"`
+	Month  string  `json:"month"`
+	Amount float64 `json:"amount"`
+}
+
+// CategorySales — продажи и возвраты продавца по категории товаров.
+// Возврат относится к продавцу исходного чека и попадает в месяц, когда его оформили.
+type CategorySales struct {
+	UserID   int     `json:"-"`
+	ShopID   int     `json:"-"`
+	Category string  `json:"category"`
+	Sold     float64 `json:"sold"`
+	Returned float64 `json:"returned"`
+}
+
+type StatementLine struct {
+	Category   string  `json:"category"`
+	Sold       float64 `json:"sold"`
+	Returned   float64 `json:"returned"`
+	Net        float64 `json:"net"`
+	Rate       float64 `json:"rate"`
+	Commission float64 `json:"commission"`
+}
+
+// Statement — расчётный лист продавца за месяц в одном магазине
+type Statement struct {
+	UserID       int             `json:"user_id"`
+	EmployeeName string          `json:"employee_name"`
+	ShopID       int             `json:"shop_id"`
+	ShopName     string          `json:"shop_name"`
+	Month        string          `json:"month"`
+	PlanID       int             `json:"plan_id,omitempty"`
+	PlanName     string          `json:"plan_name,omitempty"`
+	Sold         float64         `json:"sold"`
+	Returned     float64         `json:"returned"`
+	Net          float64         `json:"net"`
+	Commission   float64         `json:"commission"`
+	Target       float64         `json:"target,omitempty"`
+	TargetDone   float64         `json:"target_done_percent,omitempty"`
+	Lines        []StatementLine `json:"lines"`
+}
+
+// calculate считает комиссию по категориям; без плана комиссия нулевая.
+// Если возвратов больше, чем продаж, комиссия отрицательная — она удерживается из выплат.
+func (st *Statement) calculate(plan *Plan, sales []CategorySales) {
+	for _, c := range sales {
+		st.Sold += c.Sold
+		st.Returned += c.Returned
+	}
+	st.Sold = payment.Round(st.Sold)
+	st.Returned = payment.Round(st.Returned)
+	st.Net = payment.Round(st.Sold - st.Returned)
+
+	st.Lines = make([]StatementLine, 0, len(sales))
+	for _, c := range sales {
+		line := StatementLine{
+			Category: c.Category,
+			Sold:     payment.Round(c.Sold),
+			Returned: payment.Round(c.Returned),
+			Net:      payment.Round(c.Sold - c.Returned),
+		}
+		if plan != nil {
+			line.Rate = plan.rateFor(c.Category, st.Net)
+			line.Commission = payment.Round(line.Net * line.Rate / 100)
+		}
+		st.Commission += line.Commission
+		st.Lines = append(st.Lines, line)
+	}
+	st.Commission = payment.Round(st.Commission)
+	if plan != nil {
+		st.PlanID = plan.ID
+		st.PlanName = plan.Name
+	}
+	if st.Target > 0 {
+		st.TargetDone = payment.Round(st.Net / st.Target * 100)
+	}
+}
+
+// monthRange — полуинтервал [начало месяца, начало следующего)
+func monthRange(month string) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation(monthLayout, month, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("неправильный месяц %q, нужен формат YYYY-MM", month)
+	}
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+// ShopProgress — выполнение месячного плана продаж магазина
+type ShopProgress struct {
+	ShopID     int     `json:"shop_id"`
+	ShopName   string  `json:"shop_name"`
+	Net        float64 `json:"net"`
+	Commission float64 `json:"commission"`
+	Target     float64 `json:"target,omitempty"`
+	TargetDone float64 `json:"target_done_percent,omitempty"`
+}
+
+// Report — расчётные листы продавцов за месяц и итоги по магазинам
+type Report struct {
+	Month      string         `json:"month"`
+	Statements []Statement    `json:"statements"`
+	Shops      []ShopProgress `json:"shops"`
+}
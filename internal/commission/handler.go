@@ -0,0 +1,332 @@
+package commission
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPlanNotFound), errors.Is(err, ErrShopNotFound), errors.Is(err, ErrNotEmployee):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ownerClaims — планы комиссий и расчёт ведёт только владелец
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// month — ?month=YYYY-MM, по умолчанию текущий месяц
+func month(r *http.Request) string {
+	if m := r.URL.Query().Get("month"); m != "" {
+		return m
+	}
+	return time.Now().Format(monthLayout)
+}
+
+// CreatePlan godoc
+// @Summary Create commission plan
+// @Description Создаёт план комиссий: flat — единый процент rate; tiered — процент по ступеням месячного чистого объёма (tiers, ставка достигнутой ступени на весь объём); category — проценты по категориям товаров (category_rates), для остальных — rate.
+// @Tags commissions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param plan body Plan true "План"
+// @Success 201 {object} Plan
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/commissions/plans [post]
+func (h *Handler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var p Plan
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreatePlan(r.Context(), claims.ID, &p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// GetPlans godoc
+// @Summary List commission plans
+// @Tags commissions
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Plan
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/commissions/plans [get]
+func (h *Handler) GetPlans(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	plans, err := h.service.GetPlans(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения планов комиссий", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(plans)
+}
+
+// UpdatePlan godoc
+// @Summary Update commission plan
+// @Description Изменения действуют на все ещё не выплаченные периоды: расчёт всегда идёт по текущему плану.
+// @Tags commissions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param plan_id path int true "Plan ID"
+// @Param plan body Plan true "План"
+// @Success 200 {object} Plan
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 404 {string} string "план комиссий не найден"
+// @Router /owner/commissions/plans/{plan_id} [put]
+func (h *Handler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "plan_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID плана", http.StatusBadRequest)
+		return
+	}
+
+	var p Plan
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	p.ID = id
+
+	if err := h.service.UpdatePlan(r.Context(), claims.ID, &p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// GetAssignments godoc
+// @Summary List commission plan assignments
+// @Tags commissions
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Assignment
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/commissions/assignments [get]
+func (h *Handler) GetAssignments(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.service.GetAssignments(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения назначений планов", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// SetAssignment godoc
+// @Summary Assign commission plan
+// @Description Назначает план сотруднику магазина (user_id) или всем сотрудникам магазина (без user_id). План сотрудника важнее плана магазина. plan_id = 0 снимает назначение.
+// @Tags commissions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param assignment body Assignment true "Назначение"
+// @Success 200 {object} Assignment
+// @Failure 404 {string} string "пользователь не работает в этом магазине"
+// @Router /owner/commissions/assignments [put]
+func (h *Handler) SetAssignment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var a Assignment
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetAssignment(r.Context(), claims.ID, &a); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// GetTargets godoc
+// @Summary List monthly sales targets
+// @Tags commissions
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param month query string false "Месяц YYYY-MM (по умолчанию текущий)"
+// @Success 200 {array} Target
+// @Failure 400 {string} string "неправильный месяц"
+// @Router /owner/commissions/targets [get]
+func (h *Handler) GetTargets(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.service.GetTargets(r.Context(), claims.ID, month(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// SetTarget godoc
+// @Summary Set monthly sales target
+// @Description План продаж на месяц для сотрудника магазина (user_id) или магазина целиком. amount = 0 снимает план.
+// @Tags commissions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param target body Target true "План продаж"
+// @Success 200 {object} Target
+// @Failure 400 {string} string "неправильный месяц"
+// @Failure 404 {string} string "магазин не найден"
+// @Router /owner/commissions/targets [put]
+func (h *Handler) SetTarget(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var t Target
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetTarget(r.Context(), claims.ID, &t); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// GetReport godoc
+// @Summary Commission statements
+// @Description Расчётные листы продавцов за месяц: продажи, возвраты, чистые продажи и комиссия по категориям, выполнение плана продаж. Возвраты уменьшают комиссию продавца исходного чека в месяце возврата. format=csv — выгрузка для начисления зарплаты.
+// @Tags commissions
+// @Produce json
+// @Produce text/csv
+// @Param Authorization header string true "Bearer JWT token"
+// @Param month query string false "Месяц YYYY-MM (по умолчанию текущий)"
+// @Param shop_id query int false "Магазин"
+// @Param user_id query int false "Сотрудник"
+// @Param format query string false "json (по умолчанию) или csv"
+// @Success 200 {object} Report
+// @Failure 400 {string} string "неправильный месяц"
+// @Router /owner/commissions/statements [get]
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	shopID, _ := strconv.Atoi(query.Get("shop_id"))
+	userID, _ := strconv.Atoi(query.Get("user_id"))
+	m := month(r)
+
+	report, err := h.service.Report(r.Context(), claims.ID, m, shopID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=commissions-%s.csv", m))
+		_ = WriteCSV(w, report.Statements)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// MyStatements godoc
+// @Summary My commission statements
+// @Description Расчётные листы текущего пользователя за месяц во всех магазинах, где он работает: комиссия и выполнение плана продаж.
+// @Tags commissions
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param month query string false "Месяц YYYY-MM (по умолчанию текущий)"
+// @Success 200 {array} Statement
+// @Failure 400 {string} string "неправильный месяц"
+// @Router /me/commissions [get]
+func (h *Handler) MyStatements(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	statements, err := h.service.MyStatements(r.Context(), claims.ID, month(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statements)
+}
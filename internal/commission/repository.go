@@ -0,0 +1,293 @@
+package commission
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS commission_plans (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+			tiers JSONB,
+			category_rates JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS commission_assignments (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			user_id INT REFERENCES users(id) ON DELETE CASCADE,
+			plan_id INT NOT NULL REFERENCES commission_plans(id) ON DELETE CASCADE
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS commission_assignments_unique
+			ON commission_assignments (shop_id, (COALESCE(user_id, 0)));
+
+		CREATE TABLE IF NOT EXISTS sales_targets (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			user_id INT REFERENCES users(id) ON DELETE CASCADE,
+			month DATE NOT NULL,
+			amount NUMERIC(12, 2) NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS sales_targets_unique
+			ON sales_targets (shop_id, (COALESCE(user_id, 0)), month);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции commissions: %w", err)
+	}
+	fmt.Println("Миграция commissions выполнена успешно")
+	return nil
+}
+
+const planColumns = `id, owner_id, name, type, rate::float8, tiers, category_rates, created_at`
+
+func scanPlan(row pgx.Row) (*Plan, error) {
+	var p Plan
+	if err := row.Scan(&p.ID, &p.OwnerID, &p.Name, &p.Type, &p.Rate, &p.Tiers, &p.CategoryRates, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *Repository) CreatePlan(ctx context.Context, p *Plan) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO commission_plans (owner_id, name, type, rate, tiers, category_rates)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, p.OwnerID, p.Name, p.Type, p.Rate, p.Tiers, p.CategoryRates).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания плана комиссий: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) UpdatePlan(ctx context.Context, p *Plan) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE commission_plans SET name = $1, type = $2, rate = $3, tiers = $4, category_rates = $5
+		WHERE id = $6 AND owner_id = $7
+	`, p.Name, p.Type, p.Rate, p.Tiers, p.CategoryRates, p.ID, p.OwnerID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления плана комиссий (ID=%d): %w", p.ID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrPlanNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetPlan(ctx context.Context, ownerID, id int) (*Plan, error) {
+	p, err := scanPlan(r.db.Conn.QueryRow(ctx, `
+		SELECT `+planColumns+` FROM commission_plans WHERE id = $1 AND owner_id = $2
+	`, id, ownerID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения плана комиссий (ID=%d): %w", id, err)
+	}
+	return p, nil
+}
+
+func (r *Repository) GetPlans(ctx context.Context, ownerID int) ([]Plan, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+planColumns+` FROM commission_plans WHERE owner_id = $1 ORDER BY id
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения планов комиссий: %w", err)
+	}
+	defer rows.Close()
+
+	var plans []Plan
+	for rows.Next() {
+		p, err := scanPlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения плана комиссий: %w", err)
+		}
+		plans = append(plans, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке планов комиссий: %w", err)
+	}
+	return plans, nil
+}
+
+// SetAssignment назначает план; PlanID = 0 снимает назначение
+func (r *Repository) SetAssignment(ctx context.Context, a *Assignment) error {
+	var err error
+	if a.PlanID == 0 {
+		_, err = r.db.Conn.Exec(ctx, `
+			DELETE FROM commission_assignments WHERE shop_id = $1 AND COALESCE(user_id, 0) = COALESCE($2, 0)
+		`, a.ShopID, a.UserID)
+	} else {
+		_, err = r.db.Conn.Exec(ctx, `
+			INSERT INTO commission_assignments (shop_id, user_id, plan_id) VALUES ($1, $2, $3)
+			ON CONFLICT (shop_id, (COALESCE(user_id, 0))) DO UPDATE SET plan_id = EXCLUDED.plan_id
+		`, a.ShopID, a.UserID, a.PlanID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка назначения плана комиссий: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetAssignments(ctx context.Context, ownerID int) ([]Assignment, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT a.shop_id, a.user_id, a.plan_id
+		FROM commission_assignments a JOIN shops s ON s.id = a.shop_id
+		WHERE s.owner_id = $1
+		ORDER BY a.shop_id, a.user_id NULLS FIRST
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения назначений планов: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Assignment
+	for rows.Next() {
+		var a Assignment
+		if err := rows.Scan(&a.ShopID, &a.UserID, &a.PlanID); err != nil {
+			return nil, fmt.Errorf("ошибка чтения назначения плана: %w", err)
+		}
+		list = append(list, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке назначений планов: %w", err)
+	}
+	return list, nil
+}
+
+// SetTarget задаёт план продаж на месяц; Amount = 0 снимает его
+func (r *Repository) SetTarget(ctx context.Context, t *Target, month time.Time) error {
+	var err error
+	if t.Amount == 0 {
+		_, err = r.db.Conn.Exec(ctx, `
+			DELETE FROM sales_targets WHERE shop_id = $1 AND COALESCE(user_id, 0) = COALESCE($2, 0) AND month = $3
+		`, t.ShopID, t.UserID, month)
+	} else {
+		_, err = r.db.Conn.Exec(ctx, `
+			INSERT INTO sales_targets (shop_id, user_id, month, amount) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (shop_id, (COALESCE(user_id, 0)), month) DO UPDATE SET amount = EXCLUDED.amount
+		`, t.ShopID, t.UserID, month, t.Amount)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения плана продаж: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetTargets(ctx context.Context, ownerID int, month time.Time) ([]Target, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT t.shop_id, t.user_id, to_char(t.month, 'YYYY-MM'), t.amount::float8
+		FROM sales_targets t JOIN shops s ON s.id = t.shop_id
+		WHERE s.owner_id = $1 AND t.month = $2
+		ORDER BY t.shop_id, t.user_id NULLS FIRST
+	`, ownerID, month)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения планов продаж: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Target
+	for rows.Next() {
+		var t Target
+		if err := rows.Scan(&t.ShopID, &t.UserID, &t.Month, &t.Amount); err != nil {
+			return nil, fmt.Errorf("ошибка чтения плана продаж: %w", err)
+		}
+		list = append(list, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке планов продаж: %w", err)
+	}
+	return list, nil
+}
+
+// GetCategorySales — продажи и возвраты по продавцам, магазинам и категориям за период.
+// Продажа засчитывается продавцу чека (для старых чеков — кассиру).
+func (r *Repository) GetCategorySales(ctx context.Context, ownerID int, from, to time.Time) ([]CategorySales, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		WITH lines AS (
+			SELECT COALESCE(s.seller_id, s.cashier_id) AS user_id, s.shop_id, COALESCE(i.category, '') AS category,
+				si.total AS sold, 0::numeric AS returned
+			FROM sales s
+			JOIN shops sh ON sh.id = s.shop_id
+			JOIN sale_items si ON si.sale_id = s.id
+			LEFT JOIN items i ON i.id = si.item_id
+			WHERE sh.owner_id = $1 AND si.gift_card_id IS NULL AND s.created_at >= $2 AND s.created_at < $3
+			UNION ALL
+			SELECT COALESCE(s.seller_id, s.cashier_id), s.shop_id, COALESCE(i.category, ''), 0, ri.amount
+			FROM sale_returns rt
+			JOIN sale_return_items ri ON ri.return_id = rt.id
+			JOIN sale_items si ON si.id = ri.sale_item_id
+			JOIN sales s ON s.id = rt.sale_id
+			JOIN shops sh ON sh.id = s.shop_id
+			LEFT JOIN items i ON i.id = si.item_id
+			WHERE sh.owner_id = $1 AND rt.status <> 'failed' AND rt.created_at >= $2 AND rt.created_at < $3
+		)
+		SELECT user_id, shop_id, category, SUM(sold)::float8, SUM(returned)::float8
+		FROM lines
+		WHERE user_id IS NOT NULL
+		GROUP BY user_id, shop_id, category
+		ORDER BY user_id, shop_id, category
+	`, ownerID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения продаж по продавцам: %w", err)
+	}
+	defer rows.Close()
+
+	var list []CategorySales
+	for rows.Next() {
+		var c CategorySales
+		if err := rows.Scan(&c.UserID, &c.ShopID, &c.Category, &c.Sold, &c.Returned); err != nil {
+			return nil, fmt.Errorf("ошибка чтения продаж продавца: %w", err)
+		}
+		list = append(list, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке продаж продавцов: %w", err)
+	}
+	return list, nil
+}
+
+// GetEmployers — владельцы магазинов, в которых работает пользователь (включая его собственные)
+func (r *Repository) GetEmployers(ctx context.Context, userID int) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT DISTINCT s.owner_id FROM shops s
+		WHERE s.owner_id = $1 OR s.id IN (SELECT shop_id FROM employees WHERE user_id = $1)
+		ORDER BY s.owner_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения работодателей: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения работодателя: %w", err)
+		}
+		owners = append(owners, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке работодателей: %w", err)
+	}
+	return owners, nil
+}
@@ -0,0 +1,303 @@
+package commission
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/payment"
+	"crm-backend/internal/shop"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	shops     *shop.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository, shops *shop.Repository) *Service {
+	return &Service{repo: repo, employees: employees, shops: shops}
+}
+
+// checkStaff — магазин принадлежит владельцу, а пользователь (если указан) в нём работает
+func (s *Service) checkStaff(ctx context.Context, ownerID, shopID int, userID *int) error {
+	ok, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrShopNotFound
+	}
+	if userID == nil || *userID == ownerID {
+		return nil
+	}
+	ok, err = s.employees.IsEmployee(ctx, shopID, *userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotEmployee
+	}
+	return nil
+}
+
+func (s *Service) CreatePlan(ctx context.Context, ownerID int, p *Plan) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	p.OwnerID = ownerID
+	return s.repo.CreatePlan(ctx, p)
+}
+
+func (s *Service) UpdatePlan(ctx context.Context, ownerID int, p *Plan) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	p.OwnerID = ownerID
+	if err := s.repo.UpdatePlan(ctx, p); err != nil {
+		return err
+	}
+	updated, err := s.repo.GetPlan(ctx, ownerID, p.ID)
+	if err != nil {
+		return err
+	}
+	*p = *updated
+	return nil
+}
+
+func (s *Service) GetPlans(ctx context.Context, ownerID int) ([]Plan, error) {
+	return s.repo.GetPlans(ctx, ownerID)
+}
+
+// SetAssignment назначает план сотруднику магазина или всему магазину; plan_id = 0 снимает назначение
+func (s *Service) SetAssignment(ctx context.Context, ownerID int, a *Assignment) error {
+	if err := s.checkStaff(ctx, ownerID, a.ShopID, a.UserID); err != nil {
+		return err
+	}
+	if a.PlanID != 0 {
+		if _, err := s.repo.GetPlan(ctx, ownerID, a.PlanID); err != nil {
+			return err
+		}
+	}
+	return s.repo.SetAssignment(ctx, a)
+}
+
+func (s *Service) GetAssignments(ctx context.Context, ownerID int) ([]Assignment, error) {
+	return s.repo.GetAssignments(ctx, ownerID)
+}
+
+// SetTarget задаёт месячный план продаж сотрудника или магазина; amount = 0 снимает план
+func (s *Service) SetTarget(ctx context.Context, ownerID int, t *Target) error {
+	month, _, err := monthRange(t.Month)
+	if err != nil {
+		return err
+	}
+	if t.Amount < 0 {
+		return fmt.Errorf("план продаж не может быть отрицательным")
+	}
+	if err := s.checkStaff(ctx, ownerID, t.ShopID, t.UserID); err != nil {
+		return err
+	}
+	return s.repo.SetTarget(ctx, t, month)
+}
+
+func (s *Service) GetTargets(ctx context.Context, ownerID int, month string) ([]Target, error) {
+	from, _, err := monthRange(month)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetTargets(ctx, ownerID, from)
+}
+
+type staffKey struct {
+	shopID, userID int
+}
+
+// Report считает комиссии продавцов за месяц. План сотрудника в магазине важнее плана магазина.
+// shopID и userID сужают отчёт (0 — без отбора).
+func (s *Service) Report(ctx context.Context, ownerID int, month string, shopID, userID int) (*Report, error) {
+	from, to, err := monthRange(month)
+	if err != nil {
+		return nil, err
+	}
+	sales, err := s.repo.GetCategorySales(ctx, ownerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := s.repo.GetTargets(ctx, ownerID, from)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.repo.GetAssignments(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := s.repo.GetPlans(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.employees.GetStaffNames(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	shops, err := s.shops.GetShopsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	planByID := make(map[int]*Plan, len(plans))
+	for i := range plans {
+		planByID[plans[i].ID] = &plans[i]
+	}
+	planFor := make(map[staffKey]*Plan, len(assignments))
+	for _, a := range assignments {
+		key := staffKey{shopID: a.ShopID}
+		if a.UserID != nil {
+			key.userID = *a.UserID
+		}
+		planFor[key] = planByID[a.PlanID]
+	}
+
+	want := func(k staffKey) bool {
+		return (shopID == 0 || k.shopID == shopID) && (userID == 0 || k.userID == userID)
+	}
+
+	bySeller := make(map[staffKey][]CategorySales)
+	var order []staffKey
+	for _, c := range sales {
+		k := staffKey{shopID: c.ShopID, userID: c.UserID}
+		if !want(k) {
+			continue
+		}
+		if _, ok := bySeller[k]; !ok {
+			order = append(order, k)
+		}
+		bySeller[k] = append(bySeller[k], c)
+	}
+	targetFor := make(map[staffKey]float64)
+	for _, t := range targets {
+		k := staffKey{shopID: t.ShopID}
+		if t.UserID != nil {
+			k.userID = *t.UserID
+		}
+		targetFor[k] = t.Amount
+		// сотрудник с планом продаж попадает в отчёт, даже если ещё ничего не продал
+		if k.userID != 0 && want(k) {
+			if _, ok := bySeller[k]; !ok {
+				bySeller[k] = nil
+				order = append(order, k)
+			}
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].shopID != order[j].shopID {
+			return order[i].shopID < order[j].shopID
+		}
+		return order[i].userID < order[j].userID
+	})
+
+	shopNames := make(map[int]string, len(shops))
+	for _, sh := range shops {
+		shopNames[sh.ID] = sh.Name
+	}
+
+	report := &Report{Month: month, Statements: make([]Statement, 0, len(order)), Shops: []ShopProgress{}}
+	shopTotals := make(map[int]*ShopProgress)
+	for _, k := range order {
+		st := Statement{
+			UserID:       k.userID,
+			EmployeeName: names[k.userID],
+			ShopID:       k.shopID,
+			ShopName:     shopNames[k.shopID],
+			Month:        month,
+			Target:       targetFor[k],
+		}
+		plan := planFor[k]
+		if plan == nil {
+			plan = planFor[staffKey{shopID: k.shopID}]
+		}
+		st.calculate(plan, bySeller[k])
+		report.Statements = append(report.Statements, st)
+
+		sp, ok := shopTotals[k.shopID]
+		if !ok {
+			sp = &ShopProgress{ShopID: k.shopID, ShopName: shopNames[k.shopID]}
+			shopTotals[k.shopID] = sp
+		}
+		sp.Net += st.Net
+		sp.Commission += st.Commission
+	}
+	for _, sh := range shops {
+		sp := shopTotals[sh.ID]
+		target := targetFor[staffKey{shopID: sh.ID}]
+		if sp == nil && (target == 0 || !want(staffKey{shopID: sh.ID, userID: userID})) {
+			continue
+		}
+		if sp == nil {
+			sp = &ShopProgress{ShopID: sh.ID, ShopName: sh.Name}
+		}
+		sp.Net = payment.Round(sp.Net)
+		sp.Commission = payment.Round(sp.Commission)
+		sp.Target = target
+		if target > 0 {
+			sp.TargetDone = payment.Round(sp.Net / target * 100)
+		}
+		report.Shops = append(report.Shops, *sp)
+	}
+	return report, nil
+}
+
+// MyStatements — расчётные листы продавца за месяц во всех магазинах, где он работает
+func (s *Service) MyStatements(ctx context.Context, userID int, month string) ([]Statement, error) {
+	owners, err := s.repo.GetEmployers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	statements := []Statement{}
+	for _, ownerID := range owners {
+		report, err := s.Report(ctx, ownerID, month, 0, userID)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, report.Statements...)
+	}
+	return statements, nil
+}
+
+// WriteCSV выгружает расчётные листы для начисления зарплаты
+func WriteCSV(w io.Writer, statements []Statement) error {
+	money := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"user_id", "employee", "shop_id", "shop", "month", "plan", "sold", "returned", "net",
+		"commission", "target", "target_done_percent"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, st := range statements {
+		record := []string{
+			strconv.Itoa(st.UserID),
+			st.EmployeeName,
+			strconv.Itoa(st.ShopID),
+			st.ShopName,
+			st.Month,
+			st.PlanName,
+			money(st.Sold),
+			money(st.Returned),
+			money(st.Net),
+			money(st.Commission),
+			money(st.Target),
+			money(st.TargetDone),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,72 @@
+package customer
+
+import (
+	"strings"
+	"time"
+)
+
+// Customer — покупатель организации (владельца магазинов).
+// Телефон — основной ключ поиска на кассе, уникален в пределах организации.
+type Customer struct {
+	ID               int        `json:"id"`
+	OwnerID          int        `json:"owner_id"`
+	Name             string     `json:"name"`
+	Phone            string     `json:"phone"`
+	Email            string     `json:"email,omitempty"`
+	TelegramChatID   string     `json:"telegram_chat_id,omitempty"`
+	Birthday         *time.Time `json:"birthday,omitempty"`
+	PreferredSizes   []string   `json:"preferred_sizes"`
+	Notes            string     `json:"notes,omitempty"`
+	Tags             []string   `json:"tags"`
+	MarketingConsent bool       `json:"marketing_consent"`
+	ConsentAt        *time.Time `json:"consent_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	// AnonymizedAt — персональные данные удалены по запросу покупателя
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+	// MergedInto — карточка объединена с другой и скрыта из поиска
+	MergedInto *int `json:"merged_into,omitempty"`
+}
+
+// Interaction — контакт с покупателем вне чека: звонок, сообщение, визит, заметка
+type Interaction struct {
+	ID         int       `json:"id"`
+	CustomerID int       `json:"customer_id"`
+	ShopID     *int      `json:"shop_id,omitempty"`
+	ItemID     *int      `json:"item_id,omitempty"`
+	Type       string    `json:"type"`
+	Text       string    `json:"text"`
+	CreatedBy  int       `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+var interactionTypes = map[string]bool{"note": true, "call": true, "message": true, "visit": true}
+
+// TimelineEvent — событие в истории покупателя: покупка, возврат или контакт
+type TimelineEvent struct {
+	Type      string    `json:"type"`
+	RefID     int       `json:"ref_id"`
+	ShopID    int       `json:"shop_id"`
+	Amount    float64   `json:"amount,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NormalizePhone оставляет только цифры; казахстанский формат 8XXXXXXXXXX приводится к 7XXXXXXXXXX,
+// номер без кода страны (10 цифр) дополняется кодом 7
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	switch {
+	case len(digits) == 11 && digits[0] == '8':
+		digits = "7" + digits[1:]
+	case len(digits) == 10:
+		digits = "7" + digits
+	}
+	return digits
+}
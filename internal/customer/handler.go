@@ -0,0 +1,444 @@
+package customer
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrCustomerNotFound), errors.Is(err, ErrDuplicateNotFound), errors.Is(err, ErrMergeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrPhoneTaken), errors.Is(err, ErrAnonymized), errors.Is(err, ErrMerged),
+		errors.Is(err, ErrUndoExpired), errors.Is(err, ErrUndoBlocked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func customerParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	customerID, err := strconv.Atoi(chi.URLParam(r, "customer_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID покупателя", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, customerID, true
+}
+
+// CreateCustomer godoc
+// @Summary Create customer
+// @Description Создаёт карточку покупателя. Покупатели общие для всех магазинов владельца, телефон уникален.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer body Customer true "Покупатель"
+// @Success 201 {object} Customer
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 409 {string} string "покупатель с таким телефоном уже есть"
+// @Router /shops/{id}/customers [post]
+func (h *Handler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var c Customer
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateCustomer(r.Context(), claims.ID, shopID, &c); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// SearchCustomers godoc
+// @Summary Search customers
+// @Description Поиск покупателей по части телефона или имени (до 50 результатов).
+// @Tags customers
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param q query string false "Телефон или имя"
+// @Success 200 {array} Customer
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/customers [get]
+func (h *Handler) SearchCustomers(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	customers, err := h.service.Search(r.Context(), claims.ID, shopID, r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(customers)
+}
+
+// GetCustomer godoc
+// @Summary Get customer
+// @Description Карточка покупателя.
+// @Tags customers
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Customer ID"
+// @Success 200 {object} Customer
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Router /shops/{id}/customers/{customer_id} [get]
+func (h *Handler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.service.GetCustomer(r.Context(), claims.ID, shopID, customerID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// UpdateCustomer godoc
+// @Summary Update customer
+// @Description Обновляет карточку покупателя, включая согласие на рассылки.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Customer ID"
+// @Param customer body Customer true "Покупатель"
+// @Success 200 {object} Customer
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Failure 409 {string} string "покупатель с таким телефоном уже есть"
+// @Router /shops/{id}/customers/{customer_id} [put]
+func (h *Handler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	var c Customer
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	c.ID = customerID
+
+	if err := h.service.UpdateCustomer(r.Context(), claims.ID, shopID, &c); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// AddInteraction godoc
+// @Summary Add customer interaction
+// @Description Записывает контакт с покупателем: note, call, message или visit.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Customer ID"
+// @Param interaction body Interaction true "Контакт"
+// @Success 201 {object} Interaction
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Router /shops/{id}/customers/{customer_id}/interactions [post]
+func (h *Handler) AddInteraction(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	var in Interaction
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.AddInteraction(r.Context(), claims.ID, shopID, customerID, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(in)
+}
+
+// GetTimeline godoc
+// @Summary Get customer timeline
+// @Description История покупателя: покупки, возвраты и контакты, новые сверху.
+// @Tags customers
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Customer ID"
+// @Success 200 {array} TimelineEvent
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Router /shops/{id}/customers/{customer_id}/timeline [get]
+func (h *Handler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.service.GetTimeline(r.Context(), claims.ID, shopID, customerID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+// shopAndID — ID магазина и ID объекта из пути запроса
+func shopAndID(w http.ResponseWriter, r *http.Request, param, message string) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, param))
+	if err != nil {
+		http.Error(w, message, http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, id, true
+}
+
+// GetDuplicates godoc
+// @Summary List possible duplicate customers
+// @Description Пары карточек, похожих на одного человека, с оценкой сходства (0–1) и совпавшими признаками: phone, email, name, birthday. Список обновляется фоновой задачей раз в сутки; detect=true — пересчитать сейчас.
+// @Tags customers
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param detect query bool false "Пересчитать дубли перед выдачей"
+// @Success 200 {array} Duplicate
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/customers/duplicates [get]
+func (h *Handler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	detect, _ := strconv.ParseBool(r.URL.Query().Get("detect"))
+
+	pairs, err := h.service.GetDuplicates(r.Context(), claims.ID, shopID, detect)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pairs)
+}
+
+// DismissDuplicate godoc
+// @Summary Dismiss duplicate pair
+// @Description Отмечает, что карточки принадлежат разным людям. Пара больше не появится в списке дублей.
+// @Tags customers
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param pair_id path int true "Duplicate pair ID"
+// @Success 204
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "пара дублей не найдена"
+// @Router /shops/{id}/customers/duplicates/{pair_id}/dismiss [post]
+func (h *Handler) DismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, pairID, ok := shopAndID(w, r, "pair_id", "неправильный ID пары")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DismissDuplicate(r.Context(), claims.ID, shopID, pairID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeCustomers godoc
+// @Summary Merge duplicate into customer
+// @Description Переносит чеки, баллы лояльности, контакты и сообщения рассылок с дубля на карточку customer_id. Пустые поля карточки заполняются из дубля, размеры и теги объединяются; согласие на рассылки берётся с карточки, изменённой последней. Дубль скрывается. Объединение можно отменить в течение 7 дней.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Основная карточка"
+// @Param merge body MergeRequest true "Дубль"
+// @Success 200 {object} Merge
+// @Failure 400 {string} string "нельзя объединить покупателя с самим собой"
+// @Failure 404 {string} string "покупатель не найден"
+// @Failure 409 {string} string "карточка объединена с другим покупателем"
+// @Router /shops/{id}/customers/{customer_id}/merge [post]
+func (h *Handler) MergeCustomers(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.service.Merge(r.Context(), claims.ID, shopID, customerID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m)
+}
+
+// UndoMerge godoc
+// @Summary Undo customer merge
+// @Description Возвращает дублю перенесённые чеки, баллы, контакты и сообщения и восстанавливает основную карточку. Доступно в течение 7 дней, если после объединения не было операций с баллами.
+// @Tags customers
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param merge_id path int true "Merge ID"
+// @Success 200 {object} Merge
+// @Failure 404 {string} string "объединение не найдено"
+// @Failure 409 {string} string "объединение уже отменено или срок отмены истёк"
+// @Router /shops/{id}/customers/merges/{merge_id}/undo [post]
+func (h *Handler) UndoMerge(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, mergeID, ok := shopAndID(w, r, "merge_id", "неправильный ID объединения")
+	if !ok {
+		return
+	}
+
+	m, err := h.service.UndoMerge(r.Context(), claims.ID, shopID, mergeID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,239 @@
+package customer
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DuplicateOpen      = "open"
+	DuplicateDismissed = "dismissed"
+	DuplicateMerged    = "merged"
+)
+
+const (
+	// duplicateThreshold — минимальная оценка, с которой пара попадает в список возможных дублей
+	duplicateThreshold = 0.4
+	// maxBucket — группы с одинаковым ключом больше этого размера не сравниваются попарно
+	// (например, тысяча покупателей с одной датой рождения 1 января)
+	maxBucket = 50
+	// mergeUndoWindow — сколько времени объединение можно отменить
+	mergeUndoWindow = 7 * 24 * time.Hour
+)
+
+// Duplicate — пара карточек, похожих на одного и того же человека.
+// Reasons — совпавшие признаки: phone, email, name, birthday.
+type Duplicate struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"owner_id"`
+	CustomerID  int       `json:"customer_id"`
+	DuplicateID int       `json:"duplicate_id"`
+	Score       float64   `json:"score"`
+	Reasons     []string  `json:"reasons"`
+	Status      string    `json:"status"`
+	DetectedAt  time.Time `json:"detected_at"`
+	Customer    *Customer `json:"customer,omitempty"`
+	Duplicate   *Customer `json:"duplicate,omitempty"`
+}
+
+// Merge — объединение дубля с основной карточкой. Moved хранит, что именно перенесено,
+// чтобы в течение окна отмены вернуть всё обратно.
+type Merge struct {
+	ID          int          `json:"id"`
+	OwnerID     int          `json:"owner_id"`
+	SurvivorID  int          `json:"survivor_id"`
+	DuplicateID int          `json:"duplicate_id"`
+	MergedBy    int          `json:"merged_by"`
+	Moved       MovedRecords `json:"moved"`
+	MergedAt    time.Time    `json:"merged_at"`
+	UndoUntil   time.Time    `json:"undo_until"`
+	UndoneAt    *time.Time   `json:"undone_at,omitempty"`
+}
+
+type MovedRecords struct {
+	Sales          []int `json:"sales"`
+	LoyaltyEntries []int `json:"loyalty_entries"`
+	Interactions   []int `json:"interactions"`
+	Deliveries     []int `json:"deliveries"`
+	Tasks          []int `json:"tasks"`
+	Waitlist       []int `json:"waitlist"`
+	Reservations   []int `json:"reservations"`
+}
+
+type MergeRequest struct {
+	// DuplicateID — карточка, которая вливается в текущую и скрывается
+	DuplicateID int `json:"duplicate_id"`
+}
+
+// NormalizeEmail — email без пробелов в нижнем регистре
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// phoneKey — последние 10 цифр: номер без кода страны
+func phoneKey(phone string) string {
+	if len(phone) < 10 {
+		return phone
+	}
+	return phone[len(phone)-10:]
+}
+
+func nameTokens(name string) []string {
+	return strings.Fields(strings.ToLower(strings.ReplaceAll(name, "ё", "е")))
+}
+
+// levenshtein — расстояние редактирования по символам (не байтам)
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// nameSimilarity сравнивает имена без учёта регистра и порядка слов («Иванов Иван» = «Иван Иванов»)
+func nameSimilarity(a, b string) float64 {
+	ta, tb := nameTokens(a), nameTokens(b)
+	direct := stringSimilarity(strings.Join(ta, " "), strings.Join(tb, " "))
+	sort.Strings(ta)
+	sort.Strings(tb)
+	return max(direct, stringSimilarity(strings.Join(ta, " "), strings.Join(tb, " ")))
+}
+
+// similarity — оценка от 0 до 1, насколько две карточки похожи на одного человека
+func similarity(a, b Customer) (float64, []string) {
+	var score float64
+	var reasons []string
+	if a.Phone != "" && phoneKey(a.Phone) == phoneKey(b.Phone) {
+		score += 0.6
+		reasons = append(reasons, "phone")
+	}
+	if a.Email != "" && NormalizeEmail(a.Email) == NormalizeEmail(b.Email) {
+		score += 0.5
+		reasons = append(reasons, "email")
+	}
+	if sim := nameSimilarity(a.Name, b.Name); sim >= 0.8 {
+		score += 0.3 * sim
+		reasons = append(reasons, "name")
+	}
+	if a.Birthday != nil && b.Birthday != nil && a.Birthday.Equal(*b.Birthday) {
+		score += 0.1
+		reasons = append(reasons, "birthday")
+	}
+	return min(score, 1), reasons
+}
+
+// findDuplicates ищет похожие пары. Попарно сравниваются только карточки с общим ключом
+// (номер без кода страны, email или дата рождения), чтобы не сравнивать всех со всеми.
+func findDuplicates(customers []Customer) []Duplicate {
+	buckets := make(map[string][]int)
+	for i, c := range customers {
+		if c.Phone != "" {
+			buckets["p:"+phoneKey(c.Phone)] = append(buckets["p:"+phoneKey(c.Phone)], i)
+		}
+		if email := NormalizeEmail(c.Email); email != "" {
+			buckets["e:"+email] = append(buckets["e:"+email], i)
+		}
+		if c.Birthday != nil {
+			key := "b:" + c.Birthday.Format("2006-01-02")
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	var pairs []Duplicate
+	for _, idx := range buckets {
+		if len(idx) < 2 || len(idx) > maxBucket {
+			continue
+		}
+		for x := 0; x < len(idx); x++ {
+			for y := x + 1; y < len(idx); y++ {
+				a, b := customers[idx[x]], customers[idx[y]]
+				if a.ID > b.ID {
+					a, b = b, a
+				}
+				key := [2]int{a.ID, b.ID}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				score, reasons := similarity(a, b)
+				if score < duplicateThreshold {
+					continue
+				}
+				pairs = append(pairs, Duplicate{
+					OwnerID:     a.OwnerID,
+					CustomerID:  a.ID,
+					DuplicateID: b.ID,
+					Score:       score,
+					Reasons:     reasons,
+				})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Score > pairs[j].Score })
+	return pairs
+}
+
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	out := make([]string, 0, len(a)+len(b))
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// mergeFields — карточка после объединения: пустые поля основной карточки заполняются из дубля,
+// размеры и теги объединяются, заметки склеиваются. Согласие на рассылки берётся
+// с карточки, изменённой последней: так отписка на любой из карточек не теряется.
+func mergeFields(survivor, dup Customer) Customer {
+	out := survivor
+	if out.Email == "" {
+		out.Email = dup.Email
+	}
+	if out.TelegramChatID == "" {
+		out.TelegramChatID = dup.TelegramChatID
+	}
+	if out.Birthday == nil {
+		out.Birthday = dup.Birthday
+	}
+	out.PreferredSizes = union(survivor.PreferredSizes, dup.PreferredSizes)
+	out.Tags = union(survivor.Tags, dup.Tags)
+	switch {
+	case out.Notes == "":
+		out.Notes = dup.Notes
+	case dup.Notes != "" && dup.Notes != out.Notes:
+		out.Notes = out.Notes + "\n" + dup.Notes
+	}
+	if dup.UpdatedAt.After(survivor.UpdatedAt) {
+		out.MarketingConsent = dup.MarketingConsent
+		out.ConsentAt = dup.ConsentAt
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package customer
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"+7 (701) 234-56-78", "77012345678"},
+		{"8 701 234 56 78", "77012345678"},
+		{"7012345678", "77012345678"},
+		{"+996 555 123 456", "996555123456"},
+		{"12-34", "1234"},
+	}
+	for _, tt := range tests {
+		if got := NormalizePhone(tt.in); got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPhoneKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		phone string
+		want  string
+	}{
+		{name: "казахстанский номер без кода страны", phone: "77012345678", want: "7012345678"},
+		{name: "номер с другим кодом страны", phone: "996555123456", want: "6555123456"},
+		{name: "ровно десять цифр", phone: "7012345678", want: "7012345678"},
+		{name: "короткий номер не обрезается", phone: "1234", want: "1234"},
+		{name: "пустой номер", phone: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := phoneKey(tt.phone); got != tt.want {
+			t.Errorf("%s: phoneKey(%q) = %q, ожидалось %q", tt.name, tt.phone, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "одинаковые", a: "Иван Иванов", b: "Иван Иванов", want: 1},
+		{name: "порядок слов и регистр", a: "Иванов Иван", b: "иван ИВАНОВ", want: 1},
+		{name: "ё и е", a: "Пётр Сидоров", b: "Петр Сидоров", want: 1},
+		{name: "лишние пробелы", a: "  Анна   Ли ", b: "Анна Ли", want: 1},
+		{name: "одна опечатка", a: "Мария", b: "Мариа", want: 0.8},
+		{name: "разные имена", a: "Анна", b: "Олег", want: 0},
+		{name: "пустые", a: "", b: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := nameSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: nameSimilarity(%q, %q) = %v, ожидалось %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	birthday := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	otherBirthday := time.Date(1991, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		a, b    Customer
+		score   float64
+		reasons []string
+	}{
+		{
+			name:    "тот же номер с другим кодом страны",
+			a:       Customer{Name: "Иван", Phone: "77012345678"},
+			b:       Customer{Name: "Олег", Phone: "87012345678"},
+			score:   0.6,
+			reasons: []string{"phone"},
+		},
+		{
+			name:    "email без учёта регистра",
+			a:       Customer{Name: "Анна", Phone: "77010000001", Email: "Anna@Mail.kz"},
+			b:       Customer{Name: "Ольга", Phone: "77010000002", Email: " anna@mail.kz"},
+			score:   0.5,
+			reasons: []string{"email"},
+		},
+		{
+			name:    "похожее имя и день рождения",
+			a:       Customer{Name: "Иванов Иван", Phone: "77010000001", Birthday: &birthday},
+			b:       Customer{Name: "Иван Иванов", Phone: "77010000002", Birthday: &birthday},
+			score:   0.4,
+			reasons: []string{"name", "birthday"},
+		},
+		{
+			name:    "только день рождения — не дубль",
+			a:       Customer{Name: "Анна", Phone: "77010000001", Birthday: &birthday},
+			b:       Customer{Name: "Олег", Phone: "77010000002", Birthday: &birthday},
+			score:   0.1,
+			reasons: []string{"birthday"},
+		},
+		{
+			name:    "разные дни рождения не считаются",
+			a:       Customer{Name: "Иван Иванов", Phone: "77010000001", Birthday: &birthday},
+			b:       Customer{Name: "Иван Иванов", Phone: "77010000002", Birthday: &otherBirthday},
+			score:   0.3,
+			reasons: []string{"name"},
+		},
+		{
+			name:    "совпадает всё — оценка не больше единицы",
+			a:       Customer{Name: "Иван", Phone: "77012345678", Email: "i@x.kz", Birthday: &birthday},
+			b:       Customer{Name: "Иван", Phone: "77012345678", Email: "i@x.kz", Birthday: &birthday},
+			score:   1,
+			reasons: []string{"phone", "email", "name", "birthday"},
+		},
+		{
+			name:  "пустые телефон и email не совпадают",
+			a:     Customer{Name: "Анна"},
+			b:     Customer{Name: "Олег"},
+			score: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, reasons := similarity(tt.a, tt.b)
+			if math.Abs(score-tt.score) > 1e-9 {
+				t.Errorf("оценка = %v, ожидалась %v", score, tt.score)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("признаки = %v, ожидались %v", reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	birthday := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	customers := []Customer{
+		{ID: 3, OwnerID: 1, Name: "Иван Иванов", Phone: "87012345678"},
+		{ID: 1, OwnerID: 1, Name: "Иванов Иван", Phone: "77012345678", Email: "ivan@mail.kz"},
+		{ID: 2, OwnerID: 1, Name: "Иван И.", Phone: "77019999999", Email: "IVAN@mail.kz"},
+		// Общий только день рождения — оценка ниже порога
+		{ID: 4, OwnerID: 1, Name: "Анна", Phone: "77010000004", Birthday: &birthday},
+		{ID: 5, OwnerID: 1, Name: "Олег", Phone: "77010000005", Birthday: &birthday},
+	}
+
+	got := findDuplicates(customers)
+
+	type pair struct {
+		customer, duplicate int
+		reasons             []string
+	}
+	var pairs []pair
+	for _, d := range got {
+		if d.OwnerID != 1 {
+			t.Errorf("пара %d-%d: owner_id = %d", d.CustomerID, d.DuplicateID, d.OwnerID)
+		}
+		pairs = append(pairs, pair{d.CustomerID, d.DuplicateID, d.Reasons})
+	}
+	want := []pair{
+		// Меньший ID всегда первый, пары отсортированы по убыванию оценки
+		{1, 3, []string{"phone", "name"}},
+		{1, 2, []string{"email"}},
+	}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("пары = %+v, ожидались %+v", pairs, want)
+	}
+}
+
+func TestFindDuplicatesSkipsHugeBuckets(t *testing.T) {
+	birthday := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	var customers []Customer
+	for i := 1; i <= maxBucket+1; i++ {
+		customers = append(customers, Customer{ID: i, Name: "Айгерим", Phone: fmt.Sprintf("7701%07d", i), Birthday: &birthday})
+	}
+	if got := findDuplicates(customers); len(got) != 0 {
+		t.Errorf("найдено %d пар в группе больше %d карточек", len(got), maxBucket)
+	}
+}
@@ -0,0 +1,664 @@
+package customer
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS customers (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			phone VARCHAR(20) NOT NULL,
+			email VARCHAR(255),
+			birthday DATE,
+			preferred_sizes TEXT[] NOT NULL DEFAULT '{}',
+			notes TEXT,
+			tags TEXT[] NOT NULL DEFAULT '{}',
+			marketing_consent BOOLEAN NOT NULL DEFAULT FALSE,
+			consent_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (owner_id, phone)
+		);
+
+		CREATE TABLE IF NOT EXISTS customer_interactions (
+			id SERIAL PRIMARY KEY,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			shop_id INT REFERENCES shops(id) ON DELETE SET NULL,
+			type VARCHAR(20) NOT NULL,
+			text TEXT NOT NULL,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		ALTER TABLE customers ADD COLUMN IF NOT EXISTS telegram_chat_id VARCHAR(64);
+		ALTER TABLE customer_interactions ADD COLUMN IF NOT EXISTS item_id INT REFERENCES items(id) ON DELETE SET NULL;
+		ALTER TABLE customers ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE customers ADD COLUMN IF NOT EXISTS merged_into INT REFERENCES customers(id) ON DELETE SET NULL;
+
+		CREATE TABLE IF NOT EXISTS customer_duplicates (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			duplicate_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			score NUMERIC(4, 3) NOT NULL,
+			reasons TEXT[] NOT NULL DEFAULT '{}',
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			detected_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (customer_id, duplicate_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS customer_merges (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			survivor_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			duplicate_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			survivor_before JSONB NOT NULL,
+			moved JSONB NOT NULL,
+			merged_by INT REFERENCES users(id) ON DELETE SET NULL,
+			merged_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			undo_until TIMESTAMP WITH TIME ZONE NOT NULL,
+			undone_at TIMESTAMP WITH TIME ZONE
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции customers: %w", err)
+	}
+	fmt.Println("Миграция customers выполнена успешно")
+	return nil
+}
+
+const customerColumns = `
+	id, owner_id, name, phone, COALESCE(email, ''), COALESCE(telegram_chat_id, ''), birthday, preferred_sizes, COALESCE(notes, ''), tags,
+	marketing_consent, consent_at, created_at, updated_at, anonymized_at, merged_into
+`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCustomer(row scanner) (*Customer, error) {
+	var c Customer
+	err := row.Scan(&c.ID, &c.OwnerID, &c.Name, &c.Phone, &c.Email, &c.TelegramChatID, &c.Birthday, &c.PreferredSizes, &c.Notes,
+		&c.Tags, &c.MarketingConsent, &c.ConsentAt, &c.CreatedAt, &c.UpdatedAt, &c.AnonymizedAt, &c.MergedInto)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func scanCustomers(rows pgx.Rows) ([]Customer, error) {
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения покупателя: %w", err)
+		}
+		customers = append(customers, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке покупателей: %w", err)
+	}
+	return customers, nil
+}
+
+func (r *Repository) CreateCustomer(ctx context.Context, c *Customer) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO customers
+			(owner_id, name, phone, email, telegram_chat_id, birthday, preferred_sizes, notes, tags, marketing_consent, consent_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, NULLIF($8, ''), $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`, c.OwnerID, c.Name, c.Phone, c.Email, c.TelegramChatID, c.Birthday, c.PreferredSizes, c.Notes, c.Tags,
+		c.MarketingConsent, c.ConsentAt).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания покупателя: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) UpdateCustomer(ctx context.Context, c *Customer) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		UPDATE customers
+		SET name = $1, phone = $2, email = NULLIF($3, ''), telegram_chat_id = NULLIF($4, ''), birthday = $5,
+		    preferred_sizes = $6, notes = NULLIF($7, ''), tags = $8, marketing_consent = $9, consent_at = $10,
+		    updated_at = NOW()
+		WHERE id = $11 AND owner_id = $12
+		RETURNING updated_at
+	`, c.Name, c.Phone, c.Email, c.TelegramChatID, c.Birthday, c.PreferredSizes, c.Notes, c.Tags, c.MarketingConsent,
+		c.ConsentAt, c.ID, c.OwnerID).Scan(&c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления покупателя (ID=%d): %w", c.ID, err)
+	}
+	return nil
+}
+
+// GetCustomer — покупатель организации; чужих покупателей не отдаём
+func (r *Repository) GetCustomer(ctx context.Context, ownerID, id int) (*Customer, error) {
+	c, err := scanCustomer(r.db.Conn.QueryRow(ctx, `
+		SELECT `+customerColumns+` FROM customers WHERE id = $1 AND owner_id = $2
+	`, id, ownerID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения покупателя по ID=%d: %w", id, err)
+	}
+	return c, nil
+}
+
+// Search ищет по части телефона (только цифры) или имени
+func (r *Repository) Search(ctx context.Context, ownerID int, query, phoneDigits string, limit int) ([]Customer, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+customerColumns+`
+		FROM customers
+		WHERE owner_id = $1 AND anonymized_at IS NULL AND merged_into IS NULL
+		  AND ($2 = '' OR name ILIKE '%' || $2 || '%' OR ($3 <> '' AND phone LIKE '%' || $3 || '%'))
+		ORDER BY name
+		LIMIT $4
+	`, ownerID, query, phoneDigits, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска покупателей: %w", err)
+	}
+	return scanCustomers(rows)
+}
+
+func (r *Repository) AddInteraction(ctx context.Context, in *Interaction) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO customer_interactions (customer_id, shop_id, item_id, type, text, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, in.CustomerID, in.ShopID, in.ItemID, in.Type, in.Text, in.CreatedBy).Scan(&in.ID, &in.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения контакта с покупателем: %w", err)
+	}
+	return nil
+}
+
+// GetTimeline — покупки, возвраты и контакты покупателя, новые сверху
+func (r *Repository) GetTimeline(ctx context.Context, customerID int) ([]TimelineEvent, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT 'sale', id, shop_id, total, status, created_at
+		FROM sales
+		WHERE customer_id = $1
+		UNION ALL
+		SELECT 'return', sr.id, s.shop_id, sr.amount, COALESCE(sr.reason, ''), sr.created_at
+		FROM sale_returns sr
+		JOIN sales s ON s.id = sr.sale_id
+		WHERE s.customer_id = $1 AND sr.status <> 'failed'
+		UNION ALL
+		SELECT type, id, COALESCE(shop_id, 0), 0, text, created_at
+		FROM customer_interactions
+		WHERE customer_id = $1
+		ORDER BY 6 DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории покупателя: %w", err)
+	}
+	defer rows.Close()
+
+	var events []TimelineEvent
+	for rows.Next() {
+		var e TimelineEvent
+		if err := rows.Scan(&e.Type, &e.RefID, &e.ShopID, &e.Amount, &e.Summary, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения истории покупателя: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке истории покупателя: %w", err)
+	}
+	return events, nil
+}
+
+// BelongsTo — покупатель относится к организации владельца и не объединён с другой карточкой
+func (r *Repository) BelongsTo(ctx context.Context, customerID, ownerID int) (bool, error) {
+	var exists bool
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM customers WHERE id = $1 AND owner_id = $2 AND merged_into IS NULL)
+	`, customerID, ownerID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки покупателя: %w", err)
+	}
+	return exists, nil
+}
+
+// RevokeConsent — покупатель отписался от рассылок
+func (r *Repository) RevokeConsent(ctx context.Context, customerID int) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE customers SET marketing_consent = FALSE, consent_at = NULL, updated_at = NOW() WHERE id = $1
+	`, customerID)
+	if err != nil {
+		return fmt.Errorf("ошибка отписки покупателя ID=%d: %w", customerID, err)
+	}
+	return nil
+}
+
+// GetCustomersByIDs — покупатели организации по списку ID (например, участники сегмента)
+func (r *Repository) GetCustomersByIDs(ctx context.Context, ownerID int, ids []int) ([]Customer, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+customerColumns+` FROM customers WHERE owner_id = $1 AND id = ANY($2) ORDER BY id
+	`, ownerID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения покупателей: %w", err)
+	}
+	return scanCustomers(rows)
+}
+
+func (r *Repository) GetInteractions(ctx context.Context, customerID int) ([]Interaction, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, customer_id, shop_id, item_id, type, text, COALESCE(created_by, 0), created_at
+		FROM customer_interactions
+		WHERE customer_id = $1
+		ORDER BY created_at DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения контактов с покупателем: %w", err)
+	}
+	defer rows.Close()
+
+	var interactions []Interaction
+	for rows.Next() {
+		var in Interaction
+		if err := rows.Scan(&in.ID, &in.CustomerID, &in.ShopID, &in.ItemID, &in.Type, &in.Text, &in.CreatedBy, &in.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения контакта с покупателем: %w", err)
+		}
+		interactions = append(interactions, in)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке контактов с покупателем: %w", err)
+	}
+	return interactions, nil
+}
+
+// Anonymize стирает персональные данные покупателя. Запись остаётся, чтобы чеки и баллы
+// по-прежнему ссылались на неё и отчёты по продажам не менялись.
+// Телефон заменяется на служебное значение: он уникален в пределах организации.
+func (r *Repository) Anonymize(ctx context.Context, ownerID, id int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `
+		UPDATE customers
+		SET name = 'Удалённый покупатель', phone = 'anon-' || id, email = NULL, telegram_chat_id = NULL,
+		    birthday = NULL, preferred_sizes = '{}', notes = NULL, tags = '{}',
+		    marketing_consent = FALSE, consent_at = NULL, anonymized_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND owner_id = $2
+	`, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("ошибка обезличивания покупателя ID=%d: %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("покупатель ID=%d: %w", id, pgx.ErrNoRows)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM customer_interactions WHERE customer_id = $1`, id); err != nil {
+		return fmt.Errorf("ошибка удаления контактов с покупателем ID=%d: %w", id, err)
+	}
+	// В задачах по покупателю тоже бывают имена и телефоны
+	if _, err := tx.Exec(ctx, `DELETE FROM tasks WHERE customer_id = $1`, id); err != nil {
+		return fmt.Errorf("ошибка удаления задач по покупателю ID=%d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка обезличивания покупателя ID=%d: %w", id, err)
+	}
+	return nil
+}
+
+// GetActiveCustomers — карточки организации, среди которых ищутся дубли
+func (r *Repository) GetActiveCustomers(ctx context.Context, ownerID int) ([]Customer, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+customerColumns+`
+		FROM customers
+		WHERE owner_id = $1 AND anonymized_at IS NULL AND merged_into IS NULL
+		ORDER BY id
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения покупателей: %w", err)
+	}
+	return scanCustomers(rows)
+}
+
+func (r *Repository) GetOwnersWithCustomers(ctx context.Context) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `SELECT DISTINCT owner_id FROM customers ORDER BY owner_id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения организаций: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения организации: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке организаций: %w", err)
+	}
+	return ids, nil
+}
+
+// SaveDuplicates обновляет список возможных дублей организации. Отклонённые пары остаются отклонёнными,
+// открытые пары, которые больше не находятся, удаляются.
+func (r *Repository) SaveDuplicates(ctx context.Context, ownerID int, pairs []Duplicate) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	keep := make([]int, 0, len(pairs))
+	for _, p := range pairs {
+		var id int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO customer_duplicates (owner_id, customer_id, duplicate_id, score, reasons)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (customer_id, duplicate_id) DO UPDATE
+			SET score = EXCLUDED.score, reasons = EXCLUDED.reasons, detected_at = NOW()
+			RETURNING id
+		`, ownerID, p.CustomerID, p.DuplicateID, p.Score, p.Reasons).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения дубля покупателя: %w", err)
+		}
+		keep = append(keep, id)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM customer_duplicates WHERE owner_id = $1 AND status = $2 AND NOT (id = ANY($3))
+	`, ownerID, DuplicateOpen, keep)
+	if err != nil {
+		return fmt.Errorf("ошибка очистки дублей покупателей: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения дублей покупателей: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetDuplicates(ctx context.Context, ownerID int, status string) ([]Duplicate, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, owner_id, customer_id, duplicate_id, score, reasons, status, detected_at
+		FROM customer_duplicates
+		WHERE owner_id = $1 AND status = $2
+		ORDER BY score DESC, id
+	`, ownerID, status)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения дублей покупателей: %w", err)
+	}
+	defer rows.Close()
+
+	var pairs []Duplicate
+	for rows.Next() {
+		var d Duplicate
+		if err := rows.Scan(&d.ID, &d.OwnerID, &d.CustomerID, &d.DuplicateID, &d.Score, &d.Reasons, &d.Status, &d.DetectedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения дубля покупателя: %w", err)
+		}
+		pairs = append(pairs, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке дублей покупателей: %w", err)
+	}
+	return pairs, nil
+}
+
+func (r *Repository) SetDuplicateStatus(ctx context.Context, ownerID, id int, status string) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE customer_duplicates SET status = $1 WHERE id = $2 AND owner_id = $3
+	`, status, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления дубля покупателя: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrDuplicateNotFound
+	}
+	return nil
+}
+
+// lockCustomers блокирует карточки до конца транзакции; порядок по id исключает взаимоблокировки
+func lockCustomers(ctx context.Context, tx pgx.Tx, ownerID int, ids ...int) (map[int]Customer, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT `+customerColumns+` FROM customers WHERE owner_id = $1 AND id = ANY($2) ORDER BY id FOR UPDATE
+	`, ownerID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка блокировки покупателей: %w", err)
+	}
+	customers, err := scanCustomers(rows)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]Customer, len(customers))
+	for _, c := range customers {
+		byID[c.ID] = c
+	}
+	for _, id := range ids {
+		if _, ok := byID[id]; !ok {
+			return nil, ErrCustomerNotFound
+		}
+	}
+	return byID, nil
+}
+
+func collectIDs(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]int, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func saveFields(ctx context.Context, tx pgx.Tx, c Customer) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE customers
+		SET email = NULLIF($1, ''), telegram_chat_id = NULLIF($2, ''), birthday = $3, preferred_sizes = $4,
+		    notes = NULLIF($5, ''), tags = $6, marketing_consent = $7, consent_at = $8, updated_at = NOW()
+		WHERE id = $9
+	`, c.Email, c.TelegramChatID, c.Birthday, c.PreferredSizes, c.Notes, c.Tags, c.MarketingConsent, c.ConsentAt, c.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления покупателя (ID=%d): %w", c.ID, err)
+	}
+	return nil
+}
+
+// Merge переносит чеки, баллы, контакты и сообщения рассылок с дубля на основную карточку
+// и скрывает дубль — всё в одной транзакции.
+func (r *Repository) Merge(ctx context.Context, m *Merge) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	locked, err := lockCustomers(ctx, tx, m.OwnerID, m.SurvivorID, m.DuplicateID)
+	if err != nil {
+		return err
+	}
+	survivor, dup := locked[m.SurvivorID], locked[m.DuplicateID]
+	for _, c := range []Customer{survivor, dup} {
+		if c.MergedInto != nil {
+			return ErrMerged
+		}
+		if c.AnonymizedAt != nil {
+			return ErrAnonymized
+		}
+	}
+
+	if err := saveFields(ctx, tx, mergeFields(survivor, dup)); err != nil {
+		return err
+	}
+
+	moves := []struct {
+		dst   *[]int
+		query string
+	}{
+		{&m.Moved.Sales, `UPDATE sales SET customer_id = $1 WHERE customer_id = $2 RETURNING id`},
+		{&m.Moved.LoyaltyEntries, `UPDATE loyalty_entries SET customer_id = $1 WHERE customer_id = $2 RETURNING id`},
+		{&m.Moved.Interactions, `UPDATE customer_interactions SET customer_id = $1 WHERE customer_id = $2 RETURNING id`},
+		// сообщения той же кампании, что уже есть у основной карточки, остаются на дубле
+		{&m.Moved.Deliveries, `
+			UPDATE campaign_deliveries SET customer_id = $1
+			WHERE customer_id = $2
+			  AND campaign_id NOT IN (SELECT campaign_id FROM campaign_deliveries WHERE customer_id = $1)
+			RETURNING id`},
+		{&m.Moved.Tasks, `UPDATE tasks SET customer_id = $1 WHERE customer_id = $2 RETURNING id`},
+		{&m.Moved.Waitlist, `UPDATE waitlist_entries SET customer_id = $1 WHERE customer_id = $2 RETURNING id`},
+		{&m.Moved.Reservations, `UPDATE reservations SET customer_id = $1 WHERE customer_id = $2 RETURNING id`},
+	}
+	for _, mv := range moves {
+		if *mv.dst, err = collectIDs(ctx, tx, mv.query, m.SurvivorID, m.DuplicateID); err != nil {
+			return fmt.Errorf("ошибка переноса данных покупателя: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE customers SET merged_into = $1, updated_at = NOW() WHERE id = $2`, m.SurvivorID, m.DuplicateID); err != nil {
+		return fmt.Errorf("ошибка объединения покупателей: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO customer_merges (owner_id, survivor_id, duplicate_id, survivor_before, moved, merged_by, undo_until)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second')
+		RETURNING id, merged_at, undo_until
+	`, m.OwnerID, m.SurvivorID, m.DuplicateID, survivor, m.Moved, m.MergedBy, mergeUndoWindow.Seconds()).Scan(&m.ID, &m.MergedAt, &m.UndoUntil)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения объединения покупателей: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE customer_duplicates SET status = $1
+		WHERE (customer_id = $2 AND duplicate_id = $3) OR (customer_id = $3 AND duplicate_id = $2)
+	`, DuplicateMerged, m.SurvivorID, m.DuplicateID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления дубля покупателя: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка объединения покупателей: %w", err)
+	}
+	return nil
+}
+
+// UndoMerge возвращает перенесённые записи на дубль и восстанавливает поля основной карточки.
+// Записи, созданные после объединения, остаются на основной карточке.
+func (r *Repository) UndoMerge(ctx context.Context, ownerID, mergeID int) (*Merge, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var m Merge
+	var before Customer
+	err = tx.QueryRow(ctx, `
+		SELECT id, owner_id, survivor_id, duplicate_id, COALESCE(merged_by, 0), moved, survivor_before, merged_at, undo_until, undone_at
+		FROM customer_merges
+		WHERE id = $1 AND owner_id = $2
+		FOR UPDATE
+	`, mergeID, ownerID).Scan(&m.ID, &m.OwnerID, &m.SurvivorID, &m.DuplicateID, &m.MergedBy, &m.Moved, &before, &m.MergedAt, &m.UndoUntil, &m.UndoneAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMergeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения объединения покупателей: %w", err)
+	}
+	if m.UndoneAt != nil || time.Now().After(m.UndoUntil) {
+		return nil, ErrUndoExpired
+	}
+
+	locked, err := lockCustomers(ctx, tx, ownerID, m.SurvivorID, m.DuplicateID)
+	if err != nil {
+		return nil, err
+	}
+	survivor := locked[m.SurvivorID]
+	if survivor.MergedInto != nil || survivor.AnonymizedAt != nil {
+		return nil, ErrUndoBlocked
+	}
+
+	// Новые списания баллов могли израсходовать перенесённые начисления — вернуть их уже нельзя
+	var loyaltyChanged bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM loyalty_entries WHERE customer_id = $1 AND created_at > $2)
+	`, m.SurvivorID, m.MergedAt).Scan(&loyaltyChanged)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки баллов покупателя: %w", err)
+	}
+	if loyaltyChanged {
+		return nil, ErrUndoBlocked
+	}
+
+	moves := []struct {
+		ids   []int
+		query string
+	}{
+		{m.Moved.Sales, `UPDATE sales SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+		{m.Moved.LoyaltyEntries, `UPDATE loyalty_entries SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+		{m.Moved.Interactions, `UPDATE customer_interactions SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+		{m.Moved.Deliveries, `UPDATE campaign_deliveries SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+		{m.Moved.Tasks, `UPDATE tasks SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+		{m.Moved.Waitlist, `UPDATE waitlist_entries SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+		{m.Moved.Reservations, `UPDATE reservations SET customer_id = $1 WHERE id = ANY($3) AND customer_id = $2`},
+	}
+	for _, mv := range moves {
+		if len(mv.ids) == 0 {
+			continue
+		}
+		if _, err := tx.Exec(ctx, mv.query, m.DuplicateID, m.SurvivorID, mv.ids); err != nil {
+			return nil, fmt.Errorf("ошибка возврата данных покупателя: %w", err)
+		}
+	}
+
+	before.ID = m.SurvivorID
+	if err := saveFields(ctx, tx, before); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE customers SET merged_into = NULL, updated_at = NOW() WHERE id = $1`, m.DuplicateID); err != nil {
+		return nil, fmt.Errorf("ошибка отмены объединения покупателей: %w", err)
+	}
+
+	// Раз объединение отменили, пара — не дубль
+	_, err = tx.Exec(ctx, `
+		UPDATE customer_duplicates SET status = $1
+		WHERE (customer_id = $2 AND duplicate_id = $3) OR (customer_id = $3 AND duplicate_id = $2)
+	`, DuplicateDismissed, m.SurvivorID, m.DuplicateID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления дубля покупателя: %w", err)
+	}
+
+	if err := tx.QueryRow(ctx, `UPDATE customer_merges SET undone_at = NOW() WHERE id = $1 RETURNING undone_at`, m.ID).Scan(&m.UndoneAt); err != nil {
+		return nil, fmt.Errorf("ошибка отмены объединения покупателей: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка отмены объединения покупателей: %w", err)
+	}
+	return &m, nil
+}
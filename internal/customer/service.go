@@ -0,0 +1,285 @@
+package customer
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrAccessDenied      = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrCustomerNotFound  = errors.New("покупатель не найден")
+	ErrPhoneTaken        = errors.New("покупатель с таким телефоном уже есть")
+	ErrAnonymized        = errors.New("данные покупателя удалены по его запросу")
+	ErrMerged            = errors.New("карточка объединена с другим покупателем")
+	ErrSelfMerge         = errors.New("нельзя объединить покупателя с самим собой")
+	ErrDuplicateNotFound = errors.New("пара дублей не найдена")
+	ErrMergeNotFound     = errors.New("объединение не найдено")
+	ErrUndoExpired       = errors.New("объединение уже отменено или срок отмены истёк")
+	ErrUndoBlocked       = errors.New("после объединения карточка изменилась (операции с баллами или новое объединение), отменить нельзя")
+)
+
+const searchLimit = 50
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+// organisation — владелец магазина, если у пользователя есть доступ к магазину.
+// Покупатели общие для всех магазинов владельца.
+func (s *Service) organisation(ctx context.Context, userID, shopID int) (int, error) {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrAccessDenied
+	}
+	return s.employees.GetShopOwnerID(ctx, shopID)
+}
+
+func normalize(c *Customer) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("имя покупателя обязательно")
+	}
+	c.Phone = NormalizePhone(c.Phone)
+	if len(c.Phone) < 10 {
+		return errors.New("неправильный номер телефона")
+	}
+	c.Email = NormalizeEmail(c.Email)
+	if c.Email != "" && !strings.Contains(c.Email, "@") {
+		return errors.New("неправильный email")
+	}
+	if c.PreferredSizes == nil {
+		c.PreferredSizes = []string{}
+	}
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	return nil
+}
+
+func mapWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrPhoneTaken
+	}
+	return err
+}
+
+func (s *Service) CreateCustomer(ctx context.Context, userID, shopID int, c *Customer) error {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return err
+	}
+	if err := normalize(c); err != nil {
+		return err
+	}
+	c.OwnerID = ownerID
+	c.ConsentAt = nil
+	if c.MarketingConsent {
+		now := time.Now()
+		c.ConsentAt = &now
+	}
+	return mapWriteError(s.repo.CreateCustomer(ctx, c))
+}
+
+func (s *Service) UpdateCustomer(ctx context.Context, userID, shopID int, c *Customer) error {
+	existing, err := s.GetCustomer(ctx, userID, shopID, c.ID)
+	if err != nil {
+		return err
+	}
+	if existing.AnonymizedAt != nil {
+		return ErrAnonymized
+	}
+	if existing.MergedInto != nil {
+		return ErrMerged
+	}
+	if err := normalize(c); err != nil {
+		return err
+	}
+	c.OwnerID = existing.OwnerID
+	c.CreatedAt = existing.CreatedAt
+
+	// Дата согласия на рассылки фиксируется в момент, когда покупатель его дал
+	switch {
+	case c.MarketingConsent && existing.MarketingConsent:
+		c.ConsentAt = existing.ConsentAt
+	case c.MarketingConsent:
+		now := time.Now()
+		c.ConsentAt = &now
+	default:
+		c.ConsentAt = nil
+	}
+	return mapWriteError(s.repo.UpdateCustomer(ctx, c))
+}
+
+func (s *Service) GetCustomer(ctx context.Context, userID, shopID, id int) (*Customer, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.repo.GetCustomer(ctx, ownerID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	return c, err
+}
+
+// Search — поиск на кассе по телефону или имени
+func (s *Service) Search(ctx context.Context, userID, shopID int, query string) ([]Customer, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.TrimSpace(query)
+	return s.repo.Search(ctx, ownerID, query, NormalizePhone(query), searchLimit)
+}
+
+func (s *Service) AddInteraction(ctx context.Context, userID, shopID, customerID int, in *Interaction) error {
+	c, err := s.GetCustomer(ctx, userID, shopID, customerID)
+	if err != nil {
+		return err
+	}
+	if c.AnonymizedAt != nil {
+		return ErrAnonymized
+	}
+	if c.MergedInto != nil {
+		return ErrMerged
+	}
+	if !interactionTypes[in.Type] {
+		return fmt.Errorf("неизвестный тип контакта: %s", in.Type)
+	}
+	if strings.TrimSpace(in.Text) == "" {
+		return errors.New("текст контакта обязателен")
+	}
+	in.CustomerID = customerID
+	in.ShopID = &shopID
+	in.CreatedBy = userID
+	return s.repo.AddInteraction(ctx, in)
+}
+
+func (s *Service) GetTimeline(ctx context.Context, userID, shopID, customerID int) ([]TimelineEvent, error) {
+	if _, err := s.GetCustomer(ctx, userID, shopID, customerID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTimeline(ctx, customerID)
+}
+
+// DetectDuplicates пересчитывает список возможных дублей организации
+func (s *Service) DetectDuplicates(ctx context.Context, ownerID int) ([]Duplicate, error) {
+	customers, err := s.repo.GetActiveCustomers(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	pairs := findDuplicates(customers)
+	if err := s.repo.SaveDuplicates(ctx, ownerID, pairs); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// RunDuplicateDetection — фоновый поиск дублей по всем организациям
+func (s *Service) RunDuplicateDetection(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			owners, err := s.repo.GetOwnersWithCustomers(ctx)
+			if err != nil {
+				log.Printf("ошибка поиска дублей покупателей: %v", err)
+				continue
+			}
+			for _, ownerID := range owners {
+				if _, err := s.DetectDuplicates(ctx, ownerID); err != nil {
+					log.Printf("ошибка поиска дублей покупателей организации ID=%d: %v", ownerID, err)
+				}
+			}
+		}
+	}
+}
+
+// GetDuplicates — открытые пары дублей с карточками обоих покупателей
+func (s *Service) GetDuplicates(ctx context.Context, userID, shopID int, detect bool) ([]Duplicate, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if detect {
+		if _, err := s.DetectDuplicates(ctx, ownerID); err != nil {
+			return nil, err
+		}
+	}
+	pairs, err := s.repo.GetDuplicates(ctx, ownerID, DuplicateOpen)
+	if err != nil || len(pairs) == 0 {
+		return pairs, err
+	}
+
+	ids := make([]int, 0, len(pairs)*2)
+	for _, p := range pairs {
+		ids = append(ids, p.CustomerID, p.DuplicateID)
+	}
+	customers, err := s.repo.GetCustomersByIDs(ctx, ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*Customer, len(customers))
+	for i := range customers {
+		byID[customers[i].ID] = &customers[i]
+	}
+	for i := range pairs {
+		pairs[i].Customer = byID[pairs[i].CustomerID]
+		pairs[i].Duplicate = byID[pairs[i].DuplicateID]
+	}
+	return pairs, nil
+}
+
+// DismissDuplicate — пара не дубль; повторный поиск её не вернёт
+func (s *Service) DismissDuplicate(ctx context.Context, userID, shopID, pairID int) error {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetDuplicateStatus(ctx, ownerID, pairID, DuplicateDismissed)
+}
+
+// Merge вливает дубль в карточку survivorID. Объединение можно отменить в течение mergeUndoWindow.
+func (s *Service) Merge(ctx context.Context, userID, shopID, survivorID int, req MergeRequest) (*Merge, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if req.DuplicateID == survivorID {
+		return nil, ErrSelfMerge
+	}
+	m := &Merge{OwnerID: ownerID, SurvivorID: survivorID, DuplicateID: req.DuplicateID, MergedBy: userID}
+	if err := s.repo.Merge(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *Service) UndoMerge(ctx context.Context, userID, shopID, mergeID int) (*Merge, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.UndoMerge(ctx, ownerID, mergeID)
+}
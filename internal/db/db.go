@@ -5,27 +5,34 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type DB struct {
-	Conn *pgx.Conn
+	// Conn — пул соединений: HTTP-запросы и фоновые задачи работают параллельно,
+	// а каждая транзакция (Begin) получает своё соединение из пула
+	Conn *pgxpool.Pool
 }
 
 func NewDB(dsn string) (*DB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	conn, err := pgx.Connect(ctx, dsn)
+	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось подключиться к базе: %w", err)
 	}
+	// pgxpool.New не открывает соединение сразу — проверяем, что база доступна
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("не удалось подключиться к базе: %w", err)
+	}
 
 	fmt.Println("Подключение к базе установлено")
-	return &DB{Conn: conn}, nil
+	return &DB{Conn: pool}, nil
 }
 
 func (db *DB) Close() {
-	db.Conn.Close(context.Background())
+	db.Conn.Close()
 	fmt.Println("Соединение с базой закрыто")
 }
@@ -98,3 +98,67 @@ func (r *Repository) GetShopIDByEmployee(ctx context.Context, employeeID int) (i
 	}
 	return shopID, nil
 }
+
+// HasShopAccess — пользователь является владельцем магазина или его сотрудником
+func (r *Repository) HasShopAccess(ctx context.Context, shopID, userID int) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS(SELECT 1 FROM shops WHERE id = $1 AND owner_id = $2)
+		    OR EXISTS(SELECT 1 FROM employees WHERE shop_id = $1 AND user_id = $2)
+	`
+	err := r.db.Conn.QueryRow(ctx, query, shopID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки доступа к магазину: %w", err)
+	}
+	return exists, nil
+}
+
+// GetShopOwnerID — владелец магазина (организация, к которой относится магазин)
+func (r *Repository) GetShopOwnerID(ctx context.Context, shopID int) (int, error) {
+	var ownerID int
+	err := r.db.Conn.QueryRow(ctx, `SELECT owner_id FROM shops WHERE id = $1`, shopID).Scan(&ownerID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения владельца магазина ID=%d: %w", shopID, err)
+	}
+	return ownerID, nil
+}
+
+// IsEmployee — пользователь числится сотрудником магазина
+func (r *Repository) IsEmployee(ctx context.Context, shopID, userID int) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM employees WHERE shop_id = $1 AND user_id = $2)`
+	err := r.db.Conn.QueryRow(ctx, query, shopID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки сотрудника: %w", err)
+	}
+	return exists, nil
+}
+
+// GetStaffNames — имена владельца и сотрудников его магазинов
+func (r *Repository) GetStaffNames(ctx context.Context, ownerID int) (map[int]string, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT u.id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))
+		FROM users u
+		WHERE u.id = $1 OR u.id IN (
+			SELECT e.user_id FROM employees e JOIN shops s ON s.id = e.shop_id WHERE s.owner_id = $1
+		)
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения имён сотрудников: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("ошибка чтения сотрудника: %w", err)
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке сотрудников: %w", err)
+	}
+	return names, nil
+}
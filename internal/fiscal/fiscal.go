@@ -0,0 +1,83 @@
+package fiscal
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kind — тип фискального документа
+type Kind string
+
+const (
+	KindSale       Kind = "sale"
+	KindReturn     Kind = "return"
+	KindShiftOpen  Kind = "shift_open"
+	KindShiftClose Kind = "shift_close"
+)
+
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// ReceiptLine — позиция фискального чека
+type ReceiptLine struct {
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+	Discount float64 `json:"discount"`
+	Total    float64 `json:"total"`
+}
+
+// ReceiptPayment — оплата в фискальном чеке
+type ReceiptPayment struct {
+	Method string  `json:"method"`
+	Amount float64 `json:"amount"`
+}
+
+// Receipt — данные чека продажи или возврата для ОФД
+type Receipt struct {
+	ShopID    int              `json:"shop_id"`
+	RefID     int              `json:"ref_id"`
+	SaleID    int              `json:"sale_id"`
+	Total     float64          `json:"total"`
+	Lines     []ReceiptLine    `json:"lines"`
+	Payments  []ReceiptPayment `json:"payments"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ShiftEvent — открытие или закрытие смены в онлайн-кассе
+type ShiftEvent struct {
+	ShopID    int       `json:"shop_id"`
+	ShiftID   int       `json:"shift_id"`
+	Cash      float64   `json:"cash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Result — ответ ОФД: фискальный признак и ссылка для QR-кода на чеке
+type Result struct {
+	ExternalID   string    `json:"external_id"`
+	FiscalSign   string    `json:"fiscal_sign"`
+	QRURL        string    `json:"qr_url"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// Document — фискальный документ в очереди на отправку.
+// Пока ОФД не ответил, документ остаётся в статусе pending и повторяется фоновой задачей.
+type Document struct {
+	ID            int             `json:"id"`
+	ShopID        int             `json:"shop_id"`
+	Kind          Kind            `json:"kind"`
+	RefID         int             `json:"ref_id"`
+	Status        string          `json:"status"`
+	Payload       json.RawMessage `json:"payload"`
+	ExternalID    string          `json:"external_id,omitempty"`
+	FiscalSign    string          `json:"fiscal_sign,omitempty"`
+	QRURL         string          `json:"qr_url,omitempty"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	FiscalizedAt  *time.Time      `json:"fiscalized_at,omitempty"`
+}
@@ -0,0 +1,112 @@
+package fiscal
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrDocumentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrDocumentBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// GetDocuments godoc
+// @Summary Get fiscal documents
+// @Description Фискальные документы магазина (чеки, возвраты, смены) с их статусом в ОФД.
+// @Tags fiscal
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param status query string false "pending, done или failed"
+// @Success 200 {array} Document
+// @Failure 400 {string} string "неправильный ID магазина"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/shops/{id}/fiscal-documents [get]
+func (h *Handler) GetDocuments(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	docs, err := h.service.GetDocuments(r.Context(), claims.ID, shopID, r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(docs)
+}
+
+// RetryDocument godoc
+// @Summary Retry fiscalisation
+// @Description Повторно отправляет документ в ОФД; для уже фискализированного — заново запрашивает фискальный признак и QR.
+// @Tags fiscal
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param doc_id path int true "Document ID"
+// @Success 200 {object} Document
+// @Failure 400 {string} string "неправильный ID документа"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "фискальный документ не найден"
+// @Failure 409 {string} string "документ сейчас отправляется в ОФД, повторите позже"
+// @Failure 502 {string} string "ОФД недоступен"
+// @Router /owner/shops/{id}/fiscal-documents/{doc_id}/retry [post]
+func (h *Handler) RetryDocument(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	docID, err := strconv.Atoi(chi.URLParam(r, "doc_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID документа", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.service.Retry(r.Context(), claims.ID, shopID, docID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
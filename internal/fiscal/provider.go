@@ -0,0 +1,103 @@
+package fiscal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrProviderUnavailable = errors.New("ОФД недоступен")
+
+// Provider — онлайн-касса / оператор фискальных данных (ОФД)
+type Provider interface {
+	RegisterSale(ctx context.Context, receipt Receipt) (Result, error)
+	RegisterReturn(ctx context.Context, receipt Receipt) (Result, error)
+	OpenShift(ctx context.Context, event ShiftEvent) (Result, error)
+	CloseShift(ctx context.Context, event ShiftEvent) (Result, error)
+	// FetchReceipt повторно запрашивает у ОФД фискальный признак и QR по ID документа
+	FetchReceipt(ctx context.Context, externalID string) (Result, error)
+}
+
+// FileProvider — имитация ОФД для разработки: каждый документ сохраняется
+// JSON-файлом в каталоге. Если в каталоге есть файл "offline",
+// провайдер отвечает ошибкой — так можно проверить повторную отправку.
+type FileProvider struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileProvider(dir string) (*FileProvider, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога ОФД: %w", err)
+	}
+	return &FileProvider{dir: dir}, nil
+}
+
+type fileRecord struct {
+	Kind    Kind        `json:"kind"`
+	Request interface{} `json:"request"`
+	Result  Result      `json:"result"`
+}
+
+func (p *FileProvider) register(kind Kind, request interface{}, total float64) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(p.dir, "offline")); err == nil {
+		return Result{}, ErrProviderUnavailable
+	}
+
+	now := time.Now()
+	res := Result{
+		ExternalID:   fmt.Sprintf("%s-%d", kind, now.UnixNano()),
+		FiscalSign:   fmt.Sprintf("%010d", rand.Int63n(1e10)),
+		RegisteredAt: now,
+	}
+	if kind == KindSale || kind == KindReturn {
+		res.QRURL = fmt.Sprintf("https://consumer.oofd.kz/?i=%s&f=%s&s=%.2f&t=%s",
+			res.FiscalSign, res.ExternalID, total, now.Format("20060102T150405"))
+	}
+
+	data, err := json.MarshalIndent(fileRecord{Kind: kind, Request: request, Result: res}, "", "  ")
+	if err != nil {
+		return Result{}, err
+	}
+	if err := os.WriteFile(filepath.Join(p.dir, res.ExternalID+".json"), data, 0o644); err != nil {
+		return Result{}, fmt.Errorf("ошибка записи документа ОФД: %w", err)
+	}
+	return res, nil
+}
+
+func (p *FileProvider) RegisterSale(ctx context.Context, receipt Receipt) (Result, error) {
+	return p.register(KindSale, receipt, receipt.Total)
+}
+
+func (p *FileProvider) RegisterReturn(ctx context.Context, receipt Receipt) (Result, error) {
+	return p.register(KindReturn, receipt, receipt.Total)
+}
+
+func (p *FileProvider) OpenShift(ctx context.Context, event ShiftEvent) (Result, error) {
+	return p.register(KindShiftOpen, event, event.Cash)
+}
+
+func (p *FileProvider) CloseShift(ctx context.Context, event ShiftEvent) (Result, error) {
+	return p.register(KindShiftClose, event, event.Cash)
+}
+
+func (p *FileProvider) FetchReceipt(ctx context.Context, externalID string) (Result, error) {
+	data, err := os.ReadFile(filepath.Join(p.dir, filepath.Base(externalID)+".json"))
+	if err != nil {
+		return Result{}, fmt.Errorf("документ %s не найден в ОФД: %w", externalID, err)
+	}
+	var rec fileRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return Result{}, fmt.Errorf("ошибка чтения документа ОФД: %w", err)
+	}
+	return rec.Result, nil
+}
@@ -0,0 +1,217 @@
+package fiscal
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS fiscal_documents (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			ref_id INT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			payload JSONB NOT NULL,
+			external_id VARCHAR(100),
+			fiscal_sign VARCHAR(100),
+			qr_url TEXT,
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			fiscalized_at TIMESTAMP WITH TIME ZONE,
+			UNIQUE (kind, ref_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS fiscal_documents_due ON fiscal_documents (next_attempt_at) WHERE status = 'pending';
+
+		ALTER TABLE fiscal_documents ADD COLUMN IF NOT EXISTS sending_until TIMESTAMP WITH TIME ZONE;
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции fiscal_documents: %w", err)
+	}
+	fmt.Println("Миграция fiscal_documents выполнена успешно")
+	return nil
+}
+
+const documentColumns = `
+	id, shop_id, kind, ref_id, status, payload, COALESCE(external_id, ''), COALESCE(fiscal_sign, ''),
+	COALESCE(qr_url, ''), attempts, COALESCE(last_error, ''), next_attempt_at, created_at, fiscalized_at
+`
+
+func scanDocuments(rows pgx.Rows) ([]Document, error) {
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		var d Document
+		if err := rows.Scan(&d.ID, &d.ShopID, &d.Kind, &d.RefID, &d.Status, &d.Payload, &d.ExternalID,
+			&d.FiscalSign, &d.QRURL, &d.Attempts, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.FiscalizedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения фискального документа: %w", err)
+		}
+		docs = append(docs, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке фискальных документов: %w", err)
+	}
+	return docs, nil
+}
+
+// updateTarget переносит фискальные реквизиты на чек продажи или возврата
+func updateTarget(ctx context.Context, tx pgx.Tx, d *Document) error {
+	var table string
+	switch d.Kind {
+	case KindSale:
+		table = "sales"
+	case KindReturn:
+		table = "sale_returns"
+	default:
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE `+table+` SET fiscal_status = $1, fiscal_sign = $2, fiscal_qr_url = $3 WHERE id = $4
+	`, d.Status, d.FiscalSign, d.QRURL, d.RefID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления фискальных реквизитов чека: %w", err)
+	}
+	return nil
+}
+
+// CreateDocument сохраняет документ уже взятым в отправку до sendingUntil: пока идёт первая попытка,
+// фоновый повтор его не возьмёт, а next_attempt_at сразу указывает на следующую попытку.
+func (r *Repository) CreateDocument(ctx context.Context, d *Document, sendingUntil time.Time) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO fiscal_documents (shop_id, kind, ref_id, status, payload, next_attempt_at, sending_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, d.ShopID, d.Kind, d.RefID, d.Status, d.Payload, d.NextAttemptAt, sendingUntil).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания фискального документа: %w", err)
+	}
+	if err := updateTarget(ctx, tx, d); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения фискального документа: %w", err)
+	}
+	return nil
+}
+
+// SaveAttempt сохраняет результат попытки отправки, снимает отметку об отправке и синхронизирует статус на чеке
+func (r *Repository) SaveAttempt(ctx context.Context, d *Document) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE fiscal_documents
+		SET status = $1, external_id = $2, fiscal_sign = $3, qr_url = $4, attempts = $5,
+		    last_error = $6, next_attempt_at = $7, fiscalized_at = $8, sending_until = NULL
+		WHERE id = $9
+	`, d.Status, d.ExternalID, d.FiscalSign, d.QRURL, d.Attempts, d.LastError, d.NextAttemptAt, d.FiscalizedAt, d.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления фискального документа ID=%d: %w", d.ID, err)
+	}
+	if err := updateTarget(ctx, tx, d); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения фискального документа: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueDocuments атомарно берёт в отправку документы, у которых подошло время повтора.
+// Документ, который уже отправляется (в том числе синхронно при продаже), не берётся, пока не истечёт sending_until,
+// а SKIP LOCKED не даёт двум обработчикам взять один и тот же документ.
+func (r *Repository) ClaimDueDocuments(ctx context.Context, now, sendingUntil time.Time, limit int) ([]Document, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		UPDATE fiscal_documents SET sending_until = $2
+		WHERE id IN (
+			SELECT id FROM fiscal_documents
+			WHERE status = 'pending' AND next_attempt_at <= $1 AND (sending_until IS NULL OR sending_until <= $1)
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+documentColumns, now, sendingUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения очереди ОФД: %w", err)
+	}
+	return scanDocuments(rows)
+}
+
+// ClaimDocument берёт документ в отправку для ручного повтора; false — документ сейчас отправляется
+func (r *Repository) ClaimDocument(ctx context.Context, id int, now, sendingUntil time.Time) (*Document, bool, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		UPDATE fiscal_documents SET sending_until = $3
+		WHERE id = $1 AND (sending_until IS NULL OR sending_until <= $2)
+		RETURNING `+documentColumns, id, now, sendingUntil)
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка получения фискального документа: %w", err)
+	}
+	docs, err := scanDocuments(rows)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(docs) == 0 {
+		return nil, false, nil
+	}
+	return &docs[0], true, nil
+}
+
+func (r *Repository) GetDocumentsByShop(ctx context.Context, shopID int, status string) ([]Document, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+documentColumns+`
+		FROM fiscal_documents
+		WHERE shop_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+	`, shopID, status)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения фискальных документов: %w", err)
+	}
+	return scanDocuments(rows)
+}
+
+func (r *Repository) GetDocumentByID(ctx context.Context, id int) (*Document, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+documentColumns+`
+		FROM fiscal_documents
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения фискального документа: %w", err)
+	}
+	docs, err := scanDocuments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("фискальный документ ID=%d: %w", id, pgx.ErrNoRows)
+	}
+	return &docs[0], nil
+}
@@ -0,0 +1,223 @@
+package fiscal
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrAccessDenied     = errors.New("доступ запрещён: вы не владелец магазина")
+	ErrDocumentNotFound = errors.New("фискальный документ не найден")
+	ErrDocumentBusy     = errors.New("документ сейчас отправляется в ОФД, повторите позже")
+)
+
+const (
+	// maxAttempts — после стольких неудачных попыток документ помечается failed и ждёт ручного повтора
+	maxAttempts = 20
+	maxBackoff  = time.Hour
+	// sendLease — сколько документ считается отправляемым: дольше любого запроса к ОФД.
+	// Если процесс упал посреди отправки, по истечении срока документ снова возьмёт фоновый повтор.
+	sendLease = 5 * time.Minute
+)
+
+// retryDelay — пауза перед следующей попыткой: 1 мин, 2 мин, 4 мин... не больше maxBackoff
+func retryDelay(attempts int) time.Duration {
+	backoff := time.Duration(1<<min(attempts, 10)) * 30 * time.Second
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+type Service struct {
+	repo      *Repository
+	provider  Provider
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, provider Provider, employees *employee.Repository) *Service {
+	return &Service{repo: repo, provider: provider, employees: employees}
+}
+
+// SubmitSale ставит чек продажи в очередь ОФД и сразу пытается его фискализировать.
+// Ошибка ОФД не возвращается — документ останется pending и будет отправлен повторно.
+func (s *Service) SubmitSale(ctx context.Context, receipt Receipt) (*Document, error) {
+	return s.submit(ctx, receipt.ShopID, KindSale, receipt.RefID, receipt)
+}
+
+func (s *Service) SubmitReturn(ctx context.Context, receipt Receipt) (*Document, error) {
+	return s.submit(ctx, receipt.ShopID, KindReturn, receipt.RefID, receipt)
+}
+
+func (s *Service) SubmitShiftOpen(ctx context.Context, event ShiftEvent) (*Document, error) {
+	return s.submit(ctx, event.ShopID, KindShiftOpen, event.ShiftID, event)
+}
+
+func (s *Service) SubmitShiftClose(ctx context.Context, event ShiftEvent) (*Document, error) {
+	return s.submit(ctx, event.ShopID, KindShiftClose, event.ShiftID, event)
+}
+
+func (s *Service) submit(ctx context.Context, shopID int, kind Kind, refID int, payload interface{}) (*Document, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки фискального документа: %w", err)
+	}
+
+	now := time.Now()
+	d := &Document{ShopID: shopID, Kind: kind, RefID: refID, Status: StatusPending, Payload: data, NextAttemptAt: now.Add(retryDelay(1))}
+	if err := s.repo.CreateDocument(ctx, d, now.Add(sendLease)); err != nil {
+		return nil, err
+	}
+	if err := s.attempt(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *Service) send(ctx context.Context, d *Document) (Result, error) {
+	switch d.Kind {
+	case KindSale, KindReturn:
+		var receipt Receipt
+		if err := json.Unmarshal(d.Payload, &receipt); err != nil {
+			return Result{}, err
+		}
+		if d.Kind == KindSale {
+			return s.provider.RegisterSale(ctx, receipt)
+		}
+		return s.provider.RegisterReturn(ctx, receipt)
+	case KindShiftOpen, KindShiftClose:
+		var event ShiftEvent
+		if err := json.Unmarshal(d.Payload, &event); err != nil {
+			return Result{}, err
+		}
+		if d.Kind == KindShiftOpen {
+			return s.provider.OpenShift(ctx, event)
+		}
+		return s.provider.CloseShift(ctx, event)
+	}
+	return Result{}, fmt.Errorf("неизвестный тип документа: %s", d.Kind)
+}
+
+// attempt отправляет документ в ОФД и сохраняет результат. Возвращает только ошибки БД.
+func (s *Service) attempt(ctx context.Context, d *Document) error {
+	d.Attempts++
+	res, err := s.send(ctx, d)
+	if err != nil {
+		d.LastError = err.Error()
+		d.NextAttemptAt = time.Now().Add(retryDelay(d.Attempts))
+		if d.Attempts >= maxAttempts {
+			d.Status = StatusFailed
+		}
+		log.Printf("фискализация %s #%d не удалась (попытка %d): %v", d.Kind, d.RefID, d.Attempts, err)
+	} else {
+		d.Status = StatusDone
+		d.LastError = ""
+		d.ExternalID = res.ExternalID
+		d.FiscalSign = res.FiscalSign
+		d.QRURL = res.QRURL
+		d.FiscalizedAt = &res.RegisteredAt
+	}
+	return s.repo.SaveAttempt(ctx, d)
+}
+
+// ProcessDue повторяет отправку документов, у которых подошло время
+func (s *Service) ProcessDue(ctx context.Context) error {
+	now := time.Now()
+	docs, err := s.repo.ClaimDueDocuments(ctx, now, now.Add(sendLease), 50)
+	if err != nil {
+		return err
+	}
+	for i := range docs {
+		if err := s.attempt(ctx, &docs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunRetries — фоновая задача повторной фискализации, работает до отмены ctx
+func (s *Service) RunRetries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ProcessDue(ctx); err != nil {
+				log.Printf("ошибка повторной фискализации: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) checkOwner(ctx context.Context, ownerID, shopID int) error {
+	isOwner, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !isOwner {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *Service) GetDocuments(ctx context.Context, ownerID, shopID int, status string) ([]Document, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDocumentsByShop(ctx, shopID, status)
+}
+
+// Retry — ручной повтор: неотправленный документ отправляется сейчас,
+// у уже фискализированного заново запрашиваются признак и QR из ОФД.
+func (s *Service) Retry(ctx context.Context, ownerID, shopID, docID int) (*Document, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	d, err := s.repo.GetDocumentByID(ctx, docID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.ShopID != shopID {
+		return nil, ErrDocumentNotFound
+	}
+
+	if d.Status == StatusDone {
+		res, err := s.provider.FetchReceipt(ctx, d.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		d.FiscalSign = res.FiscalSign
+		d.QRURL = res.QRURL
+		if err := s.repo.SaveAttempt(ctx, d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
+	now := time.Now()
+	d, claimed, err := s.repo.ClaimDocument(ctx, d.ID, now, now.Add(sendLease))
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrDocumentBusy
+	}
+	d.Status = StatusPending
+	if err := s.attempt(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
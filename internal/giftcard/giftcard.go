@@ -0,0 +1,83 @@
+package giftcard
+
+import (
+	"errors"
+	"time"
+)
+
+// EntryType — тип операции в журнале подарочной карты
+type EntryType string
+
+const (
+	EntryIssue  EntryType = "issue"
+	EntryRedeem EntryType = "redeem"
+	EntryRefund EntryType = "refund"
+)
+
+var (
+	ErrCardNotFound        = errors.New("подарочная карта не найдена")
+	ErrCardExpired         = errors.New("срок действия подарочной карты истёк")
+	ErrCardNotValidHere    = errors.New("подарочная карта не действует в этом магазине")
+	ErrInsufficientBalance = errors.New("на подарочной карте недостаточно средств")
+)
+
+// Card — подарочная карта (сертификат). Если ShopID не задан, карта действует во всех магазинах владельца.
+// Balance — текущий остаток; он всегда равен сумме операций журнала.
+type Card struct {
+	ID             int        `json:"id"`
+	OwnerID        int        `json:"owner_id"`
+	ShopID         *int       `json:"shop_id,omitempty"`
+	Code           string     `json:"code"`
+	InitialBalance float64    `json:"initial_balance"`
+	Balance        float64    `json:"balance"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	IssuedBy       int        `json:"issued_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// UsableAt проверяет, можно ли расплатиться картой в магазине shopID (владелец магазина — shopOwnerID)
+func (c Card) UsableAt(shopID, shopOwnerID int, now time.Time) error {
+	if c.OwnerID != shopOwnerID || (c.ShopID != nil && *c.ShopID != shopID) {
+		return ErrCardNotValidHere
+	}
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return ErrCardExpired
+	}
+	return nil
+}
+
+// Entry — операция журнала. Amount со знаком: выпуск и возврат пополняют карту, списание уменьшает.
+// У возврата ParentID указывает на списание, которое он отменяет.
+type Entry struct {
+	ID        int       `json:"id"`
+	CardID    int       `json:"card_id"`
+	ShopID    int       `json:"shop_id"`
+	Type      EntryType `json:"type"`
+	Amount    float64   `json:"amount"`
+	ParentID  *int      `json:"parent_id,omitempty"`
+	CreatedBy *int      `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Ledger — журнал карты и остаток, восстановленный по нему
+type Ledger struct {
+	Card            Card    `json:"card"`
+	Entries         []Entry `json:"entries"`
+	ComputedBalance float64 `json:"computed_balance"`
+}
+
+// BalanceInfo — ответ проверки баланса на кассе
+type BalanceInfo struct {
+	Code      string     `json:"code"`
+	Balance   float64    `json:"balance"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Usable    bool       `json:"usable"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+type IssueRequest struct {
+	Amount    float64    `json:"amount"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// AllShops — карта действует во всех магазинах владельца, а не только в магазине выпуска
+	AllShops bool `json:"all_shops"`
+}
@@ -0,0 +1,173 @@
+package giftcard
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied), errors.Is(err, ErrOwnerOnly):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrCardNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// IssueCard godoc
+// @Summary Issue gift card
+// @Description Выпускает подарочную карту с уникальным кодом без оплаты (подарок, компенсация). Только для владельца магазина — покупателю карта продаётся чеком (gift_cards в POST /shops/{id}/sales). По умолчанию карта действует только в магазине выпуска, all_shops — во всех магазинах владельца.
+// @Tags gift-cards
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param card body IssueRequest true "Номинал и срок действия"
+// @Success 201 {object} Card
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/gift-cards [post]
+func (h *Handler) IssueCard(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var req IssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	card, err := h.service.Issue(r.Context(), claims.ID, shopID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(card)
+}
+
+// CheckBalance godoc
+// @Summary Check gift card balance
+// @Description Остаток подарочной карты и можно ли ею расплатиться в этом магазине.
+// @Tags gift-cards
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param code path string true "Код карты"
+// @Success 200 {object} BalanceInfo
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "подарочная карта не найдена"
+// @Router /shops/{id}/gift-cards/{code} [get]
+func (h *Handler) CheckBalance(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	info, err := h.service.CheckBalance(r.Context(), claims.ID, shopID, chi.URLParam(r, "code"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// GetLedger godoc
+// @Summary Get gift card ledger
+// @Description Журнал выпуска, списаний и возвратов по карте; computed_balance пересчитан по журналу.
+// @Tags gift-cards
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param code path string true "Код карты"
+// @Success 200 {object} Ledger
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "подарочная карта не найдена"
+// @Router /shops/{id}/gift-cards/{code}/ledger [get]
+func (h *Handler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	ledger, err := h.service.GetLedger(r.Context(), claims.ID, shopID, chi.URLParam(r, "code"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ledger)
+}
+
+// GetCards godoc
+// @Summary Get gift cards
+// @Description Все подарочные карты владельца с текущими остатками.
+// @Tags gift-cards
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Card
+// @Failure 401 {string} string "не авторизован"
+// @Failure 500 {string} string "ошибка получения подарочных карт"
+// @Router /owner/gift-cards [get]
+func (h *Handler) GetCards(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	cards, err := h.service.GetCards(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения подарочных карт", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cards)
+}
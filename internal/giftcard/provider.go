@@ -0,0 +1,44 @@
+package giftcard
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/payment"
+	"fmt"
+	"time"
+)
+
+// Provider — платёжный провайдер для способа оплаты gift_card.
+// Код карты приходит в Reference тендера, идентификатор операции — номер записи журнала.
+type Provider struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewProvider(repo *Repository, employees *employee.Repository) *Provider {
+	return &Provider{repo: repo, employees: employees}
+}
+
+func (p *Provider) Charge(ctx context.Context, req payment.ChargeRequest) (string, error) {
+	ownerID, err := p.employees.GetShopOwnerID(ctx, req.ShopID)
+	if err != nil {
+		return "", err
+	}
+	entryID, err := p.repo.Redeem(ctx, NormalizeCode(req.Reference), req.ShopID, ownerID, payment.Round(req.Amount), time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("GC-%d", entryID), nil
+}
+
+func (p *Provider) Refund(ctx context.Context, req payment.RefundRequest) (string, error) {
+	var redeemID int
+	if _, err := fmt.Sscanf(req.ProviderRef, "GC-%d", &redeemID); err != nil {
+		return "", fmt.Errorf("неизвестная операция подарочной карты: %s", req.ProviderRef)
+	}
+	entryID, err := p.repo.RefundRedemption(ctx, redeemID, req.ShopID, payment.Round(req.Amount))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("GC-%d", entryID), nil
+}
@@ -0,0 +1,267 @@
+package giftcard
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"crm-backend/internal/payment"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS gift_cards (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT REFERENCES shops(id) ON DELETE CASCADE,
+			code VARCHAR(32) NOT NULL UNIQUE,
+			initial_balance NUMERIC(10, 2) NOT NULL,
+			balance NUMERIC(10, 2) NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			issued_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS gift_card_entries (
+			id SERIAL PRIMARY KEY,
+			card_id INT NOT NULL REFERENCES gift_cards(id) ON DELETE CASCADE,
+			shop_id INT REFERENCES shops(id) ON DELETE SET NULL,
+			type VARCHAR(20) NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL,
+			parent_id INT REFERENCES gift_card_entries(id),
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции gift_cards: %w", err)
+	}
+	fmt.Println("Миграция gift_cards выполнена успешно")
+	return nil
+}
+
+const cardColumns = `
+	id, owner_id, shop_id, code, initial_balance, balance, expires_at, COALESCE(issued_by, 0), created_at
+`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCard(row scanner) (*Card, error) {
+	var c Card
+	err := row.Scan(&c.ID, &c.OwnerID, &c.ShopID, &c.Code, &c.InitialBalance, &c.Balance, &c.ExpiresAt,
+		&c.IssuedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// IssueCard создаёт карту и первую запись журнала (выпуск) в одной транзакции
+func (r *Repository) IssueCard(ctx context.Context, c *Card, shopID int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := InsertCard(ctx, tx, c, shopID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения подарочной карты: %w", err)
+	}
+	return nil
+}
+
+// InsertCard записывает карту и её выпуск в журнал внутри чужой транзакции —
+// так продажа карты сохраняется вместе с чеком, которым она оплачена
+func InsertCard(ctx context.Context, tx pgx.Tx, c *Card, shopID int) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO gift_cards (owner_id, shop_id, code, initial_balance, balance, expires_at, issued_by)
+		VALUES ($1, $2, $3, $4, $4, $5, $6)
+		RETURNING id, balance, created_at
+	`, c.OwnerID, c.ShopID, c.Code, c.InitialBalance, c.ExpiresAt, c.IssuedBy).Scan(&c.ID, &c.Balance, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка выпуска подарочной карты: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO gift_card_entries (card_id, shop_id, type, amount, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`, c.ID, shopID, EntryIssue, c.InitialBalance, c.IssuedBy)
+	if err != nil {
+		return fmt.Errorf("ошибка записи выпуска карты в журнал: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetCardByCode(ctx context.Context, code string) (*Card, error) {
+	c, err := scanCard(r.db.Conn.QueryRow(ctx, `SELECT `+cardColumns+` FROM gift_cards WHERE code = $1`, code))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения подарочной карты: %w", err)
+	}
+	return c, nil
+}
+
+func (r *Repository) GetCardsByOwner(ctx context.Context, ownerID int) ([]Card, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+cardColumns+`
+		FROM gift_cards
+		WHERE owner_id = $1
+		ORDER BY created_at DESC
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения подарочных карт: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []Card
+	for rows.Next() {
+		c, err := scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения подарочной карты: %w", err)
+		}
+		cards = append(cards, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке подарочных карт: %w", err)
+	}
+	return cards, nil
+}
+
+func (r *Repository) GetEntries(ctx context.Context, cardID int) ([]Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, card_id, COALESCE(shop_id, 0), type, amount, parent_id, created_by, created_at
+		FROM gift_card_entries
+		WHERE card_id = $1
+		ORDER BY id
+	`, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала карты: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.CardID, &e.ShopID, &e.Type, &e.Amount, &e.ParentID, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения операции карты: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке журнала карты: %w", err)
+	}
+	return entries, nil
+}
+
+// Redeem списывает сумму с карты. Карта блокируется на время транзакции,
+// поэтому две кассы не смогут одновременно потратить один и тот же остаток.
+func (r *Repository) Redeem(ctx context.Context, code string, shopID, shopOwnerID int, amount float64, now time.Time) (int, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := scanCard(tx.QueryRow(ctx, `SELECT `+cardColumns+` FROM gift_cards WHERE code = $1 FOR UPDATE`, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrCardNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения подарочной карты: %w", err)
+	}
+	if err := c.UsableAt(shopID, shopOwnerID, now); err != nil {
+		return 0, err
+	}
+	if payment.Round(amount) > c.Balance {
+		return 0, fmt.Errorf("%w: остаток %.2f", ErrInsufficientBalance, c.Balance)
+	}
+
+	var entryID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO gift_card_entries (card_id, shop_id, type, amount)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, c.ID, shopID, EntryRedeem, -amount).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка записи списания с карты: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE gift_cards SET balance = balance - $1 WHERE id = $2`, amount, c.ID); err != nil {
+		return 0, fmt.Errorf("ошибка обновления остатка карты: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка списания с карты: %w", err)
+	}
+	return entryID, nil
+}
+
+// RefundRedemption возвращает на карту часть списания redeemID.
+// Суммарно по одному списанию нельзя вернуть больше, чем было списано.
+func (r *Repository) RefundRedemption(ctx context.Context, redeemID, shopID int, amount float64) (int, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var cardID int
+	var redeemed float64
+	err = tx.QueryRow(ctx, `
+		SELECT e.card_id, -e.amount
+		FROM gift_card_entries e
+		JOIN gift_cards c ON c.id = e.card_id
+		WHERE e.id = $1 AND e.type = $2
+		FOR UPDATE OF c
+	`, redeemID, EntryRedeem).Scan(&cardID, &redeemed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrCardNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения списания с карты: %w", err)
+	}
+
+	var refunded float64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM gift_card_entries WHERE parent_id = $1 AND type = $2
+	`, redeemID, EntryRefund).Scan(&refunded)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта возвратов на карту: %w", err)
+	}
+	if payment.Round(refunded+amount) > payment.Round(redeemed) {
+		return 0, payment.ErrRefundExceedsPaid
+	}
+
+	var entryID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO gift_card_entries (card_id, shop_id, type, amount, parent_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, cardID, shopID, EntryRefund, amount, redeemID).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка записи возврата на карту: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE gift_cards SET balance = balance + $1 WHERE id = $2`, amount, cardID); err != nil {
+		return 0, fmt.Errorf("ошибка обновления остатка карты: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка возврата на карту: %w", err)
+	}
+	return entryID, nil
+}
@@ -0,0 +1,184 @@
+package giftcard
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/payment"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrAccessDenied = errors.New("доступ запрещён: нет доступа к магазину")
+	// ErrOwnerOnly — без оплаты карту выпускает только владелец, на кассе карты продаются чеком
+	ErrOwnerOnly = errors.New("доступ запрещён: выпустить карту без оплаты может только владелец, продайте карту через чек")
+)
+
+// codeAlphabet — без похожих символов (0/O, 1/I), чтобы код было легко продиктовать
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+func (s *Service) checkAccess(ctx context.Context, shopID, userID int) error {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// NormalizeCode приводит введённый кассиром код к виду, в котором он хранится
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// generateCode — код вида XXXX-XXXX-XXXX-XXXX
+func generateCode() (string, error) {
+	var b strings.Builder
+	for i := 0; i < 16; i++ {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("ошибка генерации кода карты: %w", err)
+		}
+		b.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// NewCard проверяет номинал и срок действия и собирает карту с новым кодом.
+// Карту ещё нужно сохранить: Issue — при выпуске владельцем, InsertCard — вместе с чеком продажи.
+func NewCard(ownerID, shopID, issuedBy int, req IssueRequest) (*Card, error) {
+	amount := payment.Round(req.Amount)
+	if amount <= 0 {
+		return nil, errors.New("номинал карты должен быть больше нуля")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("срок действия карты должен быть в будущем")
+	}
+
+	c := &Card{OwnerID: ownerID, InitialBalance: amount, ExpiresAt: req.ExpiresAt, IssuedBy: issuedBy}
+	if !req.AllShops {
+		c.ShopID = &shopID
+	}
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+	c.Code = code
+	return c, nil
+}
+
+// Issue выпускает карту без оплаты (подарок, компенсация клиенту). Это может только владелец магазина —
+// покупателям карты продаются чеком (CreateSaleRequest.GiftCards), чтобы деньги прошли через кассу и ОФД.
+func (s *Service) Issue(ctx context.Context, userID, shopID int, req IssueRequest) (*Card, error) {
+	ok, err := s.employees.IsOwner(ctx, shopID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrOwnerOnly
+	}
+
+	c, err := NewCard(userID, shopID, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Совпадение кодов маловероятно, но уникальность гарантирует только база — пробуем несколько раз
+	for attempt := 0; ; attempt++ {
+		err = s.repo.IssueCard(ctx, c, shopID)
+		var pgErr *pgconn.PgError
+		if err != nil && errors.As(err, &pgErr) && pgErr.Code == "23505" && attempt < 3 {
+			if c.Code, err = generateCode(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// cardForShop — карта по коду, если она принадлежит организации магазина
+func (s *Service) cardForShop(ctx context.Context, userID, shopID int, code string) (*Card, int, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, 0, err
+	}
+	ownerID, err := s.employees.GetShopOwnerID(ctx, shopID)
+	if err != nil {
+		return nil, 0, err
+	}
+	c, err := s.repo.GetCardByCode(ctx, NormalizeCode(code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, ErrCardNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	// Карты чужих организаций не показываем вовсе
+	if c.OwnerID != ownerID {
+		return nil, 0, ErrCardNotFound
+	}
+	return c, ownerID, nil
+}
+
+// CheckBalance — остаток карты и можно ли ею расплатиться в этом магазине
+func (s *Service) CheckBalance(ctx context.Context, userID, shopID int, code string) (*BalanceInfo, error) {
+	c, ownerID, err := s.cardForShop(ctx, userID, shopID, code)
+	if err != nil {
+		return nil, err
+	}
+	info := &BalanceInfo{Code: c.Code, Balance: c.Balance, ExpiresAt: c.ExpiresAt, Usable: true}
+	if err := c.UsableAt(shopID, ownerID, time.Now()); err != nil {
+		info.Usable = false
+		info.Reason = err.Error()
+	} else if c.Balance <= 0 {
+		info.Usable = false
+		info.Reason = ErrInsufficientBalance.Error()
+	}
+	return info, nil
+}
+
+// GetLedger — журнал операций карты и остаток, пересчитанный по журналу
+func (s *Service) GetLedger(ctx context.Context, userID, shopID int, code string) (*Ledger, error) {
+	c, _, err := s.cardForShop(ctx, userID, shopID, code)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.GetEntries(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	l := &Ledger{Card: *c, Entries: entries}
+	for _, e := range entries {
+		l.ComputedBalance += e.Amount
+	}
+	l.ComputedBalance = payment.Round(l.ComputedBalance)
+	return l, nil
+}
+
+func (s *Service) GetCards(ctx context.Context, ownerID int) ([]Card, error) {
+	return s.repo.GetCardsByOwner(ctx, ownerID)
+}
@@ -0,0 +1,182 @@
+package impersonation
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrSessionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrSuperadmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func superadmin(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil || claims.Role != "superadmin" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+func sessionID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "impersonation_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID сеанса", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// Start godoc
+// @Summary Impersonate user
+// @Description Выдаёт superadmin токен, с которым он видит систему глазами пользователя (например, владельца при разборе обращения). В токене id, email и role — пользователя, actor_id — superadmin. Токен действует 30 минут (minutes — от 1 до 60) или до завершения сеанса. Начало, конец и каждый запрос сеанса с кодом ответа записываются в историю сеанса и в журнал действий организации пользователя (владельца магазинов, где работает сотрудник; у пользователя без организации — в его собственный). Смена пароля, email, 2FA, PIN, выпуск ключей и выгрузка персональных данных с таким токеном запрещены. Только для superadmin.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "User ID"
+// @Param request body StartRequest true "Основание и длительность"
+// @Success 201 {object} Started
+// @Failure 400 {string} string "укажите основание входа от имени пользователя"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "пользователь не найден"
+// @Router /admin/users/{id}/impersonate [post]
+func (h *Handler) Start(w http.ResponseWriter, r *http.Request) {
+	claims, ok := superadmin(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID", http.StatusBadRequest)
+		return
+	}
+
+	var req StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	started, err := h.service.Start(r.Context(), claims.ID, userID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(started)
+}
+
+// GetSessions godoc
+// @Summary List impersonation sessions
+// @Description Сеансы входа от имени пользователей, новые сверху, с числом запросов. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param actor_id query int false "ID superadmin"
+// @Param user_id query int false "ID пользователя"
+// @Param limit query int false "Не больше 100"
+// @Success 200 {array} Session
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /admin/impersonations [get]
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := superadmin(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	var f Filter
+	f.ActorID, _ = strconv.Atoi(q.Get("actor_id"))
+	f.UserID, _ = strconv.Atoi(q.Get("user_id"))
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	sessions, err := h.service.List(r.Context(), f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sessions)
+}
+
+// GetRequests godoc
+// @Summary Get impersonation session requests
+// @Description Все запросы, сделанные в сеансе, по порядку: метод, путь и код ответа. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param impersonation_id path int true "ID сеанса"
+// @Success 200 {array} Request
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "сеанс входа от имени пользователя не найден"
+// @Router /admin/impersonations/{impersonation_id}/requests [get]
+func (h *Handler) GetRequests(w http.ResponseWriter, r *http.Request) {
+	if _, ok := superadmin(w, r); !ok {
+		return
+	}
+	id, ok := sessionID(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := h.service.Requests(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(requests)
+}
+
+// End godoc
+// @Summary End impersonation session
+// @Description Завершает сеанс досрочно: токен сеанса сразу перестаёт приниматься. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param impersonation_id path int true "ID сеанса"
+// @Success 200 {object} Session
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "сеанс входа от имени пользователя не найден"
+// @Router /admin/impersonations/{impersonation_id}/end [post]
+func (h *Handler) End(w http.ResponseWriter, r *http.Request) {
+	if _, ok := superadmin(w, r); !ok {
+		return
+	}
+	id, ok := sessionID(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.service.End(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(session)
+}
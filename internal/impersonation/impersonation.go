@@ -0,0 +1,86 @@
+package impersonation
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Сеанс входа от имени пользователя короткий: по умолчанию 30 минут, не дольше часа
+	defaultTTL = 30 * time.Minute
+	maxTTL     = time.Hour
+
+	defaultLimit = 100
+
+	// Начало, конец и каждый запрос сеанса видны в журнале действий организации пользователя
+	ActionStart   = "impersonation.start"
+	ActionEnd     = "impersonation.end"
+	ActionRequest = "impersonation.request"
+)
+
+var (
+	ErrUserNotFound    = errors.New("пользователь не найден")
+	ErrSessionNotFound = errors.New("сеанс входа от имени пользователя не найден")
+	ErrSelf            = errors.New("нельзя войти от имени самого себя")
+	ErrSuperadmin      = errors.New("нельзя войти от имени другого superadmin")
+)
+
+// Session — сеанс, в котором superadmin (actor) работает от имени пользователя, чтобы увидеть то же, что и он
+type Session struct {
+	ID        int        `json:"id"`
+	ActorID   int        `json:"actor_id"`
+	UserID    int        `json:"user_id"`
+	Reason    string     `json:"reason"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Requests  int        `json:"requests"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// StartRequest — основание (например, номер обращения) и длительность сеанса в минутах
+type StartRequest struct {
+	Reason  string `json:"reason"`
+	Minutes int    `json:"minutes,omitempty"`
+}
+
+func (r *StartRequest) validate() (time.Duration, error) {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return 0, errors.New("укажите основание входа от имени пользователя")
+	}
+	if utf8.RuneCountInString(r.Reason) > 500 {
+		return 0, errors.New("основание слишком длинное")
+	}
+	if r.Minutes == 0 {
+		return defaultTTL, nil
+	}
+	ttl := time.Duration(r.Minutes) * time.Minute
+	if ttl < 0 || ttl > maxTTL {
+		return 0, errors.New("сеанс длится от 1 до 60 минут")
+	}
+	return ttl, nil
+}
+
+// Started — токен для работы от имени пользователя; действует до expires_at или до завершения сеанса
+type Started struct {
+	Token   string  `json:"token"`
+	Session Session `json:"session"`
+}
+
+// Request — запрос, выполненный в сеансе, с кодом ответа
+type Request struct {
+	ID        int       `json:"id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter — отбор сеансов; пустые поля не ограничивают выборку
+type Filter struct {
+	ActorID int
+	UserID  int
+	Limit   int
+}
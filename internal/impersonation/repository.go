@@ -0,0 +1,224 @@
+package impersonation
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS impersonations (
+			id SERIAL PRIMARY KEY,
+			actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			reason TEXT NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			ended_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS impersonation_requests (
+			id SERIAL PRIMARY KEY,
+			impersonation_id INT NOT NULL REFERENCES impersonations(id) ON DELETE CASCADE,
+			method VARCHAR(10) NOT NULL,
+			path TEXT NOT NULL,
+			status INT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS impersonation_requests_session ON impersonation_requests (impersonation_id, id);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции impersonations: %w", err)
+	}
+	fmt.Println("Миграция impersonations выполнена успешно")
+	return nil
+}
+
+// User — email и роль пользователя, от имени которого входит superadmin
+func (r *Repository) User(ctx context.Context, userID int) (string, string, error) {
+	var email, role string
+	err := r.db.Conn.QueryRow(ctx, `SELECT email, role FROM users WHERE id = $1`, userID).Scan(&email, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	return email, role, nil
+}
+
+// AuditOwners — журналы действий, в которые пишется сеанс: владелец пишет в свой, сотрудник —
+// в журналы владельцев всех магазинов, где работает. У пользователя без организации журнал свой.
+func (r *Repository) AuditOwners(ctx context.Context, userID int) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT DISTINCT s.owner_id
+		FROM employees e
+		JOIN shops s ON s.id = e.shop_id
+		JOIN users u ON u.id = e.user_id
+		WHERE e.user_id = $1 AND u.role <> 'owner' AND s.owner_id IS NOT NULL
+		ORDER BY s.owner_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения владельцев пользователя: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения владельца пользователя: %w", err)
+		}
+		owners = append(owners, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке владельцев пользователя: %w", err)
+	}
+	if len(owners) == 0 {
+		owners = []int{userID}
+	}
+	return owners, nil
+}
+
+func (r *Repository) Create(ctx context.Context, s *Session) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO impersonations (actor_id, user_id, reason, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, s.ActorID, s.UserID, s.Reason, s.ExpiresAt).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания сеанса входа от имени пользователя: %w", err)
+	}
+	return nil
+}
+
+const sessionColumns = `
+	i.id, COALESCE(i.actor_id, 0), i.user_id, i.reason, i.expires_at, i.ended_at, i.created_at,
+	(SELECT COUNT(*) FROM impersonation_requests q WHERE q.impersonation_id = i.id)`
+
+func scanSession(row pgx.Row, s *Session) error {
+	return row.Scan(&s.ID, &s.ActorID, &s.UserID, &s.Reason, &s.ExpiresAt, &s.EndedAt, &s.CreatedAt, &s.Requests)
+}
+
+func (r *Repository) Get(ctx context.Context, id int) (*Session, error) {
+	var s Session
+	err := scanSession(r.db.Conn.QueryRow(ctx, `SELECT `+sessionColumns+` FROM impersonations i WHERE i.id = $1`, id), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сеанса входа от имени пользователя: %w", err)
+	}
+	return &s, nil
+}
+
+// List — сеансы, новые сверху
+func (r *Repository) List(ctx context.Context, f Filter) ([]Session, error) {
+	if f.Limit <= 0 || f.Limit > defaultLimit {
+		f.Limit = defaultLimit
+	}
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+sessionColumns+`
+		FROM impersonations i
+		WHERE ($1 = 0 OR i.actor_id = $1)
+		  AND ($2 = 0 OR i.user_id = $2)
+		ORDER BY i.id DESC
+		LIMIT $3
+	`, f.ActorID, f.UserID, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сеансов входа от имени пользователя: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		if err := scanSession(rows, &s); err != nil {
+			return nil, fmt.Errorf("ошибка чтения сеанса входа от имени пользователя: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке сеансов входа от имени пользователя: %w", err)
+	}
+	return sessions, nil
+}
+
+// End завершает сеанс досрочно; false — сеанс уже был завершён раньше
+func (r *Repository) End(ctx context.Context, id int) (bool, error) {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE impersonations SET ended_at = NOW() WHERE id = $1 AND ended_at IS NULL
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("ошибка завершения сеанса входа от имени пользователя: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Active — сеанс не завершён и не истёк, его начал actorID, и он по-прежнему superadmin
+func (r *Repository) Active(ctx context.Context, id, actorID int, now time.Time) (bool, error) {
+	var active bool
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM impersonations i
+			JOIN users a ON a.id = i.actor_id
+			JOIN users u ON u.id = i.user_id
+			WHERE i.id = $1 AND i.actor_id = $2 AND i.ended_at IS NULL AND i.expires_at > $3
+			  AND a.role = 'superadmin' AND u.role <> 'superadmin'
+		)
+	`, id, actorID, now).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки сеанса входа от имени пользователя: %w", err)
+	}
+	return active, nil
+}
+
+func (r *Repository) LogRequest(ctx context.Context, id int, method, path string, status int) error {
+	if _, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO impersonation_requests (impersonation_id, method, path, status) VALUES ($1, $2, $3, $4)
+	`, id, method, path, status); err != nil {
+		return fmt.Errorf("ошибка записи запроса сеанса: %w", err)
+	}
+	return nil
+}
+
+// Requests — запросы сеанса по порядку
+func (r *Repository) Requests(ctx context.Context, id int) ([]Request, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, method, path, status, created_at
+		FROM impersonation_requests
+		WHERE impersonation_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения запросов сеанса: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []Request{}
+	for rows.Next() {
+		var q Request
+		if err := rows.Scan(&q.ID, &q.Method, &q.Path, &q.Status, &q.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения запроса сеанса: %w", err)
+		}
+		requests = append(requests, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке запросов сеанса: %w", err)
+	}
+	return requests, nil
+}
@@ -0,0 +1,123 @@
+package impersonation
+
+import (
+	"context"
+	"crm-backend/internal/audit"
+	"crm-backend/internal/auth"
+	"log"
+	"time"
+)
+
+type Service struct {
+	repo  *Repository
+	audit *audit.Repository
+}
+
+func NewService(repo *Repository, auditRepo *audit.Repository) *Service {
+	return &Service{repo: repo, audit: auditRepo}
+}
+
+// Start выдаёт superadmin actorID токен для работы от имени пользователя userID.
+// Начало сеанса попадает в журнал действий организации пользователя, какой бы ни была его роль.
+func (s *Service) Start(ctx context.Context, actorID, userID int, req StartRequest) (*Started, error) {
+	ttl, err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+	if actorID == userID {
+		return nil, ErrSelf
+	}
+	email, role, err := s.repo.User(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "superadmin" {
+		return nil, ErrSuperadmin
+	}
+
+	session := Session{ActorID: actorID, UserID: userID, Reason: req.Reason, ExpiresAt: time.Now().Add(ttl)}
+	if err := s.repo.Create(ctx, &session); err != nil {
+		return nil, err
+	}
+	token, err := auth.GenerateImpersonationJWT(actorID, session.ID, userID, email, role, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.record(ctx, ActionStart, &session, map[string]any{"reason": req.Reason, "expires_at": session.ExpiresAt}); err != nil {
+		return nil, err
+	}
+	log.Printf("superadmin ID=%d вошёл от имени пользователя ID=%d (сеанс ID=%d): %s", actorID, userID, session.ID, req.Reason)
+	return &Started{Token: token, Session: session}, nil
+}
+
+// End завершает сеанс досрочно: выданный токен сразу перестаёт приниматься
+func (s *Service) End(ctx context.Context, id int) (*Session, error) {
+	ended, err := s.repo.End(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ended {
+		return session, nil
+	}
+	if err := s.record(ctx, ActionEnd, session, map[string]any{"requests": session.Requests}); err != nil {
+		return nil, err
+	}
+	log.Printf("сеанс входа от имени пользователя ID=%d завершён", id)
+	return session, nil
+}
+
+// record пишет событие сеанса в журналы всех организаций пользователя (см. Repository.AuditOwners)
+func (s *Service) record(ctx context.Context, action string, session *Session, details map[string]any) error {
+	owners, err := s.repo.AuditOwners(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	details["user_id"] = session.UserID
+	for _, ownerID := range owners {
+		if err := s.audit.Record(ctx, &audit.Entry{
+			OwnerID:    ownerID,
+			ActorID:    session.ActorID,
+			Action:     action,
+			EntityType: "impersonation",
+			EntityID:   session.ID,
+			Details:    details,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) List(ctx context.Context, f Filter) ([]Session, error) {
+	return s.repo.List(ctx, f)
+}
+
+// Requests — все запросы сеанса с кодами ответов
+func (s *Service) Requests(ctx context.Context, id int) ([]Request, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Requests(ctx, id)
+}
+
+// ImpersonationActive и LogRequest подключаются к middleware (auth.ImpersonationStore)
+func (s *Service) ImpersonationActive(ctx context.Context, id, actorID int) (bool, error) {
+	return s.repo.Active(ctx, id, actorID, time.Now())
+}
+
+// LogRequest пишет запрос в историю сеанса (она остаётся указателем для superadmin)
+// и в журнал действий организации пользователя
+func (s *Service) LogRequest(ctx context.Context, id int, method, path string, status int) error {
+	if err := s.repo.LogRequest(ctx, id, method, path, status); err != nil {
+		return err
+	}
+	session, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.record(ctx, ActionRequest, session, map[string]any{"method": method, "path": path, "status": status})
+}
@@ -0,0 +1,183 @@
+package lockout
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func superadmin(w http.ResponseWriter, r *http.Request) bool {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil || claims.Role != "superadmin" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func filterFromQuery(r *http.Request) Filter {
+	q := r.URL.Query()
+	f := Filter{Email: q.Get("email"), IP: q.Get("ip"), FailedOnly: q.Get("failed") == "true"}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	return f
+}
+
+// UnlockUser godoc
+// @Summary Unlock user login
+// @Description Снимает блокировку входа с учётной записи и обнуляет счётчик неудачных попыток. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "пользователь не найден"
+// @Router /admin/users/{id}/unlock [post]
+func (h *Handler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.UnlockUser(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "unlocked"})
+}
+
+// GetUserLoginAttempts godoc
+// @Summary Get user login history
+// @Description История попыток входа по email пользователя, новые сверху: удачные, с неверным паролем и отклонённые из-за блокировки. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "User ID"
+// @Param failed query bool false "Только неудачные"
+// @Param limit query int false "Сколько записей вернуть (до 100)"
+// @Success 200 {array} Attempt
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "пользователь не найден"
+// @Router /admin/users/{id}/login-attempts [get]
+func (h *Handler) GetUserLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID", http.StatusBadRequest)
+		return
+	}
+
+	attempts, err := h.service.UserAttempts(r.Context(), id, filterFromQuery(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(attempts)
+}
+
+// GetLoginAttempts godoc
+// @Summary Get login history
+// @Description История попыток входа, новые сверху, в том числе с несуществующими email. Можно отобрать по email и адресу. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param email query string false "Email"
+// @Param ip query string false "IP-адрес"
+// @Param failed query bool false "Только неудачные"
+// @Param limit query int false "Сколько записей вернуть (до 100)"
+// @Success 200 {array} Attempt
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /admin/login-attempts [get]
+func (h *Handler) GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+
+	attempts, err := h.service.Attempts(r.Context(), filterFromQuery(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(attempts)
+}
+
+// GetLocks godoc
+// @Summary Get active login locks
+// @Description Действующие блокировки входа по email (kind=account) и адресу (kind=ip). Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Lock
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /admin/login-locks [get]
+func (h *Handler) GetLocks(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+
+	locks, err := h.service.ActiveLocks(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(locks)
+}
+
+// UnlockIP godoc
+// @Summary Unlock login from IP
+// @Description Снимает блокировку входа с адреса и обнуляет его счётчик неудачных попыток. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param ip path string true "IP-адрес"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /admin/login-locks/ip/{ip} [delete]
+func (h *Handler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	if !superadmin(w, r) {
+		return
+	}
+
+	if err := h.service.UnlockIP(r.Context(), chi.URLParam(r, "ip")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "unlocked"})
+}
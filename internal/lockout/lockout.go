@@ -0,0 +1,105 @@
+package lockout
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// Первые accountFreeFailures неудачных попыток подряд на учётную запись проходят без задержки,
+	// дальше каждая неудачная попытка блокирует вход вдвое дольше предыдущей: 30 с, 1 мин, 2 мин... до accountMaxLock
+	accountFreeFailures = 5
+	accountBaseLock     = 30 * time.Second
+	accountMaxLock      = 30 * time.Minute
+
+	// С одного адреса перебирают разные учётные записи, поэтому порог выше, а блокировка дольше
+	ipFreeFailures = 20
+	ipBaseLock     = time.Minute
+	ipMaxLock      = time.Hour
+
+	// failureWindow — счётчик начинается заново, если неудачных попыток не было дольше этого времени
+	failureWindow = time.Hour
+	// historyTTL — сколько хранится история попыток входа
+	historyTTL = 90 * 24 * time.Hour
+
+	defaultLimit = 100
+)
+
+// Kind — по чему считаются неудачные попытки
+type Kind string
+
+const (
+	KindAccount Kind = "account"
+	KindIP      Kind = "ip"
+)
+
+// Reason — результат попытки входа в истории
+type Reason string
+
+const (
+	ReasonOK            Reason = "ok"
+	ReasonUnknownEmail  Reason = "unknown_email"
+	ReasonWrongPassword Reason = "wrong_password"
+	ReasonWrongCode     Reason = "wrong_2fa_code"
+	// ReasonWrongResetCode — неверный код из SMS при сбросе пароля: перебор кода ограничивается так же, как перебор пароля
+	ReasonWrongResetCode Reason = "wrong_reset_code"
+	ReasonLocked         Reason = "locked"
+)
+
+var (
+	ErrLocked       = errors.New("слишком много неудачных попыток входа, попробуйте позже")
+	ErrUserNotFound = errors.New("пользователь не найден")
+)
+
+// LockedError — вход или проверка кода закрыты после неудачных попыток; RetryAfter — сколько ещё ждать
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string { return ErrLocked.Error() }
+
+func (e *LockedError) Unwrap() error { return ErrLocked }
+
+// Attempt — запись истории входа. Счётчики по учётной записи ведутся по email,
+// поэтому попытки с несуществующими адресами ограничиваются так же, как с настоящими.
+type Attempt struct {
+	ID        int       `json:"id"`
+	UserID    *int      `json:"user_id,omitempty"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	Success   bool      `json:"success"`
+	Reason    Reason    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter — отбор истории входа; пустые поля не ограничивают выборку
+type Filter struct {
+	Email      string
+	IP         string
+	FailedOnly bool
+	Limit      int
+}
+
+// Lock — счётчик неудачных попыток по учётной записи (email) или адресу
+type Lock struct {
+	Kind          Kind       `json:"kind"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+}
+
+// lockDuration — на сколько блокируется вход после failures неудачных попыток подряд
+func lockDuration(failures, free int, base, maxLock time.Duration) time.Duration {
+	if failures < free {
+		return 0
+	}
+	d := base
+	for i := free; i < failures && d < maxLock; i++ {
+		d *= 2
+	}
+	if d > maxLock {
+		d = maxLock
+	}
+	return d
+}
@@ -0,0 +1,35 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		free     int
+		base     time.Duration
+		maxLock  time.Duration
+		want     time.Duration
+	}{
+		{name: "первая ошибка", failures: 1, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: 0},
+		{name: "последняя бесплатная ошибка", failures: accountFreeFailures - 1, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: 0},
+		{name: "порог — базовая блокировка", failures: accountFreeFailures, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: 30 * time.Second},
+		{name: "каждая следующая ошибка удваивает", failures: accountFreeFailures + 1, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: time.Minute},
+		{name: "пятая после порога", failures: accountFreeFailures + 5, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: 16 * time.Minute},
+		{name: "упирается в максимум", failures: accountFreeFailures + 6, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: accountMaxLock},
+		{name: "много ошибок не переполняют длительность", failures: 10000, free: accountFreeFailures, base: accountBaseLock, maxLock: accountMaxLock, want: accountMaxLock},
+		{name: "адрес: ниже порога", failures: ipFreeFailures - 1, free: ipFreeFailures, base: ipBaseLock, maxLock: ipMaxLock, want: 0},
+		{name: "адрес: порог", failures: ipFreeFailures, free: ipFreeFailures, base: ipBaseLock, maxLock: ipMaxLock, want: time.Minute},
+		{name: "адрес: 32 минуты", failures: ipFreeFailures + 5, free: ipFreeFailures, base: ipBaseLock, maxLock: ipMaxLock, want: 32 * time.Minute},
+		{name: "адрес: максимум час", failures: ipFreeFailures + 6, free: ipFreeFailures, base: ipBaseLock, maxLock: ipMaxLock, want: time.Hour},
+		{name: "база больше максимума", failures: 1, free: 1, base: 2 * time.Hour, maxLock: time.Hour, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := lockDuration(tt.failures, tt.free, tt.base, tt.maxLock); got != tt.want {
+			t.Errorf("%s: lockDuration(%d) = %v, ожидалось %v", tt.name, tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package lockout
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS login_attempts (
+			id SERIAL PRIMARY KEY,
+			user_id INT REFERENCES users(id) ON DELETE SET NULL,
+			email VARCHAR(100) NOT NULL,
+			ip VARCHAR(64) NOT NULL,
+			success BOOLEAN NOT NULL,
+			reason VARCHAR(30) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS login_attempts_email ON login_attempts (email, created_at);
+		CREATE INDEX IF NOT EXISTS login_attempts_ip ON login_attempts (ip, created_at);
+
+		CREATE TABLE IF NOT EXISTS login_failures (
+			kind VARCHAR(20) NOT NULL,
+			key VARCHAR(100) NOT NULL,
+			failures INT NOT NULL DEFAULT 0,
+			locked_until TIMESTAMP WITH TIME ZONE,
+			last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (kind, key)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции login_attempts: %w", err)
+	}
+	fmt.Println("Миграция login_attempts выполнена успешно")
+	return nil
+}
+
+func (r *Repository) LogAttempt(ctx context.Context, a *Attempt) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO login_attempts (user_id, email, ip, success, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, a.UserID, a.Email, a.IP, a.Success, a.Reason).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка записи попытки входа: %w", err)
+	}
+	return nil
+}
+
+// LockedUntil — до какого времени вход закрыт для этого email или адреса; nil — не закрыт
+func (r *Repository) LockedUntil(ctx context.Context, email, ip string) (*time.Time, error) {
+	var until *time.Time
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT MAX(locked_until) FROM login_failures
+		WHERE (kind = $1 AND key = $2) OR (kind = $3 AND key = $4)
+	`, KindAccount, email, KindIP, ip).Scan(&until)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки блокировки входа: %w", err)
+	}
+	return until, nil
+}
+
+// AddFailure увеличивает счётчик и возвращает число неудачных попыток подряд.
+// Если последняя неудачная попытка была раньше windowStart, счёт начинается заново.
+func (r *Repository) AddFailure(ctx context.Context, kind Kind, key string, now, windowStart time.Time) (int, error) {
+	var failures int
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO login_failures (kind, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (kind, key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < $4 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = $3
+		RETURNING failures
+	`, kind, key, now, windowStart).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка учёта неудачной попытки входа: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *Repository) SetLockedUntil(ctx context.Context, kind Kind, key string, until time.Time) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE login_failures SET locked_until = $3 WHERE kind = $1 AND key = $2
+	`, kind, key, until)
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки входа: %w", err)
+	}
+	return nil
+}
+
+// Reset обнуляет счётчик и снимает блокировку
+func (r *Repository) Reset(ctx context.Context, kind Kind, key string) error {
+	_, err := r.db.Conn.Exec(ctx, `DELETE FROM login_failures WHERE kind = $1 AND key = $2`, kind, key)
+	if err != nil {
+		return fmt.Errorf("ошибка снятия блокировки входа: %w", err)
+	}
+	return nil
+}
+
+// ActiveLocks — блокировки, действующие на момент now
+func (r *Repository) ActiveLocks(ctx context.Context, now time.Time) ([]Lock, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT kind, key, failures, locked_until, last_failure_at
+		FROM login_failures
+		WHERE locked_until > $1
+		ORDER BY locked_until DESC
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения блокировок входа: %w", err)
+	}
+	defer rows.Close()
+
+	var locks []Lock
+	for rows.Next() {
+		var l Lock
+		if err := rows.Scan(&l.Kind, &l.Key, &l.Failures, &l.LockedUntil, &l.LastFailureAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения блокировки входа: %w", err)
+		}
+		locks = append(locks, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке блокировок входа: %w", err)
+	}
+	return locks, nil
+}
+
+// Attempts — история входа, новые сверху
+func (r *Repository) Attempts(ctx context.Context, f Filter) ([]Attempt, error) {
+	if f.Limit <= 0 || f.Limit > defaultLimit {
+		f.Limit = defaultLimit
+	}
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, user_id, email, ip, success, reason, created_at
+		FROM login_attempts
+		WHERE ($1 = '' OR email = $1)
+		  AND ($2 = '' OR ip = $2)
+		  AND (NOT $3 OR NOT success)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`, f.Email, f.IP, f.FailedOnly, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории входа: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []Attempt
+	for rows.Next() {
+		var a Attempt
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Email, &a.IP, &a.Success, &a.Reason, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения попытки входа: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке истории входа: %w", err)
+	}
+	return attempts, nil
+}
+
+// UserEmail — email пользователя в том виде, в каком по нему ведётся счётчик
+func (r *Repository) UserEmail(ctx context.Context, userID int) (string, error) {
+	var email string
+	err := r.db.Conn.QueryRow(ctx, `SELECT LOWER(email) FROM users WHERE id = $1`, userID).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	return email, nil
+}
+
+// DeleteOld удаляет историю старше attemptsBefore и счётчики без неудачных попыток с failuresBefore,
+// если блокировка уже истекла
+func (r *Repository) DeleteOld(ctx context.Context, attemptsBefore, failuresBefore time.Time) error {
+	if _, err := r.db.Conn.Exec(ctx, `DELETE FROM login_attempts WHERE created_at < $1`, attemptsBefore); err != nil {
+		return fmt.Errorf("ошибка очистки истории входа: %w", err)
+	}
+	if _, err := r.db.Conn.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+	`, failuresBefore); err != nil {
+		return fmt.Errorf("ошибка очистки счётчиков входа: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package lockout
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check возвращает, сколько ещё закрыт вход для этого email или адреса; 0 — можно входить.
+// Отклонённая попытка попадает в историю, но счётчики не увеличивает.
+func (s *Service) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	email = normalizeEmail(email)
+	now := time.Now()
+	until, err := s.repo.LockedUntil(ctx, email, ip)
+	if err != nil {
+		return 0, err
+	}
+	if until == nil || !until.After(now) {
+		return 0, nil
+	}
+	if err := s.repo.LogAttempt(ctx, &Attempt{Email: email, IP: ip, Reason: ReasonLocked}); err != nil {
+		log.Printf("ошибка записи попытки входа: %v", err)
+	}
+	return until.Sub(now), nil
+}
+
+// Failure учитывает неудачную попытку. userID — 0, если email не найден.
+// После порога каждая следующая неудача блокирует вход по email и адресу вдвое дольше.
+func (s *Service) Failure(ctx context.Context, userID int, email, ip string, reason Reason) error {
+	email = normalizeEmail(email)
+	a := &Attempt{Email: email, IP: ip, Reason: reason}
+	if userID != 0 {
+		a.UserID = &userID
+	}
+	if err := s.repo.LogAttempt(ctx, a); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.count(ctx, KindAccount, email, now, accountFreeFailures, accountBaseLock, accountMaxLock); err != nil {
+		return err
+	}
+	return s.count(ctx, KindIP, ip, now, ipFreeFailures, ipBaseLock, ipMaxLock)
+}
+
+func (s *Service) count(ctx context.Context, kind Kind, key string, now time.Time, free int, base, maxLock time.Duration) error {
+	failures, err := s.repo.AddFailure(ctx, kind, key, now, now.Add(-failureWindow))
+	if err != nil {
+		return err
+	}
+	d := lockDuration(failures, free, base, maxLock)
+	if d == 0 {
+		return nil
+	}
+	log.Printf("вход заблокирован (%s %s) на %s после %d неудачных попыток", kind, key, d, failures)
+	return s.repo.SetLockedUntil(ctx, kind, key, now.Add(d))
+}
+
+// Success записывает удачный вход и обнуляет счётчик учётной записи.
+// Счётчик адреса не сбрасывается: иначе перебор чужих паролей можно перемежать входом в свою учётную запись.
+func (s *Service) Success(ctx context.Context, userID int, email, ip string) error {
+	email = normalizeEmail(email)
+	if err := s.repo.LogAttempt(ctx, &Attempt{UserID: &userID, Email: email, IP: ip, Success: true, Reason: ReasonOK}); err != nil {
+		return err
+	}
+	return s.repo.Reset(ctx, KindAccount, email)
+}
+
+// UnlockUser снимает блокировку входа с учётной записи
+func (s *Service) UnlockUser(ctx context.Context, userID int) error {
+	email, err := s.repo.UserEmail(ctx, userID)
+	if err != nil {
+		return err
+	}
+	log.Printf("блокировка входа снята с пользователя ID=%d", userID)
+	return s.repo.Reset(ctx, KindAccount, email)
+}
+
+// UnlockIP снимает блокировку входа с адреса
+func (s *Service) UnlockIP(ctx context.Context, ip string) error {
+	log.Printf("блокировка входа снята с адреса %s", ip)
+	return s.repo.Reset(ctx, KindIP, ip)
+}
+
+// UserAttempts — история входа по текущему email пользователя
+func (s *Service) UserAttempts(ctx context.Context, userID int, f Filter) ([]Attempt, error) {
+	email, err := s.repo.UserEmail(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	f.Email = email
+	return s.repo.Attempts(ctx, f)
+}
+
+func (s *Service) Attempts(ctx context.Context, f Filter) ([]Attempt, error) {
+	f.Email = normalizeEmail(f.Email)
+	return s.repo.Attempts(ctx, f)
+}
+
+func (s *Service) ActiveLocks(ctx context.Context) ([]Lock, error) {
+	return s.repo.ActiveLocks(ctx, time.Now())
+}
+
+// RunCleanup раз в interval удаляет старую историю входа и забытые счётчики
+func (s *Service) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := s.repo.DeleteOld(ctx, now.Add(-historyTTL), now.Add(-failureWindow)); err != nil {
+				log.Printf("ошибка очистки истории входа: %v", err)
+			}
+		}
+	}
+}
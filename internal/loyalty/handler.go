@@ -0,0 +1,376 @@
+package loyalty
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrCustomerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ownerClaims — настройки программы меняет только владелец
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// GetProgram godoc
+// @Summary Get loyalty programme
+// @Description Настройки программы лояльности владельца: процент начисления, стоимость балла, срок жизни, уровни.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {object} Program
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty [get]
+func (h *Handler) GetProgram(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := h.service.GetProgram(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения программы лояльности", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// SaveProgram godoc
+// @Summary Save loyalty programme
+// @Description Сохраняет настройки программы лояльности и включает или выключает её.
+// @Tags loyalty
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param program body Program true "Настройки"
+// @Success 200 {object} Program
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty [put]
+func (h *Handler) SaveProgram(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var p Program
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SaveProgram(r.Context(), claims.ID, &p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// CreateEarnRate godoc
+// @Summary Create earn rate
+// @Description Особый процент начисления для магазина и/или категории товаров.
+// @Tags loyalty
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param rate body EarnRate true "Правило начисления"
+// @Success 201 {object} EarnRate
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty/earn-rates [post]
+func (h *Handler) CreateEarnRate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var rate EarnRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateEarnRate(r.Context(), claims.ID, &rate); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rate)
+}
+
+// GetEarnRates godoc
+// @Summary Get earn rates
+// @Description Особые проценты начисления владельца.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} EarnRate
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty/earn-rates [get]
+func (h *Handler) GetEarnRates(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	rates, err := h.service.GetEarnRates(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения правил начисления", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rates)
+}
+
+// DeleteEarnRate godoc
+// @Summary Delete earn rate
+// @Description Удаляет особый процент начисления.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param rate_id path int true "Rate ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "неправильный ID правила"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty/earn-rates/{rate_id} [delete]
+func (h *Handler) DeleteEarnRate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "rate_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID правила", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteEarnRate(r.Context(), claims.ID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// CreateBonus godoc
+// @Summary Create bonus campaign
+// @Description Бонусная кампания: умножение баллов в день рождения (kind=birthday, ± window_days) или в период (kind=period).
+// @Tags loyalty
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param bonus body Bonus true "Бонус"
+// @Success 201 {object} Bonus
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty/bonuses [post]
+func (h *Handler) CreateBonus(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var b Bonus
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateBonus(r.Context(), claims.ID, &b); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(b)
+}
+
+// GetBonuses godoc
+// @Summary Get bonus campaigns
+// @Description Бонусные кампании владельца.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Bonus
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty/bonuses [get]
+func (h *Handler) GetBonuses(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	bonuses, err := h.service.GetBonuses(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения бонусов", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bonuses)
+}
+
+// DeleteBonus godoc
+// @Summary Delete bonus campaign
+// @Description Удаляет бонусную кампанию.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param bonus_id path int true "Bonus ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "неправильный ID бонуса"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/loyalty/bonuses/{bonus_id} [delete]
+func (h *Handler) DeleteBonus(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "bonus_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID бонуса", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteBonus(r.Context(), claims.ID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+func customerParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	customerID, err := strconv.Atoi(chi.URLParam(r, "customer_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID покупателя", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, customerID, true
+}
+
+// GetAccount godoc
+// @Summary Get customer loyalty account
+// @Description Баланс баллов, уровень покупателя и сколько осталось до следующего уровня.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Customer ID"
+// @Success 200 {object} Account
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Router /shops/{id}/customers/{customer_id}/loyalty [get]
+func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	acc, err := h.service.GetAccount(r.Context(), claims.ID, shopID, customerID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(acc)
+}
+
+// GetLedger godoc
+// @Summary Get customer points ledger
+// @Description Журнал баллов покупателя: начисления, оплаты, возвраты, отмены и сгорания.
+// @Tags loyalty
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param customer_id path int true "Customer ID"
+// @Success 200 {array} Entry
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Router /shops/{id}/customers/{customer_id}/loyalty/ledger [get]
+func (h *Handler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, customerID, ok := customerParams(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.service.GetLedger(r.Context(), claims.ID, shopID, customerID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
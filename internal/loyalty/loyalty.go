@@ -0,0 +1,247 @@
+package loyalty
+
+import (
+	"errors"
+	"time"
+)
+
+type Tier string
+
+const (
+	TierBase   Tier = "base"
+	TierSilver Tier = "silver"
+	TierGold   Tier = "gold"
+)
+
+// EntryType — операция в журнале баллов
+type EntryType string
+
+const (
+	EntryEarn    EntryType = "earn"    // начисление за покупку
+	EntryRedeem  EntryType = "redeem"  // оплата баллами
+	EntryRestore EntryType = "restore" // возврат баллов, которыми оплатили возвращённый товар
+	EntryReverse EntryType = "reverse" // списание начисленного за возвращённый товар
+	EntryExpire  EntryType = "expire"  // сгорание
+)
+
+const (
+	BonusBirthday = "birthday"
+	BonusPeriod   = "period"
+)
+
+var (
+	ErrAccessDenied       = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrCustomerNotFound   = errors.New("покупатель не найден")
+	ErrProgramDisabled    = errors.New("программа лояльности не включена")
+	ErrCustomerRequired   = errors.New("для оплаты баллами укажите покупателя")
+	ErrInsufficientPoints = errors.New("недостаточно баллов")
+)
+
+// Program — настройки программы лояльности владельца.
+// Баллы начисляются в процентах от оплаченной суммы; один балл стоит PointValue тенге.
+// Уровень покупателя считается по сумме покупок за последние TierWindowDays дней
+// и умножает начисление.
+type Program struct {
+	OwnerID          int       `json:"owner_id"`
+	Enabled          bool      `json:"enabled"`
+	EarnPercent      float64   `json:"earn_percent"`
+	PointValue       float64   `json:"point_value"`
+	ExpiryDays       int       `json:"expiry_days"`
+	TierWindowDays   int       `json:"tier_window_days"`
+	SilverSpend      float64   `json:"silver_spend"`
+	GoldSpend        float64   `json:"gold_spend"`
+	SilverMultiplier float64   `json:"silver_multiplier"`
+	GoldMultiplier   float64   `json:"gold_multiplier"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// DefaultProgram — настройки, пока владелец ничего не сохранял (программа выключена)
+func DefaultProgram(ownerID int) Program {
+	return Program{
+		OwnerID:          ownerID,
+		EarnPercent:      3,
+		PointValue:       1,
+		ExpiryDays:       365,
+		TierWindowDays:   365,
+		SilverSpend:      200000,
+		GoldSpend:        500000,
+		SilverMultiplier: 1.5,
+		GoldMultiplier:   2,
+	}
+}
+
+func (p Program) Validate() error {
+	switch {
+	case p.EarnPercent < 0 || p.EarnPercent > 100:
+		return errors.New("процент начисления должен быть от 0 до 100")
+	case p.PointValue <= 0:
+		return errors.New("стоимость балла должна быть больше нуля")
+	case p.ExpiryDays < 0:
+		return errors.New("срок жизни баллов не может быть отрицательным")
+	case p.TierWindowDays <= 0:
+		return errors.New("период расчёта уровня должен быть больше нуля")
+	case p.GoldSpend < p.SilverSpend:
+		return errors.New("порог gold не может быть ниже порога silver")
+	case p.SilverMultiplier < 1 || p.GoldMultiplier < 1:
+		return errors.New("множитель уровня не может быть меньше 1")
+	}
+	return nil
+}
+
+// TierFor — уровень и множитель начисления по сумме покупок за период
+func (p Program) TierFor(spend float64) (Tier, float64) {
+	switch {
+	case p.GoldSpend > 0 && spend >= p.GoldSpend:
+		return TierGold, p.GoldMultiplier
+	case p.SilverSpend > 0 && spend >= p.SilverSpend:
+		return TierSilver, p.SilverMultiplier
+	}
+	return TierBase, 1
+}
+
+// ExpiresAt — когда сгорят баллы, начисленные в момент now (nil — бессрочно)
+func (p Program) ExpiresAt(now time.Time) *time.Time {
+	if p.ExpiryDays == 0 {
+		return nil
+	}
+	t := now.AddDate(0, 0, p.ExpiryDays)
+	return &t
+}
+
+// EarnRate — процент начисления для магазина и/или категории вместо общего
+type EarnRate struct {
+	ID       int     `json:"id"`
+	OwnerID  int     `json:"owner_id"`
+	ShopID   *int    `json:"shop_id,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Percent  float64 `json:"percent"`
+}
+
+// rateFor выбирает самое точное правило: магазин+категория, категория, магазин, иначе общий процент
+func rateFor(rates []EarnRate, shopID int, category string, fallback float64) float64 {
+	best, bestScore := fallback, 0
+	for _, r := range rates {
+		score := 0
+		if r.ShopID != nil {
+			if *r.ShopID != shopID {
+				continue
+			}
+			score++
+		}
+		if r.Category != "" {
+			if r.Category != category {
+				continue
+			}
+			score += 2
+		}
+		if score > bestScore {
+			best, bestScore = r.Percent, score
+		}
+	}
+	return best
+}
+
+// Bonus — бонусная кампания: умножает начисление в день рождения покупателя
+// (± WindowDays) или в заданный период
+type Bonus struct {
+	ID         int        `json:"id"`
+	OwnerID    int        `json:"owner_id"`
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`
+	Multiplier float64    `json:"multiplier"`
+	WindowDays int        `json:"window_days"`
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (b Bonus) Validate() error {
+	if b.Name == "" {
+		return errors.New("название бонуса обязательно")
+	}
+	if b.Multiplier <= 1 {
+		return errors.New("множитель бонуса должен быть больше 1")
+	}
+	switch b.Kind {
+	case BonusBirthday:
+		if b.WindowDays < 0 {
+			return errors.New("окно дня рождения не может быть отрицательным")
+		}
+	case BonusPeriod:
+		if b.StartsAt == nil || b.EndsAt == nil || !b.EndsAt.After(*b.StartsAt) {
+			return errors.New("для бонуса на период нужны начало и конец")
+		}
+	default:
+		return errors.New("неизвестный тип бонуса")
+	}
+	return nil
+}
+
+// AppliesAt — действует ли бонус для покупателя с днём рождения birthday в момент now
+func (b Bonus) AppliesAt(now time.Time, birthday *time.Time) bool {
+	if !b.Active {
+		return false
+	}
+	switch b.Kind {
+	case BonusPeriod:
+		return b.StartsAt != nil && b.EndsAt != nil && !now.Before(*b.StartsAt) && now.Before(*b.EndsAt)
+	case BonusBirthday:
+		if birthday == nil {
+			return false
+		}
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		// Сравниваем с днём рождения в этом, прошлом и следующем году — окно может переходить через Новый год
+		for _, year := range []int{now.Year() - 1, now.Year(), now.Year() + 1} {
+			bd := time.Date(year, birthday.Month(), birthday.Day(), 0, 0, 0, 0, time.UTC)
+			days := int(today.Sub(bd).Hours() / 24)
+			if days >= -b.WindowDays && days <= b.WindowDays {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Entry — операция журнала баллов. Points со знаком.
+// У начислений и возвращённых баллов Remaining — сколько из них ещё не потрачено и не сгорело.
+type Entry struct {
+	ID         int        `json:"id"`
+	OwnerID    int        `json:"owner_id"`
+	CustomerID int        `json:"customer_id"`
+	Type       EntryType  `json:"type"`
+	Points     float64    `json:"points"`
+	Amount     float64    `json:"amount,omitempty"`
+	Remaining  float64    `json:"remaining,omitempty"`
+	SaleID     *int       `json:"sale_id,omitempty"`
+	ReturnID   *int       `json:"return_id,omitempty"`
+	ParentID   *int       `json:"parent_id,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Account — состояние покупателя в программе лояльности
+type Account struct {
+	CustomerID   int     `json:"customer_id"`
+	Balance      float64 `json:"balance"`
+	BalanceValue float64 `json:"balance_value"`
+	Tier         Tier    `json:"tier"`
+	RollingSpend float64 `json:"rolling_spend"`
+	NextTier     Tier    `json:"next_tier,omitempty"`
+	ToNextTier   float64 `json:"to_next_tier,omitempty"`
+}
+
+// Purchase — данные проведённого чека для начисления баллов
+type Purchase struct {
+	ShopID     int
+	CustomerID int
+	SaleID     int
+	Lines      []PurchaseLine
+	// PointsPaid — часть чека (в тенге), оплаченная баллами; на неё баллы не начисляются
+	PointsPaid float64
+}
+
+type PurchaseLine struct {
+	Category string
+	Amount   float64
+}
@@ -0,0 +1,64 @@
+package loyalty
+
+import (
+	"context"
+	"crm-backend/internal/payment"
+	"fmt"
+	"time"
+)
+
+// Provider — оплата баллами (способ оплаты loyalty_points). Баллы списываются
+// с покупателя чека; при возврате товара возвращаются на его счёт.
+type Provider struct {
+	service *Service
+}
+
+func NewProvider(service *Service) *Provider {
+	return &Provider{service: service}
+}
+
+func (p *Provider) Charge(ctx context.Context, req payment.ChargeRequest) (string, error) {
+	if req.CustomerID == 0 {
+		return "", ErrCustomerRequired
+	}
+	program, err := p.service.programForShop(ctx, req.ShopID)
+	if err != nil {
+		return "", err
+	}
+	ok, err := p.service.customers.BelongsTo(ctx, req.CustomerID, program.OwnerID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrCustomerNotFound
+	}
+
+	amount := payment.Round(req.Amount)
+	points := payment.Round(amount / program.PointValue)
+	entryID, err := p.service.repo.Redeem(ctx, program.OwnerID, req.CustomerID, points, amount, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return redeemRef(entryID), nil
+}
+
+func (p *Provider) Refund(ctx context.Context, req payment.RefundRequest) (string, error) {
+	var redeemID int
+	if _, err := fmt.Sscanf(req.ProviderRef, "LP-%d", &redeemID); err != nil {
+		return "", fmt.Errorf("неизвестная операция с баллами: %s", req.ProviderRef)
+	}
+	ownerID, err := p.service.employees.GetShopOwnerID(ctx, req.ShopID)
+	if err != nil {
+		return "", err
+	}
+	program, err := p.service.GetProgram(ctx, ownerID)
+	if err != nil {
+		return "", err
+	}
+	// Возврат проходит, даже если программу успели выключить: баллы принадлежат покупателю
+	entryID, err := p.service.repo.Restore(ctx, redeemID, payment.Round(req.Amount), program.ExpiresAt(time.Now()))
+	if err != nil {
+		return "", err
+	}
+	return redeemRef(entryID), nil
+}
@@ -0,0 +1,568 @@
+package loyalty
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"crm-backend/internal/payment"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS loyalty_programs (
+			owner_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			earn_percent NUMERIC(5, 2) NOT NULL,
+			point_value NUMERIC(10, 2) NOT NULL,
+			expiry_days INT NOT NULL,
+			tier_window_days INT NOT NULL,
+			silver_spend NUMERIC(12, 2) NOT NULL,
+			gold_spend NUMERIC(12, 2) NOT NULL,
+			silver_multiplier NUMERIC(4, 2) NOT NULL,
+			gold_multiplier NUMERIC(4, 2) NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS loyalty_earn_rates (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT REFERENCES shops(id) ON DELETE CASCADE,
+			category VARCHAR(255),
+			percent NUMERIC(5, 2) NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS loyalty_bonuses (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			multiplier NUMERIC(4, 2) NOT NULL,
+			window_days INT NOT NULL DEFAULT 0,
+			starts_at TIMESTAMP WITH TIME ZONE,
+			ends_at TIMESTAMP WITH TIME ZONE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS loyalty_entries (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL,
+			points NUMERIC(12, 2) NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			remaining NUMERIC(12, 2) NOT NULL DEFAULT 0,
+			sale_id INT REFERENCES sales(id) ON DELETE SET NULL,
+			return_id INT REFERENCES sale_returns(id) ON DELETE SET NULL,
+			parent_id INT REFERENCES loyalty_entries(id),
+			expires_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS loyalty_entries_customer ON loyalty_entries (customer_id);
+		CREATE INDEX IF NOT EXISTS loyalty_entries_expiring ON loyalty_entries (expires_at) WHERE remaining > 0;
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции loyalty: %w", err)
+	}
+	fmt.Println("Миграция loyalty выполнена успешно")
+	return nil
+}
+
+func (r *Repository) GetProgram(ctx context.Context, ownerID int) (*Program, error) {
+	var p Program
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT owner_id, enabled, earn_percent, point_value, expiry_days, tier_window_days,
+		       silver_spend, gold_spend, silver_multiplier, gold_multiplier, updated_at
+		FROM loyalty_programs
+		WHERE owner_id = $1
+	`, ownerID).Scan(&p.OwnerID, &p.Enabled, &p.EarnPercent, &p.PointValue, &p.ExpiryDays, &p.TierWindowDays,
+		&p.SilverSpend, &p.GoldSpend, &p.SilverMultiplier, &p.GoldMultiplier, &p.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения программы лояльности: %w", err)
+	}
+	return &p, nil
+}
+
+func (r *Repository) SaveProgram(ctx context.Context, p *Program) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO loyalty_programs
+			(owner_id, enabled, earn_percent, point_value, expiry_days, tier_window_days,
+			 silver_spend, gold_spend, silver_multiplier, gold_multiplier)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (owner_id) DO UPDATE
+		SET enabled = EXCLUDED.enabled, earn_percent = EXCLUDED.earn_percent, point_value = EXCLUDED.point_value,
+		    expiry_days = EXCLUDED.expiry_days, tier_window_days = EXCLUDED.tier_window_days,
+		    silver_spend = EXCLUDED.silver_spend, gold_spend = EXCLUDED.gold_spend,
+		    silver_multiplier = EXCLUDED.silver_multiplier, gold_multiplier = EXCLUDED.gold_multiplier,
+		    updated_at = NOW()
+		RETURNING updated_at
+	`, p.OwnerID, p.Enabled, p.EarnPercent, p.PointValue, p.ExpiryDays, p.TierWindowDays,
+		p.SilverSpend, p.GoldSpend, p.SilverMultiplier, p.GoldMultiplier).Scan(&p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения программы лояльности: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) CreateEarnRate(ctx context.Context, rate *EarnRate) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO loyalty_earn_rates (owner_id, shop_id, category, percent)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		RETURNING id
+	`, rate.OwnerID, rate.ShopID, rate.Category, rate.Percent).Scan(&rate.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка создания правила начисления: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetEarnRates(ctx context.Context, ownerID int) ([]EarnRate, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, owner_id, shop_id, COALESCE(category, ''), percent
+		FROM loyalty_earn_rates
+		WHERE owner_id = $1
+		ORDER BY id
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения правил начисления: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []EarnRate
+	for rows.Next() {
+		var rate EarnRate
+		if err := rows.Scan(&rate.ID, &rate.OwnerID, &rate.ShopID, &rate.Category, &rate.Percent); err != nil {
+			return nil, fmt.Errorf("ошибка чтения правила начисления: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке правил начисления: %w", err)
+	}
+	return rates, nil
+}
+
+func (r *Repository) DeleteEarnRate(ctx context.Context, ownerID, id int) error {
+	res, err := r.db.Conn.Exec(ctx, `DELETE FROM loyalty_earn_rates WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления правила начисления (ID=%d): %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("правило начисления с ID %d не найдено", id)
+	}
+	return nil
+}
+
+func (r *Repository) CreateBonus(ctx context.Context, b *Bonus) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO loyalty_bonuses (owner_id, name, kind, multiplier, window_days, starts_at, ends_at, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, b.OwnerID, b.Name, b.Kind, b.Multiplier, b.WindowDays, b.StartsAt, b.EndsAt, b.Active).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания бонуса: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetBonuses(ctx context.Context, ownerID int) ([]Bonus, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, owner_id, name, kind, multiplier, window_days, starts_at, ends_at, active, created_at
+		FROM loyalty_bonuses
+		WHERE owner_id = $1
+		ORDER BY id
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения бонусов: %w", err)
+	}
+	defer rows.Close()
+
+	var bonuses []Bonus
+	for rows.Next() {
+		var b Bonus
+		if err := rows.Scan(&b.ID, &b.OwnerID, &b.Name, &b.Kind, &b.Multiplier, &b.WindowDays,
+			&b.StartsAt, &b.EndsAt, &b.Active, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения бонуса: %w", err)
+		}
+		bonuses = append(bonuses, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке бонусов: %w", err)
+	}
+	return bonuses, nil
+}
+
+func (r *Repository) DeleteBonus(ctx context.Context, ownerID, id int) error {
+	res, err := r.db.Conn.Exec(ctx, `DELETE FROM loyalty_bonuses WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления бонуса (ID=%d): %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("бонус с ID %d не найден", id)
+	}
+	return nil
+}
+
+// GetBalance — остаток баллов покупателя, сумма всех операций журнала
+func (r *Repository) GetBalance(ctx context.Context, customerID int) (float64, error) {
+	var balance float64
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT COALESCE(SUM(points), 0) FROM loyalty_entries WHERE customer_id = $1
+	`, customerID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения баланса баллов: %w", err)
+	}
+	return balance, nil
+}
+
+func (r *Repository) GetEntries(ctx context.Context, customerID int) ([]Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, owner_id, customer_id, type, points, amount, remaining, sale_id, return_id, parent_id,
+		       expires_at, created_at
+		FROM loyalty_entries
+		WHERE customer_id = $1
+		ORDER BY id DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала баллов: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.OwnerID, &e.CustomerID, &e.Type, &e.Points, &e.Amount, &e.Remaining,
+			&e.SaleID, &e.ReturnID, &e.ParentID, &e.ExpiresAt, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения операции с баллами: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке журнала баллов: %w", err)
+	}
+	return entries, nil
+}
+
+// RollingSpend — сумма покупок покупателя (за вычетом возвратов) начиная с since
+func (r *Repository) RollingSpend(ctx context.Context, customerID int, since time.Time) (float64, error) {
+	var spend float64
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT COALESCE(SUM(total - refunded), 0) FROM sales WHERE customer_id = $1 AND created_at >= $2
+	`, customerID, since).Scan(&spend)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта суммы покупок: %w", err)
+	}
+	return spend, nil
+}
+
+// Earn записывает начисление за покупку. Одна покупка — одно начисление.
+func (r *Repository) Earn(ctx context.Context, e *Entry) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCustomer(ctx, tx, e.CustomerID); err != nil {
+		return err
+	}
+	remaining, err := lotRemaining(ctx, tx, e.CustomerID, e.Points)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO loyalty_entries (owner_id, customer_id, type, points, amount, remaining, sale_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, remaining, created_at
+	`, e.OwnerID, e.CustomerID, EntryEarn, e.Points, e.Amount, remaining, e.SaleID, e.ExpiresAt).Scan(&e.ID, &e.Remaining, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка начисления баллов: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка начисления баллов: %w", err)
+	}
+	return nil
+}
+
+// lotRemaining — сколько из новых баллов можно тратить. Если баланс ушёл в минус
+// (отменили начисление за уже потраченные баллы), новые баллы сначала гасят долг.
+func lotRemaining(ctx context.Context, tx pgx.Tx, customerID int, points float64) (float64, error) {
+	var balance float64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(points), 0) FROM loyalty_entries WHERE customer_id = $1
+	`, customerID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения баланса баллов: %w", err)
+	}
+	if balance >= 0 {
+		return points, nil
+	}
+	if points+balance <= 0 {
+		return 0, nil
+	}
+	return payment.Round(points + balance), nil
+}
+
+// lockCustomer блокирует покупателя до конца транзакции, чтобы операции с его баллами шли по очереди
+func lockCustomer(ctx context.Context, tx pgx.Tx, customerID int) error {
+	var id int
+	err := tx.QueryRow(ctx, `SELECT id FROM customers WHERE id = $1 FOR UPDATE`, customerID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrCustomerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки покупателя: %w", err)
+	}
+	return nil
+}
+
+// consume уменьшает остаток непотраченных начислений: сначала preferLot (если задан),
+// затем по порядку сгорания. Возвращает то, что списать не удалось.
+func consume(ctx context.Context, tx pgx.Tx, customerID int, points float64, preferLot int, now time.Time) (float64, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, remaining
+		FROM loyalty_entries
+		WHERE customer_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY (id = $3) DESC, expires_at NULLS LAST, id
+	`, customerID, now, preferLot)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения начислений: %w", err)
+	}
+	type lot struct {
+		id        int
+		remaining float64
+	}
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.remaining); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка чтения начисления: %w", err)
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка при обработке начислений: %w", err)
+	}
+
+	left := payment.Round(points)
+	for _, l := range lots {
+		if left <= 0 {
+			break
+		}
+		take := l.remaining
+		if take > left {
+			take = left
+		}
+		if _, err := tx.Exec(ctx, `UPDATE loyalty_entries SET remaining = remaining - $1 WHERE id = $2`, take, l.id); err != nil {
+			return 0, fmt.Errorf("ошибка списания с начисления: %w", err)
+		}
+		left = payment.Round(left - take)
+	}
+	return left, nil
+}
+
+// Redeem списывает баллы в оплату чека
+func (r *Repository) Redeem(ctx context.Context, ownerID, customerID int, points, amount float64, now time.Time) (int, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCustomer(ctx, tx, customerID); err != nil {
+		return 0, err
+	}
+
+	var available float64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(remaining), 0)
+		FROM loyalty_entries
+		WHERE customer_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at > $2)
+	`, customerID, now).Scan(&available)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения доступных баллов: %w", err)
+	}
+	if payment.Round(points) > available {
+		return 0, fmt.Errorf("%w: доступно %.2f", ErrInsufficientPoints, available)
+	}
+	if _, err := consume(ctx, tx, customerID, points, 0, now); err != nil {
+		return 0, err
+	}
+
+	var entryID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO loyalty_entries (owner_id, customer_id, type, points, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, ownerID, customerID, EntryRedeem, -points, amount).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка записи оплаты баллами: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка оплаты баллами: %w", err)
+	}
+	return entryID, nil
+}
+
+// Restore возвращает баллы, которыми была оплачена часть чека (amount — сумма возврата в тенге).
+// Баллы пересчитываются по курсу исходного списания, а не текущему.
+func (r *Repository) Restore(ctx context.Context, redeemID int, amount float64, expiresAt *time.Time) (int, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var ownerID, customerID int
+	var redeemedPoints, redeemedAmount float64
+	err = tx.QueryRow(ctx, `
+		SELECT owner_id, customer_id, -points, amount FROM loyalty_entries WHERE id = $1 AND type = $2
+	`, redeemID, EntryRedeem).Scan(&ownerID, &customerID, &redeemedPoints, &redeemedAmount)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения оплаты баллами (ID=%d): %w", redeemID, err)
+	}
+	if err := lockCustomer(ctx, tx, customerID); err != nil {
+		return 0, err
+	}
+
+	var restored float64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM loyalty_entries WHERE parent_id = $1 AND type = $2
+	`, redeemID, EntryRestore).Scan(&restored)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта возвращённых баллов: %w", err)
+	}
+	if payment.Round(restored+amount) > payment.Round(redeemedAmount) {
+		return 0, payment.ErrRefundExceedsPaid
+	}
+
+	points := payment.Round(redeemedPoints * amount / redeemedAmount)
+	remaining, err := lotRemaining(ctx, tx, customerID, points)
+	if err != nil {
+		return 0, err
+	}
+	var entryID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO loyalty_entries (owner_id, customer_id, type, points, amount, remaining, parent_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, ownerID, customerID, EntryRestore, points, amount, remaining, redeemID, expiresAt).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка возврата баллов: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка возврата баллов: %w", err)
+	}
+	return entryID, nil
+}
+
+// Reverse списывает долю share баллов, начисленных за чек saleID, при возврате returnID.
+// Если баллы уже потрачены, баланс уходит в минус и погасится следующими начислениями.
+func (r *Repository) Reverse(ctx context.Context, saleID, returnID int, share float64, now time.Time) (float64, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var earnID, ownerID, customerID int
+	var earned float64
+	err = tx.QueryRow(ctx, `
+		SELECT id, owner_id, customer_id, points FROM loyalty_entries WHERE sale_id = $1 AND type = $2
+	`, saleID, EntryEarn).Scan(&earnID, &ownerID, &customerID, &earned)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// За чек ничего не начисляли
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения начисления за чек ID=%d: %w", saleID, err)
+	}
+	if err := lockCustomer(ctx, tx, customerID); err != nil {
+		return 0, err
+	}
+
+	var reversed float64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(-SUM(points), 0) FROM loyalty_entries WHERE parent_id = $1 AND type = $2
+	`, earnID, EntryReverse).Scan(&reversed)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта отменённых баллов: %w", err)
+	}
+
+	points := payment.Round(earned * share)
+	if points > payment.Round(earned-reversed) {
+		points = payment.Round(earned - reversed)
+	}
+	if points <= 0 {
+		return 0, nil
+	}
+
+	// Сначала забираем из самого начисления за этот чек, остальное — из других
+	if _, err := consume(ctx, tx, customerID, points, earnID, now); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO loyalty_entries (owner_id, customer_id, type, points, sale_id, return_id, parent_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, ownerID, customerID, EntryReverse, -points, saleID, returnID, earnID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отмены начисления: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка отмены начисления: %w", err)
+	}
+	return points, nil
+}
+
+// ExpireDue списывает сгоревшие остатки начислений
+func (r *Repository) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `
+		WITH expired AS (
+			UPDATE loyalty_entries e
+			SET remaining = 0
+			FROM (
+				SELECT id, remaining FROM loyalty_entries
+				WHERE remaining > 0 AND expires_at <= $1
+				FOR UPDATE
+			) due
+			WHERE e.id = due.id
+			RETURNING e.id, e.owner_id, e.customer_id, due.remaining
+		)
+		INSERT INTO loyalty_entries (owner_id, customer_id, type, points, parent_id)
+		SELECT owner_id, customer_id, $2, -remaining, id FROM expired
+	`, now, EntryExpire)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сгорания баллов: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка сгорания баллов: %w", err)
+	}
+	return int(res.RowsAffected()), nil
+}
@@ -0,0 +1,280 @@
+package loyalty
+
+import (
+	"context"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/payment"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	customers *customer.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository, customers *customer.Repository) *Service {
+	return &Service{repo: repo, employees: employees, customers: customers}
+}
+
+// GetProgram — настройки владельца; если их ещё не сохраняли, программа выключена
+func (s *Service) GetProgram(ctx context.Context, ownerID int) (*Program, error) {
+	p, err := s.repo.GetProgram(ctx, ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		def := DefaultProgram(ownerID)
+		return &def, nil
+	}
+	return p, err
+}
+
+func (s *Service) SaveProgram(ctx context.Context, ownerID int, p *Program) error {
+	p.OwnerID = ownerID
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return s.repo.SaveProgram(ctx, p)
+}
+
+func (s *Service) CreateEarnRate(ctx context.Context, ownerID int, rate *EarnRate) error {
+	rate.OwnerID = ownerID
+	if rate.Percent < 0 || rate.Percent > 100 {
+		return errors.New("процент начисления должен быть от 0 до 100")
+	}
+	if rate.ShopID == nil && rate.Category == "" {
+		return errors.New("укажите магазин или категорию")
+	}
+	if rate.ShopID != nil {
+		isOwner, err := s.employees.IsOwner(ctx, *rate.ShopID, ownerID)
+		if err != nil {
+			return err
+		}
+		if !isOwner {
+			return ErrAccessDenied
+		}
+	}
+	return s.repo.CreateEarnRate(ctx, rate)
+}
+
+func (s *Service) GetEarnRates(ctx context.Context, ownerID int) ([]EarnRate, error) {
+	return s.repo.GetEarnRates(ctx, ownerID)
+}
+
+func (s *Service) DeleteEarnRate(ctx context.Context, ownerID, id int) error {
+	return s.repo.DeleteEarnRate(ctx, ownerID, id)
+}
+
+func (s *Service) CreateBonus(ctx context.Context, ownerID int, b *Bonus) error {
+	b.OwnerID = ownerID
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	return s.repo.CreateBonus(ctx, b)
+}
+
+func (s *Service) GetBonuses(ctx context.Context, ownerID int) ([]Bonus, error) {
+	return s.repo.GetBonuses(ctx, ownerID)
+}
+
+func (s *Service) DeleteBonus(ctx context.Context, ownerID, id int) error {
+	return s.repo.DeleteBonus(ctx, ownerID, id)
+}
+
+// customerOwner проверяет доступ к магазину и что покупатель из той же организации
+func (s *Service) customerOwner(ctx context.Context, userID, shopID, customerID int) (int, error) {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrAccessDenied
+	}
+	ownerID, err := s.employees.GetShopOwnerID(ctx, shopID)
+	if err != nil {
+		return 0, err
+	}
+	ok, err = s.customers.BelongsTo(ctx, customerID, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrCustomerNotFound
+	}
+	return ownerID, nil
+}
+
+// tier — уровень покупателя по сумме покупок за окно программы
+func (s *Service) tier(ctx context.Context, p *Program, customerID int, now time.Time) (float64, Tier, float64, error) {
+	spend, err := s.repo.RollingSpend(ctx, customerID, now.AddDate(0, 0, -p.TierWindowDays))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	tier, multiplier := p.TierFor(spend)
+	return spend, tier, multiplier, nil
+}
+
+func (s *Service) GetAccount(ctx context.Context, userID, shopID, customerID int) (*Account, error) {
+	ownerID, err := s.customerOwner(ctx, userID, shopID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.GetProgram(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.repo.GetBalance(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	spend, tier, _, err := s.tier(ctx, p, customerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	acc := &Account{
+		CustomerID:   customerID,
+		Balance:      balance,
+		BalanceValue: payment.Round(balance * p.PointValue),
+		Tier:         tier,
+		RollingSpend: spend,
+	}
+	switch tier {
+	case TierBase:
+		acc.NextTier, acc.ToNextTier = TierSilver, payment.Round(p.SilverSpend-spend)
+	case TierSilver:
+		acc.NextTier, acc.ToNextTier = TierGold, payment.Round(p.GoldSpend-spend)
+	}
+	return acc, nil
+}
+
+func (s *Service) GetLedger(ctx context.Context, userID, shopID, customerID int) ([]Entry, error) {
+	if _, err := s.customerOwner(ctx, userID, shopID, customerID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetEntries(ctx, customerID)
+}
+
+// EarnForSale начисляет баллы за проведённый чек. Процент берётся по магазину и категории товара,
+// умножается на уровень покупателя и самый выгодный из действующих бонусов.
+func (s *Service) EarnForSale(ctx context.Context, purchase Purchase) (*Entry, error) {
+	ownerID, err := s.employees.GetShopOwnerID(ctx, purchase.ShopID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.GetProgram(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Enabled {
+		return nil, nil
+	}
+	c, err := s.customers.GetCustomer(ctx, ownerID, purchase.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.repo.GetEarnRates(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	bonuses, err := s.repo.GetBonuses(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, _, tierMultiplier, err := s.tier(ctx, p, purchase.CustomerID, now)
+	if err != nil {
+		return nil, err
+	}
+	bonusMultiplier := 1.0
+	for _, b := range bonuses {
+		if b.AppliesAt(now, c.Birthday) && b.Multiplier > bonusMultiplier {
+			bonusMultiplier = b.Multiplier
+		}
+	}
+
+	var total, base float64
+	for _, l := range purchase.Lines {
+		total += l.Amount
+	}
+	if total <= 0 {
+		return nil, nil
+	}
+	// Часть чека, оплаченная баллами, распределяется по позициям пропорционально и не начисляется
+	paidShare := (total - purchase.PointsPaid) / total
+	for _, l := range purchase.Lines {
+		base += l.Amount * paidShare * rateFor(rates, purchase.ShopID, l.Category, p.EarnPercent) / 100
+	}
+
+	points := payment.Round(base * tierMultiplier * bonusMultiplier / p.PointValue)
+	if points <= 0 {
+		return nil, nil
+	}
+	saleID := purchase.SaleID
+	e := &Entry{
+		OwnerID:    ownerID,
+		CustomerID: purchase.CustomerID,
+		Points:     points,
+		Amount:     payment.Round(total - purchase.PointsPaid),
+		SaleID:     &saleID,
+		ExpiresAt:  p.ExpiresAt(now),
+	}
+	if err := s.repo.Earn(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ReverseForReturn отменяет начисление за возвращённую долю чека
+func (s *Service) ReverseForReturn(ctx context.Context, saleID, returnID int, share float64) error {
+	if share <= 0 {
+		return nil
+	}
+	if share > 1 {
+		share = 1
+	}
+	_, err := s.repo.Reverse(ctx, saleID, returnID, share, time.Now())
+	return err
+}
+
+// RunExpiry — фоновая задача сгорания баллов
+func (s *Service) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.ExpireDue(ctx, time.Now()); err != nil {
+				log.Printf("ошибка сгорания баллов: %v", err)
+			}
+		}
+	}
+}
+
+// programForShop — включённая программа организации магазина
+func (s *Service) programForShop(ctx context.Context, shopID int) (*Program, error) {
+	ownerID, err := s.employees.GetShopOwnerID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.GetProgram(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Enabled {
+		return nil, ErrProgramDisabled
+	}
+	return p, nil
+}
+
+func redeemRef(entryID int) string {
+	return fmt.Sprintf("LP-%d", entryID)
+}
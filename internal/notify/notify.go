@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Channel — канал доставки сообщений
+type Channel string
+
+const (
+	ChannelSMS      Channel = "sms"
+	ChannelEmail    Channel = "email"
+	ChannelTelegram Channel = "telegram"
+)
+
+func (c Channel) Valid() bool {
+	switch c {
+	case ChannelSMS, ChannelEmail, ChannelTelegram:
+		return true
+	}
+	return false
+}
+
+var ErrNoSender = errors.New("канал доставки не подключён")
+
+// Message — одно сообщение получателю. To — телефон, email или chat id в Telegram.
+type Message struct {
+	Channel Channel
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender — отправка сообщений через конкретный канал (SMS-шлюз, SMTP, Telegram Bot API).
+// Возвращает идентификатор сообщения у провайдера.
+type Sender interface {
+	Send(ctx context.Context, msg Message) (string, error)
+}
+
+// LogSender — локальная замена настоящего провайдера: сообщения пишутся в лог сервера
+type LogSender struct {
+	name string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewLogSender(name string) *LogSender {
+	return &LogSender{name: name}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) (string, error) {
+	s.mu.Lock()
+	s.seq++
+	id := fmt.Sprintf("%s-%06d", s.name, s.seq)
+	s.mu.Unlock()
+
+	log.Printf("[%s] %s -> %s: %s %s", s.name, id, msg.To, msg.Subject, msg.Body)
+	return id, nil
+}
+
+// Dispatcher выбирает отправителя по каналу
+type Dispatcher struct {
+	senders map[Channel]Sender
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{senders: make(map[Channel]Sender)}
+}
+
+func (d *Dispatcher) Register(channel Channel, sender Sender) {
+	d.senders[channel] = sender
+}
+
+func (d *Dispatcher) Send(ctx context.Context, msg Message) (string, error) {
+	sender, ok := d.senders[msg.Channel]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoSender, msg.Channel)
+	}
+	return sender.Send(ctx, msg)
+}
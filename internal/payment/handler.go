@@ -0,0 +1,105 @@
+package payment
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetShopMethods godoc
+// @Summary Get shop payment methods
+// @Description Возвращает способы оплаты, доступные в магазине.
+// @Tags payments
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {array} ShopMethod
+// @Failure 400 {string} string "неправильный ID магазина"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 500 {string} string "ошибка получения способов оплаты"
+// @Router /shops/{id}/payment-methods [get]
+func (h *Handler) GetShopMethods(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	methods, err := h.service.ListShopMethods(r.Context(), claims.ID, shopID)
+	if err != nil {
+		if errors.Is(err, ErrAccessDenied) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "ошибка получения способов оплаты", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(methods)
+}
+
+// SetShopMethods godoc
+// @Summary Configure shop payment methods
+// @Description Включает или отключает способы оплаты магазина (cash, card, kaspi_qr, gift_card, loyalty_points).
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param methods body []ShopMethod true "Способы оплаты"
+// @Success 200 {string} string "Способы оплаты сохранены"
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/shops/{id}/payment-methods [put]
+func (h *Handler) SetShopMethods(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var methods []ShopMethod
+	if err := json.NewDecoder(r.Body).Decode(&methods); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetShopMethods(r.Context(), claims.ID, shopID, methods); err != nil {
+		if errors.Is(err, ErrAccessDenied) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Способы оплаты сохранены"))
+}
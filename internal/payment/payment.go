@@ -0,0 +1,75 @@
+package payment
+
+import (
+	"math"
+	"time"
+)
+
+// Method — способ оплаты
+type Method string
+
+const (
+	MethodCash     Method = "cash"
+	MethodCard     Method = "card"
+	MethodKaspiQR  Method = "kaspi_qr"
+	MethodGiftCard Method = "gift_card"
+	MethodLoyalty  Method = "loyalty_points"
+)
+
+// DefaultMethods — способы оплаты магазина, для которого ещё ничего не настроено
+var DefaultMethods = []Method{MethodCash, MethodCard, MethodKaspiQR}
+
+func (m Method) Valid() bool {
+	switch m {
+	case MethodCash, MethodCard, MethodKaspiQR, MethodGiftCard, MethodLoyalty:
+		return true
+	}
+	return false
+}
+
+// ShopMethod — настройка способа оплаты для магазина
+type ShopMethod struct {
+	ID      int    `json:"id"`
+	ShopID  int    `json:"shop_id"`
+	Method  Method `json:"method"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Tender — то, чем покупатель хочет оплатить часть чека.
+// Для наличных Amount — сколько денег дал покупатель (сдача считается отдельно).
+// Reference — код подарочной карты и т.п.
+type Tender struct {
+	Method    Method  `json:"method"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference,omitempty"`
+}
+
+// Payment — проведённая оплата по чеку
+type Payment struct {
+	ID          int       `json:"id"`
+	SaleID      int       `json:"sale_id"`
+	Method      Method    `json:"method"`
+	Amount      float64   `json:"amount"`
+	Tendered    float64   `json:"tendered"`
+	Change      float64   `json:"change"`
+	Reference   string    `json:"reference,omitempty"`
+	ProviderRef string    `json:"provider_ref,omitempty"`
+	Refunded    float64   `json:"refunded"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Refund — возврат денег на исходный способ оплаты
+type Refund struct {
+	ID          int       `json:"id"`
+	PaymentID   int       `json:"payment_id"`
+	ReturnID    int       `json:"return_id"`
+	Method      Method    `json:"method"`
+	Amount      float64   `json:"amount"`
+	ProviderRef string    `json:"provider_ref,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Round округляет сумму до тиын
+func Round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrDeclined = errors.New("платёж отклонён")
+
+// ChargeRequest — запрос на списание у провайдера (эквайринг, Kaspi, подарочные карты)
+type ChargeRequest struct {
+	ShopID     int
+	CustomerID int
+	Method     Method
+	Amount     float64
+	Reference  string
+}
+
+// RefundRequest — возврат ранее проведённого списания
+type RefundRequest struct {
+	ShopID      int
+	Method      Method
+	ProviderRef string
+	Amount      float64
+}
+
+// PaymentProvider — внешний (или внутренний) обработчик безналичной оплаты.
+// Charge возвращает идентификатор операции у провайдера, по нему потом делается Refund.
+type PaymentProvider interface {
+	Charge(ctx context.Context, req ChargeRequest) (string, error)
+	Refund(ctx context.Context, req RefundRequest) (string, error)
+}
+
+// FakeProvider — локальный провайдер для разработки без настоящего эквайера.
+// Одобряет любые списания, кроме тендеров с Reference = "decline",
+// и помнит остаток по каждой операции, чтобы нельзя было вернуть больше списанного.
+// Всё хранится в памяти процесса.
+type FakeProvider struct {
+	name string
+
+	mu      sync.Mutex
+	seq     int
+	charges map[string]float64
+}
+
+func NewFakeProvider(name string) *FakeProvider {
+	return &FakeProvider{name: name, charges: make(map[string]float64)}
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, req ChargeRequest) (string, error) {
+	if req.Reference == "decline" {
+		return "", ErrDeclined
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+	ref := fmt.Sprintf("%s-%06d", p.name, p.seq)
+	p.charges[ref] = Round(req.Amount)
+	return ref, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, req RefundRequest) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Операции из прошлого запуска сервера провайдер не помнит — их возврат просто одобряем
+	if left, ok := p.charges[req.ProviderRef]; ok {
+		if Round(req.Amount) > left {
+			return "", ErrRefundExceedsPaid
+		}
+		p.charges[req.ProviderRef] = Round(left - req.Amount)
+	}
+
+	p.seq++
+	return fmt.Sprintf("%s-r%06d", p.name, p.seq), nil
+}
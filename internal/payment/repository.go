@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS shop_payment_methods (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			method VARCHAR(20) NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			UNIQUE (shop_id, method)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции shop_payment_methods: %w", err)
+	}
+	fmt.Println("Миграция shop_payment_methods выполнена успешно")
+	return nil
+}
+
+func (r *Repository) GetShopMethods(ctx context.Context, shopID int) ([]ShopMethod, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, shop_id, method, enabled
+		FROM shop_payment_methods
+		WHERE shop_id = $1
+		ORDER BY id
+	`, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения способов оплаты: %w", err)
+	}
+	defer rows.Close()
+
+	var methods []ShopMethod
+	for rows.Next() {
+		var m ShopMethod
+		if err := rows.Scan(&m.ID, &m.ShopID, &m.Method, &m.Enabled); err != nil {
+			return nil, fmt.Errorf("ошибка чтения способа оплаты: %w", err)
+		}
+		methods = append(methods, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке способов оплаты: %w", err)
+	}
+	return methods, nil
+}
+
+func (r *Repository) UpsertShopMethod(ctx context.Context, m ShopMethod) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO shop_payment_methods (shop_id, method, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (shop_id, method) DO UPDATE SET enabled = EXCLUDED.enabled
+	`, m.ShopID, m.Method, m.Enabled)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения способа оплаты: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package payment
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"errors"
+	"fmt"
+)
+
+var ErrAccessDenied = errors.New("доступ запрещён: нет доступа к магазину")
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	providers map[Method]PaymentProvider
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{
+		repo:      repo,
+		employees: employees,
+		providers: make(map[Method]PaymentProvider),
+	}
+}
+
+// RegisterProvider подключает провайдера для безналичного способа оплаты
+func (s *Service) RegisterProvider(method Method, provider PaymentProvider) {
+	s.providers[method] = provider
+}
+
+// GetShopMethods — настройки магазина; если их нет, отдаём способы по умолчанию
+func (s *Service) GetShopMethods(ctx context.Context, shopID int) ([]ShopMethod, error) {
+	methods, err := s.repo.GetShopMethods(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		return methods, nil
+	}
+	for _, m := range DefaultMethods {
+		methods = append(methods, ShopMethod{ShopID: shopID, Method: m, Enabled: true})
+	}
+	return methods, nil
+}
+
+// ListShopMethods — способы оплаты магазина для владельца или сотрудника
+func (s *Service) ListShopMethods(ctx context.Context, userID, shopID int) ([]ShopMethod, error) {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAccessDenied
+	}
+	return s.GetShopMethods(ctx, shopID)
+}
+
+func (s *Service) SetShopMethods(ctx context.Context, ownerID, shopID int, methods []ShopMethod) error {
+	isOwner, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !isOwner {
+		return ErrAccessDenied
+	}
+
+	for _, m := range methods {
+		if !m.Method.Valid() {
+			return fmt.Errorf("неизвестный способ оплаты: %s", m.Method)
+		}
+		m.ShopID = shopID
+		if err := s.repo.UpsertShopMethod(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) IsEnabled(ctx context.Context, shopID int, method Method) (bool, error) {
+	methods, err := s.GetShopMethods(ctx, shopID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range methods {
+		if m.Method == method {
+			return m.Enabled, nil
+		}
+	}
+	return false, nil
+}
+
+// Charge проводит безналичный платёж через провайдера и записывает его ссылку в платёж
+func (s *Service) Charge(ctx context.Context, shopID, customerID int, p *Payment) error {
+	if p.Method == MethodCash {
+		return nil
+	}
+	provider, ok := s.providers[p.Method]
+	if !ok {
+		return fmt.Errorf("способ оплаты %s не подключён", p.Method)
+	}
+	ref, err := provider.Charge(ctx, ChargeRequest{
+		ShopID:     shopID,
+		CustomerID: customerID,
+		Method:     p.Method,
+		Amount:     p.Amount,
+		Reference:  p.Reference,
+	})
+	if err != nil {
+		return fmt.Errorf("оплата %s на %.2f не прошла: %w", p.Method, p.Amount, err)
+	}
+	p.ProviderRef = ref
+	return nil
+}
+
+// Refund возвращает деньги на исходный способ оплаты
+func (s *Service) Refund(ctx context.Context, shopID int, original Payment, rf *Refund) error {
+	if rf.Method == MethodCash {
+		return nil
+	}
+	provider, ok := s.providers[rf.Method]
+	if !ok {
+		return fmt.Errorf("способ оплаты %s не подключён", rf.Method)
+	}
+	ref, err := provider.Refund(ctx, RefundRequest{
+		ShopID:      shopID,
+		Method:      rf.Method,
+		ProviderRef: original.ProviderRef,
+		Amount:      rf.Amount,
+	})
+	if err != nil {
+		return fmt.Errorf("возврат %s на %.2f не прошёл: %w", rf.Method, rf.Amount, err)
+	}
+	rf.ProviderRef = ref
+	return nil
+}
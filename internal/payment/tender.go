@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoTenders          = errors.New("не указана оплата")
+	ErrInsufficientTender = errors.New("недостаточно средств для оплаты чека")
+	ErrCashlessOverpaid   = errors.New("безналичная оплата превышает сумму чека")
+	ErrRefundExceedsPaid  = errors.New("сумма возврата превышает оплаченную")
+)
+
+// Settle раскладывает сумму чека по тендерам (раздельная оплата).
+// Безналичные тендеры списываются ровно на указанную сумму и вместе не могут
+// превышать чек, остаток закрывается наличными, с которых считается сдача.
+// Возвращает платежи (ещё не сохранённые) и сдачу.
+func Settle(total float64, tenders []Tender) ([]Payment, float64, error) {
+	if len(tenders) == 0 {
+		return nil, 0, ErrNoTenders
+	}
+	total = Round(total)
+
+	var payments []Payment
+	var cashless, cash float64
+	for _, t := range tenders {
+		if !t.Method.Valid() {
+			return nil, 0, fmt.Errorf("неизвестный способ оплаты: %s", t.Method)
+		}
+		amount := Round(t.Amount)
+		if amount <= 0 {
+			return nil, 0, fmt.Errorf("сумма оплаты (%s) должна быть больше нуля", t.Method)
+		}
+		if t.Method == MethodCash {
+			cash += amount
+			continue
+		}
+		cashless += amount
+		payments = append(payments, Payment{
+			Method:    t.Method,
+			Amount:    amount,
+			Tendered:  amount,
+			Reference: t.Reference,
+		})
+	}
+
+	cashless = Round(cashless)
+	if cashless > total {
+		return nil, 0, ErrCashlessOverpaid
+	}
+	if Round(cashless+cash) < total {
+		return nil, 0, ErrInsufficientTender
+	}
+
+	var change float64
+	if cash > 0 {
+		due := Round(total - cashless)
+		change = Round(cash - due)
+		if due == 0 {
+			// Наличные не понадобились — отдаём их целиком как сдачу и не пишем пустой платёж
+			return payments, change, nil
+		}
+		payments = append(payments, Payment{
+			Method:   MethodCash,
+			Amount:   due,
+			Tendered: Round(cash),
+			Change:   change,
+		})
+	}
+	return payments, change, nil
+}
+
+// PlanRefund распределяет сумму возврата по исходным платежам чека.
+// Сначала возвращаем на безналичные способы (карта, QR, подарочная карта),
+// чтобы не выдавать наличными то, что было оплачено картой, остаток — наличными.
+func PlanRefund(payments []Payment, amount float64) ([]Refund, error) {
+	amount = Round(amount)
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	ordered := make([]Payment, 0, len(payments))
+	for _, p := range payments {
+		if p.Method != MethodCash {
+			ordered = append(ordered, p)
+		}
+	}
+	for _, p := range payments {
+		if p.Method == MethodCash {
+			ordered = append(ordered, p)
+		}
+	}
+
+	var refunds []Refund
+	left := amount
+	for _, p := range ordered {
+		if left <= 0 {
+			break
+		}
+		available := Round(p.Amount - p.Refunded)
+		if available <= 0 {
+			continue
+		}
+		part := available
+		if left < part {
+			part = left
+		}
+		refunds = append(refunds, Refund{
+			PaymentID: p.ID,
+			Method:    p.Method,
+			Amount:    part,
+		})
+		left = Round(left - part)
+	}
+	if left > 0 {
+		return nil, ErrRefundExceedsPaid
+	}
+	return refunds, nil
+}
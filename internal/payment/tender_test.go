@@ -0,0 +1,172 @@
+package payment
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    float64
+		tenders  []Tender
+		payments []Payment
+		change   float64
+		err      error
+	}{
+		{
+			name:    "наличные со сдачей",
+			total:   1250,
+			tenders: []Tender{{Method: MethodCash, Amount: 2000}},
+			payments: []Payment{
+				{Method: MethodCash, Amount: 1250, Tendered: 2000, Change: 750},
+			},
+			change: 750,
+		},
+		{
+			name:    "карта и наличные: наличные закрывают остаток",
+			total:   1000.5,
+			tenders: []Tender{{Method: MethodCash, Amount: 500}, {Method: MethodCard, Amount: 600.25}},
+			payments: []Payment{
+				{Method: MethodCard, Amount: 600.25, Tendered: 600.25},
+				{Method: MethodCash, Amount: 400.25, Tendered: 500, Change: 99.75},
+			},
+			change: 99.75,
+		},
+		{
+			name:    "наличные не понадобились — отдаются сдачей без платежа",
+			total:   300,
+			tenders: []Tender{{Method: MethodKaspiQR, Amount: 300}, {Method: MethodCash, Amount: 100}},
+			payments: []Payment{
+				{Method: MethodKaspiQR, Amount: 300, Tendered: 300},
+			},
+			change: 100,
+		},
+		{
+			name:    "копейки округляются до тиын",
+			total:   0.1 + 0.2,
+			tenders: []Tender{{Method: MethodCard, Amount: 0.1}, {Method: MethodCard, Amount: 0.2}},
+			payments: []Payment{
+				{Method: MethodCard, Amount: 0.1, Tendered: 0.1},
+				{Method: MethodCard, Amount: 0.2, Tendered: 0.2},
+			},
+		},
+		{
+			name:  "подарочная карта сохраняет код",
+			total: 500,
+			tenders: []Tender{
+				{Method: MethodGiftCard, Amount: 500, Reference: "GC-1"},
+			},
+			payments: []Payment{
+				{Method: MethodGiftCard, Amount: 500, Tendered: 500, Reference: "GC-1"},
+			},
+		},
+		{name: "без оплаты", total: 100, err: ErrNoTenders},
+		{
+			name:    "безнал больше чека",
+			total:   100,
+			tenders: []Tender{{Method: MethodCard, Amount: 150}},
+			err:     ErrCashlessOverpaid,
+		},
+		{
+			name:    "не хватает денег",
+			total:   100,
+			tenders: []Tender{{Method: MethodCard, Amount: 50}, {Method: MethodCash, Amount: 49.99}},
+			err:     ErrInsufficientTender,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments, change, err := Settle(tt.total, tt.tenders)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка = %v, ожидалась %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(payments, tt.payments) {
+				t.Errorf("платежи = %+v, ожидались %+v", payments, tt.payments)
+			}
+			if change != tt.change {
+				t.Errorf("сдача = %v, ожидалась %v", change, tt.change)
+			}
+		})
+	}
+}
+
+func TestSettleRejectsInvalidTender(t *testing.T) {
+	for _, tender := range []Tender{{Method: "barter", Amount: 10}, {Method: MethodCard, Amount: 0}, {Method: MethodCash, Amount: -5}} {
+		if _, _, err := Settle(10, []Tender{tender}); err == nil {
+			t.Errorf("тендер %+v принят", tender)
+		}
+	}
+}
+
+func TestPlanRefund(t *testing.T) {
+	payments := []Payment{
+		{ID: 1, Method: MethodCash, Amount: 300},
+		{ID: 2, Method: MethodCard, Amount: 500, Refunded: 100},
+		{ID: 3, Method: MethodGiftCard, Amount: 200},
+	}
+
+	tests := []struct {
+		name    string
+		amount  float64
+		refunds []Refund
+		err     error
+	}{
+		{name: "нулевая сумма", amount: 0},
+		{
+			name:    "сначала безнал в порядке оплаты",
+			amount:  450.5,
+			refunds: []Refund{{PaymentID: 2, Method: MethodCard, Amount: 400}, {PaymentID: 3, Method: MethodGiftCard, Amount: 50.5}},
+		},
+		{
+			name:   "остаток наличными",
+			amount: 700,
+			refunds: []Refund{
+				{PaymentID: 2, Method: MethodCard, Amount: 400},
+				{PaymentID: 3, Method: MethodGiftCard, Amount: 200},
+				{PaymentID: 1, Method: MethodCash, Amount: 100},
+			},
+		},
+		{
+			name:   "вся оставшаяся сумма",
+			amount: 900,
+			refunds: []Refund{
+				{PaymentID: 2, Method: MethodCard, Amount: 400},
+				{PaymentID: 3, Method: MethodGiftCard, Amount: 200},
+				{PaymentID: 1, Method: MethodCash, Amount: 300},
+			},
+		},
+		{name: "больше оплаченного", amount: 900.01, err: ErrRefundExceedsPaid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refunds, err := PlanRefund(payments, tt.amount)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка = %v, ожидалась %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(refunds, tt.refunds) {
+				t.Errorf("возвраты = %+v, ожидались %+v", refunds, tt.refunds)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{0.1 + 0.2, 0.3},
+		{2.344, 2.34},
+		{2.345001, 2.35},
+		{-1.234, -1.23},
+		{1000.999, 1001},
+	}
+	for _, tt := range tests {
+		if got := Round(tt.in); got != tt.want {
+			t.Errorf("Round(%v) = %v, ожидалось %v", tt.in, got, tt.want)
+		}
+	}
+}
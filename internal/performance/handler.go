@@ -0,0 +1,164 @@
+package performance
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/period"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ownerClaims — сравнивать продавцов может только владелец
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// GetShopPerformance godoc
+// @Summary Shop sellers performance
+// @Description Показатели продавцов магазина за период: выручка, чеки, средний чек, товаров в чеке (UPT), доля возвратов, чеков на смену и выручка в час по табелю. Сотрудники без продаж тоже в списке. Рейтинг — по выручке.
+// @Tags performance
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param from query string false "Дата начала (YYYY-MM-DD)"
+// @Param to query string false "Дата окончания включительно (YYYY-MM-DD)"
+// @Success 200 {object} ShopReport
+// @Failure 400 {string} string "неправильный период"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/performance [get]
+func (h *Handler) GetShopPerformance(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	from, to, err := period.FromRequest(r)
+	if err != nil {
+		http.Error(w, "неправильный период", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.ShopReport(r.Context(), claims.ID, shopID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// GetEmployeePerformance godoc
+// @Summary Seller performance
+// @Description Показатели продавца в магазине за период и продажи по дням.
+// @Tags performance
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param user_id path int true "User ID продавца"
+// @Param from query string false "Дата начала (YYYY-MM-DD)"
+// @Param to query string false "Дата окончания включительно (YYYY-MM-DD)"
+// @Success 200 {object} EmployeeReport
+// @Failure 400 {string} string "неправильный период"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/performance/{user_id} [get]
+func (h *Handler) GetEmployeePerformance(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID сотрудника", http.StatusBadRequest)
+		return
+	}
+	from, to, err := period.FromRequest(r)
+	if err != nil {
+		http.Error(w, "неправильный период", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.EmployeeReport(r.Context(), claims.ID, shopID, userID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// GetLeaderboard godoc
+// @Summary Sellers leaderboard
+// @Description Рейтинг продавцов по всем магазинам владельца (или одному магазину) по выбранному показателю. Для доли возвратов выше стоит меньшее значение.
+// @Tags performance
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param metric query string false "revenue (по умолчанию), receipts, avg_basket, upt, return_rate, receipts_per_shift, revenue_per_hour"
+// @Param shop_id query int false "Магазин"
+// @Param from query string false "Дата начала (YYYY-MM-DD)"
+// @Param to query string false "Дата окончания включительно (YYYY-MM-DD)"
+// @Success 200 {object} Leaderboard
+// @Failure 400 {string} string "неизвестный показатель"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/performance/leaderboard [get]
+func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := period.FromRequest(r)
+	if err != nil {
+		http.Error(w, "неправильный период", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	shopID, _ := strconv.Atoi(query.Get("shop_id"))
+
+	board, err := h.service.Leaderboard(r.Context(), claims.ID, shopID, query.Get("metric"), from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(board)
+}
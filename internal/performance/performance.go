@@ -0,0 +1,178 @@
+package performance
+
+import (
+	"crm-backend/internal/payment"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Показатели, по которым строится рейтинг продавцов
+const (
+	MetricRevenue          = "revenue"
+	MetricReceipts         = "receipts"
+	MetricAvgBasket        = "avg_basket"
+	MetricUPT              = "upt"
+	MetricReturnRate       = "return_rate"
+	MetricReceiptsPerShift = "receipts_per_shift"
+	MetricRevenuePerHour   = "revenue_per_hour"
+)
+
+var (
+	ErrAccessDenied  = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrUnknownMetric = errors.New("неизвестный показатель")
+)
+
+// Counters — накопленные за период продажи и рабочее время продавца
+type Counters struct {
+	UserID        int
+	ShopID        int
+	Receipts      int
+	Units         int
+	Revenue       float64
+	Returned      float64
+	Shifts        int
+	WorkedMinutes int
+}
+
+func (c *Counters) add(o Counters) {
+	c.Receipts += o.Receipts
+	c.Units += o.Units
+	c.Revenue += o.Revenue
+	c.Returned += o.Returned
+	c.Shifts += o.Shifts
+	c.WorkedMinutes += o.WorkedMinutes
+}
+
+// Metrics — показатели продавца за период.
+// Счётчика посетителей нет, поэтому конверсия смены — число чеков на отработанную смену (по табелю).
+// ReturnRate — доля возвращённой суммы по чекам периода, в процентах.
+type Metrics struct {
+	UserID           int     `json:"user_id"`
+	EmployeeName     string  `json:"employee_name"`
+	ShopID           int     `json:"shop_id,omitempty"`
+	Rank             int     `json:"rank,omitempty"`
+	Revenue          float64 `json:"revenue"`
+	Receipts         int     `json:"receipts"`
+	Units            int     `json:"units"`
+	AvgBasket        float64 `json:"avg_basket"`
+	UPT              float64 `json:"upt"`
+	Returned         float64 `json:"returned"`
+	ReturnRate       float64 `json:"return_rate"`
+	Shifts           int     `json:"shifts"`
+	WorkedHours      float64 `json:"worked_hours"`
+	ReceiptsPerShift float64 `json:"receipts_per_shift"`
+	RevenuePerHour   float64 `json:"revenue_per_hour"`
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return payment.Round(a / b)
+}
+
+func metrics(c Counters, name string) Metrics {
+	hours := float64(c.WorkedMinutes) / 60
+	return Metrics{
+		UserID:           c.UserID,
+		EmployeeName:     name,
+		ShopID:           c.ShopID,
+		Revenue:          payment.Round(c.Revenue),
+		Receipts:         c.Receipts,
+		Units:            c.Units,
+		AvgBasket:        ratio(c.Revenue, float64(c.Receipts)),
+		UPT:              ratio(float64(c.Units), float64(c.Receipts)),
+		Returned:         payment.Round(c.Returned),
+		ReturnRate:       ratio(c.Returned*100, c.Revenue),
+		Shifts:           c.Shifts,
+		WorkedHours:      payment.Round(hours),
+		ReceiptsPerShift: ratio(float64(c.Receipts), float64(c.Shifts)),
+		RevenuePerHour:   ratio(c.Revenue, hours),
+	}
+}
+
+// value — значение показателя для рейтинга; для доли возвратов лучше меньшее значение
+func (m *Metrics) value(metric string) (float64, bool) {
+	switch metric {
+	case MetricRevenue:
+		return m.Revenue, false
+	case MetricReceipts:
+		return float64(m.Receipts), false
+	case MetricAvgBasket:
+		return m.AvgBasket, false
+	case MetricUPT:
+		return m.UPT, false
+	case MetricReturnRate:
+		return m.ReturnRate, true
+	case MetricReceiptsPerShift:
+		return m.ReceiptsPerShift, false
+	case MetricRevenuePerHour:
+		return m.RevenuePerHour, false
+	}
+	return 0, false
+}
+
+func validMetric(metric string) bool {
+	switch metric {
+	case MetricRevenue, MetricReceipts, MetricAvgBasket, MetricUPT, MetricReturnRate,
+		MetricReceiptsPerShift, MetricRevenuePerHour:
+		return true
+	}
+	return false
+}
+
+// rank сортирует продавцов по показателю и проставляет места (равные значения делят место)
+func rank(list []Metrics, metric string) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, asc := list[i].value(metric)
+		b, _ := list[j].value(metric)
+		if asc {
+			return a < b
+		}
+		return a > b
+	})
+	for i := range list {
+		list[i].Rank = i + 1
+		if i > 0 {
+			prev, _ := list[i-1].value(metric)
+			cur, _ := list[i].value(metric)
+			if prev == cur {
+				list[i].Rank = list[i-1].Rank
+			}
+		}
+	}
+}
+
+// Day — продажи продавца за день
+type Day struct {
+	Date     string  `json:"date"`
+	Revenue  float64 `json:"revenue"`
+	Receipts int     `json:"receipts"`
+	Units    int     `json:"units"`
+}
+
+// ShopReport — показатели всех продавцов магазина за период
+type ShopReport struct {
+	ShopID    int       `json:"shop_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Total     Metrics   `json:"total"`
+	Employees []Metrics `json:"employees"`
+}
+
+// EmployeeReport — показатели продавца в магазине с разбивкой по дням
+type EmployeeReport struct {
+	Metrics
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Days []Day     `json:"days"`
+}
+
+// Leaderboard — рейтинг продавцов по всем (или одному) магазинам владельца
+type Leaderboard struct {
+	Metric    string    `json:"metric"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Employees []Metrics `json:"employees"`
+}
@@ -0,0 +1,93 @@
+package performance
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+	"time"
+)
+
+// Repository читает продажи и табель; собственных таблиц у аналитики нет
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// GetCounters — продажи и отработанные смены по продавцам и магазинам владельца за период [from, to).
+// shopID = 0 — все магазины. Продажа засчитывается продавцу чека (для старых чеков — кассиру).
+func (r *Repository) GetCounters(ctx context.Context, ownerID, shopID int, from, to time.Time) ([]Counters, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		WITH sold AS (
+			SELECT COALESCE(s.seller_id, s.cashier_id) AS user_id, s.shop_id, COUNT(*) AS receipts,
+				COALESCE(SUM(u.units), 0) AS units, SUM(s.total) AS revenue, SUM(s.refunded) AS returned
+			FROM sales s
+			JOIN shops sh ON sh.id = s.shop_id
+			LEFT JOIN LATERAL (SELECT SUM(quantity) AS units FROM sale_items WHERE sale_id = s.id) u ON TRUE
+			WHERE sh.owner_id = $1 AND ($2 = 0 OR s.shop_id = $2) AND s.created_at >= $3 AND s.created_at < $4
+			GROUP BY 1, 2
+		), worked AS (
+			SELECT te.user_id, te.shop_id, COUNT(*) AS shifts, SUM(te.worked_minutes) AS minutes
+			FROM time_entries te
+			JOIN shops sh ON sh.id = te.shop_id
+			WHERE sh.owner_id = $1 AND ($2 = 0 OR te.shop_id = $2) AND te.clock_in >= $3 AND te.clock_in < $4
+			  AND te.clock_out IS NOT NULL
+			GROUP BY 1, 2
+		)
+		SELECT COALESCE(sold.user_id, worked.user_id), COALESCE(sold.shop_id, worked.shop_id),
+			COALESCE(sold.receipts, 0), COALESCE(sold.units, 0)::int,
+			COALESCE(sold.revenue, 0)::float8, COALESCE(sold.returned, 0)::float8,
+			COALESCE(worked.shifts, 0), COALESCE(worked.minutes, 0)::int
+		FROM sold FULL JOIN worked ON worked.user_id = sold.user_id AND worked.shop_id = sold.shop_id
+		WHERE COALESCE(sold.user_id, worked.user_id) IS NOT NULL
+	`, ownerID, shopID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения показателей продавцов: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Counters
+	for rows.Next() {
+		var c Counters
+		if err := rows.Scan(&c.UserID, &c.ShopID, &c.Receipts, &c.Units, &c.Revenue, &c.Returned, &c.Shifts,
+			&c.WorkedMinutes); err != nil {
+			return nil, fmt.Errorf("ошибка чтения показателей продавца: %w", err)
+		}
+		list = append(list, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке показателей продавцов: %w", err)
+	}
+	return list, nil
+}
+
+// GetDays — продажи продавца в магазине по дням
+func (r *Repository) GetDays(ctx context.Context, shopID, userID int, from, to time.Time) ([]Day, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT to_char(s.created_at, 'YYYY-MM-DD') AS day, SUM(s.total)::float8, COUNT(*),
+			COALESCE(SUM((SELECT SUM(quantity) FROM sale_items WHERE sale_id = s.id)), 0)::int
+		FROM sales s
+		WHERE s.shop_id = $1 AND COALESCE(s.seller_id, s.cashier_id) = $2 AND s.created_at >= $3 AND s.created_at < $4
+		GROUP BY day
+		ORDER BY day
+	`, shopID, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения продаж по дням: %w", err)
+	}
+	defer rows.Close()
+
+	days := []Day{}
+	for rows.Next() {
+		var d Day
+		if err := rows.Scan(&d.Date, &d.Revenue, &d.Receipts, &d.Units); err != nil {
+			return nil, fmt.Errorf("ошибка чтения продаж за день: %w", err)
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке продаж по дням: %w", err)
+	}
+	return days, nil
+}
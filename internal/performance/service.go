@@ -0,0 +1,137 @@
+package performance
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"time"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+func (s *Service) checkOwner(ctx context.Context, ownerID, shopID int) error {
+	ok, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// ShopReport — показатели каждого сотрудника магазина за период, включая тех, кто ничего не продал
+func (s *Service) ShopReport(ctx context.Context, ownerID, shopID int, from, to time.Time) (*ShopReport, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	counters, err := s.repo.GetCounters(ctx, ownerID, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	staff, err := s.employees.GetEmployeesByShop(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.employees.GetStaffNames(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool, len(counters))
+	var total Counters
+	for _, c := range counters {
+		seen[c.UserID] = true
+		total.add(c)
+	}
+	for _, e := range staff {
+		if !seen[e.UserID] {
+			seen[e.UserID] = true
+			counters = append(counters, Counters{UserID: e.UserID, ShopID: shopID})
+		}
+	}
+
+	report := &ShopReport{ShopID: shopID, From: from, To: to, Employees: make([]Metrics, 0, len(counters))}
+	for _, c := range counters {
+		report.Employees = append(report.Employees, metrics(c, names[c.UserID]))
+	}
+	rank(report.Employees, MetricRevenue)
+	report.Total = metrics(total, "")
+	report.Total.ShopID = shopID
+	return report, nil
+}
+
+// EmployeeReport — показатели продавца в магазине и продажи по дням
+func (s *Service) EmployeeReport(ctx context.Context, ownerID, shopID, userID int, from, to time.Time) (*EmployeeReport, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	counters, err := s.repo.GetCounters(ctx, ownerID, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.employees.GetStaffNames(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	days, err := s.repo.GetDays(ctx, shopID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Counters{UserID: userID, ShopID: shopID}
+	for _, row := range counters {
+		if row.UserID == userID {
+			c = row
+		}
+	}
+	return &EmployeeReport{Metrics: metrics(c, names[userID]), From: from, To: to, Days: days}, nil
+}
+
+// Leaderboard — рейтинг продавцов по показателю. Без магазина показатели продавца складываются по всем магазинам.
+func (s *Service) Leaderboard(ctx context.Context, ownerID, shopID int, metric string, from, to time.Time) (*Leaderboard, error) {
+	if metric == "" {
+		metric = MetricRevenue
+	}
+	if !validMetric(metric) {
+		return nil, ErrUnknownMetric
+	}
+	if shopID != 0 {
+		if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+			return nil, err
+		}
+	}
+	counters, err := s.repo.GetCounters(ctx, ownerID, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.employees.GetStaffNames(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[int]*Counters)
+	var order []int
+	for _, c := range counters {
+		sum, ok := byUser[c.UserID]
+		if !ok {
+			sum = &Counters{UserID: c.UserID, ShopID: shopID}
+			byUser[c.UserID] = sum
+			order = append(order, c.UserID)
+		}
+		sum.add(c)
+	}
+
+	board := &Leaderboard{Metric: metric, From: from, To: to, Employees: make([]Metrics, 0, len(order))}
+	for _, id := range order {
+		board.Employees = append(board.Employees, metrics(*byUser[id], names[id]))
+	}
+	rank(board.Employees, metric)
+	return board, nil
+}
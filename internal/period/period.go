@@ -0,0 +1,32 @@
+package period
+
+import (
+	"net/http"
+	"time"
+)
+
+const layout = "2006-01-02"
+
+// FromRequest читает период из ?from=2006-01-02&to=2006-01-02 (to включительно).
+// Возвращает полуинтервал [from, to). По умолчанию — последние 30 дней.
+func FromRequest(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -29)
+	to := from.AddDate(0, 0, 30)
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.ParseInLocation(layout, v, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.ParseInLocation(layout, v, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
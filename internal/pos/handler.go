@@ -0,0 +1,241 @@
+package pos
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied), errors.Is(err, ErrWrongPassword), errors.Is(err, ErrPINNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrInvalidDevice), errors.Is(err, ErrInvalidPIN):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrPINLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
+	case errors.Is(err, ErrDeviceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ownerClaims — кассами управляет владелец магазина
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// CreateDevice godoc
+// @Summary Register POS device
+// @Description Регистрирует кассу магазина и выдаёт её ключ (credential). Ключ показывается один раз: его сохраняют в настройках кассы и передают в заголовке X-Device-Token.
+// @Tags pos
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "ID магазина"
+// @Param request body DeviceRequest true "Название кассы"
+// @Success 201 {object} DeviceCredential
+// @Failure 400 {string} string "название кассы обязательно"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/shops/{id}/pos-devices [post]
+func (h *Handler) CreateDevice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var req DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	cred, err := h.service.CreateDevice(r.Context(), claims.ID, shopID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(cred)
+}
+
+// GetDevices godoc
+// @Summary List POS devices
+// @Description Кассы магазина, включая отключённые, с временем последнего входа.
+// @Tags pos
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "ID магазина"
+// @Success 200 {array} Device
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/shops/{id}/pos-devices [get]
+func (h *Handler) GetDevices(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	devices, err := h.service.GetDevices(r.Context(), claims.ID, shopID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(devices)
+}
+
+// RevokeDevice godoc
+// @Summary Revoke POS device
+// @Description Отключает кассу: её ключ и все токены, выданные на ней по PIN, перестают действовать.
+// @Tags pos
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "ID магазина"
+// @Param device_id path int true "ID кассы"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "касса не найдена"
+// @Router /owner/shops/{id}/pos-devices/{device_id} [delete]
+func (h *Handler) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	deviceID, err := strconv.Atoi(chi.URLParam(r, "device_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID кассы", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RevokeDevice(r.Context(), claims.ID, shopID, deviceID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// SetPIN godoc
+// @Summary Set my POS PIN
+// @Description Задаёт PIN из 4–6 цифр для входа на кассах магазинов, где работает пользователь. Нужен текущий пароль. Новый PIN снимает блокировку после неверных попыток на всех кассах. Владельцу и superadmin PIN не задаётся — они входят только с паролем и 2FA.
+// @Tags pos
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body PINRequest true "PIN и текущий пароль"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "PIN должен состоять из 4–6 цифр"
+// @Failure 403 {string} string "неверный текущий пароль или PIN недоступен владельцу"
+// @Router /me/pin [put]
+func (h *Handler) SetPIN(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req PINRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetPIN(r.Context(), claims.ID, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "pin_set"})
+}
+
+// GetStaff godoc
+// @Summary List staff for POS login
+// @Description Сотрудники магазина кассы, у которых задан PIN и он не заблокирован на этой кассе, — для выбора пользователя на кассе. Касса передаёт свой ключ в заголовке X-Device-Token.
+// @Tags pos
+// @Produce json
+// @Param X-Device-Token header string true "Ключ кассы"
+// @Success 200 {array} StaffMember
+// @Failure 401 {string} string "касса не зарегистрирована или отключена"
+// @Router /pos/staff [get]
+func (h *Handler) GetStaff(w http.ResponseWriter, r *http.Request) {
+	staff, err := h.service.Staff(r.Context(), r.Header.Get(DeviceHeader))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(staff)
+}
+
+// Login godoc
+// @Summary Log in on POS device with PIN
+// @Description Вход сотрудника на зарегистрированной кассе по PIN. Токен выдаётся с ролью cashier, действует 12 часов и только на кассовых маршрутах /shops/{id} магазина этой кассы (продажи, смены, покупатели, баланс подарочной карты, выдача броней, учёт времени); после отключения кассы перестаёт действовать. Владелец и superadmin по PIN не входят. После 5 неверных PIN подряд на кассе PIN блокируется на ней до смены в профиле.
+// @Tags pos
+// @Accept json
+// @Produce json
+// @Param X-Device-Token header string true "Ключ кассы"
+// @Param request body LoginRequest true "Пользователь и PIN"
+// @Success 200 {object} LoginResponse
+// @Failure 401 {string} string "неверный PIN"
+// @Failure 403 {string} string "вход по PIN недоступен владельцу и администратору, войдите с паролем"
+// @Failure 423 {string} string "PIN заблокирован после неверных попыток, задайте новый в профиле"
+// @Router /pos/login [post]
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.service.Login(r.Context(), r.Header.Get(DeviceHeader), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,121 @@
+package pos
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DeviceHeader — заголовок, в котором касса передаёт свой ключ
+	DeviceHeader = "X-Device-Token"
+	// credentialPrefix — по префиксу ключ кассы легко узнать в конфигурации и логах
+	credentialPrefix = "pos_"
+
+	// tokenTTL — токен после входа по PIN действует не дольше смены
+	tokenTTL = 12 * time.Hour
+	// maxPINFailures — после стольких неверных PIN подряд на одной кассе PIN блокируется на ней до смены в профиле
+	maxPINFailures = 5
+	minPINLength   = 4
+	maxPINLength   = 6
+)
+
+var (
+	ErrAccessDenied   = errors.New("доступ запрещён")
+	ErrDeviceNotFound = errors.New("касса не найдена")
+	ErrInvalidDevice  = errors.New("касса не зарегистрирована или отключена")
+	ErrInvalidPIN     = errors.New("неверный PIN")
+	ErrPINLocked      = errors.New("PIN заблокирован после неверных попыток, задайте новый в профиле")
+	ErrWrongPassword  = errors.New("неверный текущий пароль")
+	ErrPINNotAllowed  = errors.New("вход по PIN недоступен владельцу и администратору, войдите с паролем")
+)
+
+// pinAllowed — PIN заводят только сотрудники: у владельца и superadmin вход только по паролю и 2FA
+func pinAllowed(role string) bool {
+	return role != "owner" && role != "superadmin"
+}
+
+// Device — зарегистрированная касса магазина. Ключ кассы показывается один раз при регистрации,
+// в базе хранится только его хэш.
+type Device struct {
+	ID         int        `json:"id"`
+	ShopID     int        `json:"shop_id"`
+	Name       string     `json:"name"`
+	CreatedBy  int        `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type DeviceRequest struct {
+	Name string `json:"name"`
+}
+
+func (r *DeviceRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("название кассы обязательно")
+	}
+	if utf8.RuneCountInString(r.Name) > 100 {
+		return errors.New("название кассы слишком длинное")
+	}
+	return nil
+}
+
+// DeviceCredential — ответ на регистрацию кассы; credential больше не будет показан
+type DeviceCredential struct {
+	Device     Device `json:"device"`
+	Credential string `json:"credential"`
+}
+
+// PINRequest — новый PIN для входа на кассе; нужен текущий пароль
+type PINRequest struct {
+	PIN             string `json:"pin"`
+	CurrentPassword string `json:"current_password"`
+}
+
+func (r *PINRequest) validate() error {
+	if len(r.PIN) < minPINLength || len(r.PIN) > maxPINLength {
+		return errors.New("PIN должен состоять из 4–6 цифр")
+	}
+	for _, c := range r.PIN {
+		if c < '0' || c > '9' {
+			return errors.New("PIN должен состоять из 4–6 цифр")
+		}
+	}
+	if strings.Count(r.PIN, r.PIN[:1]) == len(r.PIN) {
+		return errors.New("PIN не должен состоять из одинаковых цифр")
+	}
+	return nil
+}
+
+// LoginRequest — вход сотрудника на кассе: выбор себя в списке и PIN
+type LoginRequest struct {
+	UserID int    `json:"user_id"`
+	PIN    string `json:"pin"`
+}
+
+// StaffMember — сотрудник, который может войти на кассе по PIN
+type StaffMember struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// LoginResponse — токен, действующий только на кассовых маршрутах магазина этой кассы
+type LoginResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	ShopID    int         `json:"shop_id"`
+	DeviceID  int         `json:"device_id"`
+	User      StaffMember `json:"user"`
+}
+
+// Account — пользователь, входящий на кассе
+type Account struct {
+	ID           int
+	Email        string
+	Role         string
+	Name         string
+	PasswordHash string
+}
@@ -0,0 +1,260 @@
+package pos
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS pos_devices (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			credential_hash VARCHAR(64) NOT NULL UNIQUE,
+			created_by INT NOT NULL REFERENCES users(id),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			last_seen_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE INDEX IF NOT EXISTS pos_devices_shop ON pos_devices (shop_id);
+
+		CREATE TABLE IF NOT EXISTS user_pins (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			pin_hash VARCHAR(100) NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Неверные попытки считаются по каждой кассе в pos_pin_failures
+		ALTER TABLE user_pins DROP COLUMN IF EXISTS failed_attempts;
+
+		CREATE TABLE IF NOT EXISTS pos_pin_failures (
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			device_id INT NOT NULL REFERENCES pos_devices(id) ON DELETE CASCADE,
+			failed_attempts INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, device_id)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции pos_devices: %w", err)
+	}
+	fmt.Println("Миграция pos_devices выполнена успешно")
+	return nil
+}
+
+const deviceColumns = `id, shop_id, name, created_by, created_at, last_seen_at, revoked_at`
+
+func scanDevice(row pgx.Row, d *Device) error {
+	return row.Scan(&d.ID, &d.ShopID, &d.Name, &d.CreatedBy, &d.CreatedAt, &d.LastSeenAt, &d.RevokedAt)
+}
+
+func (r *Repository) CreateDevice(ctx context.Context, d *Device, credentialHash string) error {
+	err := scanDevice(r.db.Conn.QueryRow(ctx, `
+		INSERT INTO pos_devices (shop_id, name, credential_hash, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+deviceColumns, d.ShopID, d.Name, credentialHash, d.CreatedBy), d)
+	if err != nil {
+		return fmt.Errorf("ошибка регистрации кассы: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetShopDevices(ctx context.Context, shopID int) ([]Device, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+deviceColumns+` FROM pos_devices WHERE shop_id = $1 ORDER BY revoked_at NULLS FIRST, id
+	`, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения касс: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []Device
+	for rows.Next() {
+		var d Device
+		if err := scanDevice(rows, &d); err != nil {
+			return nil, fmt.Errorf("ошибка чтения кассы: %w", err)
+		}
+		devices = append(devices, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке касс: %w", err)
+	}
+	return devices, nil
+}
+
+// GetByCredential — действующая касса по хэшу ключа
+func (r *Repository) GetByCredential(ctx context.Context, credentialHash string) (*Device, error) {
+	var d Device
+	err := scanDevice(r.db.Conn.QueryRow(ctx, `
+		SELECT `+deviceColumns+` FROM pos_devices WHERE credential_hash = $1 AND revoked_at IS NULL
+	`, credentialHash), &d)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidDevice
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения кассы: %w", err)
+	}
+	return &d, nil
+}
+
+// RevokeDevice отключает кассу; выданные на ней токены перестают действовать
+func (r *Repository) RevokeDevice(ctx context.Context, shopID, deviceID int) error {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE pos_devices SET revoked_at = NOW() WHERE id = $1 AND shop_id = $2 AND revoked_at IS NULL
+	`, deviceID, shopID)
+	if err != nil {
+		return fmt.Errorf("ошибка отключения кассы: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *Repository) Touch(ctx context.Context, deviceID int, at time.Time) error {
+	_, err := r.db.Conn.Exec(ctx, `UPDATE pos_devices SET last_seen_at = $2 WHERE id = $1`, deviceID, at)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления кассы: %w", err)
+	}
+	return nil
+}
+
+// DeviceActive — касса зарегистрирована в магазине и не отключена (auth.DeviceStore)
+func (r *Repository) DeviceActive(ctx context.Context, deviceID, shopID int) (bool, error) {
+	var active bool
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM pos_devices WHERE id = $1 AND shop_id = $2 AND revoked_at IS NULL)
+	`, deviceID, shopID).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки кассы: %w", err)
+	}
+	return active, nil
+}
+
+// Staff — сотрудники магазина с PIN, не заблокированным на этой кассе. Владельцы и superadmin
+// на кассе по PIN не входят.
+func (r *Repository) Staff(ctx context.Context, shopID, deviceID int) ([]StaffMember, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT u.id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))
+		FROM users u
+		JOIN user_pins p ON p.user_id = u.id
+		LEFT JOIN pos_pin_failures f ON f.user_id = u.id AND f.device_id = $2
+		WHERE u.id IN (SELECT user_id FROM employees WHERE shop_id = $1)
+		  AND u.role NOT IN ('owner', 'superadmin')
+		  AND COALESCE(f.failed_attempts, 0) < $3
+		ORDER BY 2, u.id
+	`, shopID, deviceID, maxPINFailures)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сотрудников кассы: %w", err)
+	}
+	defer rows.Close()
+
+	var staff []StaffMember
+	for rows.Next() {
+		var m StaffMember
+		if err := rows.Scan(&m.UserID, &m.Name); err != nil {
+			return nil, fmt.Errorf("ошибка чтения сотрудника: %w", err)
+		}
+		staff = append(staff, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке сотрудников: %w", err)
+	}
+	return staff, nil
+}
+
+// GetAccount — пользователь; ErrInvalidPIN, если такого нет (чтобы не раскрывать ID на кассе)
+func (r *Repository) GetAccount(ctx context.Context, userID int) (*Account, error) {
+	var a Account
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT id, email, role, TRIM(COALESCE(first_name, '') || ' ' || COALESCE(last_name, '')), password_hash
+		FROM users WHERE id = $1
+	`, userID).Scan(&a.ID, &a.Email, &a.Role, &a.Name, &a.PasswordHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidPIN
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	return &a, nil
+}
+
+// SetPIN задаёт PIN и снимает его блокировку на всех кассах
+func (r *Repository) SetPIN(ctx context.Context, userID int, pinHash string) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_pins (user_id, pin_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET pin_hash = EXCLUDED.pin_hash, updated_at = NOW()
+	`, userID, pinHash)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения PIN: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM pos_pin_failures WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("ошибка сброса неверных попыток PIN: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения PIN: %w", err)
+	}
+	return nil
+}
+
+// GetPIN — хэш PIN; ErrInvalidPIN — PIN не задан
+func (r *Repository) GetPIN(ctx context.Context, userID int) (string, error) {
+	var hash string
+	err := r.db.Conn.QueryRow(ctx, `SELECT pin_hash FROM user_pins WHERE user_id = $1`, userID).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInvalidPIN
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения PIN: %w", err)
+	}
+	return hash, nil
+}
+
+// ReservePINAttempt заранее учитывает попытку ввода PIN на кассе как неверную — до медленной проверки хэша,
+// чтобы параллельные попытки не обошли порог. Возвращает число попыток подряд вместе с этой;
+// 0 — PIN на кассе уже заблокирован. Верный PIN сбрасывает счётчик (ResetPINFailures).
+func (r *Repository) ReservePINAttempt(ctx context.Context, userID, deviceID, maxFailures int) (int, error) {
+	var failures int
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO pos_pin_failures (user_id, device_id, failed_attempts) VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, device_id) DO UPDATE SET failed_attempts = pos_pin_failures.failed_attempts + 1
+		WHERE pos_pin_failures.failed_attempts < $3
+		RETURNING failed_attempts
+	`, userID, deviceID, maxFailures).Scan(&failures)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка учёта попытки ввода PIN: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *Repository) ResetPINFailures(ctx context.Context, userID, deviceID int) error {
+	_, err := r.db.Conn.Exec(ctx, `DELETE FROM pos_pin_failures WHERE user_id = $1 AND device_id = $2`, userID, deviceID)
+	if err != nil {
+		return fmt.Errorf("ошибка сброса неверных попыток PIN: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package pos
+
+import (
+	"context"
+	"crm-backend/internal/auth"
+	"crm-backend/internal/employee"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+func newCredential() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации ключа кассы: %w", err)
+	}
+	return credentialPrefix + hex.EncodeToString(b), nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkOwner — кассы регистрирует и отключает владелец магазина
+func (s *Service) checkOwner(ctx context.Context, ownerID, shopID int) error {
+	ok, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// CreateDevice регистрирует кассу и выдаёт её ключ; ключ показывается один раз
+func (s *Service) CreateDevice(ctx context.Context, ownerID, shopID int, req *DeviceRequest) (*DeviceCredential, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	credential, err := newCredential()
+	if err != nil {
+		return nil, err
+	}
+	d := Device{ShopID: shopID, Name: req.Name, CreatedBy: ownerID}
+	if err := s.repo.CreateDevice(ctx, &d, hash(credential)); err != nil {
+		return nil, err
+	}
+	return &DeviceCredential{Device: d, Credential: credential}, nil
+}
+
+func (s *Service) GetDevices(ctx context.Context, ownerID, shopID int) ([]Device, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetShopDevices(ctx, shopID)
+}
+
+// RevokeDevice отключает кассу: её ключ и все выданные на ней токены перестают действовать
+func (s *Service) RevokeDevice(ctx context.Context, ownerID, shopID, deviceID int) error {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return err
+	}
+	return s.repo.RevokeDevice(ctx, shopID, deviceID)
+}
+
+// SetPIN задаёт PIN для входа на кассах; нужен текущий пароль. Новый PIN снимает блокировку.
+func (s *Service) SetPIN(ctx context.Context, userID int, req *PINRequest) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+	acc, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !pinAllowed(acc.Role) {
+		return ErrPINNotAllowed
+	}
+	if req.CurrentPassword == "" || !auth.CheckPassword(acc.PasswordHash, req.CurrentPassword) {
+		return ErrWrongPassword
+	}
+	pinHash, err := auth.HashPassword(req.PIN)
+	if err != nil {
+		return fmt.Errorf("ошибка хэширования PIN: %w", err)
+	}
+	return s.repo.SetPIN(ctx, userID, pinHash)
+}
+
+// device — действующая касса по ключу из заголовка
+func (s *Service) device(ctx context.Context, credential string) (*Device, error) {
+	if credential == "" {
+		return nil, ErrInvalidDevice
+	}
+	return s.repo.GetByCredential(ctx, hash(credential))
+}
+
+// Staff — кого показать на кассе для выбора пользователя
+func (s *Service) Staff(ctx context.Context, credential string) ([]StaffMember, error) {
+	d, err := s.device(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Staff(ctx, d.ShopID, d.ID)
+}
+
+// Login — вход сотрудника магазина на кассе по PIN. Токен даёт роль кассира, действует только на кассовых
+// маршрутах магазина этой кассы и пока касса не отключена. Владелец и superadmin по PIN не входят.
+// После 5 неверных PIN подряд на кассе PIN блокируется на ней: чужая касса не может заблокировать сотрудника везде.
+func (s *Service) Login(ctx context.Context, credential string, req *LoginRequest) (*LoginResponse, error) {
+	d, err := s.device(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+	// Чужим для магазина пользователям отвечаем так же, как на неверный PIN
+	ok, err := s.employees.HasShopAccess(ctx, d.ShopID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidPIN
+	}
+	acc, err := s.repo.GetAccount(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !pinAllowed(acc.Role) {
+		return nil, ErrPINNotAllowed
+	}
+	pinHash, err := s.repo.GetPIN(ctx, acc.ID)
+	if err != nil {
+		return nil, err
+	}
+	// Попытка занимается до проверки хэша: параллельные запросы не проверят больше maxPINFailures PIN подряд
+	failures, err := s.repo.ReservePINAttempt(ctx, acc.ID, d.ID, maxPINFailures)
+	if err != nil {
+		return nil, err
+	}
+	if failures == 0 {
+		return nil, ErrPINLocked
+	}
+	if !auth.CheckPassword(pinHash, req.PIN) {
+		if failures >= maxPINFailures {
+			log.Printf("PIN пользователя ID=%d заблокирован на кассе ID=%d после неверных попыток", acc.ID, d.ID)
+			return nil, ErrPINLocked
+		}
+		return nil, ErrInvalidPIN
+	}
+
+	if err := s.repo.ResetPINFailures(ctx, acc.ID, d.ID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.repo.Touch(ctx, d.ID, now); err != nil {
+		log.Printf("ошибка обновления кассы ID=%d: %v", d.ID, err)
+	}
+	token, err := auth.GenerateDeviceJWT(acc.ID, acc.Email, d.ShopID, d.ID, tokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return &LoginResponse{
+		Token:     token,
+		ExpiresAt: now.Add(tokenTTL),
+		ShopID:    d.ShopID,
+		DeviceID:  d.ID,
+		User:      StaffMember{UserID: acc.ID, Name: acc.Name},
+	}, nil
+}
@@ -0,0 +1,146 @@
+package privacy
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/customer"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, customer.ErrCustomerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, customer.ErrAnonymized):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ownerClaims — запросы покупателей на выгрузку и удаление данных исполняет только владелец
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+func customerID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "customer_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID покупателя", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// ExportCustomer godoc
+// @Summary Export customer data
+// @Description Выгрузка всех данных покупателя (профиль, контакты, покупки, баллы, сообщения рассылок) по его запросу. format=zip — архив с отдельными JSON-файлами, по умолчанию — один JSON. Выгрузка записывается в журнал аудита.
+// @Tags privacy
+// @Produce json
+// @Produce application/zip
+// @Param Authorization header string true "Bearer JWT token"
+// @Param customer_id path int true "Customer ID"
+// @Param format query string false "json или zip"
+// @Success 200 {object} Export
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "покупатель не найден"
+// @Router /owner/customers/{customer_id}/export [get]
+func (h *Handler) ExportCustomer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := customerID(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		http.Error(w, "format должен быть json или zip", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.service.Export(r.Context(), claims.ID, claims.ID, id, format)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="customer-%d.zip"`, id))
+		_ = WriteZIP(w, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="customer-%d.json"`, id))
+	_ = json.NewEncoder(w).Encode(export)
+}
+
+// AnonymizeCustomer godoc
+// @Summary Erase customer personal data
+// @Description Обезличивает покупателя по его запросу: стирает имя, контакты, заметки, теги и историю контактов, снимает согласие на рассылки. Чеки и баллы сохраняются, суммы продаж в отчётах не меняются. Действие необратимо и записывается в журнал аудита с указанным основанием.
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param customer_id path int true "Customer ID"
+// @Param request body AnonymizeRequest true "Основание"
+// @Success 200 {object} customer.Customer
+// @Failure 400 {string} string "укажите основание удаления данных"
+// @Failure 404 {string} string "покупатель не найден"
+// @Failure 409 {string} string "данные покупателя удалены по его запросу"
+// @Router /owner/customers/{customer_id}/anonymize [post]
+func (h *Handler) AnonymizeCustomer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := customerID(w, r)
+	if !ok {
+		return
+	}
+
+	var req AnonymizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.service.Anonymize(r.Context(), claims.ID, claims.ID, id, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
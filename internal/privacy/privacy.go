@@ -0,0 +1,65 @@
+package privacy
+
+import (
+	"archive/zip"
+	"crm-backend/internal/campaign"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/loyalty"
+	"crm-backend/internal/sale"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Действия в журнале аудита
+const (
+	ActionExport    = "customer.export"
+	ActionAnonymize = "customer.anonymize"
+)
+
+// Export — всё, что хранится о покупателе
+type Export struct {
+	GeneratedAt  time.Time              `json:"generated_at"`
+	Customer     *customer.Customer     `json:"customer"`
+	Interactions []customer.Interaction `json:"interactions"`
+	Purchases    []sale.Sale            `json:"purchases"`
+	Loyalty      *LoyaltyData           `json:"loyalty"`
+	Messages     []campaign.Delivery    `json:"messages"`
+}
+
+type LoyaltyData struct {
+	Balance float64         `json:"balance"`
+	Entries []loyalty.Entry `json:"entries"`
+}
+
+type AnonymizeRequest struct {
+	// Reason — основание: например, номер и дата заявления покупателя
+	Reason string `json:"reason"`
+}
+
+// WriteZIP пишет выгрузку архивом: по JSON-файлу на каждый раздел
+func WriteZIP(w io.Writer, e *Export) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", e.Customer},
+		{"interactions.json", e.Interactions},
+		{"purchases.json", e.Purchases},
+		{"loyalty.json", e.Loyalty},
+		{"messages.json", e.Messages},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: e.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,122 @@
+package privacy
+
+import (
+	"context"
+	"crm-backend/internal/audit"
+	"crm-backend/internal/campaign"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/loyalty"
+	"crm-backend/internal/sale"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Service struct {
+	customers *customer.Repository
+	sales     *sale.Repository
+	loyalty   *loyalty.Repository
+	campaigns *campaign.Repository
+	audit     *audit.Repository
+}
+
+func NewService(customers *customer.Repository, sales *sale.Repository, loyaltyRepo *loyalty.Repository,
+	campaigns *campaign.Repository, auditRepo *audit.Repository) *Service {
+	return &Service{customers: customers, sales: sales, loyalty: loyaltyRepo, campaigns: campaigns, audit: auditRepo}
+}
+
+func (s *Service) getCustomer(ctx context.Context, ownerID, customerID int) (*customer.Customer, error) {
+	c, err := s.customers.GetCustomer(ctx, ownerID, customerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, customer.ErrCustomerNotFound
+	}
+	return c, err
+}
+
+// Export собирает данные покупателя: профиль, контакты, покупки, баллы и сообщения рассылок.
+// Сам факт выгрузки записывается в журнал аудита.
+func (s *Service) Export(ctx context.Context, ownerID, actorID, customerID int, format string) (*Export, error) {
+	c, err := s.getCustomer(ctx, ownerID, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Export{GeneratedAt: time.Now(), Customer: c, Loyalty: &LoyaltyData{}}
+	if e.Interactions, err = s.customers.GetInteractions(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	saleIDs, err := s.sales.GetSaleIDsByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range saleIDs {
+		sl, err := s.sales.GetSaleByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		e.Purchases = append(e.Purchases, *sl)
+	}
+
+	if e.Loyalty.Balance, err = s.loyalty.GetBalance(ctx, customerID); err != nil {
+		return nil, err
+	}
+	if e.Loyalty.Entries, err = s.loyalty.GetEntries(ctx, customerID); err != nil {
+		return nil, err
+	}
+	if e.Messages, err = s.campaigns.GetDeliveriesByCustomer(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	err = s.audit.Record(ctx, &audit.Entry{
+		OwnerID:    ownerID,
+		ActorID:    actorID,
+		Action:     ActionExport,
+		EntityType: "customer",
+		EntityID:   customerID,
+		Details:    map[string]any{"format": format},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Anonymize стирает персональные данные покупателя по его запросу.
+// Чеки, возвраты и начисления баллов остаются и ссылаются на обезличенную запись,
+// поэтому суммы продаж в отчётах не меняются.
+func (s *Service) Anonymize(ctx context.Context, ownerID, actorID, customerID int, req AnonymizeRequest) (*customer.Customer, error) {
+	c, err := s.getCustomer(ctx, ownerID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if c.AnonymizedAt != nil {
+		return nil, customer.ErrAnonymized
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, errors.New("укажите основание удаления данных")
+	}
+
+	if err := s.customers.Anonymize(ctx, ownerID, customerID); err != nil {
+		return nil, err
+	}
+	if err := s.campaigns.ForgetCustomer(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	err = s.audit.Record(ctx, &audit.Entry{
+		OwnerID:    ownerID,
+		ActorID:    actorID,
+		Action:     ActionAnonymize,
+		EntityType: "customer",
+		EntityID:   customerID,
+		Details:    map[string]any{"reason": req.Reason},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getCustomer(ctx, ownerID, customerID)
+}
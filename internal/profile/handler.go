@@ -0,0 +1,200 @@
+package profile
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrWrongPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrNoEmailChange):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrTooManyAttempts):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// GetProfile godoc
+// @Summary Get my profile
+// @Description Профиль текущего пользователя. pending_email — новый email, ожидающий подтверждения.
+// @Tags profile
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {object} Profile
+// @Failure 401 {string} string "не авторизован"
+// @Router /me/profile [get]
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	p, err := h.service.Get(r.Context(), claims.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// UpdateProfile godoc
+// @Summary Update my name and phone
+// @Description Меняет имя, фамилию и телефон текущего пользователя. Телефон нужен для восстановления пароля по SMS; для его смены нужен текущий пароль.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body UpdateRequest true "Имя, фамилия и телефон"
+// @Success 200 {object} Profile
+// @Failure 400 {string} string "имя обязательно"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "неверный текущий пароль"
+// @Router /me/profile [put]
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.service.Update(r.Context(), claims.ID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// RequestEmailChange godoc
+// @Summary Request email change
+// @Description Отправляет код подтверждения на новый email. Нужен текущий пароль. Email меняется после подтверждения кодом (код действует 30 минут).
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body EmailChangeRequest true "Новый email и текущий пароль"
+// @Success 202 {object} map[string]string
+// @Failure 400 {string} string "неправильный email"
+// @Failure 403 {string} string "неверный текущий пароль"
+// @Failure 409 {string} string "email уже используется"
+// @Router /me/email [post]
+func (h *Handler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req EmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RequestEmailChange(r.Context(), claims.ID, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "code_sent"})
+}
+
+// ConfirmEmail godoc
+// @Summary Confirm email change
+// @Description Подтверждает новый email кодом из письма. Возвращает новый токен текущей сессии; на прежний адрес уходит уведомление.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body EmailConfirmRequest true "Код"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {string} string "неверный или просроченный код"
+// @Failure 404 {string} string "нет запроса на смену email"
+// @Failure 429 {string} string "слишком много неверных кодов"
+// @Router /me/email/confirm [post]
+func (h *Handler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req EmailConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.service.ConfirmEmail(r.Context(), claims.ID, req.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ChangePassword godoc
+// @Summary Change my password
+// @Description Меняет пароль после проверки текущего. Пароль — не короче 8 символов, с буквами и цифрами. Все остальные сессии завершаются; в ответе — новый токен текущей сессии.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body PasswordChangeRequest true "Текущий и новый пароль"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {string} string "пароль должен быть не короче 8 символов"
+// @Failure 403 {string} string "неверный текущий пароль"
+// @Router /me/password [post]
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.service.ChangePassword(r.Context(), claims.ID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,107 @@
+package profile
+
+import (
+	"crm-backend/internal/customer"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	// emailCodeTTL — сколько действует код подтверждения нового email
+	emailCodeTTL = 30 * time.Minute
+	// maxCodeAttempts — после стольких неверных кодов запрос на смену email нужно отправить заново
+	maxCodeAttempts = 5
+)
+
+var (
+	ErrUserNotFound     = errors.New("пользователь не найден")
+	ErrWrongPassword    = errors.New("неверный текущий пароль")
+	ErrEmailTaken       = errors.New("email уже используется")
+	ErrNoEmailChange    = errors.New("нет запроса на смену email")
+	ErrInvalidCode      = errors.New("неверный или просроченный код")
+	ErrTooManyAttempts  = errors.New("слишком много неверных кодов, запросите смену email заново")
+	ErrSamePassword     = errors.New("новый пароль совпадает с текущим")
+	ErrPasswordRequired = errors.New("укажите текущий пароль")
+)
+
+// Profile — данные текущего пользователя. PendingEmail — новый адрес, ожидающий подтверждения.
+type Profile struct {
+	ID           int    `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	Phone        string `json:"phone,omitempty"`
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
+// UpdateRequest — смена имени и телефона. Телефон используется для восстановления пароля по SMS,
+// поэтому его смена требует текущего пароля; null — телефон не меняется, пустая строка — удалить.
+type UpdateRequest struct {
+	FirstName       string  `json:"first_name"`
+	LastName        string  `json:"last_name"`
+	Phone           *string `json:"phone,omitempty"`
+	CurrentPassword string  `json:"current_password,omitempty"`
+}
+
+func (r *UpdateRequest) validate() error {
+	r.FirstName = strings.TrimSpace(r.FirstName)
+	r.LastName = strings.TrimSpace(r.LastName)
+	if r.FirstName == "" {
+		return errors.New("имя обязательно")
+	}
+	if len([]rune(r.FirstName)) > 50 || len([]rune(r.LastName)) > 50 {
+		return errors.New("имя и фамилия — не длиннее 50 символов")
+	}
+	if r.Phone != nil {
+		phone := customer.NormalizePhone(*r.Phone)
+		if phone != "" && (len(phone) < 10 || len(phone) > 15) {
+			return errors.New("неправильный номер телефона")
+		}
+		r.Phone = &phone
+	}
+	return nil
+}
+
+// EmailChangeRequest — смена email; новый адрес начинает действовать после подтверждения кодом
+type EmailChangeRequest struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+func (r *EmailChangeRequest) validate() error {
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	if addr, err := mail.ParseAddress(r.Email); err != nil || addr.Address != r.Email || len(r.Email) > 100 {
+		return errors.New("неправильный email")
+	}
+	if r.CurrentPassword == "" {
+		return ErrPasswordRequired
+	}
+	return nil
+}
+
+type EmailConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// EmailChange — ожидающая подтверждения смена email; код хранится только в виде хэша
+type EmailChange struct {
+	UserID    int
+	NewEmail  string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// TokenResponse — новый токен текущей сессии после смены email или пароля
+type TokenResponse struct {
+	Token   string   `json:"token"`
+	Profile *Profile `json:"profile"`
+}
@@ -0,0 +1,161 @@
+package profile
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS phone VARCHAR(20);
+
+		CREATE TABLE IF NOT EXISTS email_changes (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			new_email VARCHAR(100) NOT NULL,
+			code_hash VARCHAR(64) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции email_changes: %w", err)
+	}
+	fmt.Println("Миграция email_changes выполнена успешно")
+	return nil
+}
+
+// GetProfile возвращает профиль и хэш пароля пользователя
+func (r *Repository) GetProfile(ctx context.Context, userID int) (*Profile, string, error) {
+	var p Profile
+	var hash string
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT u.id, COALESCE(u.first_name, ''), COALESCE(u.last_name, ''), u.email, u.role, COALESCE(u.password_hash, ''),
+			COALESCE(u.phone, ''), COALESCE(ec.new_email, '')
+		FROM users u
+		LEFT JOIN email_changes ec ON ec.user_id = u.id AND ec.expires_at > NOW()
+		WHERE u.id = $1
+	`, userID).Scan(&p.ID, &p.FirstName, &p.LastName, &p.Email, &p.Role, &hash, &p.Phone, &p.PendingEmail)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrUserNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка получения профиля: %w", err)
+	}
+	return &p, hash, nil
+}
+
+func (r *Repository) Update(ctx context.Context, userID int, firstName, lastName, phone string) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE users SET first_name = $1, last_name = NULLIF($2, ''), phone = NULLIF($3, '') WHERE id = $4
+	`, firstName, lastName, phone, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления профиля: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) EmailTaken(ctx context.Context, email string, userID int) (bool, error) {
+	var taken bool
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = $1 AND id <> $2)
+	`, email, userID).Scan(&taken)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки email: %w", err)
+	}
+	return taken, nil
+}
+
+// SaveEmailChange заменяет прежний запрос на смену email новым
+func (r *Repository) SaveEmailChange(ctx context.Context, c *EmailChange) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO email_changes (user_id, new_email, code_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET new_email = EXCLUDED.new_email, code_hash = EXCLUDED.code_hash,
+			attempts = 0, expires_at = EXCLUDED.expires_at, created_at = NOW()
+	`, c.UserID, c.NewEmail, c.CodeHash, c.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения запроса на смену email: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetEmailChange(ctx context.Context, userID int) (*EmailChange, error) {
+	c := EmailChange{UserID: userID}
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT new_email, code_hash, attempts, expires_at FROM email_changes WHERE user_id = $1
+	`, userID).Scan(&c.NewEmail, &c.CodeHash, &c.Attempts, &c.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoEmailChange
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения запроса на смену email: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *Repository) AddAttempt(ctx context.Context, userID int) error {
+	_, err := r.db.Conn.Exec(ctx, `UPDATE email_changes SET attempts = attempts + 1 WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления запроса на смену email: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteEmailChange(ctx context.Context, userID int) error {
+	_, err := r.db.Conn.Exec(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления запроса на смену email: %w", err)
+	}
+	return nil
+}
+
+// ConfirmEmail применяет новый email и удаляет запрос на смену
+func (r *Repository) ConfirmEmail(ctx context.Context, userID int, email string) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET email = $1 WHERE id = $2`, email, userID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("ошибка смены email: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("ошибка смены email: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка смены email: %w", err)
+	}
+	return nil
+}
+
+// UpdatePassword меняет пароль и завершает все сессии, выданные до revokedAt
+func (r *Repository) UpdatePassword(ctx context.Context, userID int, hash string, revokedAt time.Time) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE users SET password_hash = $1, sessions_revoked_at = $2 WHERE id = $3
+	`, hash, revokedAt, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка смены пароля: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package profile
+
+import (
+	"context"
+	"crm-backend/internal/auth"
+	"crm-backend/internal/notify"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	repo     *Repository
+	notifier *notify.Dispatcher
+}
+
+func NewService(repo *Repository, notifier *notify.Dispatcher) *Service {
+	return &Service{repo: repo, notifier: notifier}
+}
+
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации кода: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// notice — письмо о смене данных входа на прежний адрес; ошибка доставки не отменяет смену
+func (s *Service) notice(ctx context.Context, email, subject, body string) {
+	msg := notify.Message{Channel: notify.ChannelEmail, To: email, Subject: subject, Body: body}
+	if _, err := s.notifier.Send(ctx, msg); err != nil {
+		log.Printf("ошибка отправки уведомления на %s: %v", email, err)
+	}
+}
+
+func (s *Service) Get(ctx context.Context, userID int) (*Profile, error) {
+	p, _, err := s.repo.GetProfile(ctx, userID)
+	return p, err
+}
+
+// Update меняет имя и телефон; для смены телефона нужен текущий пароль
+func (s *Service) Update(ctx context.Context, userID int, req *UpdateRequest) (*Profile, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	p, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	phone := p.Phone
+	if req.Phone != nil && *req.Phone != p.Phone {
+		if _, err := s.checkPassword(ctx, userID, req.CurrentPassword); err != nil {
+			return nil, err
+		}
+		phone = *req.Phone
+	}
+	if err := s.repo.Update(ctx, userID, req.FirstName, req.LastName, phone); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID)
+}
+
+// checkPassword загружает профиль и проверяет текущий пароль
+func (s *Service) checkPassword(ctx context.Context, userID int, password string) (*Profile, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	p, hash, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.CheckPassword(hash, password) {
+		return nil, ErrWrongPassword
+	}
+	return p, nil
+}
+
+// RequestEmailChange отправляет код подтверждения на новый адрес. Email меняется только после ввода кода.
+func (s *Service) RequestEmailChange(ctx context.Context, userID int, req *EmailChangeRequest) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+	p, err := s.checkPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(req.Email, p.Email) {
+		return errors.New("это уже ваш email")
+	}
+	taken, err := s.repo.EmailTaken(ctx, req.Email, userID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return err
+	}
+	change := &EmailChange{
+		UserID:    userID,
+		NewEmail:  req.Email,
+		CodeHash:  hashCode(code),
+		ExpiresAt: time.Now().Add(emailCodeTTL),
+	}
+	if err := s.repo.SaveEmailChange(ctx, change); err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		Channel: notify.ChannelEmail,
+		To:      req.Email,
+		Subject: "Подтверждение email",
+		Body:    fmt.Sprintf("Код подтверждения: %s. Код действует %d минут.", code, int(emailCodeTTL/time.Minute)),
+	}
+	if _, err := s.notifier.Send(ctx, msg); err != nil {
+		return fmt.Errorf("не удалось отправить код подтверждения: %w", err)
+	}
+	return nil
+}
+
+// ConfirmEmail применяет новый email по коду и выдаёт токен с новым адресом
+func (s *Service) ConfirmEmail(ctx context.Context, userID int, code string) (*TokenResponse, error) {
+	change, err := s.repo.GetEmailChange(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(change.ExpiresAt) {
+		return nil, ErrInvalidCode
+	}
+	if change.Attempts >= maxCodeAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare([]byte(hashCode(code)), []byte(change.CodeHash)) != 1 {
+		if err := s.repo.AddAttempt(ctx, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCode
+	}
+
+	p, _, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	oldEmail := p.Email
+	if err := s.repo.ConfirmEmail(ctx, userID, change.NewEmail); err != nil {
+		return nil, err
+	}
+	s.notice(ctx, oldEmail, "Email изменён", fmt.Sprintf("Email вашей учётной записи изменён на %s.", change.NewEmail))
+
+	p.Email = change.NewEmail
+	p.PendingEmail = ""
+	token, err := auth.GenerateJWT(p.ID, p.Email, p.Role)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return &TokenResponse{Token: token, Profile: p}, nil
+}
+
+// ChangePassword меняет пароль и завершает все остальные сессии: старые токены перестают действовать,
+// текущая сессия получает новый токен
+func (s *Service) ChangePassword(ctx context.Context, userID int, req *PasswordChangeRequest) (*TokenResponse, error) {
+	p, err := s.checkPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return nil, ErrSamePassword
+	}
+	if err := auth.ValidatePassword(req.NewPassword, p.Email); err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка хэширования пароля: %w", err)
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, hash, auth.RevokeTime(time.Now())); err != nil {
+		return nil, err
+	}
+	s.notice(ctx, p.Email, "Пароль изменён", "Пароль вашей учётной записи изменён, остальные сеансы завершены.")
+
+	token, err := auth.GenerateJWT(p.ID, p.Email, p.Role)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return &TokenResponse{Token: token, Profile: p}, nil
+}
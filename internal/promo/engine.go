@@ -0,0 +1,167 @@
+package promo
+
+import (
+	"crm-backend/internal/payment"
+	"sort"
+	"time"
+)
+
+// unit — одна единица товара в корзине, для акций, считающих штуки (bogo, n_for_price)
+type unit struct {
+	line  int
+	price float64
+}
+
+// Evaluate применяет акции к корзине. Акции идут по убыванию приоритета.
+// Обычные акции суммируются; эксклюзивная применяется только к позициям
+// без других скидок и после неё на эти позиции больше ничего не действует.
+func Evaluate(promos []Promotion, lines []Line, now time.Time) Evaluation {
+	ev := Evaluation{Lines: make([]EvaluatedLine, len(lines))}
+	for i, l := range lines {
+		subtotal := payment.Round(l.UnitPrice * float64(l.Quantity))
+		ev.Lines[i] = EvaluatedLine{Line: l, Subtotal: subtotal, Total: subtotal}
+		ev.Subtotal += subtotal
+	}
+
+	ordered := make([]Promotion, len(promos))
+	copy(ordered, promos)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority > ordered[j].Priority
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	locked := make([]bool, len(lines))
+	for _, p := range ordered {
+		if !p.ActiveAt(now) {
+			continue
+		}
+
+		var eligible []int
+		for i := range ev.Lines {
+			if locked[i] || ev.Lines[i].Total <= 0 || !p.Matches(ev.Lines[i].Line) {
+				continue
+			}
+			if p.Exclusive && ev.Lines[i].Discount > 0 {
+				continue
+			}
+			eligible = append(eligible, i)
+		}
+		if len(eligible) == 0 {
+			continue
+		}
+
+		discounts := discountsFor(p, ev.Lines, eligible)
+
+		var total float64
+		for i, d := range discounts {
+			d = payment.Round(d)
+			if d > ev.Lines[i].Total {
+				d = ev.Lines[i].Total
+			}
+			if d <= 0 {
+				continue
+			}
+			l := &ev.Lines[i]
+			l.Discount = payment.Round(l.Discount + d)
+			l.Total = payment.Round(l.Total - d)
+			l.Applied = append(l.Applied, AppliedRule{PromotionID: p.ID, Name: p.Name, Type: p.Type, Discount: d})
+			total += d
+			if p.Exclusive {
+				locked[i] = true
+			}
+		}
+		if total > 0 {
+			ev.Applied = append(ev.Applied, AppliedRule{
+				PromotionID: p.ID, Name: p.Name, Type: p.Type, Discount: payment.Round(total),
+			})
+		}
+	}
+
+	for _, l := range ev.Lines {
+		ev.Discount += l.Discount
+	}
+	ev.Subtotal = payment.Round(ev.Subtotal)
+	ev.Discount = payment.Round(ev.Discount)
+	ev.Total = payment.Round(ev.Subtotal - ev.Discount)
+	return ev
+}
+
+// discountsFor считает скидку акции по каждой подходящей позиции (индекс позиции -> сумма)
+func discountsFor(p Promotion, lines []EvaluatedLine, eligible []int) map[int]float64 {
+	discounts := make(map[int]float64)
+
+	switch p.Type {
+	case TypePercentOff:
+		for _, i := range eligible {
+			discounts[i] = lines[i].Total * p.Percent / 100
+		}
+
+	case TypeFixedOff:
+		for _, i := range eligible {
+			discounts[i] = p.Amount * float64(lines[i].Quantity)
+		}
+
+	case TypeBOGO:
+		// Из каждой группы «buy + get» скидка достаётся самым дешёвым единицам
+		units := expandUnits(lines, eligible)
+		group := p.BuyQuantity + p.GetQuantity
+		for start := 0; start+group <= len(units); start += group {
+			for _, u := range units[start+p.BuyQuantity : start+group] {
+				discounts[u.line] += u.price * p.Percent / 100
+			}
+		}
+
+	case TypeNForPrice:
+		units := expandUnits(lines, eligible)
+		n := p.BuyQuantity
+		for start := 0; start+n <= len(units); start += n {
+			var sum float64
+			for _, u := range units[start : start+n] {
+				sum += u.price
+			}
+			if sum <= p.Amount {
+				continue
+			}
+			// Скидку группы раскладываем пропорционально цене единиц
+			for _, u := range units[start : start+n] {
+				discounts[u.line] += (sum - p.Amount) * u.price / sum
+			}
+		}
+
+	case TypeThreshold:
+		var base float64
+		for _, i := range eligible {
+			base += lines[i].Total
+		}
+		if base < p.MinSubtotal || base <= 0 {
+			break
+		}
+		off := p.Amount
+		if p.Percent > 0 {
+			off = base * p.Percent / 100
+		}
+		if off > base {
+			off = base
+		}
+		for _, i := range eligible {
+			discounts[i] = off * lines[i].Total / base
+		}
+	}
+	return discounts
+}
+
+// expandUnits раскладывает позиции на единицы, от дорогих к дешёвым.
+// Цена единицы — с учётом уже применённых скидок.
+func expandUnits(lines []EvaluatedLine, eligible []int) []unit {
+	var units []unit
+	for _, i := range eligible {
+		price := lines[i].Total / float64(lines[i].Quantity)
+		for q := 0; q < lines[i].Quantity; q++ {
+			units = append(units, unit{line: i, price: price})
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price > units[b].price })
+	return units
+}
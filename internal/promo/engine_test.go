@@ -0,0 +1,207 @@
+package promo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 3, 15, 14, 0, 0, 0, time.UTC) // пятница
+
+	nike := Line{ItemID: 1, Brand: "Nike", Category: "shoes", Quantity: 2, UnitPrice: 1000}
+	adidas := Line{ItemID: 2, Brand: "Adidas", Category: "shoes", Quantity: 1, UnitPrice: 500}
+	socks := Line{ItemID: 3, Brand: "Nike", Category: "socks", Quantity: 1, UnitPrice: 400}
+
+	tests := []struct {
+		name      string
+		promos    []Promotion
+		lines     []Line
+		discounts []float64 // скидка по каждой позиции
+		total     float64
+		applied   []int // ID сработавших акций по порядку
+	}{
+		{
+			name:      "без акций",
+			lines:     []Line{nike, adidas},
+			discounts: []float64{0, 0},
+			total:     2500,
+		},
+		{
+			name:      "процент на бренд",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypePercentOff, Percent: 10, Brand: "nike"}},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{200, 0},
+			total:     2300,
+			applied:   []int{1},
+		},
+		{
+			name:      "фиксированная скидка не больше цены позиции",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypeFixedOff, Amount: 600, ItemID: intPtr(2)}},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{0, 500},
+			total:     2000,
+			applied:   []int{1},
+		},
+		{
+			name:      "купи 2 — третий бесплатно: скидка на самую дешёвую единицу",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypeBOGO, BuyQuantity: 2, GetQuantity: 1, Percent: 100}},
+			lines:     []Line{nike, socks},
+			discounts: []float64{0, 400},
+			total:     2000,
+			applied:   []int{1},
+		},
+		{
+			name:      "неполная группа bogo не получает скидку",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypeBOGO, BuyQuantity: 2, GetQuantity: 1, Percent: 100}},
+			lines:     []Line{nike},
+			discounts: []float64{0},
+			total:     2000,
+		},
+		{
+			name:      "3 за 2000: скидка раскладывается пропорционально цене",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypeNForPrice, BuyQuantity: 3, Amount: 2000}},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{400, 100},
+			total:     2000,
+			applied:   []int{1},
+		},
+		{
+			name:      "порог суммы чека достигнут",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypeThreshold, MinSubtotal: 2500, Percent: 10}},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{200, 50},
+			total:     2250,
+			applied:   []int{1},
+		},
+		{
+			name:      "порог суммы чека не достигнут",
+			promos:    []Promotion{{ID: 1, Active: true, Type: TypeThreshold, MinSubtotal: 2500.01, Amount: 300}},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{0, 0},
+			total:     2500,
+		},
+		{
+			name: "обычные акции суммируются по убыванию приоритета",
+			promos: []Promotion{
+				{ID: 1, Active: true, Type: TypeFixedOff, Amount: 50, Priority: 1},
+				{ID: 2, Active: true, Type: TypePercentOff, Percent: 10, Priority: 5},
+			},
+			lines:     []Line{adidas},
+			discounts: []float64{100},
+			total:     400,
+			applied:   []int{2, 1},
+		},
+		{
+			name: "эксклюзивная акция закрывает позицию для остальных",
+			promos: []Promotion{
+				{ID: 1, Active: true, Type: TypePercentOff, Percent: 20, ItemID: intPtr(1), Priority: 2, Exclusive: true},
+				{ID: 2, Active: true, Type: TypePercentOff, Percent: 10, Priority: 1},
+			},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{400, 50},
+			total:     2050,
+			applied:   []int{1, 2},
+		},
+		{
+			name: "эксклюзивная акция пропускает уже уценённые позиции",
+			promos: []Promotion{
+				{ID: 1, Active: true, Type: TypePercentOff, Percent: 10, ItemID: intPtr(1), Priority: 2},
+				{ID: 2, Active: true, Type: TypePercentOff, Percent: 50, Priority: 1, Exclusive: true},
+			},
+			lines:     []Line{nike, adidas},
+			discounts: []float64{200, 250},
+			total:     2050,
+			applied:   []int{1, 2},
+		},
+		{
+			name: "неактивные и не начавшиеся акции не действуют",
+			promos: []Promotion{
+				{ID: 1, Active: false, Type: TypePercentOff, Percent: 10},
+				{ID: 2, Active: true, Type: TypePercentOff, Percent: 10, StartsAt: timePtr(now.Add(time.Hour))},
+			},
+			lines:     []Line{adidas},
+			discounts: []float64{0},
+			total:     500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := Evaluate(tt.promos, tt.lines, now)
+
+			var discounts []float64
+			for _, l := range ev.Lines {
+				discounts = append(discounts, l.Discount)
+				if l.Total != l.Subtotal-l.Discount {
+					t.Errorf("позиция %d: итог %v не равен %v - %v", l.ItemID, l.Total, l.Subtotal, l.Discount)
+				}
+			}
+			if !reflect.DeepEqual(discounts, tt.discounts) {
+				t.Errorf("скидки по позициям = %v, ожидались %v", discounts, tt.discounts)
+			}
+			if ev.Total != tt.total {
+				t.Errorf("итог = %v, ожидался %v", ev.Total, tt.total)
+			}
+
+			var applied []int
+			for _, a := range ev.Applied {
+				applied = append(applied, a.PromotionID)
+			}
+			if !reflect.DeepEqual(applied, tt.applied) {
+				t.Errorf("сработали акции %v, ожидались %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestActiveAt(t *testing.T) {
+	friday := time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		p    Promotion
+		want bool
+	}{
+		{name: "без ограничений", p: Promotion{Active: true}, want: true},
+		{name: "выключена", p: Promotion{}, want: false},
+		{name: "ещё не началась", p: Promotion{Active: true, StartsAt: timePtr(friday.Add(time.Minute))}, want: false},
+		{name: "закончилась ровно сейчас", p: Promotion{Active: true, EndsAt: timePtr(friday)}, want: false},
+		{name: "в свой день недели", p: Promotion{Active: true, DaysOfWeek: []int{5, 6}}, want: true},
+		{name: "не в свой день недели", p: Promotion{Active: true, DaysOfWeek: []int{0, 6}}, want: false},
+		{name: "внутри часов", p: Promotion{Active: true, TimeFrom: "14:00", TimeTo: "15:00"}, want: true},
+		{name: "конец часов не включается", p: Promotion{Active: true, TimeFrom: "12:00", TimeTo: "14:30"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.p.ActiveAt(friday); got != tt.want {
+			t.Errorf("%s: ActiveAt = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Promotion
+		ok   bool
+	}{
+		{name: "процент", p: Promotion{Name: "A", Type: TypePercentOff, Percent: 15}, ok: true},
+		{name: "без названия", p: Promotion{Type: TypePercentOff, Percent: 15}},
+		{name: "процент больше 100", p: Promotion{Name: "A", Type: TypePercentOff, Percent: 150}},
+		{name: "bogo без количества", p: Promotion{Name: "A", Type: TypeBOGO, Percent: 100}},
+		{name: "n_for_price из одной штуки", p: Promotion{Name: "A", Type: TypeNForPrice, BuyQuantity: 1, Amount: 100}},
+		{name: "порог с процентом и суммой", p: Promotion{Name: "A", Type: TypeThreshold, MinSubtotal: 100, Percent: 5, Amount: 10}},
+		{name: "неверный день недели", p: Promotion{Name: "A", Type: TypeFixedOff, Amount: 10, DaysOfWeek: []int{7}}},
+		{name: "неверное время", p: Promotion{Name: "A", Type: TypeFixedOff, Amount: 10, TimeFrom: "25:00"}},
+		{name: "неизвестный тип", p: Promotion{Name: "A", Type: "gift"}},
+	}
+	for _, tt := range tests {
+		if err := tt.p.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}
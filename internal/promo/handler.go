@@ -0,0 +1,213 @@
+package promo
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// CreatePromotion godoc
+// @Summary Create promotion
+// @Description Создаёт акцию владельца: percent_off, fixed_off, bogo, n_for_price или threshold.
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param promotion body Promotion true "Акция"
+// @Success 201 {object} Promotion
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/promotions [post]
+func (h *Handler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return
+	}
+
+	var p Promotion
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreatePromotion(r.Context(), claims.ID, &p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// GetPromotions godoc
+// @Summary Get promotions
+// @Description Все акции владельца.
+// @Tags promotions
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Promotion
+// @Failure 401 {string} string "не авторизован"
+// @Failure 500 {string} string "ошибка получения акций"
+// @Router /owner/promotions [get]
+func (h *Handler) GetPromotions(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	promos, err := h.service.GetPromotions(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения акций", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(promos)
+}
+
+// UpdatePromotion godoc
+// @Summary Update promotion
+// @Description Обновляет акцию владельца.
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param promo_id path int true "Promotion ID"
+// @Param promotion body Promotion true "Акция"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/promotions/{promo_id} [put]
+func (h *Handler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "promo_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID акции", http.StatusBadRequest)
+		return
+	}
+
+	var p Promotion
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	p.ID = id
+
+	if err := h.service.UpdatePromotion(r.Context(), claims.ID, p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+}
+
+// DeletePromotion godoc
+// @Summary Delete promotion
+// @Description Удаляет акцию владельца.
+// @Tags promotions
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param promo_id path int true "Promotion ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "неправильный ID акции"
+// @Failure 401 {string} string "не авторизован"
+// @Router /owner/promotions/{promo_id} [delete]
+func (h *Handler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "promo_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID акции", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeletePromotion(r.Context(), claims.ID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// EvaluatePrices godoc
+// @Summary Evaluate cart prices
+// @Description Считает цены корзины с учётом действующих акций и объясняет, какие акции сработали.
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param cart body EvaluateRequest true "Корзина"
+// @Success 200 {object} Evaluation
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/prices/evaluate [post]
+func (h *Handler) EvaluatePrices(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var req EvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	ev, err := h.service.Quote(r.Context(), claims.ID, shopID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ev)
+}
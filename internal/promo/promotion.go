@@ -0,0 +1,191 @@
+package promo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Типы акций
+const (
+	TypePercentOff = "percent_off" // скидка Percent% на подходящие товары
+	TypeFixedOff   = "fixed_off"   // скидка Amount с каждой единицы
+	TypeBOGO       = "bogo"        // купи BuyQuantity — получи GetQuantity со скидкой Percent% (100 = бесплатно)
+	TypeNForPrice  = "n_for_price" // BuyQuantity единиц за Amount
+	TypeThreshold  = "threshold"   // при сумме от MinSubtotal скидка Percent% или Amount на чек
+)
+
+// Promotion — правило скидки владельца. Без ShopID действует во всех его магазинах,
+// пустые Brand/Category/ItemID означают «любой товар».
+type Promotion struct {
+	ID          int        `json:"id"`
+	OwnerID     int        `json:"owner_id"`
+	ShopID      *int       `json:"shop_id,omitempty"`
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Percent     float64    `json:"percent,omitempty"`
+	Amount      float64    `json:"amount,omitempty"`
+	BuyQuantity int        `json:"buy_quantity,omitempty"`
+	GetQuantity int        `json:"get_quantity,omitempty"`
+	MinSubtotal float64    `json:"min_subtotal,omitempty"`
+	Brand       string     `json:"brand,omitempty"`
+	Category    string     `json:"category,omitempty"`
+	ItemID      *int       `json:"item_id,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	DaysOfWeek  []int      `json:"days_of_week,omitempty"` // 0 — воскресенье
+	TimeFrom    string     `json:"time_from,omitempty"`    // "HH:MM"
+	TimeTo      string     `json:"time_to,omitempty"`
+	Priority    int        `json:"priority"`
+	Exclusive   bool       `json:"exclusive"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (p Promotion) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("название акции не может быть пустым")
+	}
+	if p.Percent < 0 || p.Percent > 100 {
+		return fmt.Errorf("процент скидки должен быть от 0 до 100")
+	}
+	if p.Amount < 0 || p.MinSubtotal < 0 {
+		return fmt.Errorf("суммы акции не могут быть отрицательными")
+	}
+
+	switch p.Type {
+	case TypePercentOff:
+		if p.Percent == 0 {
+			return fmt.Errorf("для percent_off нужно указать percent")
+		}
+	case TypeFixedOff:
+		if p.Amount == 0 {
+			return fmt.Errorf("для fixed_off нужно указать amount")
+		}
+	case TypeBOGO:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 || p.Percent == 0 {
+			return fmt.Errorf("для bogo нужно указать buy_quantity, get_quantity и percent")
+		}
+	case TypeNForPrice:
+		if p.BuyQuantity < 2 || p.Amount == 0 {
+			return fmt.Errorf("для n_for_price нужно указать buy_quantity (от 2) и amount")
+		}
+	case TypeThreshold:
+		if p.MinSubtotal == 0 || (p.Percent == 0) == (p.Amount == 0) {
+			return fmt.Errorf("для threshold нужно указать min_subtotal и одно из: percent или amount")
+		}
+	default:
+		return fmt.Errorf("неизвестный тип акции: %s", p.Type)
+	}
+
+	for _, d := range p.DaysOfWeek {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("день недели должен быть от 0 до 6")
+		}
+	}
+	for _, t := range []string{p.TimeFrom, p.TimeTo} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", t); err != nil {
+			return fmt.Errorf("время должно быть в формате HH:MM")
+		}
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && p.EndsAt.Before(*p.StartsAt) {
+		return fmt.Errorf("акция заканчивается раньше, чем начинается")
+	}
+	return nil
+}
+
+// ActiveAt — действует ли акция в момент t (даты, дни недели и часы)
+func (p Promotion) ActiveAt(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+
+	if len(p.DaysOfWeek) > 0 {
+		found := false
+		for _, d := range p.DaysOfWeek {
+			if time.Weekday(d) == t.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	clock := t.Format("15:04")
+	if p.TimeFrom != "" && clock < p.TimeFrom {
+		return false
+	}
+	if p.TimeTo != "" && clock >= p.TimeTo {
+		return false
+	}
+	return true
+}
+
+// Matches — подходит ли товар под таргетинг акции
+func (p Promotion) Matches(l Line) bool {
+	if p.ItemID != nil && *p.ItemID != l.ItemID {
+		return false
+	}
+	if p.Brand != "" && !strings.EqualFold(p.Brand, l.Brand) {
+		return false
+	}
+	if p.Category != "" && !strings.EqualFold(p.Category, l.Category) {
+		return false
+	}
+	return true
+}
+
+// Line — позиция корзины для расчёта цены
+type Line struct {
+	ItemID    int     `json:"item_id"`
+	Name      string  `json:"name"`
+	Brand     string  `json:"brand"`
+	Category  string  `json:"category"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+// AppliedRule — объяснение, какая акция сколько скинула
+type AppliedRule struct {
+	PromotionID int     `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Discount    float64 `json:"discount"`
+}
+
+type EvaluatedLine struct {
+	Line
+	Subtotal float64       `json:"subtotal"`
+	Discount float64       `json:"discount"`
+	Total    float64       `json:"total"`
+	Applied  []AppliedRule `json:"applied,omitempty"`
+}
+
+// Evaluation — итог расчёта корзины
+type Evaluation struct {
+	Lines    []EvaluatedLine `json:"lines"`
+	Subtotal float64         `json:"subtotal"`
+	Discount float64         `json:"discount"`
+	Total    float64         `json:"total"`
+	Applied  []AppliedRule   `json:"applied"`
+}
+
+type CartLine struct {
+	ItemID   int `json:"item_id"`
+	Quantity int `json:"quantity"`
+}
+
+type EvaluateRequest struct {
+	Items []CartLine `json:"items"`
+}
@@ -0,0 +1,153 @@
+package promo
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS promotions (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT REFERENCES shops(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			percent NUMERIC(5, 2) NOT NULL DEFAULT 0,
+			amount NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			buy_quantity INT NOT NULL DEFAULT 0,
+			get_quantity INT NOT NULL DEFAULT 0,
+			min_subtotal NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			brand VARCHAR(255),
+			category VARCHAR(255),
+			item_id INT REFERENCES items(id) ON DELETE CASCADE,
+			starts_at TIMESTAMP WITH TIME ZONE,
+			ends_at TIMESTAMP WITH TIME ZONE,
+			days_of_week INT[],
+			time_from VARCHAR(5),
+			time_to VARCHAR(5),
+			priority INT NOT NULL DEFAULT 0,
+			exclusive BOOLEAN NOT NULL DEFAULT FALSE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции promotions: %w", err)
+	}
+	fmt.Println("Миграция promotions выполнена успешно")
+	return nil
+}
+
+const promotionColumns = `
+	id, owner_id, shop_id, name, type, percent, amount, buy_quantity, get_quantity, min_subtotal,
+	COALESCE(brand, ''), COALESCE(category, ''), item_id, starts_at, ends_at, days_of_week,
+	COALESCE(time_from, ''), COALESCE(time_to, ''), priority, exclusive, active, created_at
+`
+
+func scanPromotions(rows pgx.Rows) ([]Promotion, error) {
+	defer rows.Close()
+
+	var promos []Promotion
+	for rows.Next() {
+		var p Promotion
+		if err := rows.Scan(&p.ID, &p.OwnerID, &p.ShopID, &p.Name, &p.Type, &p.Percent, &p.Amount,
+			&p.BuyQuantity, &p.GetQuantity, &p.MinSubtotal, &p.Brand, &p.Category, &p.ItemID,
+			&p.StartsAt, &p.EndsAt, &p.DaysOfWeek, &p.TimeFrom, &p.TimeTo, &p.Priority,
+			&p.Exclusive, &p.Active, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения акции: %w", err)
+		}
+		promos = append(promos, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке акций: %w", err)
+	}
+	return promos, nil
+}
+
+func (r *Repository) CreatePromotion(ctx context.Context, p *Promotion) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO promotions
+			(owner_id, shop_id, name, type, percent, amount, buy_quantity, get_quantity, min_subtotal,
+			 brand, category, item_id, starts_at, ends_at, days_of_week, time_from, time_to,
+			 priority, exclusive, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		RETURNING id, created_at
+	`, p.OwnerID, p.ShopID, p.Name, p.Type, p.Percent, p.Amount, p.BuyQuantity, p.GetQuantity, p.MinSubtotal,
+		p.Brand, p.Category, p.ItemID, p.StartsAt, p.EndsAt, p.DaysOfWeek, p.TimeFrom, p.TimeTo,
+		p.Priority, p.Exclusive, p.Active).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания акции: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) UpdatePromotion(ctx context.Context, p Promotion) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE promotions
+		SET shop_id = $1, name = $2, type = $3, percent = $4, amount = $5, buy_quantity = $6,
+		    get_quantity = $7, min_subtotal = $8, brand = $9, category = $10, item_id = $11,
+		    starts_at = $12, ends_at = $13, days_of_week = $14, time_from = $15, time_to = $16,
+		    priority = $17, exclusive = $18, active = $19
+		WHERE id = $20 AND owner_id = $21
+	`, p.ShopID, p.Name, p.Type, p.Percent, p.Amount, p.BuyQuantity, p.GetQuantity, p.MinSubtotal,
+		p.Brand, p.Category, p.ItemID, p.StartsAt, p.EndsAt, p.DaysOfWeek, p.TimeFrom, p.TimeTo,
+		p.Priority, p.Exclusive, p.Active, p.ID, p.OwnerID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления акции (ID=%d): %w", p.ID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("акция с ID %d не найдена", p.ID)
+	}
+	return nil
+}
+
+func (r *Repository) DeletePromotion(ctx context.Context, ownerID, id int) error {
+	res, err := r.db.Conn.Exec(ctx, `DELETE FROM promotions WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления акции (ID=%d): %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("акция с ID %d не найдена", id)
+	}
+	return nil
+}
+
+func (r *Repository) GetPromotionsByOwner(ctx context.Context, ownerID int) ([]Promotion, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE owner_id = $1
+		ORDER BY priority DESC, id
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения акций: %w", err)
+	}
+	return scanPromotions(rows)
+}
+
+// GetActiveForShop — включённые акции магазина и общие акции его владельца
+func (r *Repository) GetActiveForShop(ctx context.Context, shopID int) ([]Promotion, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE active
+		  AND (shop_id = $1 OR (shop_id IS NULL AND owner_id = (SELECT owner_id FROM shops WHERE id = $1)))
+		ORDER BY priority DESC, id
+	`, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения акций магазина: %w", err)
+	}
+	return scanPromotions(rows)
+}
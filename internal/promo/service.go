@@ -0,0 +1,114 @@
+package promo
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/shop"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrAccessDenied = errors.New("доступ запрещён: нет доступа к магазину")
+
+type Service struct {
+	repo      *Repository
+	items     *shop.Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, items *shop.Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, items: items, employees: employees}
+}
+
+// checkTarget — акцию можно привязать только к своему магазину
+func (s *Service) checkTarget(ctx context.Context, p Promotion) error {
+	if p.ShopID == nil {
+		return nil
+	}
+	isOwner, err := s.employees.IsOwner(ctx, *p.ShopID, p.OwnerID)
+	if err != nil {
+		return err
+	}
+	if !isOwner {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *Service) CreatePromotion(ctx context.Context, ownerID int, p *Promotion) error {
+	p.OwnerID = ownerID
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := s.checkTarget(ctx, *p); err != nil {
+		return err
+	}
+	return s.repo.CreatePromotion(ctx, p)
+}
+
+func (s *Service) UpdatePromotion(ctx context.Context, ownerID int, p Promotion) error {
+	p.OwnerID = ownerID
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := s.checkTarget(ctx, p); err != nil {
+		return err
+	}
+	return s.repo.UpdatePromotion(ctx, p)
+}
+
+func (s *Service) DeletePromotion(ctx context.Context, ownerID, id int) error {
+	return s.repo.DeletePromotion(ctx, ownerID, id)
+}
+
+func (s *Service) GetPromotions(ctx context.Context, ownerID int) ([]Promotion, error) {
+	return s.repo.GetPromotionsByOwner(ctx, ownerID)
+}
+
+// EvaluateLines — расчёт цен корзины по действующим акциям магазина (используется на кассе)
+func (s *Service) EvaluateLines(ctx context.Context, shopID int, lines []Line, now time.Time) (Evaluation, error) {
+	promos, err := s.repo.GetActiveForShop(ctx, shopID)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	return Evaluate(promos, lines, now), nil
+}
+
+// Quote — предварительный расчёт корзины с объяснением применённых акций
+func (s *Service) Quote(ctx context.Context, userID, shopID int, req EvaluateRequest) (Evaluation, error) {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	if !ok {
+		return Evaluation{}, ErrAccessDenied
+	}
+
+	var lines []Line
+	for _, c := range req.Items {
+		if c.Quantity <= 0 {
+			return Evaluation{}, fmt.Errorf("количество товара ID=%d должно быть больше нуля", c.ItemID)
+		}
+		item, err := s.items.GetItemByID(ctx, c.ItemID)
+		if err != nil {
+			return Evaluation{}, err
+		}
+		if item.ShopID != shopID {
+			return Evaluation{}, fmt.Errorf("товар ID=%d не принадлежит магазину", c.ItemID)
+		}
+		lines = append(lines, LineFromItem(*item, c.Quantity))
+	}
+	return s.EvaluateLines(ctx, shopID, lines, time.Now())
+}
+
+func LineFromItem(item shop.Item, quantity int) Line {
+	return Line{
+		ItemID:    item.ID,
+		Name:      item.Name,
+		Brand:     item.Brand,
+		Category:  item.Category,
+		Quantity:  quantity,
+		UnitPrice: item.SalePrice,
+	}
+}
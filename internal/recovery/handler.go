@@ -0,0 +1,89 @@
+package recovery
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/lockout"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrTooManyRequests):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// RequestReset godoc
+// @Summary Request password reset
+// @Description Отправляет ссылку для сброса пароля на email (channel=email, по умолчанию) или 6-значный код на телефон из профиля (channel=sms). Ответ одинаковый, есть такой email или нет. Не больше 3 запросов на учётную запись за 15 минут и 10 запросов с одного адреса в час.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetRequest true "Email и канал"
+// @Success 202 {object} map[string]string
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 429 {string} string "слишком много запросов, попробуйте позже"
+// @Router /auth/password-reset [post]
+func (h *Handler) RequestReset(w http.ResponseWriter, r *http.Request) {
+	var req ResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RequestReset(r.Context(), auth.ClientIP(r), &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"status": "если учётная запись существует, инструкции по сбросу пароля отправлены",
+	})
+}
+
+// ConfirmReset godoc
+// @Summary Set new password
+// @Description Задаёт новый пароль по токену из ссылки или по email и коду из SMS. Токен и код одноразовые; код сгорает после 5 проверок, в том числе параллельных. Неверный код учитывается как неудачный вход по email и адресу: после серии ошибок подтверждение и вход закрываются с нарастающей задержкой (429 с Retry-After). Все сессии пользователя завершаются.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ConfirmRequest true "Токен или email и код, новый пароль"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "ссылка или код недействительны"
+// @Failure 429 {string} string "слишком много неудачных попыток, попробуйте позже"
+// @Router /auth/password-reset/confirm [post]
+func (h *Handler) ConfirmReset(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Confirm(r.Context(), auth.ClientIP(r), &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "password_changed"})
+}
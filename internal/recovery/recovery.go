@@ -0,0 +1,86 @@
+package recovery
+
+import (
+	"crm-backend/internal/notify"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// linkTTL — сколько действует ссылка для сброса пароля из письма
+	linkTTL = time.Hour
+	// codeTTL — сколько действует код из SMS
+	codeTTL = 15 * time.Minute
+	// maxCodeAttempts — после стольких проверок кода он сгорает
+	maxCodeAttempts = 5
+
+	// Не больше accountLimit запросов на учётную запись за accountWindow
+	accountLimit  = 3
+	accountWindow = 15 * time.Minute
+	// Не больше ipLimit запросов с одного адреса за ipWindow
+	ipLimit  = 10
+	ipWindow = time.Hour
+)
+
+var (
+	ErrInvalidReset    = errors.New("ссылка или код недействительны, запросите сброс пароля заново")
+	ErrTooManyRequests = errors.New("слишком много запросов, попробуйте позже")
+)
+
+// ResetRequest — запрос на сброс пароля. channel: email (ссылка в письме) или sms (6-значный код
+// на телефон из профиля).
+type ResetRequest struct {
+	Email   string         `json:"email"`
+	Channel notify.Channel `json:"channel"`
+}
+
+func (r *ResetRequest) validate() error {
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	if r.Email == "" {
+		return errors.New("укажите email")
+	}
+	switch r.Channel {
+	case "":
+		r.Channel = notify.ChannelEmail
+	case notify.ChannelEmail, notify.ChannelSMS:
+	default:
+		return errors.New("канал должен быть email или sms")
+	}
+	return nil
+}
+
+// ConfirmRequest — новый пароль по токену из ссылки или по email и коду из SMS
+type ConfirmRequest struct {
+	Token       string `json:"token,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Code        string `json:"code,omitempty"`
+	NewPassword string `json:"new_password"`
+}
+
+func (r *ConfirmRequest) validate() error {
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Token == "" && (r.Email == "" || r.Code == "") {
+		return errors.New("укажите токен из ссылки или email и код")
+	}
+	return nil
+}
+
+// Reset — выданный токен или код сброса; в базе хранится только его хэш
+type Reset struct {
+	ID        int
+	UserID    int
+	Channel   notify.Channel
+	Hash      string
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Account — учётная запись, для которой запрошен сброс
+type Account struct {
+	ID    int
+	Email string
+	Phone string
+}
@@ -0,0 +1,221 @@
+package recovery
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS password_resets (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			channel VARCHAR(20) NOT NULL,
+			token_hash VARCHAR(64) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS password_resets_token ON password_resets (token_hash);
+		CREATE INDEX IF NOT EXISTS password_resets_user ON password_resets (user_id, created_at);
+
+		-- Журнал запросов для ограничения частоты, в том числе по несуществующим email
+		CREATE TABLE IF NOT EXISTS password_reset_requests (
+			id SERIAL PRIMARY KEY,
+			ip VARCHAR(64) NOT NULL,
+			email VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS password_reset_requests_ip ON password_reset_requests (ip, created_at);
+		CREATE INDEX IF NOT EXISTS password_reset_requests_email ON password_reset_requests (email, created_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции password_resets: %w", err)
+	}
+	fmt.Println("Миграция password_resets выполнена успешно")
+	return nil
+}
+
+// LogRequest записывает запрос и возвращает, сколько запросов было с этого адреса и на этот email
+// с начала окон ограничения (включая текущий)
+func (r *Repository) LogRequest(ctx context.Context, ip, email string, ipSince, emailSince time.Time) (int, int, error) {
+	if _, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO password_reset_requests (ip, email) VALUES ($1, $2)
+	`, ip, email); err != nil {
+		return 0, 0, fmt.Errorf("ошибка записи запроса на сброс пароля: %w", err)
+	}
+	var byIP, byEmail int
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE ip = $1 AND created_at >= $3),
+			COUNT(*) FILTER (WHERE email = $2 AND created_at >= $4)
+		FROM password_reset_requests
+		WHERE created_at >= LEAST($3, $4)
+	`, ip, email, ipSince, emailSince).Scan(&byIP, &byEmail)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка подсчёта запросов на сброс пароля: %w", err)
+	}
+	return byIP, byEmail, nil
+}
+
+// FindAccount — учётная запись по email; nil — такой нет
+func (r *Repository) FindAccount(ctx context.Context, email string) (*Account, error) {
+	var a Account
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT id, email, COALESCE(phone, '') FROM users WHERE LOWER(email) = $1
+	`, email).Scan(&a.ID, &a.Email, &a.Phone)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска учётной записи: %w", err)
+	}
+	return &a, nil
+}
+
+func (r *Repository) GetAccount(ctx context.Context, userID int) (*Account, error) {
+	var a Account
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT id, email, COALESCE(phone, '') FROM users WHERE id = $1
+	`, userID).Scan(&a.ID, &a.Email, &a.Phone)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidReset
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска учётной записи: %w", err)
+	}
+	return &a, nil
+}
+
+// CreateReset выдаёт новый токен; прежние неиспользованные токены пользователя перестают действовать
+func (r *Repository) CreateReset(ctx context.Context, reset *Reset) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE password_resets SET expires_at = NOW() WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+	`, reset.UserID); err != nil {
+		return fmt.Errorf("ошибка создания сброса пароля: %w", err)
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO password_resets (user_id, channel, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, reset.UserID, reset.Channel, reset.Hash, reset.ExpiresAt).Scan(&reset.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка создания сброса пароля: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка создания сброса пароля: %w", err)
+	}
+	return nil
+}
+
+const resetColumns = `id, user_id, channel, token_hash, attempts, expires_at, used_at`
+
+func scanReset(row pgx.Row) (*Reset, error) {
+	var reset Reset
+	err := row.Scan(&reset.ID, &reset.UserID, &reset.Channel, &reset.Hash, &reset.Attempts, &reset.ExpiresAt, &reset.UsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidReset
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сброса пароля: %w", err)
+	}
+	return &reset, nil
+}
+
+// GetByLinkHash — токен из ссылки в письме. Коды из SMS так не ищутся: их перебор ограничен попытками,
+// а без указания email они бы проверялись без ограничений.
+func (r *Repository) GetByLinkHash(ctx context.Context, hash string) (*Reset, error) {
+	return scanReset(r.db.Conn.QueryRow(ctx, `
+		SELECT `+resetColumns+` FROM password_resets WHERE token_hash = $1 AND channel = 'email'
+	`, hash))
+}
+
+// GetLatest — последний выданный пользователю токен
+func (r *Repository) GetLatest(ctx context.Context, userID int) (*Reset, error) {
+	return scanReset(r.db.Conn.QueryRow(ctx, `
+		SELECT `+resetColumns+` FROM password_resets WHERE user_id = $1 ORDER BY id DESC LIMIT 1
+	`, userID))
+}
+
+// ClaimAttempt расходует попытку ввода кода до его проверки. Попытка занимается одним запросом,
+// поэтому параллельные запросы не проверят больше maxAttempts кодов. false — попытки кончились
+// или код уже использован: код сгорел.
+func (r *Repository) ClaimAttempt(ctx context.Context, resetID, maxAttempts int) (bool, error) {
+	var attempts int
+	err := r.db.Conn.QueryRow(ctx, `
+		UPDATE password_resets SET attempts = attempts + 1
+		WHERE id = $1 AND attempts < $2 AND used_at IS NULL
+		RETURNING attempts
+	`, resetID, maxAttempts).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка обновления сброса пароля: %w", err)
+	}
+	return true, nil
+}
+
+// Complete гасит токен, меняет пароль и завершает все сессии пользователя.
+// Токен, уже использованный параллельным запросом, даёт ErrInvalidReset.
+func (r *Repository) Complete(ctx context.Context, reset *Reset, passwordHash string, at time.Time) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE password_resets SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND expires_at > $1
+	`, at, reset.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка сброса пароля: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidReset
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET password_hash = $1, sessions_revoked_at = $2 WHERE id = $3
+	`, passwordHash, at, reset.UserID); err != nil {
+		return fmt.Errorf("ошибка сброса пароля: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сброса пароля: %w", err)
+	}
+	return nil
+}
+
+// DeleteOld удаляет журнал запросов и отработавшие токены старше before
+func (r *Repository) DeleteOld(ctx context.Context, before time.Time) error {
+	if _, err := r.db.Conn.Exec(ctx, `DELETE FROM password_reset_requests WHERE created_at < $1`, before); err != nil {
+		return fmt.Errorf("ошибка очистки запросов на сброс пароля: %w", err)
+	}
+	if _, err := r.db.Conn.Exec(ctx, `DELETE FROM password_resets WHERE expires_at < $1`, before); err != nil {
+		return fmt.Errorf("ошибка очистки сбросов пароля: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package recovery
+
+import (
+	"context"
+	"crm-backend/internal/auth"
+	"crm-backend/internal/lockout"
+	"crm-backend/internal/notify"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	repo      *Repository
+	sender    *notify.Dispatcher
+	guard     *lockout.Service
+	publicURL string
+}
+
+func NewService(repo *Repository, sender *notify.Dispatcher, guard *lockout.Service, publicURL string) *Service {
+	return &Service{repo: repo, sender: sender, guard: guard, publicURL: strings.TrimRight(publicURL, "/")}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации кода: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestReset отправляет ссылку или код для сброса пароля. Ответ не зависит от того, есть ли такой email:
+// неизвестный адрес, превышение лимита по учётной записи и ошибки доставки молча пропускаются.
+// Ошибку возвращает только превышение лимита по IP-адресу.
+func (s *Service) RequestReset(ctx context.Context, ip string, req *ResetRequest) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	byIP, byEmail, err := s.repo.LogRequest(ctx, ip, req.Email, now.Add(-ipWindow), now.Add(-accountWindow))
+	if err != nil {
+		return err
+	}
+	if byIP > ipLimit {
+		return ErrTooManyRequests
+	}
+	if byEmail > accountLimit {
+		log.Printf("сброс пароля: превышен лимит запросов для %s", req.Email)
+		return nil
+	}
+
+	account, err := s.repo.FindAccount(ctx, req.Email)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return nil
+	}
+	if req.Channel == notify.ChannelSMS && account.Phone == "" {
+		log.Printf("сброс пароля: у пользователя ID=%d нет телефона для SMS", account.ID)
+		return nil
+	}
+
+	msg, reset, err := s.issue(account, req.Channel, now)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.CreateReset(ctx, reset); err != nil {
+		return err
+	}
+	if _, err := s.sender.Send(ctx, msg); err != nil {
+		log.Printf("сброс пароля: ошибка отправки пользователю ID=%d: %v", account.ID, err)
+	}
+	return nil
+}
+
+// issue готовит токен (ссылку в письме) или код (в SMS) и сообщение с ним
+func (s *Service) issue(account *Account, channel notify.Channel, now time.Time) (notify.Message, *Reset, error) {
+	reset := &Reset{UserID: account.ID, Channel: channel}
+	if channel == notify.ChannelSMS {
+		code, err := newCode()
+		if err != nil {
+			return notify.Message{}, nil, err
+		}
+		reset.Hash = hash(code)
+		reset.ExpiresAt = now.Add(codeTTL)
+		return notify.Message{
+			Channel: notify.ChannelSMS,
+			To:      account.Phone,
+			Body:    fmt.Sprintf("Код для сброса пароля: %s. Никому его не сообщайте.", code),
+		}, reset, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return notify.Message{}, nil, err
+	}
+	reset.Hash = hash(token)
+	reset.ExpiresAt = now.Add(linkTTL)
+	return notify.Message{
+		Channel: notify.ChannelEmail,
+		To:      account.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Чтобы задать новый пароль, перейдите по ссылке: %s/reset-password?token=%s\n"+
+			"Ссылка действует %d минут. Если вы не запрашивали сброс, просто проигнорируйте письмо.",
+			s.publicURL, token, int(linkTTL/time.Minute)),
+	}, reset, nil
+}
+
+// find проверяет токен из ссылки или код из SMS. Каждая проверка кода расходует попытку, а неверный код
+// учитывается как неудачный вход по email и адресу (lockout): перебор кода ограничен так же, как перебор пароля.
+func (s *Service) find(ctx context.Context, ip string, req *ConfirmRequest, now time.Time) (*Reset, *Account, error) {
+	if req.Token != "" {
+		reset, err := s.repo.GetByLinkHash(ctx, hash(req.Token))
+		if err != nil {
+			return nil, nil, err
+		}
+		if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+			return nil, nil, ErrInvalidReset
+		}
+		account, err := s.repo.GetAccount(ctx, reset.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return reset, account, nil
+	}
+
+	wait, err := s.guard.Check(ctx, req.Email, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wait > 0 {
+		return nil, nil, &lockout.LockedError{RetryAfter: wait}
+	}
+
+	account, err := s.repo.FindAccount(ctx, req.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if account == nil {
+		s.failed(ctx, 0, req.Email, ip)
+		return nil, nil, ErrInvalidReset
+	}
+	reset, err := s.repo.GetLatest(ctx, account.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reset.Channel != notify.ChannelSMS || reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+		return nil, nil, ErrInvalidReset
+	}
+	claimed, err := s.repo.ClaimAttempt(ctx, reset.ID, maxCodeAttempts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !claimed {
+		return nil, nil, ErrInvalidReset
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(req.Code)), []byte(reset.Hash)) != 1 {
+		s.failed(ctx, account.ID, req.Email, ip)
+		return nil, nil, ErrInvalidReset
+	}
+	return reset, account, nil
+}
+
+func (s *Service) failed(ctx context.Context, userID int, email, ip string) {
+	if err := s.guard.Failure(ctx, userID, email, ip, lockout.ReasonWrongResetCode); err != nil {
+		log.Printf("сброс пароля: ошибка учёта неверного кода: %v", err)
+	}
+}
+
+// Confirm задаёт новый пароль. Токен или код одноразовый; все сессии пользователя завершаются.
+func (s *Service) Confirm(ctx context.Context, ip string, req *ConfirmRequest) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	reset, account, err := s.find(ctx, ip, req, now)
+	if err != nil {
+		return err
+	}
+	if err := auth.ValidatePassword(req.NewPassword, account.Email); err != nil {
+		return err
+	}
+	passwordHash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("ошибка хэширования пароля: %w", err)
+	}
+	if err := s.repo.Complete(ctx, reset, passwordHash, auth.RevokeTime(now)); err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		Channel: notify.ChannelEmail,
+		To:      account.Email,
+		Subject: "Пароль изменён",
+		Body:    "Пароль вашей учётной записи сброшен, все сеансы завершены.",
+	}
+	if _, err := s.sender.Send(ctx, msg); err != nil {
+		log.Printf("сброс пароля: ошибка уведомления пользователя ID=%d: %v", account.ID, err)
+	}
+	return nil
+}
+
+// RunCleanup раз в interval удаляет журнал запросов и токены старше суток
+func (s *Service) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repo.DeleteOld(ctx, time.Now().Add(-24*time.Hour)); err != nil {
+				log.Printf("ошибка очистки сбросов пароля: %v", err)
+			}
+		}
+	}
+}
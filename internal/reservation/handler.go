@@ -0,0 +1,287 @@
+package reservation
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/sale"
+	"crm-backend/internal/shift"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrReservationNotFound), errors.Is(err, ErrItemNotFound), errors.Is(err, ErrShopNotFound),
+		errors.Is(err, customer.ErrCustomerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNotEnoughStock), errors.Is(err, ErrInvalidState), errors.Is(err, shift.ErrNoOpenShift),
+		errors.Is(err, shift.ErrShiftClosed), errors.Is(err, sale.ErrOutOfStock), errors.Is(err, sale.ErrHoldClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func reservationParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "reservation_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID брони", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, id, true
+}
+
+// GetAvailability godoc
+// @Summary Cross-shop availability
+// @Description Наличие товара во всех магазинах организации: остаток, отложено (лист ожидания и брони) и свободно. item_id подбирает ту же модель в других магазинах, q ищет по названию или бренду.
+// @Tags reservations
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param item_id query int false "Товар, который ищем в других магазинах"
+// @Param q query string false "Название или бренд"
+// @Param size query string false "Размер"
+// @Success 200 {array} Availability
+// @Failure 400 {string} string "укажите товар или строку поиска"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/availability [get]
+func (h *Handler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	itemID, _ := strconv.Atoi(query.Get("item_id"))
+
+	result, err := h.service.Availability(r.Context(), claims.ID, shopID, itemID, query.Get("q"), query.Get("size"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// CreateReservation godoc
+// @Summary Reserve item
+// @Description Бронирует товар любого магазина организации для покупателя на hold_hours часов (по умолчанию 24). Если pickup_shop_id отличается от магазина товара, бронь ждёт перемещения.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param reservation body Reservation true "Бронь"
+// @Success 201 {object} Reservation
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 404 {string} string "товар не найден"
+// @Failure 409 {string} string "недостаточно свободного товара"
+// @Router /shops/{id}/reservations [post]
+func (h *Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var rv Reservation
+	if err := json.NewDecoder(r.Body).Decode(&rv); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Create(r.Context(), claims.ID, shopID, &rv); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rv)
+}
+
+// GetReservations godoc
+// @Summary List shop reservations
+// @Description Брони, которые магазин должен отправить или выдать. По умолчанию — действующие (held, in_transit, ready).
+// @Tags reservations
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param status query string false "held, in_transit, ready, collected, expired или cancelled"
+// @Success 200 {array} Reservation
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/reservations [get]
+func (h *Handler) GetReservations(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.service.GetReservations(r.Context(), claims.ID, shopID, r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+type action func(s *Service, r *http.Request, userID, shopID, id int) (*Reservation, error)
+
+func (h *Handler) change(w http.ResponseWriter, r *http.Request, do action) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, id, ok := reservationParams(w, r)
+	if !ok {
+		return
+	}
+
+	rv, err := do(h.service, r, claims.ID, shopID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rv)
+}
+
+// ShipReservation godoc
+// @Summary Ship reservation to pickup shop
+// @Description Магазин-источник отправил забронированный товар: он списывается с остатка и едет в магазин выдачи.
+// @Tags reservations
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param reservation_id path int true "Reservation ID"
+// @Success 200 {object} Reservation
+// @Failure 404 {string} string "бронь не найдена"
+// @Failure 409 {string} string "действие недоступно в текущем статусе брони"
+// @Router /shops/{id}/reservations/{reservation_id}/ship [post]
+func (h *Handler) ShipReservation(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, func(s *Service, r *http.Request, userID, shopID, id int) (*Reservation, error) {
+		return s.Ship(r.Context(), userID, shopID, id)
+	})
+}
+
+// ReceiveReservation godoc
+// @Summary Receive transferred reservation
+// @Description Магазин выдачи принял перемещённый товар. Товар ставится на остаток и ждёт покупателя hold_hours часов с момента приёмки.
+// @Tags reservations
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param reservation_id path int true "Reservation ID"
+// @Success 200 {object} Reservation
+// @Failure 404 {string} string "бронь не найдена"
+// @Failure 409 {string} string "действие недоступно в текущем статусе брони"
+// @Router /shops/{id}/reservations/{reservation_id}/receive [post]
+func (h *Handler) ReceiveReservation(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, func(s *Service, r *http.Request, userID, shopID, id int) (*Reservation, error) {
+		return s.Receive(r.Context(), userID, shopID, id)
+	})
+}
+
+// CancelReservation godoc
+// @Summary Cancel reservation
+// @Description Снимает бронь. Освободившийся товар предлагается листу ожидания. Бронь в пути отменить нельзя.
+// @Tags reservations
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param reservation_id path int true "Reservation ID"
+// @Success 200 {object} Reservation
+// @Failure 404 {string} string "бронь не найдена"
+// @Failure 409 {string} string "действие недоступно в текущем статусе брони"
+// @Router /shops/{id}/reservations/{reservation_id}/cancel [post]
+func (h *Handler) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, func(s *Service, r *http.Request, userID, shopID, id int) (*Reservation, error) {
+		return s.Cancel(r.Context(), userID, shopID, id)
+	})
+}
+
+// CollectReservation godoc
+// @Summary Collect reservation
+// @Description Покупатель забирает бронь: в магазине выдачи пробивается чек на забронированный товар по текущим ценам. Бронь закрывается в той же транзакции, что и чек: резерв снимается одновременно со списанием остатка.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param reservation_id path int true "Reservation ID"
+// @Param payment body CollectRequest true "Оплата"
+// @Success 200 {object} CollectResult
+// @Failure 404 {string} string "бронь не найдена"
+// @Failure 409 {string} string "действие недоступно в текущем статусе брони"
+// @Router /shops/{id}/reservations/{reservation_id}/collect [post]
+func (h *Handler) CollectReservation(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, id, ok := reservationParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req CollectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.service.Collect(r.Context(), claims.ID, shopID, id, req.Payments)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,327 @@
+package reservation
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"crm-backend/internal/waitlist"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS reservations (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+			source_shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			pickup_shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			pickup_item_id INT REFERENCES items(id) ON DELETE SET NULL,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			quantity INT NOT NULL DEFAULT 1,
+			hold_hours INT NOT NULL,
+			notes TEXT,
+			status VARCHAR(20) NOT NULL,
+			hold_until TIMESTAMP WITH TIME ZONE,
+			sale_id INT REFERENCES sales(id) ON DELETE SET NULL,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			shipped_at TIMESTAMP WITH TIME ZONE,
+			received_at TIMESTAMP WITH TIME ZONE,
+			closed_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE INDEX IF NOT EXISTS reservations_item ON reservations (item_id, status);
+		CREATE INDEX IF NOT EXISTS reservations_pickup_item ON reservations (pickup_item_id, status);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции reservations: %w", err)
+	}
+	fmt.Println("Миграция reservations выполнена успешно")
+	return nil
+}
+
+// GetAvailability — остатки подходящих товаров во всех магазинах организации.
+// itemID подбирает ту же модель (название и бренд) в других магазинах, q — поиск по названию или бренду.
+func (r *Repository) GetAvailability(ctx context.Context, ownerID, itemID int, q, size string) ([]Availability, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT i.id, i.shop_id, s.name, i.name, i.brand, COALESCE(i.size, ''), i.stock, `+waitlist.HeldSQL("i.id")+`
+		FROM items i JOIN shops s ON s.id = i.shop_id
+		WHERE s.owner_id = $1
+		  AND ($2 = 0 OR (LOWER(i.name), LOWER(i.brand)) = (SELECT LOWER(name), LOWER(brand) FROM items WHERE id = $2))
+		  AND ($3 = '' OR i.name ILIKE '%' || $3 || '%' OR i.brand ILIKE '%' || $3 || '%')
+		  AND ($4 = '' OR LOWER(COALESCE(i.size, '')) = LOWER($4))
+		ORDER BY i.name, i.brand, i.size, s.name
+	`, ownerID, itemID, q, size)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения наличия товара: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Availability
+	for rows.Next() {
+		var a Availability
+		if err := rows.Scan(&a.ItemID, &a.ShopID, &a.ShopName, &a.Name, &a.Brand, &a.Size, &a.Stock, &a.Held); err != nil {
+			return nil, fmt.Errorf("ошибка чтения наличия товара: %w", err)
+		}
+		a.Available = max(a.Stock-a.Held, 0)
+		result = append(result, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке наличия товара: %w", err)
+	}
+	return result, nil
+}
+
+const reservationColumns = `
+	rv.id, rv.owner_id, rv.item_id, i.name, COALESCE(i.size, ''), rv.source_shop_id, rv.pickup_shop_id, rv.pickup_item_id,
+	rv.customer_id, rv.quantity, rv.hold_hours, COALESCE(rv.notes, ''), rv.status, rv.hold_until, rv.sale_id,
+	COALESCE(rv.created_by, 0), rv.created_at, rv.shipped_at, rv.received_at, rv.closed_at
+`
+
+func scanReservations(rows pgx.Rows) ([]Reservation, error) {
+	defer rows.Close()
+
+	var list []Reservation
+	for rows.Next() {
+		var rv Reservation
+		if err := rows.Scan(&rv.ID, &rv.OwnerID, &rv.ItemID, &rv.ItemName, &rv.Size, &rv.SourceShopID, &rv.PickupShopID,
+			&rv.PickupItemID, &rv.CustomerID, &rv.Quantity, &rv.HoldHours, &rv.Notes, &rv.Status, &rv.HoldUntil,
+			&rv.SaleID, &rv.CreatedBy, &rv.CreatedAt, &rv.ShippedAt, &rv.ReceivedAt, &rv.ClosedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения брони: %w", err)
+		}
+		list = append(list, rv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке броней: %w", err)
+	}
+	return list, nil
+}
+
+// Create бронирует товар. Строка товара блокируется, чтобы бронь, приёмка по листу ожидания
+// и другая бронь не заняли одни и те же единицы.
+func (r *Repository) Create(ctx context.Context, rv *Reservation) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var stock, held int
+	if err := tx.QueryRow(ctx, `SELECT stock FROM items WHERE id = $1 FOR UPDATE`, rv.ItemID).Scan(&stock); err != nil {
+		return fmt.Errorf("ошибка получения остатка товара (ID=%d): %w", rv.ItemID, err)
+	}
+	if err := tx.QueryRow(ctx, waitlist.HeldQuery, rv.ItemID).Scan(&held); err != nil {
+		return fmt.Errorf("ошибка подсчёта отложенного товара: %w", err)
+	}
+	if stock-held < rv.Quantity {
+		return ErrNotEnoughStock
+	}
+
+	// Без перемещения товар сразу ждёт покупателя в том же магазине
+	rv.Status = StatusHeld
+	if !rv.Transfer() {
+		rv.Status = StatusReady
+		itemID := rv.ItemID
+		rv.PickupItemID = &itemID
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO reservations (owner_id, item_id, source_shop_id, pickup_shop_id, pickup_item_id, customer_id,
+			quantity, hold_hours, notes, status, hold_until, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, NOW() + make_interval(hours => $8), $11)
+		RETURNING id, hold_until, created_at
+	`, rv.OwnerID, rv.ItemID, rv.SourceShopID, rv.PickupShopID, rv.PickupItemID, rv.CustomerID, rv.Quantity,
+		rv.HoldHours, rv.Notes, rv.Status, rv.CreatedBy).Scan(&rv.ID, &rv.HoldUntil, &rv.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания брони: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка создания брони: %w", err)
+	}
+	return nil
+}
+
+// GetReservation — бронь, в которой магазин является источником или местом выдачи
+func (r *Repository) GetReservation(ctx context.Context, shopID, id int) (*Reservation, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+reservationColumns+`
+		FROM reservations rv JOIN items i ON i.id = rv.item_id
+		WHERE rv.id = $1 AND (rv.source_shop_id = $2 OR rv.pickup_shop_id = $2)
+	`, id, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения брони: %w", err)
+	}
+	list, err := scanReservations(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrReservationNotFound
+	}
+	return &list[0], nil
+}
+
+// GetReservations — брони магазина (отправляемые и ожидающие выдачи); пустой статус — действующие брони
+func (r *Repository) GetReservations(ctx context.Context, shopID int, status string) ([]Reservation, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+reservationColumns+`
+		FROM reservations rv JOIN items i ON i.id = rv.item_id
+		WHERE (rv.source_shop_id = $1 OR rv.pickup_shop_id = $1)
+		  AND (($2 = '' AND rv.status IN ('held', 'in_transit', 'ready')) OR rv.status = $2)
+		ORDER BY rv.created_at DESC, rv.id DESC
+	`, shopID, status)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения броней: %w", err)
+	}
+	return scanReservations(rows)
+}
+
+// Ship отправляет забронированный товар в магазин выдачи и списывает его с остатка источника
+func (r *Repository) Ship(ctx context.Context, id int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var itemID, quantity int
+	err = tx.QueryRow(ctx, `
+		UPDATE reservations SET status = 'in_transit', shipped_at = NOW(), hold_until = NULL
+		WHERE id = $1 AND status = 'held' AND pickup_shop_id <> source_shop_id
+		RETURNING item_id, quantity
+	`, id).Scan(&itemID, &quantity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidState
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка отправки брони (ID=%d): %w", id, err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE items SET stock = GREATEST(stock - $1, 0), updated_at = NOW() WHERE id = $2`, quantity, itemID)
+	if err != nil {
+		return fmt.Errorf("ошибка списания остатка товара: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка отправки брони (ID=%d): %w", id, err)
+	}
+	return nil
+}
+
+// Receive принимает перемещённый товар в магазине выдачи. Если такой модели и размера
+// там ещё нет, карточка товара копируется из магазина-источника.
+func (r *Repository) Receive(ctx context.Context, id int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var itemID, pickupShopID, quantity int
+	err = tx.QueryRow(ctx, `
+		SELECT item_id, pickup_shop_id, quantity FROM reservations WHERE id = $1 AND status = 'in_transit' FOR UPDATE
+	`, id).Scan(&itemID, &pickupShopID, &quantity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidState
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения брони (ID=%d): %w", id, err)
+	}
+
+	var pickupItemID int
+	err = tx.QueryRow(ctx, `
+		SELECT p.id FROM items p JOIN items src ON src.id = $2
+		WHERE p.shop_id = $1 AND LOWER(p.name) = LOWER(src.name) AND LOWER(p.brand) = LOWER(src.brand)
+		  AND LOWER(COALESCE(p.size, '')) = LOWER(COALESCE(src.size, ''))
+		ORDER BY p.id
+		LIMIT 1
+	`, pickupShopID, itemID).Scan(&pickupItemID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO items (shop_id, name, brand, category, size, purchase_price, sale_price, photo_url)
+			SELECT $1, name, brand, category, size, purchase_price, sale_price, photo_url FROM items WHERE id = $2
+			RETURNING id
+		`, pickupShopID, itemID).Scan(&pickupItemID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка поиска товара в магазине выдачи: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE items SET stock = stock + $1, updated_at = NOW() WHERE id = $2`, quantity, pickupItemID)
+	if err != nil {
+		return fmt.Errorf("ошибка оприходования товара: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE reservations
+		SET status = 'ready', pickup_item_id = $1, received_at = NOW(), hold_until = NOW() + make_interval(hours => hold_hours)
+		WHERE id = $2
+	`, pickupItemID, id)
+	if err != nil {
+		return fmt.Errorf("ошибка приёмки брони (ID=%d): %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка приёмки брони (ID=%d): %w", id, err)
+	}
+	return nil
+}
+
+// Cancel отменяет бронь, которая ещё держит товар; возвращает товар, единицы которого освободились.
+// Бронь в пути отменить нельзя — сначала товар нужно принять в магазине выдачи.
+func (r *Repository) Cancel(ctx context.Context, id int) (int, error) {
+	var itemID int
+	err := r.db.Conn.QueryRow(ctx, `
+		UPDATE reservations SET status = 'cancelled', closed_at = NOW()
+		WHERE id = $1 AND status IN ('held', 'ready')
+		RETURNING COALESCE(pickup_item_id, item_id)
+	`, id).Scan(&itemID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInvalidState
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отмены брони (ID=%d): %w", id, err)
+	}
+	return itemID, nil
+}
+
+// Expire снимает просроченные брони и возвращает товары, единицы которых освободились.
+// Брони в пути не истекают: срок отсчитывается заново после приёмки.
+func (r *Repository) Expire(ctx context.Context, now time.Time) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		UPDATE reservations SET status = 'expired', closed_at = $1
+		WHERE status IN ('held', 'ready') AND hold_until < $1
+		RETURNING COALESCE(pickup_item_id, item_id)
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка снятия просроченных броней: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[int]bool)
+	var items []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения брони: %w", err)
+		}
+		if !seen[id] {
+			seen[id] = true
+			items = append(items, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке броней: %w", err)
+	}
+	return items, nil
+}
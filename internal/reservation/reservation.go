@@ -0,0 +1,107 @@
+package reservation
+
+import (
+	"crm-backend/internal/payment"
+	"crm-backend/internal/sale"
+	"errors"
+	"time"
+)
+
+// Статусы брони. held — товар отложен в магазине-источнике (ждёт выдачи или отправки),
+// in_transit — едет в магазин выдачи, ready — ждёт покупателя в магазине выдачи.
+const (
+	StatusHeld      = "held"
+	StatusInTransit = "in_transit"
+	StatusReady     = "ready"
+	StatusCollected = "collected"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	defaultHoldHours = 24
+	maxHoldHours     = 24 * 14
+)
+
+var (
+	ErrAccessDenied        = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrReservationNotFound = errors.New("бронь не найдена")
+	ErrItemNotFound        = errors.New("товар не найден")
+	ErrShopNotFound        = errors.New("магазин выдачи не найден")
+	ErrNotEnoughStock      = errors.New("недостаточно свободного товара")
+	ErrInvalidState        = errors.New("действие недоступно в текущем статусе брони")
+)
+
+// Reservation — товар отложен для покупателя. Если магазин выдачи другой,
+// товар перемещается туда: отправка списывает его в источнике, приёмка ставит на остаток в магазине выдачи.
+type Reservation struct {
+	ID           int    `json:"id"`
+	OwnerID      int    `json:"owner_id"`
+	ItemID       int    `json:"item_id"`
+	ItemName     string `json:"item_name,omitempty"`
+	Size         string `json:"size,omitempty"`
+	SourceShopID int    `json:"source_shop_id"`
+	PickupShopID int    `json:"pickup_shop_id"`
+	// PickupItemID — тот же товар в магазине выдачи; появляется после приёмки перемещения
+	PickupItemID *int       `json:"pickup_item_id,omitempty"`
+	CustomerID   int        `json:"customer_id"`
+	Quantity     int        `json:"quantity"`
+	HoldHours    int        `json:"hold_hours"`
+	Notes        string     `json:"notes,omitempty"`
+	Status       string     `json:"status"`
+	HoldUntil    *time.Time `json:"hold_until,omitempty"`
+	SaleID       *int       `json:"sale_id,omitempty"`
+	CreatedBy    int        `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ShippedAt    *time.Time `json:"shipped_at,omitempty"`
+	ReceivedAt   *time.Time `json:"received_at,omitempty"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+// Transfer — товар нужно перемещать в другой магазин
+func (r *Reservation) Transfer() bool {
+	return r.PickupShopID != r.SourceShopID
+}
+
+func (r *Reservation) validate() error {
+	if r.ItemID == 0 {
+		return errors.New("укажите товар")
+	}
+	if r.CustomerID == 0 {
+		return errors.New("укажите покупателя")
+	}
+	if r.Quantity <= 0 {
+		r.Quantity = 1
+	}
+	if r.HoldHours == 0 {
+		r.HoldHours = defaultHoldHours
+	}
+	if r.HoldHours < 0 || r.HoldHours > maxHoldHours {
+		return errors.New("срок брони должен быть от 1 до 336 часов")
+	}
+	return nil
+}
+
+// Availability — остаток товара в одном из магазинов организации
+type Availability struct {
+	ShopID   int    `json:"shop_id"`
+	ShopName string `json:"shop_name"`
+	ItemID   int    `json:"item_id"`
+	Name     string `json:"name"`
+	Brand    string `json:"brand"`
+	Size     string `json:"size"`
+	Stock    int    `json:"stock"`
+	// Held — отложено по листу ожидания и броням
+	Held      int `json:"held"`
+	Available int `json:"available"`
+}
+
+type CollectRequest struct {
+	Payments []payment.Tender `json:"payments"`
+}
+
+// CollectResult — бронь выдана, по ней пробит чек
+type CollectResult struct {
+	Reservation *Reservation `json:"reservation"`
+	Sale        *sale.Sale   `json:"sale"`
+}
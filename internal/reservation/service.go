@@ -0,0 +1,227 @@
+package reservation
+
+import (
+	"context"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/payment"
+	"crm-backend/internal/sale"
+	"crm-backend/internal/shop"
+	"crm-backend/internal/waitlist"
+	"errors"
+	"log"
+	"time"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	items     *shop.Repository
+	customers *customer.Repository
+	sales     *sale.Service
+	waitlist  *waitlist.Service
+}
+
+func NewService(repo *Repository, employees *employee.Repository, items *shop.Repository, customers *customer.Repository,
+	sales *sale.Service, waitlistService *waitlist.Service) *Service {
+	return &Service{
+		repo:      repo,
+		employees: employees,
+		items:     items,
+		customers: customers,
+		sales:     sales,
+		waitlist:  waitlistService,
+	}
+}
+
+// organisation — владелец магазина, если у пользователя есть к нему доступ
+func (s *Service) organisation(ctx context.Context, userID, shopID int) (int, error) {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrAccessDenied
+	}
+	return s.employees.GetShopOwnerID(ctx, shopID)
+}
+
+// Availability — где в сети есть товар. Сотрудник любого магазина видит остатки всех магазинов организации.
+func (s *Service) Availability(ctx context.Context, userID, shopID, itemID int, q, size string) ([]Availability, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if itemID == 0 && q == "" {
+		return nil, errors.New("укажите товар или строку поиска")
+	}
+	return s.repo.GetAvailability(ctx, ownerID, itemID, q, size)
+}
+
+// Create бронирует товар любого магазина организации. Если магазин выдачи другой,
+// бронь ждёт отправки; иначе товар сразу ждёт покупателя.
+func (s *Service) Create(ctx context.Context, userID, shopID int, rv *Reservation) error {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return err
+	}
+	if err := rv.validate(); err != nil {
+		return err
+	}
+
+	item, err := s.items.GetItemByID(ctx, rv.ItemID)
+	if err != nil {
+		return ErrItemNotFound
+	}
+	itemOwner, err := s.employees.GetShopOwnerID(ctx, item.ShopID)
+	if err != nil || itemOwner != ownerID {
+		return ErrItemNotFound
+	}
+	if rv.PickupShopID == 0 {
+		rv.PickupShopID = item.ShopID
+	}
+	pickupOwner, err := s.employees.GetShopOwnerID(ctx, rv.PickupShopID)
+	if err != nil || pickupOwner != ownerID {
+		return ErrShopNotFound
+	}
+	ok, err := s.customers.BelongsTo(ctx, rv.CustomerID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return customer.ErrCustomerNotFound
+	}
+
+	rv.OwnerID = ownerID
+	rv.SourceShopID = item.ShopID
+	rv.CreatedBy = userID
+	if err := s.repo.Create(ctx, rv); err != nil {
+		return err
+	}
+	rv.ItemName = item.Name
+	rv.Size = item.Size
+	return nil
+}
+
+func (s *Service) GetReservations(ctx context.Context, userID, shopID int, status string) ([]Reservation, error) {
+	if _, err := s.organisation(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetReservations(ctx, shopID, status)
+}
+
+func (s *Service) get(ctx context.Context, userID, shopID, id int) (*Reservation, error) {
+	if _, err := s.organisation(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetReservation(ctx, shopID, id)
+}
+
+// Ship — магазин-источник отправил товар в магазин выдачи
+func (s *Service) Ship(ctx context.Context, userID, shopID, id int) (*Reservation, error) {
+	rv, err := s.get(ctx, userID, shopID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rv.SourceShopID != shopID {
+		return nil, errors.New("отправить товар может только магазин, где он забронирован")
+	}
+	if err := s.repo.Ship(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetReservation(ctx, shopID, id)
+}
+
+// Receive — магазин выдачи принял перемещённый товар; срок брони отсчитывается заново
+func (s *Service) Receive(ctx context.Context, userID, shopID, id int) (*Reservation, error) {
+	rv, err := s.get(ctx, userID, shopID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rv.PickupShopID != shopID {
+		return nil, errors.New("принять товар может только магазин выдачи")
+	}
+	if err := s.repo.Receive(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetReservation(ctx, shopID, id)
+}
+
+// Collect выдаёт бронь покупателю: пробивает чек на забронированный товар по текущим ценам магазина выдачи
+func (s *Service) Collect(ctx context.Context, userID, shopID, id int, payments []payment.Tender) (*CollectResult, error) {
+	rv, err := s.get(ctx, userID, shopID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rv.PickupShopID != shopID {
+		return nil, errors.New("выдать бронь может только магазин выдачи")
+	}
+	if rv.Status != StatusReady || rv.PickupItemID == nil {
+		return nil, ErrInvalidState
+	}
+
+	customerID := rv.CustomerID
+	sl, err := s.sales.CreateSale(ctx, userID, shopID, sale.CreateSaleRequest{
+		CustomerID: &customerID,
+		Items:      []sale.SaleLineRequest{{ItemID: *rv.PickupItemID, Quantity: rv.Quantity}},
+		Payments:   payments,
+		// Бронь закрывается в транзакции чека: резерв снимается там же, где списывается остаток
+		ReservationID: id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rv, err = s.repo.GetReservation(ctx, shopID, id); err != nil {
+		return nil, err
+	}
+	return &CollectResult{Reservation: rv, Sale: sl}, nil
+}
+
+// Cancel — покупатель отказался; освободившийся товар предлагается листу ожидания
+func (s *Service) Cancel(ctx context.Context, userID, shopID, id int) (*Reservation, error) {
+	if _, err := s.get(ctx, userID, shopID, id); err != nil {
+		return nil, err
+	}
+	itemID, err := s.repo.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.release(ctx, itemID)
+	return s.repo.GetReservation(ctx, shopID, id)
+}
+
+// release отдаёт освободившиеся единицы очереди листа ожидания; ошибка не отменяет снятие брони
+func (s *Service) release(ctx context.Context, itemID int) {
+	if err := s.waitlist.Reallocate(ctx, itemID); err != nil {
+		log.Printf("ошибка резерва товара ID=%d по листу ожидания: %v", itemID, err)
+	}
+}
+
+// ExpireReservations снимает просроченные брони
+func (s *Service) ExpireReservations(ctx context.Context) error {
+	itemIDs, err := s.repo.Expire(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, itemID := range itemIDs {
+		s.release(ctx, itemID)
+	}
+	return nil
+}
+
+// RunExpiry — фоновое снятие просроченных броней
+func (s *Service) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ExpireReservations(ctx); err != nil {
+				log.Printf("ошибка снятия просроченных броней: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package sale
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/period"
+	"crm-backend/internal/shift"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrSaleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, shift.ErrNoOpenShift), errors.Is(err, shift.ErrShiftClosed), errors.Is(err, ErrReturnConflict),
+		errors.Is(err, ErrOutOfStock), errors.Is(err, ErrHoldClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// CreateSale godoc
+// @Summary Create sale
+// @Description Пробивает чек в магазине. Оплата может быть раздельной: наличные, карта, Kaspi QR, подарочная карта. В gift_cards передаются подарочные карты на продажу: они пробиваются по номиналу и выпускаются вместе с чеком, оплатить их подарочной картой нельзя. Продаётся только свободный остаток: отложенные по броням и листу ожидания единицы не продаются, а товар, отложенный для покупателя, пробивается с waitlist_entry_ids.
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param sale body CreateSaleRequest true "Позиции и оплата"
+// @Success 201 {object} Sale
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 409 {string} string "смена не открыта или уже закрыта, недостаточно свободного остатка товара"
+// @Router /shops/{id}/sales [post]
+func (h *Handler) CreateSale(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateSaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	sale, err := h.service.CreateSale(r.Context(), claims.ID, shopID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(sale)
+}
+
+// GetSales godoc
+// @Summary Get shop sales
+// @Description Список чеков магазина за период (по умолчанию — последние 30 дней).
+// @Tags sales
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param from query string false "Дата начала (YYYY-MM-DD)"
+// @Param to query string false "Дата окончания (YYYY-MM-DD)"
+// @Success 200 {array} Sale
+// @Failure 400 {string} string "неправильный период"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/sales [get]
+func (h *Handler) GetSales(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	from, to, err := period.FromRequest(r)
+	if err != nil {
+		http.Error(w, "неправильный период", http.StatusBadRequest)
+		return
+	}
+
+	sales, err := h.service.GetSalesByShop(r.Context(), claims.ID, shopID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sales)
+}
+
+// GetSale godoc
+// @Summary Get sale
+// @Description Чек с позициями и оплатами.
+// @Tags sales
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param sale_id path int true "Sale ID"
+// @Success 200 {object} Sale
+// @Failure 400 {string} string "неправильный ID чека"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "чек не найден"
+// @Router /shops/{id}/sales/{sale_id} [get]
+func (h *Handler) GetSale(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	saleID, err := strconv.Atoi(chi.URLParam(r, "sale_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID чека", http.StatusBadRequest)
+		return
+	}
+
+	sale, err := h.service.GetSale(r.Context(), claims.ID, shopID, saleID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sale)
+}
+
+// ReturnSale godoc
+// @Summary Return sale items
+// @Description Возврат позиций чека. Деньги возвращаются на исходные способы оплаты.
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param sale_id path int true "Sale ID"
+// @Param data body CreateReturnRequest true "Позиции возврата"
+// @Success 201 {object} Return
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "чек не найден"
+// @Failure 409 {string} string "смена не открыта или уже закрыта"
+// @Router /shops/{id}/sales/{sale_id}/returns [post]
+func (h *Handler) ReturnSale(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	saleID, err := strconv.Atoi(chi.URLParam(r, "sale_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID чека", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.service.ReturnSale(r.Context(), claims.ID, shopID, saleID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ret)
+}
@@ -0,0 +1,653 @@
+package sale
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"crm-backend/internal/giftcard"
+	"crm-backend/internal/payment"
+	"crm-backend/internal/promo"
+	"crm-backend/internal/shift"
+	"crm-backend/internal/waitlist"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS sales (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			cashier_id INT REFERENCES users(id) ON DELETE SET NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'completed',
+			total NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			change NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			refunded NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS sale_items (
+			id SERIAL PRIMARY KEY,
+			sale_id INT NOT NULL REFERENCES sales(id) ON DELETE CASCADE,
+			item_id INT REFERENCES items(id) ON DELETE SET NULL,
+			name VARCHAR(255) NOT NULL,
+			size VARCHAR(50),
+			quantity INT NOT NULL,
+			unit_price NUMERIC(10, 2) NOT NULL,
+			total NUMERIC(10, 2) NOT NULL,
+			returned_quantity INT NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE IF NOT EXISTS payments (
+			id SERIAL PRIMARY KEY,
+			sale_id INT NOT NULL REFERENCES sales(id) ON DELETE CASCADE,
+			method VARCHAR(20) NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL,
+			tendered NUMERIC(10, 2) NOT NULL,
+			change NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			reference VARCHAR(100),
+			provider_ref VARCHAR(100),
+			refunded NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS sale_returns (
+			id SERIAL PRIMARY KEY,
+			sale_id INT NOT NULL REFERENCES sales(id) ON DELETE CASCADE,
+			amount NUMERIC(10, 2) NOT NULL,
+			reason TEXT,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS sale_return_items (
+			id SERIAL PRIMARY KEY,
+			return_id INT NOT NULL REFERENCES sale_returns(id) ON DELETE CASCADE,
+			sale_item_id INT NOT NULL REFERENCES sale_items(id) ON DELETE CASCADE,
+			quantity INT NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS refunds (
+			id SERIAL PRIMARY KEY,
+			payment_id INT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+			return_id INT NOT NULL REFERENCES sale_returns(id) ON DELETE CASCADE,
+			method VARCHAR(20) NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL,
+			provider_ref VARCHAR(100),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS shift_id INT REFERENCES shifts(id) ON DELETE SET NULL;
+		ALTER TABLE sale_returns ADD COLUMN IF NOT EXISTS shift_id INT REFERENCES shifts(id) ON DELETE SET NULL;
+
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS fiscal_status VARCHAR(20);
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS fiscal_sign VARCHAR(100);
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS fiscal_qr_url TEXT;
+		ALTER TABLE sale_returns ADD COLUMN IF NOT EXISTS fiscal_status VARCHAR(20);
+		ALTER TABLE sale_returns ADD COLUMN IF NOT EXISTS fiscal_sign VARCHAR(100);
+		ALTER TABLE sale_returns ADD COLUMN IF NOT EXISTS fiscal_qr_url TEXT;
+
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS subtotal NUMERIC(10, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS discount NUMERIC(10, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS discount NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS sale_promotions (
+			id SERIAL PRIMARY KEY,
+			sale_id INT NOT NULL REFERENCES sales(id) ON DELETE CASCADE,
+			promotion_id INT REFERENCES promotions(id) ON DELETE SET NULL,
+			name VARCHAR(255) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			discount NUMERIC(10, 2) NOT NULL
+		);
+
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers(id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS sales_customer ON sales (customer_id);
+
+		ALTER TABLE sales ADD COLUMN IF NOT EXISTS seller_id INT REFERENCES users(id) ON DELETE SET NULL;
+
+		ALTER TABLE sale_returns ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'completed';
+
+		ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS gift_card_id INT REFERENCES gift_cards(id) ON DELETE SET NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции sales: %w", err)
+	}
+	fmt.Println("Миграция sales выполнена успешно")
+	return nil
+}
+
+// CreateSale сохраняет чек вместе с позициями и платежами в одной транзакции
+func (r *Repository) CreateSale(ctx context.Context, s *Sale) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Пока чек сохраняется, смену нельзя закрыть: иначе продажа не попадёт ни в один Z-отчёт
+	if err := shift.LockOpen(ctx, tx, s.ShiftID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO sales (shop_id, cashier_id, seller_id, shift_id, customer_id, status, subtotal, discount, total, change)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, s.ShopID, s.CashierID, s.SellerID, s.ShiftID, s.CustomerID, s.Status, s.Subtotal, s.Discount, s.Total, s.Change).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания чека: %w", err)
+	}
+
+	for i := range s.Items {
+		it := &s.Items[i]
+		it.SaleID = s.ID
+		if it.card != nil {
+			if err := giftcard.InsertCard(ctx, tx, it.card, s.ShopID); err != nil {
+				return err
+			}
+			it.GiftCardID = &it.card.ID
+		}
+		err := tx.QueryRow(ctx, `
+			INSERT INTO sale_items (sale_id, item_id, name, size, quantity, unit_price, discount, total, gift_card_id)
+			VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, it.SaleID, it.ItemID, it.Name, it.Size, it.Quantity, it.UnitPrice, it.Discount, it.Total, it.GiftCardID).Scan(&it.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка добавления позиции чека: %w", err)
+		}
+	}
+
+	if err := takeStock(ctx, tx, s); err != nil {
+		return err
+	}
+
+	for _, ap := range s.Promotions {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO sale_promotions (sale_id, promotion_id, name, type, discount)
+			VALUES ($1, $2, $3, $4, $5)
+		`, s.ID, ap.PromotionID, ap.Name, ap.Type, ap.Discount)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения акций чека: %w", err)
+		}
+	}
+
+	for i := range s.Payments {
+		p := &s.Payments[i]
+		p.SaleID = s.ID
+		err := tx.QueryRow(ctx, `
+			INSERT INTO payments (sale_id, method, amount, tendered, change, reference, provider_ref)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, created_at
+		`, p.SaleID, p.Method, p.Amount, p.Tendered, p.Change, p.Reference, p.ProviderRef).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения оплаты: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения чека: %w", err)
+	}
+	return nil
+}
+
+// takeStock списывает проданные единицы с остатка. Строки товаров блокируются (в порядке ID,
+// чтобы параллельные чеки не взаимоблокировались), затем закрываются выкупаемые записи листа
+// ожидания и выдаваемая бронь, и продать можно только то, что не отложено по броням и листу ожидания.
+// Снятие резерва и списание идут в одной транзакции, поэтому единицы не считаются дважды.
+func takeStock(ctx context.Context, tx pgx.Tx, s *Sale) error {
+	need := make(map[int]int)
+	names := make(map[int]string)
+	for _, it := range s.Items {
+		if it.card == nil {
+			need[it.ItemID] += it.Quantity
+			names[it.ItemID] = it.Name
+		}
+	}
+	ids := make([]int, 0, len(need))
+	for id := range need {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	stock := make(map[int]int, len(ids))
+	for _, id := range ids {
+		var n int
+		if err := tx.QueryRow(ctx, `SELECT stock FROM items WHERE id = $1 FOR UPDATE`, id).Scan(&n); err != nil {
+			return fmt.Errorf("ошибка получения остатка товара (ID=%d): %w", id, err)
+		}
+		stock[id] = n
+	}
+
+	if len(s.waitlistEntries) > 0 {
+		tag, err := tx.Exec(ctx, `
+			UPDATE waitlist_entries SET status = 'fulfilled', closed_at = NOW()
+			WHERE id = ANY($1) AND shop_id = $2 AND item_id = ANY($3) AND status = 'held'
+		`, s.waitlistEntries, s.ShopID, ids)
+		if err != nil {
+			return fmt.Errorf("ошибка закрытия записей листа ожидания: %w", err)
+		}
+		if int(tag.RowsAffected()) != len(s.waitlistEntries) {
+			return ErrHoldClosed
+		}
+	}
+
+	if s.reservationID != 0 {
+		tag, err := tx.Exec(ctx, `
+			UPDATE reservations SET status = 'collected', sale_id = $1, closed_at = NOW()
+			WHERE id = $2 AND pickup_shop_id = $3 AND pickup_item_id = ANY($4) AND status = 'ready'
+		`, s.ID, s.reservationID, s.ShopID, ids)
+		if err != nil {
+			return fmt.Errorf("ошибка закрытия брони (ID=%d): %w", s.reservationID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrHoldClosed
+		}
+	}
+
+	for _, id := range ids {
+		var held int
+		if err := tx.QueryRow(ctx, waitlist.HeldQuery, id).Scan(&held); err != nil {
+			return fmt.Errorf("ошибка подсчёта отложенного товара: %w", err)
+		}
+		if free := stock[id] - held; free < need[id] {
+			return fmt.Errorf("%w: «%s» — свободно %d шт., в чеке %d", ErrOutOfStock, names[id], max(free, 0), need[id])
+		}
+		if _, err := tx.Exec(ctx, `UPDATE items SET stock = stock - $1 WHERE id = $2`, need[id], id); err != nil {
+			return fmt.Errorf("ошибка списания остатка товара: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) GetSaleByID(ctx context.Context, saleID int) (*Sale, error) {
+	var s Sale
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT id, shop_id, COALESCE(cashier_id, 0), COALESCE(seller_id, cashier_id, 0), COALESCE(shift_id, 0), customer_id, status, subtotal, discount, total, change, refunded, created_at,
+		       COALESCE(fiscal_status, ''), COALESCE(fiscal_sign, ''), COALESCE(fiscal_qr_url, '')
+		FROM sales
+		WHERE id = $1
+	`, saleID).Scan(&s.ID, &s.ShopID, &s.CashierID, &s.SellerID, &s.ShiftID, &s.CustomerID, &s.Status, &s.Subtotal, &s.Discount, &s.Total, &s.Change, &s.Refunded, &s.CreatedAt,
+		&s.FiscalStatus, &s.FiscalSign, &s.FiscalQRURL)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения чека по ID=%d: %w", saleID, err)
+	}
+
+	s.Items, err = r.GetSaleItems(ctx, saleID)
+	if err != nil {
+		return nil, err
+	}
+	s.Payments, err = r.GetPayments(ctx, saleID)
+	if err != nil {
+		return nil, err
+	}
+	s.Promotions, err = r.GetSalePromotions(ctx, saleID)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *Repository) GetSalePromotions(ctx context.Context, saleID int) ([]promo.AppliedRule, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT COALESCE(promotion_id, 0), name, type, discount
+		FROM sale_promotions
+		WHERE sale_id = $1
+		ORDER BY id
+	`, saleID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения акций чека: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []promo.AppliedRule
+	for rows.Next() {
+		var ap promo.AppliedRule
+		if err := rows.Scan(&ap.PromotionID, &ap.Name, &ap.Type, &ap.Discount); err != nil {
+			return nil, fmt.Errorf("ошибка чтения акции чека: %w", err)
+		}
+		applied = append(applied, ap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке акций чека: %w", err)
+	}
+	return applied, nil
+}
+
+func (r *Repository) GetSaleItems(ctx context.Context, saleID int) ([]SaleItem, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, sale_id, COALESCE(item_id, 0), name, COALESCE(size, ''), quantity, unit_price, discount, total, returned_quantity, gift_card_id
+		FROM sale_items
+		WHERE sale_id = $1
+		ORDER BY id
+	`, saleID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения позиций чека: %w", err)
+	}
+	defer rows.Close()
+
+	var items []SaleItem
+	for rows.Next() {
+		var it SaleItem
+		if err := rows.Scan(&it.ID, &it.SaleID, &it.ItemID, &it.Name, &it.Size, &it.Quantity,
+			&it.UnitPrice, &it.Discount, &it.Total, &it.ReturnedQuantity, &it.GiftCardID); err != nil {
+			return nil, fmt.Errorf("ошибка чтения позиции чека: %w", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке позиций чека: %w", err)
+	}
+	return items, nil
+}
+
+func (r *Repository) GetPayments(ctx context.Context, saleID int) ([]payment.Payment, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, sale_id, method, amount, tendered, change, COALESCE(reference, ''),
+		       COALESCE(provider_ref, ''), refunded, created_at
+		FROM payments
+		WHERE sale_id = $1
+		ORDER BY id
+	`, saleID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения оплат чека: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []payment.Payment
+	for rows.Next() {
+		var p payment.Payment
+		if err := rows.Scan(&p.ID, &p.SaleID, &p.Method, &p.Amount, &p.Tendered, &p.Change,
+			&p.Reference, &p.ProviderRef, &p.Refunded, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения оплаты: %w", err)
+		}
+		payments = append(payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке оплат: %w", err)
+	}
+	return payments, nil
+}
+
+// GetSalesByShop — чеки магазина за период (без позиций и оплат)
+func (r *Repository) GetSalesByShop(ctx context.Context, shopID int, from, to time.Time) ([]Sale, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, shop_id, COALESCE(cashier_id, 0), COALESCE(seller_id, cashier_id, 0), COALESCE(shift_id, 0), customer_id, status, subtotal, discount, total, change, refunded, created_at,
+		       COALESCE(fiscal_status, ''), COALESCE(fiscal_sign, ''), COALESCE(fiscal_qr_url, '')
+		FROM sales
+		WHERE shop_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at DESC
+	`, shopID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения чеков: %w", err)
+	}
+	defer rows.Close()
+
+	var sales []Sale
+	for rows.Next() {
+		var s Sale
+		if err := rows.Scan(&s.ID, &s.ShopID, &s.CashierID, &s.SellerID, &s.ShiftID, &s.CustomerID, &s.Status, &s.Subtotal, &s.Discount, &s.Total, &s.Change,
+			&s.Refunded, &s.CreatedAt, &s.FiscalStatus, &s.FiscalSign, &s.FiscalQRURL); err != nil {
+			return nil, fmt.Errorf("ошибка чтения чека: %w", err)
+		}
+		sales = append(sales, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке чеков: %w", err)
+	}
+	return sales, nil
+}
+
+// GetSaleIDsByCustomer — чеки покупателя, новые сверху
+func (r *Repository) GetSaleIDsByCustomer(ctx context.Context, customerID int) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `SELECT id FROM sales WHERE customer_id = $1 ORDER BY created_at DESC`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения чеков покупателя: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения чека покупателя: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке чеков покупателя: %w", err)
+	}
+	return ids, nil
+}
+
+// saleStatusSQL пересчитывает статус чека по возвращённым количествам позиций
+const saleStatusSQL = `CASE
+	WHEN NOT EXISTS (SELECT 1 FROM sale_items WHERE sale_id = $1 AND returned_quantity > 0) THEN 'completed'
+	WHEN EXISTS (SELECT 1 FROM sale_items WHERE sale_id = $1 AND returned_quantity < quantity) THEN 'partially_returned'
+	ELSE 'returned'
+END`
+
+// CreatePendingReturn закрепляет возврат до обращения к провайдерам: в одной транзакции блокирует чек,
+// отмечает возвращённые позиции и суммы на исходных платежах. Если позиции или деньги уже забрал
+// параллельный возврат, ничего не сохраняет и возвращает ErrReturnConflict.
+func (r *Repository) CreatePendingReturn(ctx context.Context, ret *Return) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM sales WHERE id = $1 FOR UPDATE`, ret.SaleID); err != nil {
+		return fmt.Errorf("ошибка блокировки чека: %w", err)
+	}
+	if err := shift.LockOpen(ctx, tx, ret.ShiftID); err != nil {
+		return err
+	}
+
+	ret.Status = ReturnPending
+	err = tx.QueryRow(ctx, `
+		INSERT INTO sale_returns (sale_id, shift_id, amount, reason, created_by, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, ret.SaleID, ret.ShiftID, ret.Amount, ret.Reason, ret.CreatedBy, ret.Status).Scan(&ret.ID, &ret.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания возврата: %w", err)
+	}
+
+	for i := range ret.Items {
+		it := &ret.Items[i]
+		it.ReturnID = ret.ID
+		err := tx.QueryRow(ctx, `
+			INSERT INTO sale_return_items (return_id, sale_item_id, quantity, amount)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, it.ReturnID, it.SaleItemID, it.Quantity, it.Amount).Scan(&it.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка добавления позиции возврата: %w", err)
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE sale_items SET returned_quantity = returned_quantity + $1
+			WHERE id = $2 AND sale_id = $3 AND returned_quantity + $1 <= quantity
+		`, it.Quantity, it.SaleItemID, ret.SaleID)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления позиции чека: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrReturnConflict
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE items SET stock = stock + $1 WHERE id = (SELECT item_id FROM sale_items WHERE id = $2)
+		`, it.Quantity, it.SaleItemID)
+		if err != nil {
+			return fmt.Errorf("ошибка возврата товара на остаток: %w", err)
+		}
+	}
+
+	for i := range ret.Refunds {
+		rf := &ret.Refunds[i]
+		rf.ReturnID = ret.ID
+		err := tx.QueryRow(ctx, `
+			INSERT INTO refunds (payment_id, return_id, method, amount)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at
+		`, rf.PaymentID, rf.ReturnID, rf.Method, rf.Amount).Scan(&rf.ID, &rf.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения возврата оплаты: %w", err)
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE payments SET refunded = refunded + $1
+			WHERE id = $2 AND sale_id = $3 AND refunded + $1 <= amount
+		`, rf.Amount, rf.PaymentID, ret.SaleID)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления оплаты: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrReturnConflict
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sales SET refunded = refunded + $2, status = `+saleStatusSQL+` WHERE id = $1
+	`, ret.SaleID, ret.Amount)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления чека: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка сохранения возврата: %w", err)
+	}
+	return nil
+}
+
+// CompleteReturn сохраняет ссылки провайдеров на возвраты оплат и завершает возврат
+func (r *Repository) CompleteReturn(ctx context.Context, ret *Return) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := saveRefundRefs(ctx, tx, ret.Refunds); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE sale_returns SET status = $2 WHERE id = $1`, ret.ID, ReturnCompleted); err != nil {
+		return fmt.Errorf("ошибка завершения возврата: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка завершения возврата: %w", err)
+	}
+	ret.Status = ReturnCompleted
+	return nil
+}
+
+// failurePlan — что делать с возвратом, на котором провайдер отказал
+type failurePlan struct {
+	status string
+	// refunded — сколько денег провайдеры фактически вернули клиенту
+	refunded float64
+	// dropRefunds — возвраты оплат, которые так и не были проведены: снимаются с платежей
+	dropRefunds []payment.Refund
+	// undoItems — позиции, которые снова считаются проданными
+	undoItems []ReturnItem
+}
+
+// planFailure разбирает отказ провайдера после done проведённых возвратов оплат. Если деньги ещё
+// не уходили, возврат снимается целиком. Если часть уже вернулась, отменять позиции нельзя: клиент
+// получил деньги, а товар мог быть снова продан. Позиции остаются возвращёнными, непроведённые
+// возвраты оплат снимаются, и возврат ждёт ручного решения.
+func planFailure(ret *Return, done int) failurePlan {
+	plan := failurePlan{status: ReturnFailed, dropRefunds: ret.Refunds[done:]}
+	for _, rf := range ret.Refunds[:done] {
+		plan.refunded += rf.Amount
+	}
+	plan.refunded = payment.Round(plan.refunded)
+	if done == 0 {
+		plan.undoItems = ret.Items
+	} else {
+		plan.status = ReturnNeedsReview
+	}
+	return plan
+}
+
+// FailReturn фиксирует отказ провайдера на возврате (см. planFailure): проведённые возвраты оплат
+// остаются в учёте, непроведённые снимаются с платежей, сумма возврата уменьшается до фактически возвращённой.
+func (r *Repository) FailReturn(ctx context.Context, ret *Return, done int) error {
+	plan := planFailure(ret, done)
+
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM sales WHERE id = $1 FOR UPDATE`, ret.SaleID); err != nil {
+		return fmt.Errorf("ошибка блокировки чека: %w", err)
+	}
+	if err := saveRefundRefs(ctx, tx, ret.Refunds[:done]); err != nil {
+		return err
+	}
+
+	for _, rf := range plan.dropRefunds {
+		if _, err := tx.Exec(ctx, `DELETE FROM refunds WHERE id = $1`, rf.ID); err != nil {
+			return fmt.Errorf("ошибка отмены возврата оплаты: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE payments SET refunded = refunded - $1 WHERE id = $2`, rf.Amount, rf.PaymentID); err != nil {
+			return fmt.Errorf("ошибка обновления оплаты: %w", err)
+		}
+	}
+
+	for _, it := range plan.undoItems {
+		_, err := tx.Exec(ctx, `
+			UPDATE sale_items SET returned_quantity = returned_quantity - $1 WHERE id = $2
+		`, it.Quantity, it.SaleItemID)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления позиции чека: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE items SET stock = stock - $1 WHERE id = (SELECT item_id FROM sale_items WHERE id = $2)
+		`, it.Quantity, it.SaleItemID)
+		if err != nil {
+			return fmt.Errorf("ошибка списания остатка товара: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sale_returns SET status = $2, amount = $3 WHERE id = $1
+	`, ret.ID, plan.status, plan.refunded)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены возврата: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE sales SET refunded = refunded - $2, status = `+saleStatusSQL+` WHERE id = $1
+	`, ret.SaleID, payment.Round(ret.Amount-plan.refunded))
+	if err != nil {
+		return fmt.Errorf("ошибка обновления чека: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка отмены возврата: %w", err)
+	}
+	ret.Status = plan.status
+	ret.Amount = plan.refunded
+	ret.Refunds = ret.Refunds[:done]
+	return nil
+}
+
+func saveRefundRefs(ctx context.Context, tx pgx.Tx, refunds []payment.Refund) error {
+	for _, rf := range refunds {
+		if _, err := tx.Exec(ctx, `UPDATE refunds SET provider_ref = NULLIF($2, '') WHERE id = $1`, rf.ID, rf.ProviderRef); err != nil {
+			return fmt.Errorf("ошибка сохранения возврата оплаты: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package sale
+
+import (
+	"crm-backend/internal/payment"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPlanFailure(t *testing.T) {
+	// Возврат на 1000 по чеку, оплаченному картой, наличными и баллами
+	ret := &Return{
+		Amount: 1000,
+		Items: []ReturnItem{
+			{SaleItemID: 1, Quantity: 1, Amount: 700},
+			{SaleItemID: 2, Quantity: 2, Amount: 300},
+		},
+		Refunds: []payment.Refund{
+			{ID: 11, PaymentID: 1, Method: payment.MethodCard, Amount: 600},
+			{ID: 12, PaymentID: 2, Method: payment.MethodCash, Amount: 300.5},
+			{ID: 13, PaymentID: 3, Method: payment.MethodLoyalty, Amount: 99.5},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		done        int
+		status      string
+		refunded    float64
+		dropRefunds []int
+		undoItems   []int
+	}{
+		{name: "отказ на первой оплате — возврат снимается целиком", done: 0, status: ReturnFailed,
+			refunded: 0, dropRefunds: []int{11, 12, 13}, undoItems: []int{1, 2}},
+		{name: "отказ на второй оплате — позиции остаются возвращёнными", done: 1, status: ReturnNeedsReview,
+			refunded: 600, dropRefunds: []int{12, 13}},
+		{name: "отказ на последней оплате", done: 2, status: ReturnNeedsReview,
+			refunded: 900.5, dropRefunds: []int{13}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planFailure(ret, tt.done)
+			if plan.status != tt.status {
+				t.Errorf("статус = %s, ожидался %s", plan.status, tt.status)
+			}
+			if math.Abs(plan.refunded-tt.refunded) > 1e-9 {
+				t.Errorf("возвращено = %v, ожидалось %v", plan.refunded, tt.refunded)
+			}
+			var drop, undo []int
+			for _, rf := range plan.dropRefunds {
+				drop = append(drop, rf.ID)
+			}
+			for _, it := range plan.undoItems {
+				undo = append(undo, it.SaleItemID)
+			}
+			if !reflect.DeepEqual(drop, tt.dropRefunds) {
+				t.Errorf("снимаются возвраты оплат %v, ожидались %v", drop, tt.dropRefunds)
+			}
+			if !reflect.DeepEqual(undo, tt.undoItems) {
+				t.Errorf("отменяются позиции %v, ожидались %v", undo, tt.undoItems)
+			}
+		})
+	}
+}
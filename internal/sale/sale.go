@@ -0,0 +1,131 @@
+package sale
+
+import (
+	"crm-backend/internal/giftcard"
+	"crm-backend/internal/payment"
+	"crm-backend/internal/promo"
+	"time"
+)
+
+const (
+	StatusCompleted         = "completed"
+	StatusPartiallyReturned = "partially_returned"
+	StatusReturned          = "returned"
+)
+
+// Статусы возврата. pending — позиции и суммы на платежах уже закреплены за возвратом,
+// а деньги ещё возвращаются через провайдеров; failed — провайдер отказал на первой же оплате,
+// возврат снят целиком; needs_review — провайдер отказал, когда часть денег уже ушла клиенту:
+// позиции остаются возвращёнными, сумма возврата — фактически возвращённая, остаток решается вручную.
+const (
+	ReturnPending     = "pending"
+	ReturnCompleted   = "completed"
+	ReturnFailed      = "failed"
+	ReturnNeedsReview = "needs_review"
+)
+
+// Sale — чек продажи
+type Sale struct {
+	ID        int `json:"id"`
+	ShopID    int `json:"shop_id"`
+	CashierID int `json:"cashier_id"`
+	// SellerID — продавец, которому засчитывается продажа (по умолчанию кассир)
+	SellerID   int    `json:"seller_id"`
+	ShiftID    int    `json:"shift_id"`
+	CustomerID *int   `json:"customer_id,omitempty"`
+	Status     string `json:"status"`
+	// Subtotal — сумма по ценам прайса, Discount — скидки по акциям, Total = Subtotal - Discount
+	Subtotal  float64   `json:"subtotal"`
+	Discount  float64   `json:"discount"`
+	Total     float64   `json:"total"`
+	Change    float64   `json:"change"`
+	Refunded  float64   `json:"refunded"`
+	CreatedAt time.Time `json:"created_at"`
+	// Фискальные реквизиты из ОФД; пока чек не фискализирован, статус pending
+	FiscalStatus string              `json:"fiscal_status,omitempty"`
+	FiscalSign   string              `json:"fiscal_sign,omitempty"`
+	FiscalQRURL  string              `json:"fiscal_qr_url,omitempty"`
+	Items        []SaleItem          `json:"items,omitempty"`
+	Payments     []payment.Payment   `json:"payments,omitempty"`
+	Promotions   []promo.AppliedRule `json:"promotions,omitempty"`
+	// GiftCards — подарочные карты, проданные этим чеком (только в ответе на продажу)
+	GiftCards []giftcard.Card `json:"gift_cards,omitempty"`
+
+	// waitlistEntries — отложенные по листу ожидания единицы, которые выкупаются этим чеком
+	waitlistEntries []int
+	// reservationID — бронь, которую закрывает этот чек
+	reservationID int
+}
+
+// SaleItem — позиция чека. Название и цена копируются из товара на момент продажи.
+// Позиция проданной подарочной карты не ссылается на товар, а ссылается на карту (GiftCardID).
+type SaleItem struct {
+	ID               int     `json:"id"`
+	SaleID           int     `json:"sale_id"`
+	ItemID           int     `json:"item_id"`
+	Name             string  `json:"name"`
+	Size             string  `json:"size"`
+	Quantity         int     `json:"quantity"`
+	UnitPrice        float64 `json:"unit_price"`
+	Discount         float64 `json:"discount"`
+	Total            float64 `json:"total"`
+	ReturnedQuantity int     `json:"returned_quantity"`
+	GiftCardID       *int    `json:"gift_card_id,omitempty"`
+
+	// card — карта, которую нужно выпустить вместе с чеком
+	card *giftcard.Card
+}
+
+// Return — возврат по чеку
+type Return struct {
+	ID           int              `json:"id"`
+	SaleID       int              `json:"sale_id"`
+	ShiftID      int              `json:"shift_id"`
+	Amount       float64          `json:"amount"`
+	Reason       string           `json:"reason"`
+	Status       string           `json:"status"`
+	CreatedBy    int              `json:"created_by"`
+	CreatedAt    time.Time        `json:"created_at"`
+	FiscalStatus string           `json:"fiscal_status,omitempty"`
+	FiscalSign   string           `json:"fiscal_sign,omitempty"`
+	FiscalQRURL  string           `json:"fiscal_qr_url,omitempty"`
+	Items        []ReturnItem     `json:"items"`
+	Refunds      []payment.Refund `json:"refunds"`
+}
+
+type ReturnItem struct {
+	ID         int     `json:"id"`
+	ReturnID   int     `json:"return_id"`
+	SaleItemID int     `json:"sale_item_id"`
+	Quantity   int     `json:"quantity"`
+	Amount     float64 `json:"amount"`
+}
+
+type SaleLineRequest struct {
+	ItemID   int `json:"item_id"`
+	Quantity int `json:"quantity"`
+}
+
+type CreateSaleRequest struct {
+	CustomerID *int              `json:"customer_id"`
+	SellerID   *int              `json:"seller_id"`
+	Items      []SaleLineRequest `json:"items"`
+	// GiftCards — подарочные карты на продажу: пробиваются по номиналу, без акций и баллов
+	GiftCards []giftcard.IssueRequest `json:"gift_cards"`
+	// WaitlistEntryIDs — записи листа ожидания, по которым покупатель забирает отложенный товар.
+	// Отложенные единицы снимаются с резерва и продаются этим же чеком, записи закрываются как выкупленные.
+	WaitlistEntryIDs []int            `json:"waitlist_entry_ids"`
+	Payments         []payment.Tender `json:"payments"`
+	// ReservationID — бронь, которую выдаёт этот чек; задаётся только при выдаче брони (reservation.Collect)
+	ReservationID int `json:"-"`
+}
+
+type ReturnLineRequest struct {
+	SaleItemID int `json:"sale_item_id"`
+	Quantity   int `json:"quantity"`
+}
+
+type CreateReturnRequest struct {
+	Items  []ReturnLineRequest `json:"items"`
+	Reason string              `json:"reason"`
+}
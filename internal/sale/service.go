@@ -0,0 +1,492 @@
+package sale
+
+import (
+	"context"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/fiscal"
+	"crm-backend/internal/giftcard"
+	"crm-backend/internal/loyalty"
+	"crm-backend/internal/payment"
+	"crm-backend/internal/promo"
+	"crm-backend/internal/shift"
+	"crm-backend/internal/shop"
+	"crm-backend/internal/waitlist"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrAccessDenied = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrSaleNotFound = errors.New("чек не найден")
+	// ErrReturnConflict — позиции или оплаты уже забрал параллельный возврат
+	ErrReturnConflict = errors.New("позиции или оплаты чека уже возвращены, обновите чек и повторите")
+	// ErrOutOfStock — свободного остатка не хватает: часть единиц может быть отложена по броням и листу ожидания
+	ErrOutOfStock = errors.New("недостаточно свободного остатка товара")
+	// ErrHoldClosed — запись листа ожидания или бронь уже выкуплена, снята или относится к другому товару
+	ErrHoldClosed = errors.New("отложенный товар уже выкуплен или снят с резерва")
+)
+
+type Service struct {
+	repo      *Repository
+	items     *shop.Repository
+	employees *employee.Repository
+	payments  *payment.Service
+	shifts    *shift.Service
+	fiscal    *fiscal.Service
+	promos    *promo.Service
+	customers *customer.Repository
+	loyalty   *loyalty.Service
+	waitlist  *waitlist.Service
+}
+
+func NewService(repo *Repository, items *shop.Repository, employees *employee.Repository, payments *payment.Service,
+	shifts *shift.Service, fiscalService *fiscal.Service, promos *promo.Service,
+	customers *customer.Repository, loyaltyService *loyalty.Service, waitlistService *waitlist.Service) *Service {
+	return &Service{
+		repo:      repo,
+		items:     items,
+		employees: employees,
+		payments:  payments,
+		shifts:    shifts,
+		fiscal:    fiscalService,
+		promos:    promos,
+		customers: customers,
+		loyalty:   loyaltyService,
+		waitlist:  waitlistService,
+	}
+}
+
+func (s *Service) checkAccess(ctx context.Context, shopID, userID int) error {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// checkCustomer — к чеку можно привязать только покупателя организации магазина
+func (s *Service) checkCustomer(ctx context.Context, shopID, customerID int) error {
+	ownerID, err := s.employees.GetShopOwnerID(ctx, shopID)
+	if err != nil {
+		return err
+	}
+	ok, err := s.customers.BelongsTo(ctx, customerID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return customer.ErrCustomerNotFound
+	}
+	return nil
+}
+
+func customerID(sale *Sale) int {
+	if sale.CustomerID == nil {
+		return 0
+	}
+	return *sale.CustomerID
+}
+
+// CreateSale пробивает чек: считает позиции по текущим ценам, раскладывает оплату
+// по способам, проводит безналичные платежи и сохраняет всё одной транзакцией.
+func (s *Service) CreateSale(ctx context.Context, cashierID, shopID int, req CreateSaleRequest) (*Sale, error) {
+	if err := s.checkAccess(ctx, shopID, cashierID); err != nil {
+		return nil, err
+	}
+	if len(req.Items) == 0 && len(req.GiftCards) == 0 {
+		return nil, fmt.Errorf("чек не может быть пустым")
+	}
+
+	current, err := s.shifts.CurrentShift(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CustomerID != nil {
+		if err := s.checkCustomer(ctx, shopID, *req.CustomerID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Продажу можно засчитать консультанту, который помогал покупателю, а не кассиру
+	sellerID := cashierID
+	if req.SellerID != nil && *req.SellerID != cashierID {
+		ok, err := s.employees.HasShopAccess(ctx, shopID, *req.SellerID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("продавец ID=%d не работает в магазине", *req.SellerID)
+		}
+		sellerID = *req.SellerID
+	}
+
+	sale := &Sale{ShopID: shopID, CashierID: cashierID, SellerID: sellerID, ShiftID: current.ID, CustomerID: req.CustomerID,
+		Status: StatusCompleted, waitlistEntries: req.WaitlistEntryIDs, reservationID: req.ReservationID}
+	var lines []promo.Line
+	sizes := make([]string, 0, len(req.Items))
+	for _, line := range req.Items {
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("количество товара ID=%d должно быть больше нуля", line.ItemID)
+		}
+		item, err := s.items.GetItemByID(ctx, line.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		if item.ShopID != shopID {
+			return nil, fmt.Errorf("товар ID=%d не принадлежит магазину", line.ItemID)
+		}
+		lines = append(lines, promo.LineFromItem(*item, line.Quantity))
+		sizes = append(sizes, item.Size)
+	}
+
+	// Цены считаются с учётом действующих акций магазина
+	ev, err := s.promos.EvaluateLines(ctx, shopID, lines, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range ev.Lines {
+		sale.Items = append(sale.Items, SaleItem{
+			ItemID:    l.ItemID,
+			Name:      l.Name,
+			Size:      sizes[i],
+			Quantity:  l.Quantity,
+			UnitPrice: l.UnitPrice,
+			Discount:  l.Discount,
+			Total:     l.Total,
+		})
+	}
+	sale.Subtotal = ev.Subtotal
+	sale.Discount = ev.Discount
+	sale.Total = ev.Total
+	sale.Promotions = ev.Applied
+
+	cards, err := s.giftCardLines(ctx, sale, req.GiftCards)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range req.Payments {
+		if len(cards) > 0 && t.Method == payment.MethodGiftCard {
+			return nil, fmt.Errorf("подарочную карту нельзя оплатить подарочной картой")
+		}
+		enabled, err := s.payments.IsEnabled(ctx, shopID, t.Method)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, fmt.Errorf("способ оплаты %s не подключён в магазине", t.Method)
+		}
+	}
+
+	payments, change, err := payment.Settle(sale.Total, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+	sale.Change = change
+
+	for i := range payments {
+		if err := s.payments.Charge(ctx, shopID, customerID(sale), &payments[i]); err != nil {
+			s.voidPayments(ctx, shopID, payments[:i])
+			return nil, err
+		}
+	}
+	sale.Payments = payments
+
+	if err := s.repo.CreateSale(ctx, sale); err != nil {
+		s.voidPayments(ctx, shopID, payments)
+		return nil, err
+	}
+	sale.GiftCards = cards
+
+	s.fiscalizeSale(ctx, sale)
+	s.earnPoints(ctx, sale, ev.Lines)
+	return sale, nil
+}
+
+// giftCardLines добавляет в чек позиции подарочных карт по номиналу. Карты выпускаются
+// в той же транзакции, что и чек, поэтому без оплаченного чека карты не появится.
+// Акции на карты не действуют, остатки не списываются, баллы за них не начисляются.
+func (s *Service) giftCardLines(ctx context.Context, sale *Sale, reqs []giftcard.IssueRequest) ([]giftcard.Card, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	ownerID, err := s.employees.GetShopOwnerID(ctx, sale.ShopID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]giftcard.Card, 0, len(reqs))
+	for _, req := range reqs {
+		c, err := giftcard.NewCard(ownerID, sale.ShopID, sale.CashierID, req)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, *c)
+	}
+	for i := range cards {
+		c := &cards[i]
+		sale.Items = append(sale.Items, SaleItem{
+			Name:      "Подарочная карта",
+			Quantity:  1,
+			UnitPrice: c.InitialBalance,
+			Total:     c.InitialBalance,
+			card:      c,
+		})
+		sale.Subtotal += c.InitialBalance
+		sale.Total += c.InitialBalance
+	}
+	sale.Subtotal = payment.Round(sale.Subtotal)
+	sale.Total = payment.Round(sale.Total)
+	return cards, nil
+}
+
+// earnPoints начисляет баллы покупателю чека. Как и фискализация, не отменяет продажу при ошибке.
+func (s *Service) earnPoints(ctx context.Context, sale *Sale, lines []promo.EvaluatedLine) {
+	if sale.CustomerID == nil {
+		return
+	}
+	purchase := loyalty.Purchase{ShopID: sale.ShopID, CustomerID: *sale.CustomerID, SaleID: sale.ID}
+	for _, l := range lines {
+		purchase.Lines = append(purchase.Lines, loyalty.PurchaseLine{Category: l.Category, Amount: l.Total})
+	}
+	for _, p := range sale.Payments {
+		if p.Method == payment.MethodLoyalty {
+			purchase.PointsPaid += p.Amount
+		}
+	}
+	if _, err := s.loyalty.EarnForSale(ctx, purchase); err != nil {
+		log.Printf("ошибка начисления баллов за чек ID=%d: %v", sale.ID, err)
+	}
+}
+
+// fiscalizeSale отправляет чек в ОФД. Продажа уже проведена, поэтому ошибки
+// только логируются — документ останется в очереди на повторную отправку.
+func (s *Service) fiscalizeSale(ctx context.Context, sale *Sale) {
+	receipt := fiscal.Receipt{
+		ShopID:    sale.ShopID,
+		RefID:     sale.ID,
+		SaleID:    sale.ID,
+		Total:     sale.Total,
+		CreatedAt: sale.CreatedAt,
+	}
+	for _, it := range sale.Items {
+		receipt.Lines = append(receipt.Lines, fiscal.ReceiptLine{
+			Name:     it.Name,
+			Quantity: it.Quantity,
+			Price:    it.UnitPrice,
+			Discount: it.Discount,
+			Total:    it.Total,
+		})
+	}
+	for _, p := range sale.Payments {
+		receipt.Payments = append(receipt.Payments, fiscal.ReceiptPayment{Method: string(p.Method), Amount: p.Amount})
+	}
+
+	doc, err := s.fiscal.SubmitSale(ctx, receipt)
+	if err != nil {
+		log.Printf("не удалось поставить чек #%d в очередь ОФД: %v", sale.ID, err)
+		return
+	}
+	sale.FiscalStatus = doc.Status
+	sale.FiscalSign = doc.FiscalSign
+	sale.FiscalQRURL = doc.QRURL
+}
+
+func (s *Service) fiscalizeReturn(ctx context.Context, sale *Sale, ret *Return) {
+	receipt := fiscal.Receipt{
+		ShopID:    sale.ShopID,
+		RefID:     ret.ID,
+		SaleID:    sale.ID,
+		Total:     ret.Amount,
+		CreatedAt: ret.CreatedAt,
+	}
+	items := make(map[int]SaleItem, len(sale.Items))
+	for _, it := range sale.Items {
+		items[it.ID] = it
+	}
+	for _, ri := range ret.Items {
+		it := items[ri.SaleItemID]
+		receipt.Lines = append(receipt.Lines, fiscal.ReceiptLine{
+			Name:     it.Name,
+			Quantity: ri.Quantity,
+			Price:    it.UnitPrice,
+			Total:    ri.Amount,
+		})
+	}
+	for _, rf := range ret.Refunds {
+		receipt.Payments = append(receipt.Payments, fiscal.ReceiptPayment{Method: string(rf.Method), Amount: rf.Amount})
+	}
+
+	doc, err := s.fiscal.SubmitReturn(ctx, receipt)
+	if err != nil {
+		log.Printf("не удалось поставить возврат #%d в очередь ОФД: %v", ret.ID, err)
+		return
+	}
+	ret.FiscalStatus = doc.Status
+	ret.FiscalSign = doc.FiscalSign
+	ret.FiscalQRURL = doc.QRURL
+}
+
+// voidPayments отменяет уже проведённые списания, если чек так и не был сохранён
+func (s *Service) voidPayments(ctx context.Context, shopID int, payments []payment.Payment) {
+	for _, p := range payments {
+		rf := payment.Refund{Method: p.Method, Amount: p.Amount}
+		if err := s.payments.Refund(ctx, shopID, p, &rf); err != nil {
+			log.Printf("не удалось отменить платёж %s (%s): %v", p.ProviderRef, p.Method, err)
+		}
+	}
+}
+
+func (s *Service) GetSale(ctx context.Context, userID, shopID, saleID int) (*Sale, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	sale, err := s.repo.GetSaleByID(ctx, saleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSaleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sale.ShopID != shopID {
+		return nil, ErrSaleNotFound
+	}
+	return sale, nil
+}
+
+func (s *Service) GetSalesByShop(ctx context.Context, userID, shopID int, from, to time.Time) ([]Sale, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetSalesByShop(ctx, shopID, from, to)
+}
+
+// ReturnSale оформляет возврат позиций и возвращает деньги на те способы,
+// которыми чек был оплачен (см. payment.PlanRefund).
+func (s *Service) ReturnSale(ctx context.Context, userID, shopID, saleID int, req CreateReturnRequest) (*Return, error) {
+	sale, err := s.GetSale(ctx, userID, shopID, saleID)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("не указаны позиции для возврата")
+	}
+
+	// Деньги выдаются из кассы — возврат тоже возможен только в открытой смене
+	current, err := s.shifts.CurrentShift(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*SaleItem, len(sale.Items))
+	for i := range sale.Items {
+		byID[sale.Items[i].ID] = &sale.Items[i]
+	}
+
+	ret := &Return{SaleID: sale.ID, ShiftID: current.ID, Reason: req.Reason, CreatedBy: userID}
+	for _, line := range req.Items {
+		it, ok := byID[line.SaleItemID]
+		if !ok {
+			return nil, fmt.Errorf("позиция ID=%d не найдена в чеке", line.SaleItemID)
+		}
+		if it.GiftCardID != nil {
+			return nil, fmt.Errorf("проданную подарочную карту вернуть нельзя: её остаток уже можно потратить")
+		}
+		if line.Quantity <= 0 || line.Quantity > it.Quantity-it.ReturnedQuantity {
+			return nil, fmt.Errorf("нельзя вернуть %d шт. позиции ID=%d", line.Quantity, line.SaleItemID)
+		}
+		amount := payment.Round(it.Total * float64(line.Quantity) / float64(it.Quantity))
+		ret.Items = append(ret.Items, ReturnItem{SaleItemID: it.ID, Quantity: line.Quantity, Amount: amount})
+		ret.Amount += amount
+	}
+	ret.Amount = payment.Round(ret.Amount)
+
+	refunds, err := payment.PlanRefund(sale.Payments, ret.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.Refunds = refunds
+
+	// Сначала закрепляем возврат в базе: параллельный возврат тех же позиций или денег
+	// получит ErrReturnConflict ещё до обращения к провайдерам
+	if err := s.repo.CreatePendingReturn(ctx, ret); err != nil {
+		return nil, err
+	}
+
+	// Деньги уходят клиенту — доводим возврат до конца, даже если клиент API отключился
+	ctx = context.WithoutCancel(ctx)
+	paymentsByID := make(map[int]payment.Payment, len(sale.Payments))
+	for _, p := range sale.Payments {
+		paymentsByID[p.ID] = p
+	}
+	for i := range ret.Refunds {
+		rf := &ret.Refunds[i]
+		if err := s.payments.Refund(ctx, shopID, paymentsByID[rf.PaymentID], rf); err != nil {
+			requested := ret.Amount
+			if ferr := s.repo.FailReturn(ctx, ret, i); ferr != nil {
+				log.Printf("ошибка отмены возврата ID=%d: %v", ret.ID, ferr)
+				return nil, err
+			}
+			if ret.Status == ReturnNeedsReview {
+				log.Printf("возврат ID=%d проведён частично: провайдеры вернули %.2f из %.2f, позиции остаются возвращёнными, остаток нужно вернуть вручную",
+					ret.ID, ret.Amount, requested)
+				s.reallocate(ctx, sale, ret)
+			}
+			return nil, err
+		}
+	}
+	if err := s.repo.CompleteReturn(ctx, ret); err != nil {
+		// Деньги уже возвращены: возврат остаётся pending, позиции и суммы за ним закреплены
+		log.Printf("ошибка завершения возврата ID=%d: %v", ret.ID, err)
+		return nil, err
+	}
+	s.reallocate(ctx, sale, ret)
+
+	s.fiscalizeReturn(ctx, sale, ret)
+	if sale.CustomerID != nil && sale.Total > 0 {
+		if err := s.loyalty.ReverseForReturn(ctx, sale.ID, ret.ID, ret.Amount/sale.Total); err != nil {
+			log.Printf("ошибка отмены баллов по возврату ID=%d: %v", ret.ID, err)
+		}
+	}
+	return ret, nil
+}
+
+// returnedItems — товары, которые вернулись на остаток по возврату, без повторов.
+// Подарочные карты и удалённые товары на остаток не возвращаются.
+func returnedItems(sale *Sale, ret *Return) []int {
+	items := make(map[int]SaleItem, len(sale.Items))
+	for _, it := range sale.Items {
+		items[it.ID] = it
+	}
+	var ids []int
+	seen := make(map[int]bool)
+	for _, ri := range ret.Items {
+		it, ok := items[ri.SaleItemID]
+		if !ok || it.ItemID == 0 || it.GiftCardID != nil || seen[it.ItemID] {
+			continue
+		}
+		seen[it.ItemID] = true
+		ids = append(ids, it.ItemID)
+	}
+	return ids
+}
+
+// reallocate предлагает вернувшиеся на остаток единицы очереди листа ожидания, как при поступлении товара.
+// Возврат уже проведён, поэтому ошибка только логируется.
+func (s *Service) reallocate(ctx context.Context, sale *Sale, ret *Return) {
+	for _, itemID := range returnedItems(sale, ret) {
+		if err := s.waitlist.Reallocate(ctx, itemID); err != nil {
+			log.Printf("ошибка резерва товара ID=%d по листу ожидания после возврата ID=%d: %v", itemID, ret.ID, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package sale
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReturnedItems(t *testing.T) {
+	cardID := 7
+	sale := &Sale{Items: []SaleItem{
+		{ID: 1, ItemID: 10},
+		{ID: 2, ItemID: 20},
+		{ID: 3, ItemID: 10},
+		{ID: 4, GiftCardID: &cardID},
+		{ID: 5},
+	}}
+
+	tests := []struct {
+		name  string
+		items []ReturnItem
+		want  []int
+	}{
+		{name: "одна позиция", items: []ReturnItem{{SaleItemID: 2, Quantity: 1}}, want: []int{20}},
+		{name: "две позиции одного товара — один резерв", items: []ReturnItem{{SaleItemID: 1, Quantity: 1}, {SaleItemID: 3, Quantity: 2}}, want: []int{10}},
+		{name: "порядок как в возврате", items: []ReturnItem{{SaleItemID: 2, Quantity: 1}, {SaleItemID: 1, Quantity: 1}}, want: []int{20, 10}},
+		{name: "подарочная карта на остаток не возвращается", items: []ReturnItem{{SaleItemID: 4, Quantity: 1}}},
+		{name: "удалённый товар", items: []ReturnItem{{SaleItemID: 5, Quantity: 1}}},
+		{name: "позиции нет в чеке", items: []ReturnItem{{SaleItemID: 99, Quantity: 1}}},
+	}
+	for _, tt := range tests {
+		if got := returnedItems(sale, &Return{Items: tt.items}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: returnedItems = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package segment
+
+import (
+	"crm-backend/internal/auth"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrSegmentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// ownerClaims — сегменты доступны только владельцу
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+func segmentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "segment_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID сегмента", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateSegment godoc
+// @Summary Create customer segment
+// @Description Сохраняет сегмент по условиям (давность покупки, сумма, любимый бренд/категория, размер, магазин, теги, RFM) и сразу считает его состав.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param segment body Segment true "Сегмент"
+// @Success 201 {object} Segment
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/segments [post]
+func (h *Handler) CreateSegment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var seg Segment
+	if err := json.NewDecoder(r.Body).Decode(&seg); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateSegment(r.Context(), claims.ID, &seg); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(seg)
+}
+
+// GetSegments godoc
+// @Summary Get customer segments
+// @Description Сохранённые сегменты владельца с количеством покупателей.
+// @Tags segments
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Segment
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/segments [get]
+func (h *Handler) GetSegments(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	segments, err := h.service.GetSegments(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения сегментов", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(segments)
+}
+
+// UpdateSegment godoc
+// @Summary Update customer segment
+// @Description Меняет название и условия сегмента и пересчитывает состав.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param segment_id path int true "Segment ID"
+// @Param segment body Segment true "Сегмент"
+// @Success 200 {object} Segment
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "сегмент не найден"
+// @Router /owner/segments/{segment_id} [put]
+func (h *Handler) UpdateSegment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	var seg Segment
+	if err := json.NewDecoder(r.Body).Decode(&seg); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	seg.ID = id
+
+	if err := h.service.UpdateSegment(r.Context(), claims.ID, &seg); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(seg)
+}
+
+// DeleteSegment godoc
+// @Summary Delete customer segment
+// @Description Удаляет сохранённый сегмент.
+// @Tags segments
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param segment_id path int true "Segment ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "сегмент не найден"
+// @Router /owner/segments/{segment_id} [delete]
+func (h *Handler) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteSegment(r.Context(), claims.ID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// PreviewRules godoc
+// @Summary Preview segment rules
+// @Description Сколько покупателей попадает под условия и первые 20 из них — без сохранения сегмента.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param rules body Rules true "Условия"
+// @Success 200 {object} Preview
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Router /owner/segments/preview [post]
+func (h *Handler) PreviewRules(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var rules Rules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	pv, err := h.service.Preview(r.Context(), claims.ID, rules)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pv)
+}
+
+// GetMembers godoc
+// @Summary Preview segment members
+// @Description Количество и первые 20 покупателей сохранённого сегмента по последнему пересчёту.
+// @Tags segments
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param segment_id path int true "Segment ID"
+// @Success 200 {object} Preview
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "сегмент не найден"
+// @Router /owner/segments/{segment_id}/members [get]
+func (h *Handler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	pv, err := h.service.PreviewMembers(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pv)
+}
+
+// RecomputeSegment godoc
+// @Summary Recompute segment
+// @Description Пересчитывает состав сегмента, не дожидаясь расписания.
+// @Tags segments
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param segment_id path int true "Segment ID"
+// @Success 200 {object} Segment
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "сегмент не найден"
+// @Router /owner/segments/{segment_id}/recompute [post]
+func (h *Handler) RecomputeSegment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	seg, err := h.service.Recompute(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(seg)
+}
+
+// ExportMembers godoc
+// @Summary Export segment members as CSV
+// @Description Выгрузка покупателей сегмента в CSV для рассылок.
+// @Tags segments
+// @Produce text/csv
+// @Param Authorization header string true "Bearer JWT token"
+// @Param segment_id path int true "Segment ID"
+// @Success 200 {string} string "CSV"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 404 {string} string "сегмент не найден"
+// @Router /owner/segments/{segment_id}/export [get]
+func (h *Handler) ExportMembers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.service.Members(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=segment-%d.csv", id))
+	_ = WriteCSV(w, members)
+}
+
+// GetRFM godoc
+// @Summary Get customers RFM scores
+// @Description RFM-оценки (давность, частота, сумма покупок, 1..5) и сегмент для каждого покупателя владельца.
+// @Tags segments
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {array} Profile
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/customers/rfm [get]
+func (h *Handler) GetRFM(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	profiles, err := h.service.GetRFM(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка расчёта RFM", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(profiles)
+}
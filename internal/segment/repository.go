@@ -0,0 +1,241 @@
+package segment
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS segments (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			rules JSONB NOT NULL DEFAULT '{}',
+			member_count INT NOT NULL DEFAULT 0,
+			computed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS segment_members (
+			segment_id INT NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			PRIMARY KEY (segment_id, customer_id)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции segments: %w", err)
+	}
+	fmt.Println("Миграция segments выполнена успешно")
+	return nil
+}
+
+// GetProfiles — покупатели владельца с агрегатами по покупкам.
+// Любимые бренд и категория — те, которых куплено больше всего штук (за вычетом возвратов).
+func (r *Repository) GetProfiles(ctx context.Context, ownerID int) ([]Profile, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT c.id, c.name, c.phone, COALESCE(c.email, ''), c.tags, c.preferred_sizes, c.marketing_consent,
+		       st.last_purchase, st.purchases, st.spend, st.shops,
+		       COALESCE(fav.brand, ''), COALESCE(fav.category, ''), sz.sizes
+		FROM customers c
+		LEFT JOIN LATERAL (
+			SELECT MAX(created_at) AS last_purchase,
+			       COUNT(*) AS purchases,
+			       COALESCE(SUM(total - refunded), 0) AS spend,
+			       COALESCE(ARRAY_AGG(DISTINCT shop_id) FILTER (WHERE shop_id IS NOT NULL), '{}'::INT[]) AS shops
+			FROM sales
+			WHERE customer_id = c.id AND status <> 'returned'
+		) st ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT
+				(SELECT i.brand FROM sale_items si JOIN sales s ON s.id = si.sale_id JOIN items i ON i.id = si.item_id
+				 WHERE s.customer_id = c.id
+				 GROUP BY i.brand ORDER BY SUM(si.quantity - si.returned_quantity) DESC, i.brand LIMIT 1) AS brand,
+				(SELECT i.category FROM sale_items si JOIN sales s ON s.id = si.sale_id JOIN items i ON i.id = si.item_id
+				 WHERE s.customer_id = c.id AND i.category IS NOT NULL
+				 GROUP BY i.category ORDER BY SUM(si.quantity - si.returned_quantity) DESC, i.category LIMIT 1) AS category
+		) fav ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT COALESCE(ARRAY_AGG(DISTINCT si.size) FILTER (WHERE si.size <> ''), '{}'::TEXT[]) AS sizes
+			FROM sale_items si
+			JOIN sales s ON s.id = si.sale_id
+			WHERE s.customer_id = c.id
+		) sz ON TRUE
+		WHERE c.owner_id = $1 AND c.merged_into IS NULL
+		ORDER BY c.id
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения профилей покупателей: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []Profile
+	for rows.Next() {
+		var p Profile
+		if err := rows.Scan(&p.CustomerID, &p.Name, &p.Phone, &p.Email, &p.Tags, &p.PreferredSizes, &p.MarketingConsent,
+			&p.LastPurchaseAt, &p.Purchases, &p.TotalSpend, &p.Shops,
+			&p.FavouriteBrand, &p.FavouriteCategory, &p.PurchasedSizes); err != nil {
+			return nil, fmt.Errorf("ошибка чтения профиля покупателя: %w", err)
+		}
+		profiles = append(profiles, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке профилей покупателей: %w", err)
+	}
+	return profiles, nil
+}
+
+const segmentColumns = `id, owner_id, name, rules, member_count, computed_at, created_at`
+
+func scanSegments(rows pgx.Rows) ([]Segment, error) {
+	defer rows.Close()
+
+	var segments []Segment
+	for rows.Next() {
+		var s Segment
+		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Rules, &s.MemberCount, &s.ComputedAt, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения сегмента: %w", err)
+		}
+		segments = append(segments, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке сегментов: %w", err)
+	}
+	return segments, nil
+}
+
+func (r *Repository) CreateSegment(ctx context.Context, s *Segment) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO segments (owner_id, name, rules)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, s.OwnerID, s.Name, s.Rules).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания сегмента: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) UpdateSegment(ctx context.Context, s Segment) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE segments SET name = $1, rules = $2 WHERE id = $3 AND owner_id = $4
+	`, s.Name, s.Rules, s.ID, s.OwnerID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления сегмента (ID=%d): %w", s.ID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrSegmentNotFound
+	}
+	return nil
+}
+
+func (r *Repository) DeleteSegment(ctx context.Context, ownerID, id int) error {
+	res, err := r.db.Conn.Exec(ctx, `DELETE FROM segments WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления сегмента (ID=%d): %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrSegmentNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetSegment(ctx context.Context, ownerID, id int) (*Segment, error) {
+	rows, err := r.db.Conn.Query(ctx, `SELECT `+segmentColumns+` FROM segments WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сегмента: %w", err)
+	}
+	segments, err := scanSegments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, ErrSegmentNotFound
+	}
+	return &segments[0], nil
+}
+
+func (r *Repository) GetSegmentsByOwner(ctx context.Context, ownerID int) ([]Segment, error) {
+	rows, err := r.db.Conn.Query(ctx, `SELECT `+segmentColumns+` FROM segments WHERE owner_id = $1 ORDER BY name`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сегментов: %w", err)
+	}
+	return scanSegments(rows)
+}
+
+// GetStaleSegments — сегменты, которые не пересчитывались с момента before
+func (r *Repository) GetStaleSegments(ctx context.Context, before time.Time) ([]Segment, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+segmentColumns+` FROM segments WHERE computed_at IS NULL OR computed_at < $1 ORDER BY owner_id, id
+	`, before)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сегментов для пересчёта: %w", err)
+	}
+	return scanSegments(rows)
+}
+
+// ReplaceMembers заменяет состав сегмента
+func (r *Repository) ReplaceMembers(ctx context.Context, segmentID int, customerIDs []int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM segment_members WHERE segment_id = $1`, segmentID); err != nil {
+		return fmt.Errorf("ошибка очистки сегмента: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO segment_members (segment_id, customer_id)
+		SELECT $1, UNNEST($2::INT[])
+	`, segmentID, customerIDs)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения состава сегмента: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE segments SET member_count = $1, computed_at = NOW() WHERE id = $2
+	`, len(customerIDs), segmentID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления сегмента: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка пересчёта сегмента: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetMemberIDs(ctx context.Context, segmentID int) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT customer_id FROM segment_members WHERE segment_id = $1 ORDER BY customer_id
+	`, segmentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения состава сегмента: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения состава сегмента: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке состава сегмента: %w", err)
+	}
+	return ids, nil
+}
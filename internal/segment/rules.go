@@ -0,0 +1,148 @@
+package segment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func containsFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches — попадает ли покупатель в сегмент
+func (r Rules) Matches(p Profile, now time.Time) bool {
+	if r.PurchasedWithinDays != nil {
+		if p.LastPurchaseAt == nil || p.LastPurchaseAt.Before(now.AddDate(0, 0, -*r.PurchasedWithinDays)) {
+			return false
+		}
+	}
+	if r.NoPurchaseForDays != nil {
+		if p.LastPurchaseAt != nil && p.LastPurchaseAt.After(now.AddDate(0, 0, -*r.NoPurchaseForDays)) {
+			return false
+		}
+	}
+	if r.MinTotalSpend != nil && p.TotalSpend < *r.MinTotalSpend {
+		return false
+	}
+	if r.MaxTotalSpend != nil && p.TotalSpend > *r.MaxTotalSpend {
+		return false
+	}
+	if r.MinPurchases != nil && p.Purchases < *r.MinPurchases {
+		return false
+	}
+	if r.FavouriteBrand != "" && !strings.EqualFold(r.FavouriteBrand, p.FavouriteBrand) {
+		return false
+	}
+	if r.FavouriteCategory != "" && !strings.EqualFold(r.FavouriteCategory, p.FavouriteCategory) {
+		return false
+	}
+	if r.Size != "" && !containsFold(p.PreferredSizes, r.Size) && !containsFold(p.PurchasedSizes, r.Size) {
+		return false
+	}
+	if r.ShopID != nil {
+		found := false
+		for _, id := range p.Shops {
+			if id == *r.ShopID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, tag := range r.Tags {
+		if !containsFold(p.Tags, tag) {
+			return false
+		}
+	}
+	if r.MarketingConsent != nil && p.MarketingConsent != *r.MarketingConsent {
+		return false
+	}
+	if len(r.RFMSegments) > 0 && !containsFold(r.RFMSegments, p.RFM.Segment) {
+		return false
+	}
+	return true
+}
+
+// quintiles раскладывает значения по оценкам 1..5: чем больше значение, тем выше оценка.
+// Одинаковые значения получают одинаковую оценку.
+func quintiles(values []float64) []int {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return values[idx[a]] < values[idx[b]] })
+
+	scores := make([]int, len(values))
+	n := len(values)
+	first := 0
+	for rank := 0; rank < n; rank++ {
+		// Оценка считается по рангу первой из равных позиций
+		if rank > 0 && values[idx[rank]] != values[idx[rank-1]] {
+			first = rank
+		}
+		scores[idx[rank]] = first*5/n + 1
+	}
+	return scores
+}
+
+func rfmSegment(r, f, m int) string {
+	switch {
+	case r >= 4 && f >= 4:
+		return RFMChampions
+	case f >= 4:
+		return RFMLoyal
+	case r >= 4 && f <= 1:
+		return RFMNew
+	case r >= 3:
+		return RFMPromising
+	case f >= 3 || m >= 4:
+		return RFMAtRisk
+	}
+	return RFMHibernating
+}
+
+// ScoreRFM проставляет RFM-оценки покупателям с покупками относительно друг друга
+func ScoreRFM(profiles []Profile, now time.Time) {
+	var buyers []int
+	for i, p := range profiles {
+		if p.Purchases == 0 || p.LastPurchaseAt == nil {
+			profiles[i].RFM = RFMScore{Segment: RFMNoPurchases}
+			continue
+		}
+		buyers = append(buyers, i)
+	}
+	if len(buyers) == 0 {
+		return
+	}
+
+	recency := make([]float64, len(buyers))
+	frequency := make([]float64, len(buyers))
+	monetary := make([]float64, len(buyers))
+	for k, i := range buyers {
+		// Чем свежее покупка, тем выше оценка — поэтому сортируем по отрицательной давности
+		recency[k] = -now.Sub(*profiles[i].LastPurchaseAt).Hours()
+		frequency[k] = float64(profiles[i].Purchases)
+		monetary[k] = profiles[i].TotalSpend
+	}
+	rs, fs, ms := quintiles(recency), quintiles(frequency), quintiles(monetary)
+
+	for k, i := range buyers {
+		profiles[i].RFM = RFMScore{
+			Recency:     rs[k],
+			Frequency:   fs[k],
+			Monetary:    ms[k],
+			Score:       fmt.Sprintf("%d%d%d", rs[k], fs[k], ms[k]),
+			Segment:     rfmSegment(rs[k], fs[k], ms[k]),
+			RecencyDays: int(now.Sub(*profiles[i].LastPurchaseAt).Hours() / 24),
+		}
+	}
+}
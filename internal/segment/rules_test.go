@@ -0,0 +1,137 @@
+package segment
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestQuintiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   []int
+	}{
+		{name: "пять разных значений", values: []float64{30, 10, 50, 20, 40}, want: []int{3, 1, 5, 2, 4}},
+		{name: "десять значений — по два на оценку", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, want: []int{1, 1, 2, 2, 3, 3, 4, 4, 5, 5}},
+		{name: "равные значения получают одну оценку", values: []float64{1, 1, 2}, want: []int{1, 1, 4}},
+		{name: "все равны", values: []float64{5, 5, 5, 5}, want: []int{1, 1, 1, 1}},
+		{name: "один покупатель", values: []float64{7}, want: []int{1}},
+		{name: "отрицательные значения", values: []float64{-24, -720, -4800}, want: []int{4, 2, 1}},
+	}
+	for _, tt := range tests {
+		if got := quintiles(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: quintiles = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRFMSegment(t *testing.T) {
+	tests := []struct {
+		r, f, m int
+		want    string
+	}{
+		{5, 5, 5, RFMChampions},
+		{4, 4, 1, RFMChampions},
+		{3, 4, 2, RFMLoyal},
+		{1, 5, 5, RFMLoyal},
+		{5, 1, 1, RFMNew},
+		{4, 1, 5, RFMNew},
+		{4, 2, 1, RFMPromising},
+		{3, 3, 3, RFMPromising},
+		{2, 3, 1, RFMAtRisk},
+		{1, 1, 4, RFMAtRisk},
+		{2, 2, 3, RFMHibernating},
+		{1, 1, 1, RFMHibernating},
+	}
+	for _, tt := range tests {
+		if got := rfmSegment(tt.r, tt.f, tt.m); got != tt.want {
+			t.Errorf("rfmSegment(%d, %d, %d) = %s, ожидался %s", tt.r, tt.f, tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestScoreRFM(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) *time.Time {
+		at := now.AddDate(0, 0, -d)
+		return &at
+	}
+
+	profiles := []Profile{
+		{CustomerID: 1, LastPurchaseAt: daysAgo(1), Purchases: 1, TotalSpend: 100},
+		{CustomerID: 2, LastPurchaseAt: daysAgo(3), Purchases: 10, TotalSpend: 9000},
+		{CustomerID: 3, LastPurchaseAt: daysAgo(40), Purchases: 8, TotalSpend: 5000},
+		{CustomerID: 4, LastPurchaseAt: daysAgo(90), Purchases: 3, TotalSpend: 7000},
+		{CustomerID: 5, LastPurchaseAt: daysAgo(365), Purchases: 2, TotalSpend: 50},
+		{CustomerID: 6},
+		{CustomerID: 7, Purchases: 2, TotalSpend: 300},
+	}
+	ScoreRFM(profiles, now)
+
+	want := []RFMScore{
+		{Recency: 5, Frequency: 1, Monetary: 2, Score: "512", Segment: RFMNew, RecencyDays: 1},
+		{Recency: 4, Frequency: 5, Monetary: 5, Score: "455", Segment: RFMChampions, RecencyDays: 3},
+		{Recency: 3, Frequency: 4, Monetary: 3, Score: "343", Segment: RFMLoyal, RecencyDays: 40},
+		{Recency: 2, Frequency: 3, Monetary: 4, Score: "234", Segment: RFMAtRisk, RecencyDays: 90},
+		{Recency: 1, Frequency: 2, Monetary: 1, Score: "121", Segment: RFMHibernating, RecencyDays: 365},
+		// Без покупок или без даты последней покупки — в сравнении не участвуют
+		{Segment: RFMNoPurchases},
+		{Segment: RFMNoPurchases},
+	}
+	for i, p := range profiles {
+		if p.RFM != want[i] {
+			t.Errorf("покупатель %d: RFM = %+v, ожидалось %+v", p.CustomerID, p.RFM, want[i])
+		}
+	}
+}
+
+func TestScoreRFMWithoutBuyers(t *testing.T) {
+	profiles := []Profile{{CustomerID: 1}, {CustomerID: 2}}
+	ScoreRFM(profiles, time.Now())
+	for _, p := range profiles {
+		if p.RFM != (RFMScore{Segment: RFMNoPurchases}) {
+			t.Errorf("покупатель %d: RFM = %+v", p.CustomerID, p.RFM)
+		}
+	}
+}
+
+func TestRulesMatches(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	last := now.AddDate(0, 0, -10)
+	p := Profile{
+		LastPurchaseAt: &last,
+		Purchases:      3,
+		TotalSpend:     1500,
+		Tags:           []string{"VIP"},
+		Shops:          []int{2},
+		PurchasedSizes: []string{"M"},
+		RFM:            RFMScore{Segment: RFMLoyal},
+	}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		want  bool
+	}{
+		{name: "без правил", rules: Rules{}, want: true},
+		{name: "покупал за 30 дней", rules: Rules{PurchasedWithinDays: intPtr(30)}, want: true},
+		{name: "не покупал за 7 дней", rules: Rules{PurchasedWithinDays: intPtr(7)}, want: false},
+		{name: "нет покупок 7 дней", rules: Rules{NoPurchaseForDays: intPtr(7)}, want: true},
+		{name: "нет покупок 30 дней", rules: Rules{NoPurchaseForDays: intPtr(30)}, want: false},
+		{name: "минимум покупок", rules: Rules{MinPurchases: intPtr(4)}, want: false},
+		{name: "размер из покупок без учёта регистра", rules: Rules{Size: "m"}, want: true},
+		{name: "тег без учёта регистра", rules: Rules{Tags: []string{"vip"}}, want: true},
+		{name: "не все теги", rules: Rules{Tags: []string{"vip", "opt"}}, want: false},
+		{name: "другой магазин", rules: Rules{ShopID: intPtr(1)}, want: false},
+		{name: "RFM-сегмент", rules: Rules{RFMSegments: []string{RFMChampions, RFMLoyal}}, want: true},
+		{name: "чужой RFM-сегмент", rules: Rules{RFMSegments: []string{RFMAtRisk}}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.Matches(p, now); got != tt.want {
+			t.Errorf("%s: Matches = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
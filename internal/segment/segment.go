@@ -0,0 +1,83 @@
+package segment
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrSegmentNotFound = errors.New("сегмент не найден")
+
+// Классические RFM-сегменты
+const (
+	RFMChampions   = "champions"
+	RFMLoyal       = "loyal"
+	RFMNew         = "new"
+	RFMPromising   = "promising"
+	RFMAtRisk      = "at_risk"
+	RFMHibernating = "hibernating"
+	RFMNoPurchases = "no_purchases"
+)
+
+// Rules — условия сегмента; пустое условие не ограничивает выборку, все заданные должны выполняться
+type Rules struct {
+	PurchasedWithinDays *int     `json:"purchased_within_days,omitempty"`
+	NoPurchaseForDays   *int     `json:"no_purchase_for_days,omitempty"`
+	MinTotalSpend       *float64 `json:"min_total_spend,omitempty"`
+	MaxTotalSpend       *float64 `json:"max_total_spend,omitempty"`
+	MinPurchases        *int     `json:"min_purchases,omitempty"`
+	FavouriteBrand      string   `json:"favourite_brand,omitempty"`
+	FavouriteCategory   string   `json:"favourite_category,omitempty"`
+	// Size — размер из предпочтений покупателя или из его покупок
+	Size   string `json:"size,omitempty"`
+	ShopID *int   `json:"shop_id,omitempty"`
+	// Tags — у покупателя должны быть все перечисленные теги
+	Tags             []string `json:"tags,omitempty"`
+	MarketingConsent *bool    `json:"marketing_consent,omitempty"`
+	RFMSegments      []string `json:"rfm_segments,omitempty"`
+}
+
+// Segment — сохранённый сегмент; состав пересчитывается по расписанию
+type Segment struct {
+	ID          int        `json:"id"`
+	OwnerID     int        `json:"owner_id"`
+	Name        string     `json:"name"`
+	Rules       Rules      `json:"rules"`
+	MemberCount int        `json:"member_count"`
+	ComputedAt  *time.Time `json:"computed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// RFMScore — оценки 1..5 по давности, частоте и сумме покупок
+type RFMScore struct {
+	Recency     int    `json:"recency"`
+	Frequency   int    `json:"frequency"`
+	Monetary    int    `json:"monetary"`
+	Score       string `json:"score"`
+	Segment     string `json:"segment"`
+	RecencyDays int    `json:"recency_days"`
+}
+
+// Profile — покупатель с агрегатами по покупкам, по которым считаются сегменты
+type Profile struct {
+	CustomerID        int        `json:"customer_id"`
+	Name              string     `json:"name"`
+	Phone             string     `json:"phone"`
+	Email             string     `json:"email,omitempty"`
+	Tags              []string   `json:"tags"`
+	PreferredSizes    []string   `json:"preferred_sizes"`
+	MarketingConsent  bool       `json:"marketing_consent"`
+	LastPurchaseAt    *time.Time `json:"last_purchase_at,omitempty"`
+	Purchases         int        `json:"purchases"`
+	TotalSpend        float64    `json:"total_spend"`
+	Shops             []int      `json:"shops"`
+	FavouriteBrand    string     `json:"favourite_brand,omitempty"`
+	FavouriteCategory string     `json:"favourite_category,omitempty"`
+	PurchasedSizes    []string   `json:"purchased_sizes"`
+	RFM               RFMScore   `json:"rfm"`
+}
+
+// Preview — сколько покупателей попадает в сегмент и первые из них
+type Preview struct {
+	Count   int       `json:"count"`
+	Members []Profile `json:"members"`
+}
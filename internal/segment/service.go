@@ -0,0 +1,253 @@
+package segment
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const previewLimit = 20
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// profiles — профили покупателей владельца с RFM-оценками на момент now
+func (s *Service) profiles(ctx context.Context, ownerID int, now time.Time) ([]Profile, error) {
+	profiles, err := s.repo.GetProfiles(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	ScoreRFM(profiles, now)
+	return profiles, nil
+}
+
+func filter(profiles []Profile, rules Rules, now time.Time) []Profile {
+	var members []Profile
+	for _, p := range profiles {
+		if rules.Matches(p, now) {
+			members = append(members, p)
+		}
+	}
+	return members
+}
+
+func preview(members []Profile) *Preview {
+	pv := &Preview{Count: len(members), Members: members}
+	if len(pv.Members) > previewLimit {
+		pv.Members = pv.Members[:previewLimit]
+	}
+	return pv
+}
+
+// Preview — количество и первые покупатели по условиям, без сохранения сегмента
+func (s *Service) Preview(ctx context.Context, ownerID int, rules Rules) (*Preview, error) {
+	now := time.Now()
+	profiles, err := s.profiles(ctx, ownerID, now)
+	if err != nil {
+		return nil, err
+	}
+	return preview(filter(profiles, rules, now)), nil
+}
+
+// GetRFM — RFM-оценки всех покупателей владельца
+func (s *Service) GetRFM(ctx context.Context, ownerID int) ([]Profile, error) {
+	return s.profiles(ctx, ownerID, time.Now())
+}
+
+func (s *Service) recompute(ctx context.Context, seg *Segment, profiles []Profile, now time.Time) error {
+	members := filter(profiles, seg.Rules, now)
+	ids := make([]int, 0, len(members))
+	for _, p := range members {
+		ids = append(ids, p.CustomerID)
+	}
+	if err := s.repo.ReplaceMembers(ctx, seg.ID, ids); err != nil {
+		return err
+	}
+	seg.MemberCount = len(ids)
+	seg.ComputedAt = &now
+	return nil
+}
+
+func (s *Service) recomputeNow(ctx context.Context, seg *Segment) error {
+	now := time.Now()
+	profiles, err := s.profiles(ctx, seg.OwnerID, now)
+	if err != nil {
+		return err
+	}
+	return s.recompute(ctx, seg, profiles, now)
+}
+
+func (s *Service) CreateSegment(ctx context.Context, ownerID int, seg *Segment) error {
+	seg.OwnerID = ownerID
+	seg.Name = strings.TrimSpace(seg.Name)
+	if seg.Name == "" {
+		return errors.New("название сегмента обязательно")
+	}
+	if err := s.repo.CreateSegment(ctx, seg); err != nil {
+		return err
+	}
+	return s.recomputeNow(ctx, seg)
+}
+
+func (s *Service) UpdateSegment(ctx context.Context, ownerID int, seg *Segment) error {
+	seg.OwnerID = ownerID
+	seg.Name = strings.TrimSpace(seg.Name)
+	if seg.Name == "" {
+		return errors.New("название сегмента обязательно")
+	}
+	if err := s.repo.UpdateSegment(ctx, *seg); err != nil {
+		return err
+	}
+	return s.recomputeNow(ctx, seg)
+}
+
+func (s *Service) DeleteSegment(ctx context.Context, ownerID, id int) error {
+	return s.repo.DeleteSegment(ctx, ownerID, id)
+}
+
+func (s *Service) GetSegments(ctx context.Context, ownerID int) ([]Segment, error) {
+	return s.repo.GetSegmentsByOwner(ctx, ownerID)
+}
+
+func (s *Service) GetSegment(ctx context.Context, ownerID, id int) (*Segment, error) {
+	return s.repo.GetSegment(ctx, ownerID, id)
+}
+
+// Recompute пересчитывает состав сегмента вне расписания
+func (s *Service) Recompute(ctx context.Context, ownerID, id int) (*Segment, error) {
+	seg, err := s.repo.GetSegment(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recomputeNow(ctx, seg); err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+// MemberIDs — покупатели сегмента по последнему пересчёту
+func (s *Service) MemberIDs(ctx context.Context, ownerID, id int) ([]int, error) {
+	if _, err := s.repo.GetSegment(ctx, ownerID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetMemberIDs(ctx, id)
+}
+
+// Members — профили покупателей сегмента (состав — по последнему пересчёту, данные — текущие)
+func (s *Service) Members(ctx context.Context, ownerID, id int) ([]Profile, error) {
+	ids, err := s.MemberIDs(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := s.profiles(ctx, ownerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	inSegment := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		inSegment[id] = true
+	}
+	var members []Profile
+	for _, p := range profiles {
+		if inSegment[p.CustomerID] {
+			members = append(members, p)
+		}
+	}
+	return members, nil
+}
+
+func (s *Service) PreviewMembers(ctx context.Context, ownerID, id int) (*Preview, error) {
+	members, err := s.Members(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	return preview(members), nil
+}
+
+// WriteCSV выгружает покупателей сегмента для рассылок
+func WriteCSV(w io.Writer, members []Profile) error {
+	cw := csv.NewWriter(w)
+	header := []string{"customer_id", "name", "phone", "email", "marketing_consent", "last_purchase_at",
+		"purchases", "total_spend", "rfm_score", "rfm_segment"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, p := range members {
+		last := ""
+		if p.LastPurchaseAt != nil {
+			last = p.LastPurchaseAt.Format(time.RFC3339)
+		}
+		record := []string{
+			strconv.Itoa(p.CustomerID),
+			p.Name,
+			p.Phone,
+			p.Email,
+			strconv.FormatBool(p.MarketingConsent),
+			last,
+			strconv.Itoa(p.Purchases),
+			fmt.Sprintf("%.2f", p.TotalSpend),
+			p.RFM.Score,
+			p.RFM.Segment,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// RecomputeStale пересчитывает сегменты, которые не обновлялись дольше maxAge.
+// Профили владельца считаются один раз на все его сегменты.
+func (s *Service) RecomputeStale(ctx context.Context, maxAge time.Duration) error {
+	now := time.Now()
+	segments, err := s.repo.GetStaleSegments(ctx, now.Add(-maxAge))
+	if err != nil {
+		return err
+	}
+
+	var profiles []Profile
+	ownerID := 0
+	for i := range segments {
+		seg := &segments[i]
+		if seg.OwnerID != ownerID {
+			profiles, err = s.profiles(ctx, seg.OwnerID, now)
+			if err != nil {
+				return err
+			}
+			ownerID = seg.OwnerID
+		}
+		if err := s.recompute(ctx, seg, profiles, now); err != nil {
+			log.Printf("ошибка пересчёта сегмента ID=%d: %v", seg.ID, err)
+		}
+	}
+	return nil
+}
+
+// RunRecompute — фоновый пересчёт сегментов
+func (s *Service) RunRecompute(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RecomputeStale(ctx, interval); err != nil {
+				log.Printf("ошибка пересчёта сегментов: %v", err)
+			}
+		}
+	}
+}
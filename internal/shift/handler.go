@@ -0,0 +1,329 @@
+package shift
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/period"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrShiftNotFound), errors.Is(err, ErrNoOpenShift):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrShiftAlreadyOpen), errors.Is(err, ErrShiftClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// shiftParams читает ID магазина и смены из URL
+func shiftParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	shiftID, err := strconv.Atoi(chi.URLParam(r, "shift_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID смены", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, shiftID, true
+}
+
+// OpenShift godoc
+// @Summary Open cash register shift
+// @Description Открывает кассовую смену магазина с начальным разменом.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param data body OpenShiftRequest true "Размен"
+// @Success 201 {object} Shift
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 409 {string} string "в магазине уже открыта смена"
+// @Router /shops/{id}/shifts [post]
+func (h *Handler) OpenShift(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var req OpenShiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	sh, err := h.service.OpenShift(r.Context(), claims.ID, shopID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(sh)
+}
+
+// GetShifts godoc
+// @Summary Get shop shifts
+// @Description Смены магазина за период (по умолчанию — последние 30 дней).
+// @Tags shifts
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param from query string false "Дата начала (YYYY-MM-DD)"
+// @Param to query string false "Дата окончания (YYYY-MM-DD)"
+// @Success 200 {array} Shift
+// @Failure 400 {string} string "неправильный период"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/shifts [get]
+func (h *Handler) GetShifts(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	from, to, err := period.FromRequest(r)
+	if err != nil {
+		http.Error(w, "неправильный период", http.StatusBadRequest)
+		return
+	}
+
+	shifts, err := h.service.GetShifts(r.Context(), claims.ID, shopID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(shifts)
+}
+
+// GetCurrentShift godoc
+// @Summary Get current shift
+// @Description Открытая смена магазина.
+// @Tags shifts
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {object} Shift
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "смена не открыта"
+// @Router /shops/{id}/shifts/current [get]
+func (h *Handler) GetCurrentShift(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	sh, err := h.service.GetCurrentShift(r.Context(), claims.ID, shopID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sh)
+}
+
+// AddCashMovement godoc
+// @Summary Cash in / cash out
+// @Description Внесение (cash_in) или изъятие (cash_out) наличных в открытой смене: мелкие расходы, инкассация.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param shift_id path int true "Shift ID"
+// @Param data body CashMovementRequest true "Операция"
+// @Success 201 {object} CashMovement
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 409 {string} string "смена уже закрыта"
+// @Router /shops/{id}/shifts/{shift_id}/cash-movements [post]
+func (h *Handler) AddCashMovement(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, shiftID, ok := shiftParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req CashMovementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.service.AddCashMovement(r.Context(), claims.ID, shopID, shiftID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(m)
+}
+
+// GetCashMovements godoc
+// @Summary Get shift cash movements
+// @Description Внесения и изъятия наличных за смену.
+// @Tags shifts
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param shift_id path int true "Shift ID"
+// @Success 200 {array} CashMovement
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "смена не найдена"
+// @Router /shops/{id}/shifts/{shift_id}/cash-movements [get]
+func (h *Handler) GetCashMovements(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, shiftID, ok := shiftParams(w, r)
+	if !ok {
+		return
+	}
+
+	movements, err := h.service.GetCashMovements(r.Context(), claims.ID, shopID, shiftID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(movements)
+}
+
+// GetReport godoc
+// @Summary Get shift report
+// @Description X-отчёт по открытой смене или Z-отчёт по закрытой: продажи по способам оплаты, возвраты, движение наличных и расхождение.
+// @Tags shifts
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param shift_id path int true "Shift ID"
+// @Success 200 {object} Report
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "смена не найдена"
+// @Router /shops/{id}/shifts/{shift_id}/report [get]
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, shiftID, ok := shiftParams(w, r)
+	if !ok {
+		return
+	}
+
+	rep, err := h.service.Report(r.Context(), claims.ID, shopID, shiftID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+// CloseShift godoc
+// @Summary Close shift
+// @Description Закрывает смену с фактической суммой в кассе и возвращает Z-отчёт.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param shift_id path int true "Shift ID"
+// @Param data body CloseShiftRequest true "Пересчитанная сумма"
+// @Success 200 {object} Report
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 409 {string} string "смена уже закрыта"
+// @Router /shops/{id}/shifts/{shift_id}/close [post]
+func (h *Handler) CloseShift(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, shiftID, ok := shiftParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req CloseShiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	rep, err := h.service.CloseShift(r.Context(), claims.ID, shopID, shiftID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rep)
+}
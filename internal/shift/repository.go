@@ -0,0 +1,313 @@
+package shift
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"crm-backend/internal/payment"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS shifts (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			opened_by INT REFERENCES users(id) ON DELETE SET NULL,
+			closed_by INT REFERENCES users(id) ON DELETE SET NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			opening_float NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			counted_cash NUMERIC(10, 2),
+			expected_cash NUMERIC(10, 2),
+			variance NUMERIC(10, 2),
+			opened_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			closed_at TIMESTAMP WITH TIME ZONE
+		);
+
+		-- В магазине одна касса: открытой может быть только одна смена
+		CREATE UNIQUE INDEX IF NOT EXISTS shifts_one_open_per_shop ON shifts (shop_id) WHERE status = 'open';
+
+		CREATE TABLE IF NOT EXISTS cash_movements (
+			id SERIAL PRIMARY KEY,
+			shift_id INT NOT NULL REFERENCES shifts(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL,
+			reason TEXT,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции shifts: %w", err)
+	}
+	fmt.Println("Миграция shifts выполнена успешно")
+	return nil
+}
+
+const shiftColumns = `
+	id, shop_id, COALESCE(opened_by, 0), COALESCE(closed_by, 0), status, opening_float,
+	counted_cash, expected_cash, variance, opened_at, closed_at
+`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// querier — пул или транзакция: Z-отчёт считается в транзакции закрытия смены, X-отчёт — без неё
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func scanShift(row scanner) (*Shift, error) {
+	var s Shift
+	err := row.Scan(&s.ID, &s.ShopID, &s.OpenedBy, &s.ClosedBy, &s.Status, &s.OpeningFloat,
+		&s.CountedCash, &s.ExpectedCash, &s.Variance, &s.OpenedAt, &s.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *Repository) OpenShift(ctx context.Context, s *Shift) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO shifts (shop_id, opened_by, status, opening_float)
+		VALUES ($1, $2, 'open', $3)
+		RETURNING id, opened_at
+	`, s.ShopID, s.OpenedBy, s.OpeningFloat).Scan(&s.ID, &s.OpenedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия смены: %w", err)
+	}
+	s.Status = StatusOpen
+	return nil
+}
+
+func (r *Repository) GetOpenShift(ctx context.Context, shopID int) (*Shift, error) {
+	s, err := scanShift(r.db.Conn.QueryRow(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE shop_id = $1 AND status = 'open'
+	`, shopID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения открытой смены: %w", err)
+	}
+	return s, nil
+}
+
+func (r *Repository) GetShiftByID(ctx context.Context, shiftID int) (*Shift, error) {
+	s, err := scanShift(r.db.Conn.QueryRow(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE id = $1
+	`, shiftID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения смены по ID=%d: %w", shiftID, err)
+	}
+	return s, nil
+}
+
+func (r *Repository) GetShiftsByShop(ctx context.Context, shopID int, from, to time.Time) ([]Shift, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+shiftColumns+`
+		FROM shifts
+		WHERE shop_id = $1 AND opened_at >= $2 AND opened_at < $3
+		ORDER BY opened_at DESC
+	`, shopID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения смен: %w", err)
+	}
+	defer rows.Close()
+
+	var shifts []Shift
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения смены: %w", err)
+		}
+		shifts = append(shifts, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке смен: %w", err)
+	}
+	return shifts, nil
+}
+
+// LockOpen блокирует открытую смену в транзакции продажи или возврата. Блокировка разделяемая:
+// продажи не мешают друг другу, а закрытие смены ждёт, пока они завершатся, и учитывает их в Z-отчёте.
+// Если смена уже закрыта, возвращает ErrShiftClosed.
+func LockOpen(ctx context.Context, tx pgx.Tx, shiftID int) error {
+	var status string
+	err := tx.QueryRow(ctx, `SELECT status FROM shifts WHERE id = $1 FOR SHARE`, shiftID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrShiftNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки смены ID=%d: %w", shiftID, err)
+	}
+	if status != StatusOpen {
+		return ErrShiftClosed
+	}
+	return nil
+}
+
+// CloseShift закрывает открытую смену и в той же транзакции считает по ней Z-отчёт rep.
+// Строка смены блокируется до конца транзакции, поэтому ни одна продажа или возврат
+// не попадут в смену после подсчёта отчёта. Если смена уже закрыта, возвращает ErrShiftClosed.
+func (r *Repository) CloseShift(ctx context.Context, s *Shift, rep *Report) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM shifts WHERE id = $1 FOR UPDATE`, s.ID).Scan(&status)
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки смены ID=%d: %w", s.ID, err)
+	}
+	if status != StatusOpen {
+		return ErrShiftClosed
+	}
+
+	if err := fillReport(ctx, tx, rep); err != nil {
+		return err
+	}
+	rep.calcExpectedCash(s.OpeningFloat)
+	expected := rep.ExpectedCash
+	variance := payment.Round(*s.CountedCash - expected)
+	s.ExpectedCash = &expected
+	s.Variance = &variance
+
+	err = tx.QueryRow(ctx, `
+		UPDATE shifts
+		SET status = 'closed', closed_by = $1, counted_cash = $2, expected_cash = $3, variance = $4, closed_at = NOW()
+		WHERE id = $5 AND status = 'open'
+		RETURNING closed_at
+	`, s.ClosedBy, s.CountedCash, s.ExpectedCash, s.Variance, s.ID).Scan(&s.ClosedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrShiftClosed
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка закрытия смены ID=%d: %w", s.ID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка закрытия смены ID=%d: %w", s.ID, err)
+	}
+	s.Status = StatusClosed
+	return nil
+}
+
+func (r *Repository) AddCashMovement(ctx context.Context, m *CashMovement) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO cash_movements (shift_id, type, amount, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, m.ShiftID, m.Type, m.Amount, m.Reason, m.CreatedBy).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения движения наличных: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetCashMovements(ctx context.Context, shiftID int) ([]CashMovement, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT id, shift_id, type, amount, COALESCE(reason, ''), COALESCE(created_by, 0), created_at
+		FROM cash_movements
+		WHERE shift_id = $1
+		ORDER BY created_at
+	`, shiftID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения движений наличных: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []CashMovement
+	for rows.Next() {
+		var m CashMovement
+		if err := rows.Scan(&m.ID, &m.ShiftID, &m.Type, &m.Amount, &m.Reason, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения движения наличных: %w", err)
+		}
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке движений наличных: %w", err)
+	}
+	return movements, nil
+}
+
+// FillReport считает по данным смены продажи, возвраты и итоги по способам оплаты
+func (r *Repository) FillReport(ctx context.Context, rep *Report) error {
+	return fillReport(ctx, r.db.Conn, rep)
+}
+
+func fillReport(ctx context.Context, q querier, rep *Report) error {
+	shiftID := rep.Shift.ID
+
+	err := q.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(total), 0) FROM sales WHERE shift_id = $1
+	`, shiftID).Scan(&rep.SalesCount, &rep.SalesTotal)
+	if err != nil {
+		return fmt.Errorf("ошибка подсчёта продаж смены: %w", err)
+	}
+
+	err = q.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM sale_returns WHERE shift_id = $1 AND status <> 'failed'
+	`, shiftID).Scan(&rep.ReturnsCount, &rep.ReturnsTotal)
+	if err != nil {
+		return fmt.Errorf("ошибка подсчёта возвратов смены: %w", err)
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT method, SUM(sales), SUM(refunds) FROM (
+			SELECT p.method, p.amount AS sales, 0 AS refunds
+			FROM payments p JOIN sales s ON s.id = p.sale_id
+			WHERE s.shift_id = $1
+			UNION ALL
+			SELECT rf.method, 0, rf.amount
+			FROM refunds rf JOIN sale_returns sr ON sr.id = rf.return_id
+			WHERE sr.shift_id = $1
+		) t
+		GROUP BY method
+		ORDER BY method
+	`, shiftID)
+	if err != nil {
+		return fmt.Errorf("ошибка подсчёта оплат смены: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mt MethodTotal
+		if err := rows.Scan(&mt.Method, &mt.Sales, &mt.Refunds); err != nil {
+			return fmt.Errorf("ошибка чтения итогов по оплатам: %w", err)
+		}
+		mt.Net = payment.Round(mt.Sales - mt.Refunds)
+		rep.ByMethod = append(rep.ByMethod, mt)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при обработке итогов по оплатам: %w", err)
+	}
+
+	err = q.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE type = 'cash_in'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE type = 'cash_out'), 0)
+		FROM cash_movements
+		WHERE shift_id = $1
+	`, shiftID).Scan(&rep.CashIn, &rep.CashOut)
+	if err != nil {
+		return fmt.Errorf("ошибка подсчёта движений наличных: %w", err)
+	}
+	return nil
+}
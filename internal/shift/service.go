@@ -0,0 +1,208 @@
+package shift
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/fiscal"
+	"crm-backend/internal/payment"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrAccessDenied     = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrNoOpenShift      = errors.New("смена не открыта")
+	ErrShiftAlreadyOpen = errors.New("в магазине уже открыта смена")
+	ErrShiftNotFound    = errors.New("смена не найдена")
+	ErrShiftClosed      = errors.New("смена уже закрыта")
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	fiscal    *fiscal.Service
+}
+
+func NewService(repo *Repository, employees *employee.Repository, fiscalService *fiscal.Service) *Service {
+	return &Service{repo: repo, employees: employees, fiscal: fiscalService}
+}
+
+func (s *Service) checkAccess(ctx context.Context, shopID, userID int) error {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// CurrentShift — открытая смена магазина; продажи и возвраты без неё запрещены
+func (s *Service) CurrentShift(ctx context.Context, shopID int) (*Shift, error) {
+	sh, err := s.repo.GetOpenShift(ctx, shopID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoOpenShift
+	}
+	return sh, err
+}
+
+func (s *Service) GetCurrentShift(ctx context.Context, userID, shopID int) (*Shift, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	return s.CurrentShift(ctx, shopID)
+}
+
+func (s *Service) OpenShift(ctx context.Context, userID, shopID int, req OpenShiftRequest) (*Shift, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	if req.OpeningFloat < 0 {
+		return nil, fmt.Errorf("размен не может быть отрицательным")
+	}
+
+	if _, err := s.CurrentShift(ctx, shopID); err == nil {
+		return nil, ErrShiftAlreadyOpen
+	} else if !errors.Is(err, ErrNoOpenShift) {
+		return nil, err
+	}
+
+	sh := &Shift{ShopID: shopID, OpenedBy: userID, OpeningFloat: payment.Round(req.OpeningFloat)}
+	if err := s.repo.OpenShift(ctx, sh); err != nil {
+		return nil, err
+	}
+
+	// Если ОФД недоступен, открытие смены уйдёт через очередь повторов
+	_, err := s.fiscal.SubmitShiftOpen(ctx, fiscal.ShiftEvent{
+		ShopID: shopID, ShiftID: sh.ID, Cash: sh.OpeningFloat, CreatedAt: sh.OpenedAt,
+	})
+	if err != nil {
+		log.Printf("не удалось поставить открытие смены #%d в очередь ОФД: %v", sh.ID, err)
+	}
+	return sh, nil
+}
+
+func (s *Service) getShift(ctx context.Context, userID, shopID, shiftID int) (*Shift, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	sh, err := s.repo.GetShiftByID(ctx, shiftID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrShiftNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sh.ShopID != shopID {
+		return nil, ErrShiftNotFound
+	}
+	return sh, nil
+}
+
+func (s *Service) GetShifts(ctx context.Context, userID, shopID int, from, to time.Time) ([]Shift, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetShiftsByShop(ctx, shopID, from, to)
+}
+
+func (s *Service) AddCashMovement(ctx context.Context, userID, shopID, shiftID int, req CashMovementRequest) (*CashMovement, error) {
+	sh, err := s.getShift(ctx, userID, shopID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	if sh.Status != StatusOpen {
+		return nil, ErrShiftClosed
+	}
+	if req.Type != MovementCashIn && req.Type != MovementCashOut {
+		return nil, fmt.Errorf("неизвестный тип операции: %s", req.Type)
+	}
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("сумма должна быть больше нуля")
+	}
+
+	m := &CashMovement{
+		ShiftID:   shiftID,
+		Type:      req.Type,
+		Amount:    payment.Round(req.Amount),
+		Reason:    req.Reason,
+		CreatedBy: userID,
+	}
+	if err := s.repo.AddCashMovement(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *Service) GetCashMovements(ctx context.Context, userID, shopID, shiftID int) ([]CashMovement, error) {
+	if _, err := s.getShift(ctx, userID, shopID, shiftID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCashMovements(ctx, shiftID)
+}
+
+func (s *Service) buildReport(ctx context.Context, sh *Shift) (*Report, error) {
+	rep := &Report{Type: "X", Shift: *sh, GeneratedAt: time.Now()}
+	if err := s.repo.FillReport(ctx, rep); err != nil {
+		return nil, err
+	}
+
+	rep.calcExpectedCash(sh.OpeningFloat)
+
+	if sh.Status == StatusClosed {
+		rep.Type = "Z"
+		rep.CountedCash = sh.CountedCash
+		rep.Variance = sh.Variance
+	}
+	return rep, nil
+}
+
+// Report — X-отчёт по открытой смене или Z-отчёт по закрытой
+func (s *Service) Report(ctx context.Context, userID, shopID, shiftID int) (*Report, error) {
+	sh, err := s.getShift(ctx, userID, shopID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildReport(ctx, sh)
+}
+
+// CloseShift закрывает смену с пересчитанной суммой в кассе и возвращает Z-отчёт
+func (s *Service) CloseShift(ctx context.Context, userID, shopID, shiftID int, req CloseShiftRequest) (*Report, error) {
+	sh, err := s.getShift(ctx, userID, shopID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	if sh.Status != StatusOpen {
+		return nil, ErrShiftClosed
+	}
+	if req.CountedCash < 0 {
+		return nil, fmt.Errorf("сумма в кассе не может быть отрицательной")
+	}
+
+	// Отчёт считается в транзакции закрытия: продажи и возвраты, начатые раньше, в него попадут,
+	// а начатые позже получат ErrShiftClosed
+	counted := payment.Round(req.CountedCash)
+	sh.ClosedBy = userID
+	sh.CountedCash = &counted
+	rep := &Report{Type: "Z", Shift: *sh, GeneratedAt: time.Now()}
+	if err := s.repo.CloseShift(ctx, sh, rep); err != nil {
+		return nil, err
+	}
+
+	_, err = s.fiscal.SubmitShiftClose(ctx, fiscal.ShiftEvent{
+		ShopID: shopID, ShiftID: sh.ID, Cash: counted, CreatedAt: *sh.ClosedAt,
+	})
+	if err != nil {
+		log.Printf("не удалось поставить закрытие смены #%d в очередь ОФД: %v", sh.ID, err)
+	}
+
+	rep.Shift = *sh
+	rep.CountedCash = &counted
+	rep.Variance = sh.Variance
+	return rep, nil
+}
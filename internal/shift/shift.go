@@ -0,0 +1,90 @@
+package shift
+
+import (
+	"crm-backend/internal/payment"
+	"time"
+)
+
+const (
+	StatusOpen   = "open"
+	StatusClosed = "closed"
+
+	MovementCashIn  = "cash_in"
+	MovementCashOut = "cash_out"
+)
+
+// Shift — кассовая смена магазина
+type Shift struct {
+	ID           int        `json:"id"`
+	ShopID       int        `json:"shop_id"`
+	OpenedBy     int        `json:"opened_by"`
+	ClosedBy     int        `json:"closed_by,omitempty"`
+	Status       string     `json:"status"`
+	OpeningFloat float64    `json:"opening_float"`
+	CountedCash  *float64   `json:"counted_cash,omitempty"`
+	ExpectedCash *float64   `json:"expected_cash,omitempty"`
+	Variance     *float64   `json:"variance,omitempty"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+// CashMovement — внесение или изъятие наличных (мелкие расходы, инкассация)
+type CashMovement struct {
+	ID        int       `json:"id"`
+	ShiftID   int       `json:"shift_id"`
+	Type      string    `json:"type"`
+	Amount    float64   `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MethodTotal — итоги смены по способу оплаты
+type MethodTotal struct {
+	Method  payment.Method `json:"method"`
+	Sales   float64        `json:"sales"`
+	Refunds float64        `json:"refunds"`
+	Net     float64        `json:"net"`
+}
+
+// Report — X-отчёт (промежуточный) или Z-отчёт (закрытие смены)
+type Report struct {
+	Type         string        `json:"type"`
+	Shift        Shift         `json:"shift"`
+	SalesCount   int           `json:"sales_count"`
+	SalesTotal   float64       `json:"sales_total"`
+	ReturnsCount int           `json:"returns_count"`
+	ReturnsTotal float64       `json:"returns_total"`
+	ByMethod     []MethodTotal `json:"by_method"`
+	CashIn       float64       `json:"cash_in"`
+	CashOut      float64       `json:"cash_out"`
+	ExpectedCash float64       `json:"expected_cash"`
+	CountedCash  *float64      `json:"counted_cash,omitempty"`
+	Variance     *float64      `json:"variance,omitempty"`
+	GeneratedAt  time.Time     `json:"generated_at"`
+}
+
+// calcExpectedCash — сколько наличных должно быть в кассе: размен, внесения и изъятия, наличные продажи за вычетом возвратов
+func (rep *Report) calcExpectedCash(openingFloat float64) {
+	rep.ExpectedCash = openingFloat + rep.CashIn - rep.CashOut
+	for _, mt := range rep.ByMethod {
+		if mt.Method == payment.MethodCash {
+			rep.ExpectedCash += mt.Net
+		}
+	}
+	rep.ExpectedCash = payment.Round(rep.ExpectedCash)
+}
+
+type OpenShiftRequest struct {
+	OpeningFloat float64 `json:"opening_float"`
+}
+
+type CloseShiftRequest struct {
+	CountedCash float64 `json:"counted_cash"`
+}
+
+type CashMovementRequest struct {
+	Type   string  `json:"type"`
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
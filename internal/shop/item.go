@@ -12,6 +12,7 @@ type Item struct {
 	PurchasePrice float64   `json:"purchase_price" db:"purchase_price"`
 	SalePrice     float64   `json:"sale_price" db:"sale_price"`
 	PhotoURL      string    `json:"photo_url" db:"photo_url"`
+	Stock         int       `json:"stock" db:"stock"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
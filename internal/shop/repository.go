@@ -117,6 +117,8 @@ func (r *Repository) MigrateItems() error {
             created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
             updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
         );
+
+        ALTER TABLE items ADD COLUMN IF NOT EXISTS stock INT NOT NULL DEFAULT 0;
     `)
 	if err != nil {
 		return fmt.Errorf("ошибка миграции items: %w", err)
@@ -154,7 +156,7 @@ func (r *Repository) CreateItem(ctx context.Context, item *Item) error {
 func (r *Repository) GetItems(ctx context.Context, shopID int) ([]Item, error) {
 	rows, err := r.db.Conn.Query(ctx, `
 		SELECT 
-			id, shop_id, name, brand, category, size, purchase_price, sale_price, photo_url, created_at, updated_at
+			id, shop_id, name, brand, category, size, purchase_price, sale_price, photo_url, stock, created_at, updated_at
 		FROM items
 		WHERE shop_id = $1
 		ORDER BY created_at DESC
@@ -178,6 +180,7 @@ func (r *Repository) GetItems(ctx context.Context, shopID int) ([]Item, error) {
 			&it.PurchasePrice,
 			&it.SalePrice,
 			&it.PhotoURL,
+			&it.Stock,
 			&it.CreatedAt,
 			&it.UpdatedAt,
 		)
@@ -194,7 +197,7 @@ func (r *Repository) GetItemByID(ctx context.Context, itemID int) (*Item, error)
 	var it Item
 	err := r.db.Conn.QueryRow(ctx, `
 		SELECT 
-			id, shop_id, name, brand, category, size, purchase_price, sale_price, photo_url, created_at, updated_at
+			id, shop_id, name, brand, category, size, purchase_price, sale_price, photo_url, stock, created_at, updated_at
 		FROM items
 		WHERE id = $1
 	`, itemID).Scan(
@@ -207,6 +210,7 @@ func (r *Repository) GetItemByID(ctx context.Context, itemID int) (*Item, error)
 		&it.PurchasePrice,
 		&it.SalePrice,
 		&it.PhotoURL,
+		&it.Stock,
 		&it.CreatedAt,
 		&it.UpdatedAt,
 	)
@@ -256,3 +260,15 @@ func (r *Repository) DeleteItem(ctx context.Context, itemID int) error {
 	}
 	return nil
 }
+
+// AddStock меняет остаток товара на delta и возвращает новый остаток
+func (r *Repository) AddStock(ctx context.Context, itemID, delta int) (int, error) {
+	var stock int
+	err := r.db.Conn.QueryRow(ctx, `
+		UPDATE items SET stock = stock + $1, updated_at = NOW() WHERE id = $2 RETURNING stock
+	`, delta, itemID).Scan(&stock)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка обновления остатка товара (ID=%d): %w", itemID, err)
+	}
+	return stock, nil
+}
@@ -0,0 +1,272 @@
+package task
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/customer"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrTaskNotFound), errors.Is(err, customer.ErrCustomerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func taskParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	taskID, err := strconv.Atoi(chi.URLParam(r, "task_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID задачи", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, taskID, true
+}
+
+// parseFilter читает общие параметры отбора; defaultStatus подставляется, если status не указан.
+// status=all — задачи в любом статусе.
+func parseFilter(w http.ResponseWriter, r *http.Request, defaultStatus string) (Filter, bool) {
+	q := r.URL.Query()
+	f := Filter{Status: q.Get("status")}
+	switch f.Status {
+	case "":
+		f.Status = defaultStatus
+	case "all":
+		f.Status = ""
+	}
+	f.AssigneeID, _ = strconv.Atoi(q.Get("assignee_id"))
+	f.CustomerID, _ = strconv.Atoi(q.Get("customer_id"))
+	if v := q.Get("due_before"); v != "" {
+		due, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "due_before должен быть в формате RFC3339", http.StatusBadRequest)
+			return f, false
+		}
+		f.DueBefore = &due
+	}
+	return f, true
+}
+
+// CreateTask godoc
+// @Summary Create follow-up task
+// @Description Создаёт задачу магазина, например «позвонить, когда придёт размер M». Покупатель и товар необязательны. Без assignee_id задача назначается автору.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param task body Task true "Задача"
+// @Success 201 {object} Task
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/tasks [post]
+func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var t Task
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateTask(r.Context(), claims.ID, shopID, &t); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// GetShopTasks godoc
+// @Summary List shop tasks
+// @Description Задачи магазина: открытые сверху, по сроку. По умолчанию только открытые; status=all — все.
+// @Tags tasks
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param status query string false "open, done, cancelled или all"
+// @Param assignee_id query int false "Исполнитель"
+// @Param customer_id query int false "Покупатель"
+// @Param due_before query string false "Срок раньше (RFC3339)"
+// @Success 200 {array} Task
+// @Failure 401 {string} string "не авторизован"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/tasks [get]
+func (h *Handler) GetShopTasks(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	f, ok := parseFilter(w, r, StatusOpen)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.service.GetShopTasks(r.Context(), claims.ID, shopID, f)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tasks)
+}
+
+// UpdateTask godoc
+// @Summary Update task
+// @Description Меняет название, заметки, покупателя, товар, исполнителя и срок задачи.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param task_id path int true "Task ID"
+// @Param task body Task true "Задача"
+// @Success 200 {object} Task
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 404 {string} string "задача не найдена"
+// @Router /shops/{id}/tasks/{task_id} [put]
+func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, taskID, ok := taskParams(w, r)
+	if !ok {
+		return
+	}
+
+	var t Task
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+	t.ID = taskID
+
+	if err := h.service.UpdateTask(r.Context(), claims.ID, shopID, &t); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// SetTaskStatus godoc
+// @Summary Change task status
+// @Description Выполнить (done), отменить (cancelled) или снова открыть (open) задачу.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param task_id path int true "Task ID"
+// @Param status body StatusRequest true "Новый статус"
+// @Success 200 {object} Task
+// @Failure 400 {string} string "неизвестный статус задачи"
+// @Failure 404 {string} string "задача не найдена"
+// @Router /shops/{id}/tasks/{task_id}/status [post]
+func (h *Handler) SetTaskStatus(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, taskID, ok := taskParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req StatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.service.SetStatus(r.Context(), claims.ID, shopID, taskID, req.Status)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// MyTasks godoc
+// @Summary My tasks
+// @Description Задачи, назначенные текущему пользователю, во всех магазинах. По умолчанию только открытые; status=all — все. Просроченные отмечены overdue.
+// @Tags tasks
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param status query string false "open, done, cancelled или all"
+// @Param due_before query string false "Срок раньше (RFC3339)"
+// @Success 200 {array} Task
+// @Failure 401 {string} string "не авторизован"
+// @Router /me/tasks [get]
+func (h *Handler) MyTasks(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	f, ok := parseFilter(w, r, StatusOpen)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.service.MyTasks(r.Context(), claims.ID, f)
+	if err != nil {
+		http.Error(w, "ошибка получения задач", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tasks)
+}
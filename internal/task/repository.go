@@ -0,0 +1,162 @@
+package task
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS tasks (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			customer_id INT REFERENCES customers(id) ON DELETE CASCADE,
+			item_id INT REFERENCES items(id) ON DELETE SET NULL,
+			title VARCHAR(255) NOT NULL,
+			notes TEXT,
+			assignee_id INT REFERENCES users(id) ON DELETE SET NULL,
+			due_at TIMESTAMP WITH TIME ZONE,
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			source VARCHAR(20) NOT NULL DEFAULT 'manual',
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			completed_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE INDEX IF NOT EXISTS tasks_assignee_open ON tasks (assignee_id, due_at) WHERE status = 'open';
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции tasks: %w", err)
+	}
+	fmt.Println("Миграция tasks выполнена успешно")
+	return nil
+}
+
+const taskColumns = `
+	id, owner_id, shop_id, customer_id, item_id, title, COALESCE(notes, ''), assignee_id, due_at,
+	status, source, COALESCE(created_by, 0), created_at, completed_at
+`
+
+func scanTasks(rows pgx.Rows) ([]Task, error) {
+	defer rows.Close()
+
+	now := time.Now()
+	var tasks []Task
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.ID, &t.OwnerID, &t.ShopID, &t.CustomerID, &t.ItemID, &t.Title, &t.Notes, &t.AssigneeID,
+			&t.DueAt, &t.Status, &t.Source, &t.CreatedBy, &t.CreatedAt, &t.CompletedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения задачи: %w", err)
+		}
+		t.Overdue = t.Status == StatusOpen && t.DueAt != nil && t.DueAt.Before(now)
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке задач: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *Repository) CreateTask(ctx context.Context, t *Task) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO tasks (owner_id, shop_id, customer_id, item_id, title, notes, assignee_id, due_at, source, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, NULLIF($10, 0))
+		RETURNING id, status, created_at
+	`, t.OwnerID, t.ShopID, t.CustomerID, t.ItemID, t.Title, t.Notes, t.AssigneeID, t.DueAt, t.Source, t.CreatedBy).Scan(&t.ID, &t.Status, &t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания задачи: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) UpdateTask(ctx context.Context, t Task) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE tasks
+		SET customer_id = $1, item_id = $2, title = $3, notes = NULLIF($4, ''), assignee_id = $5, due_at = $6
+		WHERE id = $7 AND shop_id = $8
+	`, t.CustomerID, t.ItemID, t.Title, t.Notes, t.AssigneeID, t.DueAt, t.ID, t.ShopID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления задачи (ID=%d): %w", t.ID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+func (r *Repository) SetStatus(ctx context.Context, shopID, id int, status string) error {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE tasks
+		SET status = $1, completed_at = CASE WHEN $1 = 'open' THEN NULL ELSE NOW() END
+		WHERE id = $2 AND shop_id = $3
+	`, status, id, shopID)
+	if err != nil {
+		return fmt.Errorf("ошибка смены статуса задачи (ID=%d): %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetTask(ctx context.Context, shopID, id int) (*Task, error) {
+	rows, err := r.db.Conn.Query(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND shop_id = $2`, id, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задачи: %w", err)
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrTaskNotFound
+	}
+	return &tasks[0], nil
+}
+
+// GetShopTasks — задачи магазина; открытые сверху, по сроку
+func (r *Repository) GetShopTasks(ctx context.Context, shopID int, f Filter) ([]Task, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks
+		WHERE shop_id = $1
+		  AND ($2 = '' OR status = $2)
+		  AND ($3 = 0 OR assignee_id = $3)
+		  AND ($4 = 0 OR customer_id = $4)
+		  AND ($5::TIMESTAMPTZ IS NULL OR due_at < $5)
+		ORDER BY status = 'open' DESC, due_at NULLS LAST, id
+	`, shopID, f.Status, f.AssigneeID, f.CustomerID, f.DueBefore)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задач магазина: %w", err)
+	}
+	return scanTasks(rows)
+}
+
+// GetAssignedTasks — задачи сотрудника во всех магазинах
+func (r *Repository) GetAssignedTasks(ctx context.Context, userID int, f Filter) ([]Task, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks
+		WHERE assignee_id = $1
+		  AND ($2 = '' OR status = $2)
+		  AND ($3::TIMESTAMPTZ IS NULL OR due_at < $3)
+		ORDER BY status = 'open' DESC, due_at NULLS LAST, id
+	`, userID, f.Status, f.DueBefore)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задач сотрудника: %w", err)
+	}
+	return scanTasks(rows)
+}
@@ -0,0 +1,157 @@
+package task
+
+import (
+	"context"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/shop"
+	"errors"
+	"fmt"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	customers *customer.Repository
+	items     *shop.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository, customers *customer.Repository, items *shop.Repository) *Service {
+	return &Service{repo: repo, employees: employees, customers: customers, items: items}
+}
+
+func (s *Service) checkAccess(ctx context.Context, shopID, userID int) error {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// checkLinks проверяет, что покупатель, товар и исполнитель относятся к магазину задачи
+func (s *Service) checkLinks(ctx context.Context, t *Task) error {
+	if t.CustomerID != nil {
+		ok, err := s.customers.BelongsTo(ctx, *t.CustomerID, t.OwnerID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return customer.ErrCustomerNotFound
+		}
+	}
+	if t.ItemID != nil {
+		item, err := s.items.GetItemByID(ctx, *t.ItemID)
+		if err != nil || item.ShopID != t.ShopID {
+			return fmt.Errorf("товар ID=%d не найден в магазине", *t.ItemID)
+		}
+	}
+	if t.AssigneeID != nil {
+		ok, err := s.employees.HasShopAccess(ctx, t.ShopID, *t.AssigneeID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("исполнитель не работает в этом магазине")
+		}
+	}
+	return nil
+}
+
+func (s *Service) CreateTask(ctx context.Context, userID, shopID int, t *Task) error {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	ownerID, err := s.employees.GetShopOwnerID(ctx, shopID)
+	if err != nil {
+		return err
+	}
+	t.OwnerID = ownerID
+	t.ShopID = shopID
+	t.Source = SourceManual
+	t.CreatedBy = userID
+	if t.AssigneeID == nil {
+		t.AssigneeID = &userID
+	}
+	if err := s.checkLinks(ctx, t); err != nil {
+		return err
+	}
+	return s.repo.CreateTask(ctx, t)
+}
+
+// CreateFollowUp — задача, созданная системой, без проверки прав пользователя.
+// Если исполнитель не указан, задача видна всему магазину.
+func (s *Service) CreateFollowUp(ctx context.Context, t *Task) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	ownerID, err := s.employees.GetShopOwnerID(ctx, t.ShopID)
+	if err != nil {
+		return err
+	}
+	t.OwnerID = ownerID
+	if t.Source == "" {
+		t.Source = SourceManual
+	}
+	return s.repo.CreateTask(ctx, t)
+}
+
+func (s *Service) UpdateTask(ctx context.Context, userID, shopID int, t *Task) error {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	existing, err := s.repo.GetTask(ctx, shopID, t.ID)
+	if err != nil {
+		return err
+	}
+	t.OwnerID = existing.OwnerID
+	t.ShopID = shopID
+	if err := s.checkLinks(ctx, t); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateTask(ctx, *t); err != nil {
+		return err
+	}
+	updated, err := s.repo.GetTask(ctx, shopID, t.ID)
+	if err != nil {
+		return err
+	}
+	*t = *updated
+	return nil
+}
+
+// SetStatus — выполнить, отменить или снова открыть задачу
+func (s *Service) SetStatus(ctx context.Context, userID, shopID, id int, status string) (*Task, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	switch status {
+	case StatusOpen, StatusDone, StatusCancelled:
+	default:
+		return nil, fmt.Errorf("неизвестный статус задачи: %s", status)
+	}
+	if err := s.repo.SetStatus(ctx, shopID, id, status); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTask(ctx, shopID, id)
+}
+
+func (s *Service) GetShopTasks(ctx context.Context, userID, shopID int, f Filter) ([]Task, error) {
+	if err := s.checkAccess(ctx, shopID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetShopTasks(ctx, shopID, f)
+}
+
+// MyTasks — задачи, назначенные пользователю, во всех его магазинах
+func (s *Service) MyTasks(ctx context.Context, userID int, f Filter) ([]Task, error) {
+	return s.repo.GetAssignedTasks(ctx, userID, f)
+}
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	StatusOpen      = "open"
+	StatusDone      = "done"
+	StatusCancelled = "cancelled"
+)
+
+// Источник задачи: создана сотрудником или автоматически (например, товар из листа ожидания поступил в продажу)
+const (
+	SourceManual   = "manual"
+	SourceWaitlist = "waitlist"
+)
+
+var (
+	ErrAccessDenied = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrTaskNotFound = errors.New("задача не найдена")
+)
+
+// Task — задача по покупателю: «позвонить, когда придёт размер M».
+// Покупатель и товар необязательны; исполнитель — сотрудник или владелец магазина.
+type Task struct {
+	ID          int        `json:"id"`
+	OwnerID     int        `json:"owner_id"`
+	ShopID      int        `json:"shop_id"`
+	CustomerID  *int       `json:"customer_id,omitempty"`
+	ItemID      *int       `json:"item_id,omitempty"`
+	Title       string     `json:"title"`
+	Notes       string     `json:"notes,omitempty"`
+	AssigneeID  *int       `json:"assignee_id,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Status      string     `json:"status"`
+	Source      string     `json:"source"`
+	CreatedBy   int        `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Overdue — срок прошёл, а задача ещё открыта
+	Overdue bool `json:"overdue"`
+}
+
+func (t *Task) validate() error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return errors.New("название задачи обязательно")
+	}
+	return nil
+}
+
+// Filter — отбор задач; пустые поля не ограничивают выборку
+type Filter struct {
+	Status     string
+	AssigneeID int
+	CustomerID int
+	// DueBefore — задачи со сроком раньше указанного момента (например, «на сегодня»)
+	DueBefore *time.Time
+}
+
+type StatusRequest struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,294 @@
+package timesheet
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/period"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrNotEmployee):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyClockedIn), errors.Is(err, ErrNotClockedIn), errors.Is(err, ErrOnBreak),
+		errors.Is(err, ErrNotOnBreak):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func shopID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// GetSchedule godoc
+// @Summary Get shop work schedule
+// @Description Недельный график сотрудников магазина (weekday: 1 — понедельник … 7 — воскресенье). Только для владельца.
+// @Tags timesheet
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {array} ScheduleDay
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/schedule [get]
+func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	id, ok := shopID(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := h.service.GetSchedule(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(days)
+}
+
+// SetSchedule godoc
+// @Summary Set employee weekly schedule
+// @Description Заменяет недельный график сотрудника в магазине. Время — "HH:MM", break_minutes — положенный перерыв. Пустой список снимает график.
+// @Tags timesheet
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param user_id path int true "User ID сотрудника"
+// @Param schedule body []ScheduleDay true "Рабочие дни"
+// @Success 200 {array} ScheduleDay
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "пользователь не работает в этом магазине"
+// @Router /shops/{id}/schedule/{user_id} [put]
+func (h *Handler) SetSchedule(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	id, ok := shopID(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
+	if err != nil {
+		http.Error(w, "неправильный ID сотрудника", http.StatusBadRequest)
+		return
+	}
+
+	var days []ScheduleDay
+	if err := json.NewDecoder(r.Body).Decode(&days); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetSchedule(r.Context(), claims.ID, id, userID, days); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	schedule, err := h.service.GetSchedule(r.Context(), claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(schedule)
+}
+
+// CurrentEntry godoc
+// @Summary Get my open work shift
+// @Description Текущая смена сотрудника (приход, перерыв). 204 — сотрудник не на смене.
+// @Tags timesheet
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {object} Entry
+// @Success 204 {string} string "не на смене"
+// @Router /me/timeclock [get]
+func (h *Handler) CurrentEntry(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	e, err := h.service.Current(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения смены", http.StatusInternalServerError)
+		return
+	}
+	if e == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+type clockAction func(s *Service, r *http.Request, userID, shopID int) (*Entry, error)
+
+// clock — отметки делает сам сотрудник своим токеном
+func (h *Handler) clock(w http.ResponseWriter, r *http.Request, do clockAction) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	id, ok := shopID(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := do(h.service, r, claims.ID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// ClockIn godoc
+// @Summary Clock in
+// @Description Сотрудник отмечает приход в магазин. Если по графику смена началась раньше, фиксируется опоздание (late_minutes).
+// @Tags timesheet
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {object} Entry
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 409 {string} string "смена сотрудника уже начата"
+// @Router /shops/{id}/timeclock/clock-in [post]
+func (h *Handler) ClockIn(w http.ResponseWriter, r *http.Request) {
+	h.clock(w, r, func(s *Service, r *http.Request, userID, shopID int) (*Entry, error) {
+		return s.ClockIn(r.Context(), userID, shopID)
+	})
+}
+
+// ClockOut godoc
+// @Summary Clock out
+// @Description Сотрудник отмечает уход. Считаются отработанное время без перерывов и переработка сверх графика.
+// @Tags timesheet
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {object} Entry
+// @Failure 409 {string} string "смена сотрудника не начата"
+// @Router /shops/{id}/timeclock/clock-out [post]
+func (h *Handler) ClockOut(w http.ResponseWriter, r *http.Request) {
+	h.clock(w, r, func(s *Service, r *http.Request, userID, shopID int) (*Entry, error) {
+		return s.ClockOut(r.Context(), userID, shopID)
+	})
+}
+
+// StartBreak godoc
+// @Summary Start break
+// @Description Сотрудник уходит на перерыв.
+// @Tags timesheet
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {object} Entry
+// @Failure 409 {string} string "сотрудник уже на перерыве"
+// @Router /shops/{id}/timeclock/break-start [post]
+func (h *Handler) StartBreak(w http.ResponseWriter, r *http.Request) {
+	h.clock(w, r, func(s *Service, r *http.Request, userID, shopID int) (*Entry, error) {
+		return s.StartBreak(r.Context(), userID, shopID)
+	})
+}
+
+// EndBreak godoc
+// @Summary End break
+// @Description Сотрудник вернулся с перерыва.
+// @Tags timesheet
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Success 200 {object} Entry
+// @Failure 409 {string} string "сотрудник не на перерыве"
+// @Router /shops/{id}/timeclock/break-end [post]
+func (h *Handler) EndBreak(w http.ResponseWriter, r *http.Request) {
+	h.clock(w, r, func(s *Service, r *http.Request, userID, shopID int) (*Entry, error) {
+		return s.EndBreak(r.Context(), userID, shopID)
+	})
+}
+
+// GetTimesheet godoc
+// @Summary Get shop timesheet
+// @Description Табель магазина за период: итоги по сотрудникам (смены, часы по графику и фактически, перерывы, переработка, опоздания) и сами смены. format=csv — выгрузка итогов для расчёта зарплаты.
+// @Tags timesheet
+// @Produce json
+// @Produce text/csv
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param from query string false "Дата начала (YYYY-MM-DD)"
+// @Param to query string false "Дата окончания включительно (YYYY-MM-DD)"
+// @Param format query string false "json (по умолчанию) или csv"
+// @Success 200 {object} Timesheet
+// @Failure 400 {string} string "неправильный период"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/timesheet [get]
+func (h *Handler) GetTimesheet(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+	id, ok := shopID(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := period.FromRequest(r)
+	if err != nil {
+		http.Error(w, "неправильный период", http.StatusBadRequest)
+		return
+	}
+
+	ts, err := h.service.GetTimesheet(r.Context(), claims.ID, id, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=timesheet-%d-%s.csv",
+			id, from.Format("2006-01-02")))
+		_ = WriteCSV(w, ts)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ts)
+}
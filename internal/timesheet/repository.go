@@ -0,0 +1,249 @@
+package timesheet
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS work_schedules (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			weekday INT NOT NULL,
+			starts_at TIME NOT NULL,
+			ends_at TIME NOT NULL,
+			break_minutes INT NOT NULL DEFAULT 0,
+			UNIQUE (shop_id, user_id, weekday)
+		);
+
+		CREATE TABLE IF NOT EXISTS time_entries (
+			id SERIAL PRIMARY KEY,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			clock_in TIMESTAMP WITH TIME ZONE NOT NULL,
+			clock_out TIMESTAMP WITH TIME ZONE,
+			scheduled_start TIMESTAMP WITH TIME ZONE,
+			scheduled_end TIMESTAMP WITH TIME ZONE,
+			scheduled_minutes INT NOT NULL DEFAULT 0,
+			break_minutes INT NOT NULL DEFAULT 0,
+			worked_minutes INT NOT NULL DEFAULT 0,
+			late_minutes INT NOT NULL DEFAULT 0,
+			overtime_minutes INT NOT NULL DEFAULT 0
+		);
+
+		-- Одновременно открыта только одна смена сотрудника
+		CREATE UNIQUE INDEX IF NOT EXISTS time_entries_open ON time_entries (user_id) WHERE clock_out IS NULL;
+		CREATE INDEX IF NOT EXISTS time_entries_shop ON time_entries (shop_id, clock_in);
+
+		CREATE TABLE IF NOT EXISTS time_breaks (
+			id SERIAL PRIMARY KEY,
+			entry_id INT NOT NULL REFERENCES time_entries(id) ON DELETE CASCADE,
+			started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			ended_at TIMESTAMP WITH TIME ZONE
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции timesheet: %w", err)
+	}
+	fmt.Println("Миграция timesheet выполнена успешно")
+	return nil
+}
+
+const employeeName = `TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))`
+
+func (r *Repository) GetSchedule(ctx context.Context, shopID int) ([]ScheduleDay, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT ws.shop_id, ws.user_id, `+employeeName+`, ws.weekday,
+			to_char(ws.starts_at, 'HH24:MI'), to_char(ws.ends_at, 'HH24:MI'), ws.break_minutes
+		FROM work_schedules ws JOIN users u ON u.id = ws.user_id
+		WHERE ws.shop_id = $1
+		ORDER BY ws.user_id, ws.weekday
+	`, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения графика: %w", err)
+	}
+	defer rows.Close()
+
+	var days []ScheduleDay
+	for rows.Next() {
+		var d ScheduleDay
+		if err := rows.Scan(&d.ShopID, &d.UserID, &d.EmployeeName, &d.Weekday, &d.Start, &d.End, &d.BreakMinutes); err != nil {
+			return nil, fmt.Errorf("ошибка чтения графика: %w", err)
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке графика: %w", err)
+	}
+	return days, nil
+}
+
+// GetScheduleDay — график сотрудника на день недели; nil — выходной
+func (r *Repository) GetScheduleDay(ctx context.Context, shopID, userID, weekday int) (*ScheduleDay, error) {
+	d := ScheduleDay{ShopID: shopID, UserID: userID, Weekday: weekday}
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT to_char(starts_at, 'HH24:MI'), to_char(ends_at, 'HH24:MI'), break_minutes
+		FROM work_schedules WHERE shop_id = $1 AND user_id = $2 AND weekday = $3
+	`, shopID, userID, weekday).Scan(&d.Start, &d.End, &d.BreakMinutes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения графика: %w", err)
+	}
+	return &d, nil
+}
+
+// SetSchedule заменяет недельный график сотрудника в магазине
+func (r *Repository) SetSchedule(ctx context.Context, shopID, userID int, days []ScheduleDay) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM work_schedules WHERE shop_id = $1 AND user_id = $2`, shopID, userID); err != nil {
+		return fmt.Errorf("ошибка обновления графика: %w", err)
+	}
+	for _, d := range days {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO work_schedules (shop_id, user_id, weekday, starts_at, ends_at, break_minutes)
+			VALUES ($1, $2, $3, $4::time, $5::time, $6)
+		`, shopID, userID, d.Weekday, d.Start, d.End, d.BreakMinutes)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления графика: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка обновления графика: %w", err)
+	}
+	return nil
+}
+
+const entryColumns = `
+	te.id, te.shop_id, te.user_id, ` + employeeName + `, te.clock_in, te.clock_out, te.scheduled_start, te.scheduled_end,
+	te.scheduled_minutes, te.break_minutes, te.worked_minutes, te.late_minutes, te.overtime_minutes,
+	EXISTS(SELECT 1 FROM time_breaks b WHERE b.entry_id = te.id AND b.ended_at IS NULL)
+`
+
+func scanEntries(rows pgx.Rows) ([]Entry, error) {
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.ShopID, &e.UserID, &e.EmployeeName, &e.ClockIn, &e.ClockOut, &e.ScheduledStart,
+			&e.ScheduledEnd, &e.ScheduledMinutes, &e.BreakMinutes, &e.WorkedMinutes, &e.LateMinutes, &e.OvertimeMinutes,
+			&e.OnBreak); err != nil {
+			return nil, fmt.Errorf("ошибка чтения смены: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке смен: %w", err)
+	}
+	return entries, nil
+}
+
+// GetOpenEntry — незакрытая смена сотрудника в любом магазине; nil — сотрудник не на смене
+func (r *Repository) GetOpenEntry(ctx context.Context, userID int) (*Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+entryColumns+`
+		FROM time_entries te JOIN users u ON u.id = te.user_id
+		WHERE te.user_id = $1 AND te.clock_out IS NULL
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения смены: %w", err)
+	}
+	entries, err := scanEntries(rows)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (r *Repository) CreateEntry(ctx context.Context, e *Entry) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO time_entries (shop_id, user_id, clock_in, scheduled_start, scheduled_end, scheduled_minutes, late_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, e.ShopID, e.UserID, e.ClockIn, e.ScheduledStart, e.ScheduledEnd, e.ScheduledMinutes, e.LateMinutes).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка начала смены: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) StartBreak(ctx context.Context, entryID int, at time.Time) error {
+	_, err := r.db.Conn.Exec(ctx, `INSERT INTO time_breaks (entry_id, started_at) VALUES ($1, $2)`, entryID, at)
+	if err != nil {
+		return fmt.Errorf("ошибка начала перерыва: %w", err)
+	}
+	return nil
+}
+
+// EndBreak закрывает текущий перерыв; false — сотрудник не на перерыве
+func (r *Repository) EndBreak(ctx context.Context, entryID int, at time.Time) (bool, error) {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE time_breaks SET ended_at = GREATEST($2, started_at) WHERE entry_id = $1 AND ended_at IS NULL
+	`, entryID, at)
+	if err != nil {
+		return false, fmt.Errorf("ошибка окончания перерыва: %w", err)
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// BreakDuration — сколько длились перерывы смены (все должны быть закрыты)
+func (r *Repository) BreakDuration(ctx context.Context, entryID int) (time.Duration, error) {
+	var seconds float64
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT COALESCE(SUM(EXTRACT(EPOCH FROM ended_at - started_at)), 0)::float8
+		FROM time_breaks WHERE entry_id = $1 AND ended_at IS NOT NULL
+	`, entryID).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта перерывов: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (r *Repository) CloseEntry(ctx context.Context, e *Entry) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE time_entries
+		SET clock_out = $1, break_minutes = $2, worked_minutes = $3, overtime_minutes = $4
+		WHERE id = $5
+	`, e.ClockOut, e.BreakMinutes, e.WorkedMinutes, e.OvertimeMinutes, e.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка завершения смены: %w", err)
+	}
+	return nil
+}
+
+// GetEntries — закрытые смены магазина, начатые в периоде [from, to)
+func (r *Repository) GetEntries(ctx context.Context, shopID int, from, to time.Time) ([]Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+entryColumns+`
+		FROM time_entries te JOIN users u ON u.id = te.user_id
+		WHERE te.shop_id = $1 AND te.clock_in >= $2 AND te.clock_in < $3 AND te.clock_out IS NOT NULL
+		ORDER BY te.user_id, te.clock_in
+	`, shopID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения смен: %w", err)
+	}
+	return scanEntries(rows)
+}
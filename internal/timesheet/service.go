@@ -0,0 +1,230 @@
+package timesheet
+
+import (
+	"context"
+	"crm-backend/internal/employee"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+}
+
+func NewService(repo *Repository, employees *employee.Repository) *Service {
+	return &Service{repo: repo, employees: employees}
+}
+
+// checkOwner — графики и табель ведёт владелец магазина
+func (s *Service) checkOwner(ctx context.Context, ownerID, shopID int) error {
+	ok, err := s.employees.IsOwner(ctx, shopID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *Service) checkAccess(ctx context.Context, userID, shopID int) error {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *Service) GetSchedule(ctx context.Context, ownerID, shopID int) ([]ScheduleDay, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetSchedule(ctx, shopID)
+}
+
+// SetSchedule заменяет недельный график сотрудника; пустой список — график снят
+func (s *Service) SetSchedule(ctx context.Context, ownerID, shopID, userID int, days []ScheduleDay) error {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return err
+	}
+	ok, err := s.employees.IsEmployee(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotEmployee
+	}
+
+	seen := make(map[int]bool)
+	for i := range days {
+		if err := days[i].validate(); err != nil {
+			return err
+		}
+		if seen[days[i].Weekday] {
+			return fmt.Errorf("день недели %d указан дважды", days[i].Weekday)
+		}
+		seen[days[i].Weekday] = true
+	}
+	return s.repo.SetSchedule(ctx, shopID, userID, days)
+}
+
+// Current — открытая смена сотрудника; nil — сотрудник не на смене
+func (s *Service) Current(ctx context.Context, userID int) (*Entry, error) {
+	return s.repo.GetOpenEntry(ctx, userID)
+}
+
+// ClockIn отмечает приход. Опоздание считается по графику магазина на этот день недели.
+func (s *Service) ClockIn(ctx context.Context, userID, shopID int) (*Entry, error) {
+	if err := s.checkAccess(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	open, err := s.repo.GetOpenEntry(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		return nil, ErrAlreadyClockedIn
+	}
+
+	now := time.Now()
+	day, err := s.repo.GetScheduleDay(ctx, shopID, userID, isoWeekday(now))
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{ShopID: shopID, UserID: userID, ClockIn: now}
+	e.schedule(day)
+	if err := s.repo.CreateEntry(ctx, e); err != nil {
+		// повторный приход из другой вкладки упирается в уникальный индекс
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrAlreadyClockedIn
+		}
+		return nil, err
+	}
+	return s.repo.GetOpenEntry(ctx, userID)
+}
+
+// openEntry — открытая смена сотрудника именно в этом магазине
+func (s *Service) openEntry(ctx context.Context, userID, shopID int) (*Entry, error) {
+	if err := s.checkAccess(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	e, err := s.repo.GetOpenEntry(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.ShopID != shopID {
+		return nil, ErrNotClockedIn
+	}
+	return e, nil
+}
+
+func (s *Service) StartBreak(ctx context.Context, userID, shopID int) (*Entry, error) {
+	e, err := s.openEntry(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if e.OnBreak {
+		return nil, ErrOnBreak
+	}
+	if err := s.repo.StartBreak(ctx, e.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	e.OnBreak = true
+	return e, nil
+}
+
+func (s *Service) EndBreak(ctx context.Context, userID, shopID int) (*Entry, error) {
+	e, err := s.openEntry(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.EndBreak(ctx, e.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotOnBreak
+	}
+	e.OnBreak = false
+	return e, nil
+}
+
+// ClockOut отмечает уход: незакрытый перерыв завершается, считаются отработанное время и переработка
+func (s *Service) ClockOut(ctx context.Context, userID, shopID int) (*Entry, error) {
+	e, err := s.openEntry(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if _, err := s.repo.EndBreak(ctx, e.ID, now); err != nil {
+		return nil, err
+	}
+	breaks, err := s.repo.BreakDuration(ctx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+	e.finish(now, breaks)
+	e.OnBreak = false
+	if err := s.repo.CloseEntry(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetTimesheet — табель магазина за период [from, to)
+func (s *Service) GetTimesheet(ctx context.Context, ownerID, shopID int, from, to time.Time) (*Timesheet, error) {
+	if err := s.checkOwner(ctx, ownerID, shopID); err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.GetEntries(ctx, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return &Timesheet{ShopID: shopID, From: from, To: to, Employees: summarize(entries), Entries: entries}, nil
+}
+
+// WriteCSV выгружает итоги табеля по сотрудникам для расчёта зарплаты (время — в часах)
+func WriteCSV(w io.Writer, ts *Timesheet) error {
+	hours := func(minutes int) string {
+		return strconv.FormatFloat(float64(minutes)/60, 'f', 2, 64)
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"user_id", "employee", "shifts", "scheduled_hours", "worked_hours", "break_hours",
+		"overtime_hours", "late_count", "late_minutes"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, e := range ts.Employees {
+		record := []string{
+			strconv.Itoa(e.UserID),
+			e.EmployeeName,
+			strconv.Itoa(e.Shifts),
+			hours(e.ScheduledMinutes),
+			hours(e.WorkedMinutes),
+			hours(e.BreakMinutes),
+			hours(e.OvertimeMinutes),
+			strconv.Itoa(e.LateCount),
+			strconv.Itoa(e.LateMinutes),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,160 @@
+package timesheet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// lateTolerance — опоздание меньше этого не считается
+const lateTolerance = 5 * time.Minute
+
+const clockLayout = "15:04"
+
+var (
+	ErrAccessDenied     = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrNotEmployee      = errors.New("пользователь не работает в этом магазине")
+	ErrAlreadyClockedIn = errors.New("смена сотрудника уже начата")
+	ErrNotClockedIn     = errors.New("смена сотрудника не начата")
+	ErrOnBreak          = errors.New("сотрудник уже на перерыве")
+	ErrNotOnBreak       = errors.New("сотрудник не на перерыве")
+)
+
+// ScheduleDay — рабочий день сотрудника в недельном графике магазина.
+// Время — местное время сервера, Weekday: 1 — понедельник … 7 — воскресенье.
+type ScheduleDay struct {
+	ShopID       int    `json:"shop_id"`
+	UserID       int    `json:"user_id"`
+	EmployeeName string `json:"employee_name,omitempty"`
+	Weekday      int    `json:"weekday"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	BreakMinutes int    `json:"break_minutes"`
+}
+
+func (d *ScheduleDay) validate() error {
+	if d.Weekday < 1 || d.Weekday > 7 {
+		return fmt.Errorf("день недели должен быть от 1 до 7: %d", d.Weekday)
+	}
+	start, err := time.Parse(clockLayout, d.Start)
+	if err != nil {
+		return fmt.Errorf("неправильное время начала: %s", d.Start)
+	}
+	end, err := time.Parse(clockLayout, d.End)
+	if err != nil {
+		return fmt.Errorf("неправильное время окончания: %s", d.End)
+	}
+	if !end.After(start) {
+		return errors.New("смена должна заканчиваться позже, чем начинается")
+	}
+	if d.BreakMinutes < 0 || time.Duration(d.BreakMinutes)*time.Minute >= end.Sub(start) {
+		return errors.New("перерыв должен быть короче смены")
+	}
+	return nil
+}
+
+// on — начало и конец смены по графику в день t
+func (d *ScheduleDay) on(t time.Time) (time.Time, time.Time) {
+	start, _ := time.Parse(clockLayout, d.Start)
+	end, _ := time.Parse(clockLayout, d.End)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.Add(time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute),
+		day.Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute)
+}
+
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+// Entry — отработанная смена: приход, уход и перерывы
+type Entry struct {
+	ID               int        `json:"id"`
+	ShopID           int        `json:"shop_id"`
+	UserID           int        `json:"user_id"`
+	EmployeeName     string     `json:"employee_name,omitempty"`
+	ClockIn          time.Time  `json:"clock_in"`
+	ClockOut         *time.Time `json:"clock_out,omitempty"`
+	ScheduledStart   *time.Time `json:"scheduled_start,omitempty"`
+	ScheduledEnd     *time.Time `json:"scheduled_end,omitempty"`
+	ScheduledMinutes int        `json:"scheduled_minutes"`
+	BreakMinutes     int        `json:"break_minutes"`
+	WorkedMinutes    int        `json:"worked_minutes"`
+	LateMinutes      int        `json:"late_minutes"`
+	OvertimeMinutes  int        `json:"overtime_minutes"`
+	OnBreak          bool       `json:"on_break"`
+}
+
+// schedule фиксирует в смене график дня и опоздание
+func (e *Entry) schedule(d *ScheduleDay) {
+	if d == nil {
+		return
+	}
+	start, end := d.on(e.ClockIn)
+	e.ScheduledStart = &start
+	e.ScheduledEnd = &end
+	e.ScheduledMinutes = int(end.Sub(start)/time.Minute) - d.BreakMinutes
+	if late := e.ClockIn.Sub(start); late > lateTolerance {
+		e.LateMinutes = int(late / time.Minute)
+	}
+}
+
+// finish считает отработанное время и переработку сверх графика.
+// Смена вне графика целиком считается переработкой.
+func (e *Entry) finish(clockOut time.Time, breaks time.Duration) {
+	e.ClockOut = &clockOut
+	e.BreakMinutes = int(breaks / time.Minute)
+	e.WorkedMinutes = max(int((clockOut.Sub(e.ClockIn)-breaks)/time.Minute), 0)
+	e.OvertimeMinutes = max(e.WorkedMinutes-e.ScheduledMinutes, 0)
+}
+
+// Summary — итоги сотрудника за период для расчёта зарплаты
+type Summary struct {
+	UserID           int    `json:"user_id"`
+	EmployeeName     string `json:"employee_name"`
+	Shifts           int    `json:"shifts"`
+	ScheduledMinutes int    `json:"scheduled_minutes"`
+	WorkedMinutes    int    `json:"worked_minutes"`
+	BreakMinutes     int    `json:"break_minutes"`
+	OvertimeMinutes  int    `json:"overtime_minutes"`
+	LateCount        int    `json:"late_count"`
+	LateMinutes      int    `json:"late_minutes"`
+}
+
+// Timesheet — табель магазина за период. Учитываются только закрытые смены.
+type Timesheet struct {
+	ShopID    int       `json:"shop_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Employees []Summary `json:"employees"`
+	Entries   []Entry   `json:"entries"`
+}
+
+func summarize(entries []Entry) []Summary {
+	byUser := make(map[int]*Summary)
+	var order []int
+	for _, e := range entries {
+		s, ok := byUser[e.UserID]
+		if !ok {
+			s = &Summary{UserID: e.UserID, EmployeeName: e.EmployeeName}
+			byUser[e.UserID] = s
+			order = append(order, e.UserID)
+		}
+		s.Shifts++
+		s.ScheduledMinutes += e.ScheduledMinutes
+		s.WorkedMinutes += e.WorkedMinutes
+		s.BreakMinutes += e.BreakMinutes
+		s.OvertimeMinutes += e.OvertimeMinutes
+		s.LateMinutes += e.LateMinutes
+		if e.LateMinutes > 0 {
+			s.LateCount++
+		}
+	}
+	result := make([]Summary, 0, len(order))
+	for _, id := range order {
+		result = append(result, *byUser[id])
+	}
+	return result
+}
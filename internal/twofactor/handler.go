@@ -0,0 +1,296 @@
+package twofactor
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/lockout"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrInvalidChallenge):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrWrongPassword), errors.Is(err, ErrRequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyEnabled), errors.Is(err, ErrNotEnabled), errors.Is(err, ErrNotSetUp):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// LoginSetup godoc
+// @Summary Set up 2FA during login
+// @Description Для ролей с обязательной 2FA, которые её ещё не подключили (enrollment_required в ответе /auth/login): выдаёт секрет и ссылку otpauth:// для QR-кода. Затем первый код из приложения отправляется в /auth/login/2fa.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginRequest true "Токен второго шага (challenge_token)"
+// @Success 200 {object} Setup
+// @Failure 401 {string} string "время на подтверждение входа истекло, войдите заново"
+// @Failure 409 {string} string "двухфакторная аутентификация уже включена"
+// @Router /auth/login/2fa/setup [post]
+func (h *Handler) LoginSetup(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	setup, err := h.service.LoginSetup(r.Context(), req.ChallengeToken)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(setup)
+}
+
+// CompleteLogin godoc
+// @Summary Complete login with 2FA code
+// @Description Второй шаг входа: код из приложения-аутентификатора или один из резервных кодов. Токен второго шага действует 5 минут и сгорает после 5 неверных кодов; неверные коды учитываются в блокировке входа. Если 2FA подключалась при этом входе, в ответе — резервные коды (показываются один раз).
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginRequest true "Токен второго шага и код"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {string} string "неверный код"
+// @Failure 401 {string} string "время на подтверждение входа истекло, войдите заново"
+// @Failure 429 {string} string "слишком много неудачных попыток входа, попробуйте позже"
+// @Router /auth/login/2fa [post]
+func (h *Handler) CompleteLogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.service.CompleteLogin(r.Context(), auth.ClientIP(r), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// GetStatus godoc
+// @Summary Get my 2FA status
+// @Description Включена ли 2FA, обязательна ли она для роли и сколько осталось резервных кодов.
+// @Tags profile
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {object} Status
+// @Failure 401 {string} string "не авторизован"
+// @Router /me/2fa [get]
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	st, err := h.service.Status(r.Context(), claims.ID, claims.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// Setup godoc
+// @Summary Start 2FA setup
+// @Description Выдаёт новый секрет и ссылку otpauth:// для QR-кода в приложении-аутентификаторе. Нужен текущий пароль. 2FA включается после подтверждения первым кодом.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body PasswordRequest true "Текущий пароль"
+// @Success 200 {object} Setup
+// @Failure 403 {string} string "неверный текущий пароль"
+// @Failure 409 {string} string "двухфакторная аутентификация уже включена"
+// @Router /me/2fa/setup [post]
+func (h *Handler) Setup(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req PasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	setup, err := h.service.Setup(r.Context(), claims.ID, req.CurrentPassword)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(setup)
+}
+
+// Enable godoc
+// @Summary Enable 2FA
+// @Description Включает 2FA первым кодом из приложения. В ответе — 10 резервных кодов, они показываются один раз. Неверные коды учитываются в блокировке входа.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body CodeRequest true "Код из приложения"
+// @Success 200 {object} RecoveryCodes
+// @Failure 400 {string} string "неверный код"
+// @Failure 409 {string} string "сначала получите секрет для приложения-аутентификатора"
+// @Failure 429 {string} string "слишком много неудачных попыток входа, попробуйте позже"
+// @Router /me/2fa/enable [post]
+func (h *Handler) Enable(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req CodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.service.Enable(r.Context(), claims.ID, auth.ClientIP(r), req.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RecoveryCodes{RecoveryCodes: codes})
+}
+
+// Disable godoc
+// @Summary Disable 2FA
+// @Description Отключает 2FA по текущему паролю и коду из приложения. Для ролей с обязательной 2FA недоступно.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body DisableRequest true "Текущий пароль и код"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "неверный код"
+// @Failure 403 {string} string "для вашей роли двухфакторная аутентификация обязательна"
+// @Failure 429 {string} string "слишком много неудачных попыток входа, попробуйте позже"
+// @Router /me/2fa/disable [post]
+func (h *Handler) Disable(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req DisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Disable(r.Context(), claims.ID, claims.Role, auth.ClientIP(r), &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Выдаёт 10 новых резервных кодов по коду из приложения; прежние перестают действовать.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param request body CodeRequest true "Код из приложения"
+// @Success 200 {object} RecoveryCodes
+// @Failure 400 {string} string "неверный код"
+// @Failure 429 {string} string "слишком много неудачных попыток входа, попробуйте позже"
+// @Router /me/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	var req CodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), claims.ID, auth.ClientIP(r), req.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RecoveryCodes{RecoveryCodes: codes})
+}
+
+// ResetUser godoc
+// @Summary Reset user 2FA
+// @Description Отключает 2FA пользователя, потерявшего телефон и резервные коды. Если для роли 2FA обязательна, при следующем входе её нужно подключить заново. Только для superadmin.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "пользователь не найден"
+// @Router /admin/users/{id}/2fa [delete]
+func (h *Handler) ResetUser(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil || claims.Role != "superadmin" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Reset(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+}
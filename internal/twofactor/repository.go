@@ -0,0 +1,286 @@
+package twofactor
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS user_totp (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret VARCHAR(64) NOT NULL,
+			enabled_at TIMESTAMP WITH TIME ZONE,
+			last_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS recovery_codes (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash VARCHAR(64) NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE INDEX IF NOT EXISTS recovery_codes_user ON recovery_codes (user_id);
+
+		CREATE TABLE IF NOT EXISTS login_challenges (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			purpose VARCHAR(20) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции user_totp: %w", err)
+	}
+	fmt.Println("Миграция user_totp выполнена успешно")
+	return nil
+}
+
+func (r *Repository) GetAccount(ctx context.Context, userID int) (*Account, error) {
+	var a Account
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT id, email, role, password_hash FROM users WHERE id = $1
+	`, userID).Scan(&a.ID, &a.Email, &a.Role, &a.PasswordHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	return &a, nil
+}
+
+// GetSecret — секрет пользователя; nil — 2FA не подключалась
+func (r *Repository) GetSecret(ctx context.Context, userID int) (*Secret, error) {
+	var t Secret
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT secret, enabled_at IS NOT NULL, last_step FROM user_totp WHERE user_id = $1
+	`, userID).Scan(&t.Secret, &t.Enabled, &t.LastStep)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек 2FA: %w", err)
+	}
+	return &t, nil
+}
+
+// SaveSecret сохраняет новый неподтверждённый секрет. Секрет включённой 2FA не перезаписывается.
+func (r *Repository) SaveSecret(ctx context.Context, userID int, secret string) error {
+	tag, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = NOW()
+		WHERE user_totp.enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения секрета 2FA: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyEnabled
+	}
+	return nil
+}
+
+// Enable включает 2FA с кодом из интервала step и выдаёт новые резервные коды
+func (r *Repository) Enable(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_totp SET enabled_at = NOW(), last_step = $2 WHERE user_id = $1 AND enabled_at IS NULL
+	`, userID, step)
+	if err != nil {
+		return fmt.Errorf("ошибка включения 2FA: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyEnabled
+	}
+	if err := replaceCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка включения 2FA: %w", err)
+	}
+	return nil
+}
+
+func replaceCodes(ctx context.Context, tx pgx.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("ошибка замены резервных кодов: %w", err)
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)
+		`, userID, h); err != nil {
+			return fmt.Errorf("ошибка замены резервных кодов: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes заменяет все резервные коды пользователя новыми
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка замены резервных кодов: %w", err)
+	}
+	return nil
+}
+
+// UseStep запоминает интервал принятого кода; false — код из этого интервала уже использован
+func (r *Repository) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE user_totp SET last_step = $2 WHERE user_id = $1 AND last_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки кода 2FA: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UseRecoveryCode гасит резервный код; false — такого неиспользованного кода нет
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM recovery_codes WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL LIMIT 1
+		)
+	`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки резервного кода: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *Repository) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL
+	`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта резервных кодов: %w", err)
+	}
+	return n, nil
+}
+
+// Delete отключает 2FA: удаляет секрет, резервные коды и незавершённые входы
+func (r *Repository) Delete(ctx context.Context, userID int) error {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, q := range []string{
+		`DELETE FROM user_totp WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM login_challenges WHERE user_id = $1`,
+	} {
+		if _, err := tx.Exec(ctx, q, userID); err != nil {
+			return fmt.Errorf("ошибка отключения 2FA: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка отключения 2FA: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) CreateChallenge(ctx context.Context, c *PendingLogin, tokenHash string) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO login_challenges (user_id, token_hash, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, c.UserID, tokenHash, c.Purpose, c.ExpiresAt).Scan(&c.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка создания входа: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetChallenge(ctx context.Context, tokenHash string) (*PendingLogin, error) {
+	var c PendingLogin
+	err := r.db.Conn.QueryRow(ctx, `
+		SELECT id, user_id, purpose, attempts, expires_at, used_at FROM login_challenges WHERE token_hash = $1
+	`, tokenHash).Scan(&c.ID, &c.UserID, &c.Purpose, &c.Attempts, &c.ExpiresAt, &c.UsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения входа: %w", err)
+	}
+	return &c, nil
+}
+
+// ClaimChallengeAttempt расходует попытку второго шага до проверки кода: параллельные запросы
+// не проверят больше maxAttempts кодов. false — попытки кончились или вход уже завершён.
+func (r *Repository) ClaimChallengeAttempt(ctx context.Context, id, maxAttempts int) (bool, error) {
+	var attempts int
+	err := r.db.Conn.QueryRow(ctx, `
+		UPDATE login_challenges SET attempts = attempts + 1
+		WHERE id = $1 AND attempts < $2 AND used_at IS NULL
+		RETURNING attempts
+	`, id, maxAttempts).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка обновления входа: %w", err)
+	}
+	return true, nil
+}
+
+// UseChallenge гасит токен второго шага; уже использованный параллельным запросом даёт ErrInvalidChallenge
+func (r *Repository) UseChallenge(ctx context.Context, id int, at time.Time) error {
+	tag, err := r.db.Conn.Exec(ctx, `
+		UPDATE login_challenges SET used_at = $2 WHERE id = $1 AND used_at IS NULL
+	`, id, at)
+	if err != nil {
+		return fmt.Errorf("ошибка завершения входа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// DeleteOld удаляет токены второго шага, истёкшие раньше before
+func (r *Repository) DeleteOld(ctx context.Context, before time.Time) error {
+	if _, err := r.db.Conn.Exec(ctx, `DELETE FROM login_challenges WHERE expires_at < $1`, before); err != nil {
+		return fmt.Errorf("ошибка очистки входов: %w", err)
+	}
+	return nil
+}
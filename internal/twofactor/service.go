@@ -0,0 +1,415 @@
+package twofactor
+
+import (
+	"context"
+	"crm-backend/internal/auth"
+	"crm-backend/internal/lockout"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	repo     *Repository
+	guard    *lockout.Service
+	required map[string]bool
+}
+
+// NewService — requiredRoles: роли через запятую, для которых 2FA обязательна (например "owner,superadmin").
+// Остальные подключают её по желанию.
+func NewService(repo *Repository, guard *lockout.Service, requiredRoles string) *Service {
+	required := map[string]bool{}
+	for _, role := range strings.Split(requiredRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			required[role] = true
+		}
+	}
+	return &Service{repo: repo, guard: guard, required: required}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeRecoveryCode — коды принимаются без учёта регистра, дефисов и пробелов
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// newRecoveryCodes — коды вида abcde-fghij и их хэши для хранения
+func newRecoveryCodes() ([]string, []string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("ошибка генерации резервных кодов: %w", err)
+		}
+		code := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hash(code)
+	}
+	return codes, hashes, nil
+}
+
+// Required — обязательна ли 2FA для роли
+func (s *Service) Required(role string) bool {
+	return s.required[role]
+}
+
+// Begin вызывается после верного пароля. Возвращает nil, если второй шаг не нужен: 2FA не подключена
+// и для роли не обязательна. Если обязательна, но не подключена, второй шаг начинается с подключения.
+func (s *Service) Begin(ctx context.Context, userID int, role string) (*Challenge, error) {
+	secret, err := s.repo.GetSecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := secret != nil && secret.Enabled
+	if !enabled && !s.Required(role) {
+		return nil, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	pending := &PendingLogin{UserID: userID, Purpose: PurposeVerify, ExpiresAt: time.Now().Add(challengeTTL)}
+	if !enabled {
+		pending.Purpose = PurposeEnroll
+	}
+	if err := s.repo.CreateChallenge(ctx, pending, hash(token)); err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: pending.Purpose == PurposeEnroll,
+		ChallengeToken:     token,
+		ExpiresAt:          pending.ExpiresAt,
+	}, nil
+}
+
+// pendingLogin — действующий незавершённый вход по токену второго шага
+func (s *Service) pendingLogin(ctx context.Context, token string, now time.Time) (*PendingLogin, error) {
+	if token == "" {
+		return nil, ErrInvalidChallenge
+	}
+	pending, err := s.repo.GetChallenge(ctx, hash(token))
+	if err != nil {
+		return nil, err
+	}
+	if pending.UsedAt != nil || !now.Before(pending.ExpiresAt) || pending.Attempts >= maxChallengeAttempts {
+		return nil, ErrInvalidChallenge
+	}
+	return pending, nil
+}
+
+// LoginSetup выдаёт секрет для приложения, когда 2FA обязательна и подключается прямо при входе
+func (s *Service) LoginSetup(ctx context.Context, token string) (*Setup, error) {
+	pending, err := s.pendingLogin(ctx, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if pending.Purpose != PurposeEnroll {
+		return nil, ErrAlreadyEnabled
+	}
+	acc, err := s.repo.GetAccount(ctx, pending.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.setup(ctx, acc)
+}
+
+// CompleteLogin — второй шаг входа. Каждая проверка кода расходует попытку (занимается до проверки),
+// а неверный код учитывается в счётчиках неудачных входов, как неверный пароль.
+func (s *Service) CompleteLogin(ctx context.Context, ip string, req *LoginRequest) (*LoginResponse, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	pending, err := s.pendingLogin(ctx, req.ChallengeToken, now)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := s.repo.GetAccount(ctx, pending.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLock(ctx, acc, ip); err != nil {
+		return nil, err
+	}
+	claimed, err := s.repo.ClaimChallengeAttempt(ctx, pending.ID, maxChallengeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrInvalidChallenge
+	}
+	secret, err := s.repo.GetSecret(ctx, acc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &LoginResponse{}
+	switch {
+	case pending.Purpose == PurposeEnroll:
+		resp.RecoveryCodes, err = s.enable(ctx, acc.ID, secret, req.Code)
+	case req.RecoveryCode != "":
+		err = s.useRecoveryCode(ctx, acc.ID, req.RecoveryCode)
+	default:
+		err = s.verify(ctx, acc.ID, secret, req.Code)
+	}
+	if errors.Is(err, ErrInvalidCode) {
+		s.failed(ctx, acc, ip)
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UseChallenge(ctx, pending.ID, now); err != nil {
+		return nil, err
+	}
+	resp.Token, err = auth.GenerateJWT(acc.ID, acc.Email, acc.Role)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	if err := s.guard.Success(ctx, acc.ID, acc.Email, ip); err != nil {
+		log.Printf("ошибка записи входа пользователя ID=%d: %v", acc.ID, err)
+	}
+	return resp, nil
+}
+
+func (s *Service) checkLock(ctx context.Context, acc *Account, ip string) error {
+	wait, err := s.guard.Check(ctx, acc.Email, ip)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return &lockout.LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *Service) failed(ctx context.Context, acc *Account, ip string) {
+	if err := s.guard.Failure(ctx, acc.ID, acc.Email, ip, lockout.ReasonWrongCode); err != nil {
+		log.Printf("ошибка учёта неверного кода 2FA: %v", err)
+	}
+}
+
+// verify проверяет код из приложения; один и тот же код дважды не принимается
+func (s *Service) verify(ctx context.Context, userID int, secret *Secret, code string) error {
+	if secret == nil || !secret.Enabled {
+		return ErrNotEnabled
+	}
+	step, ok := auth.VerifyTOTP(secret.Secret, code, time.Now(), secret.LastStep)
+	if !ok {
+		return ErrInvalidCode
+	}
+	fresh, err := s.repo.UseStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (s *Service) useRecoveryCode(ctx context.Context, userID int, code string) error {
+	ok, err := s.repo.UseRecoveryCode(ctx, userID, hash(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	log.Printf("пользователь ID=%d вошёл по резервному коду", userID)
+	return nil
+}
+
+// enable подтверждает неподтверждённый секрет первым кодом и выдаёт резервные коды
+func (s *Service) enable(ctx context.Context, userID int, secret *Secret, code string) ([]string, error) {
+	if secret == nil {
+		return nil, ErrNotSetUp
+	}
+	if secret.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	step, ok := auth.VerifyTOTP(secret.Secret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *Service) setup(ctx context.Context, acc *Account) (*Setup, error) {
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveSecret(ctx, acc.ID, secret); err != nil {
+		return nil, err
+	}
+	return &Setup{Secret: secret, URI: auth.TOTPURI(issuer, acc.Email, secret)}, nil
+}
+
+// checkPassword загружает учётную запись и проверяет текущий пароль
+func (s *Service) checkPassword(ctx context.Context, userID int, password string) (*Account, error) {
+	acc, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if password == "" || !auth.CheckPassword(acc.PasswordHash, password) {
+		return nil, ErrWrongPassword
+	}
+	return acc, nil
+}
+
+// checkCode проверяет код из приложения для действий в профиле. Неверные коды учитываются
+// в счётчиках неудачных входов, чтобы код нельзя было подобрать из открытой сессии.
+func (s *Service) checkCode(ctx context.Context, acc *Account, ip, code string) error {
+	if err := s.checkLock(ctx, acc, ip); err != nil {
+		return err
+	}
+	secret, err := s.repo.GetSecret(ctx, acc.ID)
+	if err != nil {
+		return err
+	}
+	err = s.verify(ctx, acc.ID, secret, code)
+	if errors.Is(err, ErrInvalidCode) {
+		s.failed(ctx, acc, ip)
+	}
+	return err
+}
+
+func (s *Service) Status(ctx context.Context, userID int, role string) (*Status, error) {
+	secret, err := s.repo.GetSecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Enabled: secret != nil && secret.Enabled, Required: s.Required(role)}
+	if st.Enabled {
+		if st.RecoveryCodesLeft, err = s.repo.RecoveryCodesLeft(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// Setup выдаёт новый секрет для подключения 2FA из профиля; нужен текущий пароль
+func (s *Service) Setup(ctx context.Context, userID int, password string) (*Setup, error) {
+	acc, err := s.checkPassword(ctx, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.setup(ctx, acc)
+}
+
+// Enable включает 2FA первым кодом из приложения и возвращает резервные коды — они показываются один раз.
+// Неверные коды учитываются в счётчиках неудачных входов, как и при входе.
+func (s *Service) Enable(ctx context.Context, userID int, ip, code string) ([]string, error) {
+	acc, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLock(ctx, acc, ip); err != nil {
+		return nil, err
+	}
+	secret, err := s.repo.GetSecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := s.enable(ctx, userID, secret, code)
+	if errors.Is(err, ErrInvalidCode) {
+		s.failed(ctx, acc, ip)
+	}
+	return codes, err
+}
+
+// Disable отключает 2FA по паролю и коду из приложения; для ролей с обязательной 2FA запрещено
+func (s *Service) Disable(ctx context.Context, userID int, role, ip string, req *DisableRequest) error {
+	if s.Required(role) {
+		return ErrRequired
+	}
+	acc, err := s.checkPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCode(ctx, acc, ip, req.Code); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, userID)
+}
+
+// RegenerateRecoveryCodes заменяет резервные коды новыми; прежние перестают действовать
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID int, ip, code string) ([]string, error) {
+	acc, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(ctx, acc, ip, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Reset — сброс 2FA администратором, когда пользователь потерял телефон и резервные коды.
+// Если для роли 2FA обязательна, при следующем входе её нужно будет подключить заново.
+func (s *Service) Reset(ctx context.Context, userID int) error {
+	if _, err := s.repo.GetAccount(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		return err
+	}
+	log.Printf("2FA пользователя ID=%d сброшена администратором", userID)
+	return nil
+}
+
+// RunCleanup раз в interval удаляет токены второго шага, истёкшие больше суток назад
+func (s *Service) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repo.DeleteOld(ctx, time.Now().Add(-24*time.Hour)); err != nil {
+				log.Printf("ошибка очистки входов 2FA: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package twofactor
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// issuer — название сервиса в приложении-аутентификаторе
+	issuer = "CRM"
+	// challengeTTL — сколько действует токен второго шага входа
+	challengeTTL = 5 * time.Minute
+	// maxChallengeAttempts — после стольких проверок кода токен второго шага сгорает
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+// Purpose — что нужно сделать на втором шаге входа
+type Purpose string
+
+const (
+	// PurposeVerify — ввести код из приложения или резервный код
+	PurposeVerify Purpose = "verify"
+	// PurposeEnroll — для роли 2FA обязательна, но ещё не подключена: сначала подключить приложение
+	PurposeEnroll Purpose = "enroll"
+)
+
+var (
+	ErrInvalidChallenge = errors.New("время на подтверждение входа истекло, войдите заново")
+	ErrInvalidCode      = errors.New("неверный код")
+	ErrAlreadyEnabled   = errors.New("двухфакторная аутентификация уже включена")
+	ErrNotEnabled       = errors.New("двухфакторная аутентификация не включена")
+	ErrNotSetUp         = errors.New("сначала получите секрет для приложения-аутентификатора")
+	ErrRequired         = errors.New("для вашей роли двухфакторная аутентификация обязательна")
+	ErrWrongPassword    = errors.New("неверный текущий пароль")
+	ErrUserNotFound     = errors.New("пользователь не найден")
+)
+
+// Challenge — ответ на верный пароль, когда нужен второй шаг входа
+type Challenge struct {
+	TwoFactorRequired  bool      `json:"two_factor_required"`
+	EnrollmentRequired bool      `json:"enrollment_required,omitempty"`
+	ChallengeToken     string    `json:"challenge_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// Setup — секрет для приложения-аутентификатора: uri показывается QR-кодом, secret — для ручного ввода
+type Setup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type Status struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type PasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code"`
+}
+
+// LoginRequest — второй шаг входа: код из приложения или один из резервных кодов
+type LoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+func (r *LoginRequest) validate() error {
+	if r.ChallengeToken == "" {
+		return ErrInvalidChallenge
+	}
+	if r.Code == "" && r.RecoveryCode == "" {
+		return errors.New("укажите код из приложения или резервный код")
+	}
+	return nil
+}
+
+// LoginResponse — токен после второго шага. Резервные коды возвращаются, только если 2FA подключена при этом входе.
+type LoginResponse struct {
+	Token         string   `json:"token"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Secret — секрет пользователя; до подтверждения первым кодом Enabled = false
+type Secret struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// PendingLogin — незавершённый вход, ожидающий второго шага
+type PendingLogin struct {
+	ID        int
+	UserID    int
+	Purpose   Purpose
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type Account struct {
+	ID           int
+	Email        string
+	Role         string
+	PasswordHash string
+}
@@ -0,0 +1,294 @@
+package waitlist
+
+import (
+	"crm-backend/internal/auth"
+	"crm-backend/internal/customer"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrEntryNotFound), errors.Is(err, ErrItemNotFound), errors.Is(err, customer.ErrCustomerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEntryClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func pathIDs(w http.ResponseWriter, r *http.Request, param, message string) (int, int, bool) {
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, param))
+	if err != nil {
+		http.Error(w, message, http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return shopID, id, true
+}
+
+// AddEntry godoc
+// @Summary Add customer to waitlist
+// @Description Записывает покупателя в очередь на товар (конкретный размер) в магазине. Канал уведомления: sms (по умолчанию), email или telegram. Если товар уже есть в наличии, он сразу откладывается.
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param entry body Entry true "Запись"
+// @Success 201 {object} Entry
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 403 {string} string "доступ запрещён"
+// @Failure 404 {string} string "товар не найден в магазине"
+// @Router /shops/{id}/waitlist [post]
+func (h *Handler) AddEntry(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+
+	var e Entry
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.AddEntry(r.Context(), claims.ID, shopID, &e); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// GetEntries godoc
+// @Summary List shop waitlist
+// @Description Лист ожидания магазина по очереди. По умолчанию — активные записи (waiting и held).
+// @Tags waitlist
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param item_id query int false "Товар"
+// @Param status query string false "waiting, held, fulfilled, expired или cancelled"
+// @Success 200 {array} Entry
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /shops/{id}/waitlist [get]
+func (h *Handler) GetEntries(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "неправильный ID магазина", http.StatusBadRequest)
+		return
+	}
+	itemID, _ := strconv.Atoi(r.URL.Query().Get("item_id"))
+
+	entries, err := h.service.GetEntries(r.Context(), claims.ID, shopID, itemID, r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+func (h *Handler) closeEntry(w http.ResponseWriter, r *http.Request, status string) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, entryID, ok := pathIDs(w, r, "entry_id", "неправильный ID записи")
+	if !ok {
+		return
+	}
+
+	e, err := h.service.Close(r.Context(), claims.ID, shopID, entryID, status)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// FulfillEntry godoc
+// @Summary Mark waitlist entry fulfilled
+// @Description Покупатель забрал отложенный для него товар.
+// @Tags waitlist
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param entry_id path int true "Entry ID"
+// @Success 200 {object} Entry
+// @Failure 404 {string} string "запись листа ожидания не найдена"
+// @Failure 409 {string} string "запись листа ожидания уже закрыта"
+// @Router /shops/{id}/waitlist/{entry_id}/fulfill [post]
+func (h *Handler) FulfillEntry(w http.ResponseWriter, r *http.Request) {
+	h.closeEntry(w, r, StatusFulfilled)
+}
+
+// CancelEntry godoc
+// @Summary Cancel waitlist entry
+// @Description Снимает покупателя с очереди. Если товар был отложен, он переходит следующему в очереди.
+// @Tags waitlist
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param entry_id path int true "Entry ID"
+// @Success 200 {object} Entry
+// @Failure 404 {string} string "запись листа ожидания не найдена"
+// @Failure 409 {string} string "запись листа ожидания уже закрыта"
+// @Router /shops/{id}/waitlist/{entry_id}/cancel [post]
+func (h *Handler) CancelEntry(w http.ResponseWriter, r *http.Request) {
+	h.closeEntry(w, r, StatusCancelled)
+}
+
+// ReceiveStock godoc
+// @Summary Receive item stock
+// @Description Приёмка товара: увеличивает остаток. Поступившие единицы по очереди откладываются покупателям из листа ожидания — им уходит уведомление, а сотруднику, записавшему покупателя, — задача перезвонить.
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param id path int true "Shop ID"
+// @Param item_id path int true "Item ID"
+// @Param receipt body ReceiveRequest true "Количество"
+// @Success 200 {object} ReceiveResult
+// @Failure 400 {string} string "количество должно быть больше нуля"
+// @Failure 404 {string} string "товар не найден в магазине"
+// @Router /shops/{id}/items/{item_id}/receive [post]
+func (h *Handler) ReceiveStock(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	shopID, itemID, ok := pathIDs(w, r, "item_id", "неправильный ID товара")
+	if !ok {
+		return
+	}
+
+	var req ReceiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.service.ReceiveStock(r.Context(), claims.ID, shopID, itemID, req.Quantity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// ownerClaims — настройки листа ожидания меняет только владелец
+func ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, _ := auth.GetUserFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "не авторизован", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != "owner" {
+		http.Error(w, "доступ запрещён", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// GetSettings godoc
+// @Summary Get waitlist settings
+// @Description Настройки листа ожидания: на сколько часов откладывается поступивший товар (по умолчанию 24).
+// @Tags waitlist
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Success 200 {object} Settings
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/waitlist/settings [get]
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	st, err := h.service.GetSettings(r.Context(), claims.ID)
+	if err != nil {
+		http.Error(w, "ошибка получения настроек", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// SaveSettings godoc
+// @Summary Update waitlist settings
+// @Description Меняет срок, на который откладывается поступивший товар (1–336 часов).
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer JWT token"
+// @Param settings body Settings true "Настройки"
+// @Success 200 {object} Settings
+// @Failure 400 {string} string "неправильный формат данных"
+// @Failure 403 {string} string "доступ запрещён"
+// @Router /owner/waitlist/settings [put]
+func (h *Handler) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ownerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var st Settings
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		http.Error(w, "неправильный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SaveSettings(r.Context(), claims.ID, &st); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
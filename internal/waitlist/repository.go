@@ -0,0 +1,307 @@
+package waitlist
+
+import (
+	"context"
+	"crm-backend/internal/db"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Migrate() error {
+	_, err := r.db.Conn.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS waitlist_settings (
+			owner_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			hold_hours INT NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS waitlist_entries (
+			id SERIAL PRIMARY KEY,
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id INT NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+			customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			quantity INT NOT NULL DEFAULT 1,
+			channel VARCHAR(20) NOT NULL,
+			notes TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'waiting',
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			notified_at TIMESTAMP WITH TIME ZONE,
+			notify_error TEXT,
+			hold_until TIMESTAMP WITH TIME ZONE,
+			closed_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE INDEX IF NOT EXISTS waitlist_entries_item ON waitlist_entries (item_id, status);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка миграции waitlist: %w", err)
+	}
+	fmt.Println("Миграция waitlist выполнена успешно")
+	return nil
+}
+
+func (r *Repository) GetSettings(ctx context.Context, ownerID int) (*Settings, error) {
+	s := Settings{OwnerID: ownerID}
+	err := r.db.Conn.QueryRow(ctx, `SELECT hold_hours FROM waitlist_settings WHERE owner_id = $1`, ownerID).Scan(&s.HoldHours)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultSettings(ownerID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек листа ожидания: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *Repository) SaveSettings(ctx context.Context, s *Settings) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		INSERT INTO waitlist_settings (owner_id, hold_hours) VALUES ($1, $2)
+		ON CONFLICT (owner_id) DO UPDATE SET hold_hours = EXCLUDED.hold_hours, updated_at = NOW()
+	`, s.OwnerID, s.HoldHours)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения настроек листа ожидания: %w", err)
+	}
+	return nil
+}
+
+// Позиция в очереди считается среди ожидающих того же товара
+const entryColumns = `
+	e.id, e.owner_id, e.shop_id, e.item_id, i.name, COALESCE(i.size, ''), e.customer_id, e.quantity, e.channel,
+	COALESCE(e.notes, ''), e.status,
+	CASE WHEN e.status = 'waiting' THEN
+		(SELECT COUNT(*) FROM waitlist_entries w
+		 WHERE w.item_id = e.item_id AND w.status = 'waiting' AND (w.created_at, w.id) <= (e.created_at, e.id))
+	ELSE 0 END,
+	COALESCE(e.created_by, 0), e.created_at, e.notified_at, COALESCE(e.notify_error, ''), e.hold_until, e.closed_at
+`
+
+func scanEntries(rows pgx.Rows) ([]Entry, error) {
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.OwnerID, &e.ShopID, &e.ItemID, &e.ItemName, &e.Size, &e.CustomerID, &e.Quantity,
+			&e.Channel, &e.Notes, &e.Status, &e.Position, &e.CreatedBy, &e.CreatedAt, &e.NotifiedAt, &e.NotifyError,
+			&e.HoldUntil, &e.ClosedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения записи листа ожидания: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке листа ожидания: %w", err)
+	}
+	return entries, nil
+}
+
+func (r *Repository) CreateEntry(ctx context.Context, e *Entry) error {
+	err := r.db.Conn.QueryRow(ctx, `
+		INSERT INTO waitlist_entries (owner_id, shop_id, item_id, customer_id, quantity, channel, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+		RETURNING id, status, created_at
+	`, e.OwnerID, e.ShopID, e.ItemID, e.CustomerID, e.Quantity, e.Channel, e.Notes, e.CreatedBy).Scan(&e.ID, &e.Status, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка добавления в лист ожидания: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetEntry(ctx context.Context, shopID, id int) (*Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+entryColumns+`
+		FROM waitlist_entries e JOIN items i ON i.id = e.item_id
+		WHERE e.id = $1 AND e.shop_id = $2
+	`, id, shopID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения записи листа ожидания: %w", err)
+	}
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	return &entries[0], nil
+}
+
+// GetEntries — лист ожидания магазина; пустой статус — активные записи (ожидают или отложены)
+func (r *Repository) GetEntries(ctx context.Context, shopID, itemID int, status string) ([]Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+entryColumns+`
+		FROM waitlist_entries e JOIN items i ON i.id = e.item_id
+		WHERE e.shop_id = $1
+		  AND ($2 = 0 OR e.item_id = $2)
+		  AND (($3 = '' AND e.status IN ('waiting', 'held')) OR e.status = $3)
+		ORDER BY e.item_id, e.created_at, e.id
+	`, shopID, itemID, status)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения листа ожидания: %w", err)
+	}
+	return scanEntries(rows)
+}
+
+// Close закрывает активную запись (выкуплена, отменена); false — запись уже не активна
+func (r *Repository) Close(ctx context.Context, shopID, id int, status string) (bool, error) {
+	res, err := r.db.Conn.Exec(ctx, `
+		UPDATE waitlist_entries SET status = $1, closed_at = NOW()
+		WHERE id = $2 AND shop_id = $3 AND status IN ('waiting', 'held')
+	`, status, id, shopID)
+	if err != nil {
+		return false, fmt.Errorf("ошибка закрытия записи листа ожидания (ID=%d): %w", id, err)
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// HeldSQL — SQL-выражение: сколько единиц товара item (параметр или столбец) занято — отложено по листу
+// ожидания или забронировано. Бронь держит товар там, где он физически лежит: до отправки — в источнике,
+// после приёмки — в магазине выдачи. Это единственное определение занятого остатка: по нему считают
+// свободный остаток лист ожидания, брони и продажи.
+func HeldSQL(item string) string {
+	return `((SELECT COALESCE(SUM(quantity), 0) FROM waitlist_entries WHERE item_id = ` + item + ` AND status = 'held') +
+		(SELECT COALESCE(SUM(quantity), 0) FROM reservations
+		 WHERE (status = 'held' AND item_id = ` + item + `) OR (status = 'ready' AND pickup_item_id = ` + item + `)))`
+}
+
+// HeldQuery — сколько единиц товара $1 занято (см. HeldSQL)
+var HeldQuery = `SELECT ` + HeldSQL("$1")
+
+// HeldQuantity — сколько единиц товара сейчас отложено по листу ожидания и броням
+func (r *Repository) HeldQuantity(ctx context.Context, itemID int) (int, error) {
+	var held int
+	err := r.db.Conn.QueryRow(ctx, HeldQuery, itemID).Scan(&held)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта отложенного товара: %w", err)
+	}
+	return held, nil
+}
+
+// Allocate откладывает свободные единицы товара ожидающим по очереди.
+// Строка товара блокируется, чтобы две приёмки не раздали одни и те же единицы.
+// Очередь строгая: если первому не хватает, следующие не обгоняют его.
+func (r *Repository) Allocate(ctx context.Context, itemID int, holdUntil time.Time) ([]int, error) {
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var available int
+	err = tx.QueryRow(ctx, `SELECT stock FROM items WHERE id = $1 FOR UPDATE`, itemID).Scan(&available)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения остатка товара (ID=%d): %w", itemID, err)
+	}
+	var held int
+	err = tx.QueryRow(ctx, HeldQuery, itemID).Scan(&held)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта отложенного товара: %w", err)
+	}
+	available -= held
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, quantity FROM waitlist_entries
+		WHERE item_id = $1 AND status = 'waiting'
+		ORDER BY created_at, id
+	`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения листа ожидания: %w", err)
+	}
+	var allocated []int
+	for rows.Next() {
+		var id, quantity int
+		if err := rows.Scan(&id, &quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка чтения записи листа ожидания: %w", err)
+		}
+		if quantity > available {
+			break
+		}
+		available -= quantity
+		allocated = append(allocated, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке листа ожидания: %w", err)
+	}
+
+	if len(allocated) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE waitlist_entries SET status = 'held', hold_until = $1 WHERE id = ANY($2)
+		`, holdUntil, allocated)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка резерва товара по листу ожидания: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка резерва товара по листу ожидания: %w", err)
+	}
+	return allocated, nil
+}
+
+func (r *Repository) MarkNotified(ctx context.Context, id int, notifyErr string) error {
+	_, err := r.db.Conn.Exec(ctx, `
+		UPDATE waitlist_entries
+		SET notified_at = CASE WHEN $1 = '' THEN NOW() ELSE notified_at END, notify_error = NULLIF($1, '')
+		WHERE id = $2
+	`, notifyErr, id)
+	if err != nil {
+		return fmt.Errorf("ошибка отметки уведомления (ID=%d): %w", id, err)
+	}
+	return nil
+}
+
+// ExpireHolds снимает просроченные резервы и возвращает товары, единицы которых освободились
+func (r *Repository) ExpireHolds(ctx context.Context, now time.Time) ([]int, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		UPDATE waitlist_entries SET status = 'expired', closed_at = $1
+		WHERE status = 'held' AND hold_until < $1
+		RETURNING item_id
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка снятия просроченных резервов: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[int]bool)
+	var items []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения резерва: %w", err)
+		}
+		if !seen[id] {
+			seen[id] = true
+			items = append(items, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке резервов: %w", err)
+	}
+	return items, nil
+}
+
+func (r *Repository) GetEntriesByIDs(ctx context.Context, ids []int) ([]Entry, error) {
+	rows, err := r.db.Conn.Query(ctx, `
+		SELECT `+entryColumns+`
+		FROM waitlist_entries e JOIN items i ON i.id = e.item_id
+		WHERE e.id = ANY($1)
+		ORDER BY e.created_at, e.id
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения записей листа ожидания: %w", err)
+	}
+	return scanEntries(rows)
+}
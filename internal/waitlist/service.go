@@ -0,0 +1,315 @@
+package waitlist
+
+import (
+	"context"
+	"crm-backend/internal/customer"
+	"crm-backend/internal/employee"
+	"crm-backend/internal/notify"
+	"crm-backend/internal/shop"
+	"crm-backend/internal/task"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const maxHoldHours = 24 * 14
+
+type Service struct {
+	repo      *Repository
+	employees *employee.Repository
+	customers *customer.Repository
+	items     *shop.Repository
+	tasks     *task.Service
+	sender    *notify.Dispatcher
+}
+
+func NewService(repo *Repository, employees *employee.Repository, customers *customer.Repository, items *shop.Repository,
+	tasks *task.Service, sender *notify.Dispatcher) *Service {
+	return &Service{repo: repo, employees: employees, customers: customers, items: items, tasks: tasks, sender: sender}
+}
+
+// organisation — владелец магазина, если у пользователя есть к нему доступ
+func (s *Service) organisation(ctx context.Context, userID, shopID int) (int, error) {
+	ok, err := s.employees.HasShopAccess(ctx, shopID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrAccessDenied
+	}
+	return s.employees.GetShopOwnerID(ctx, shopID)
+}
+
+func (s *Service) shopItem(ctx context.Context, shopID, itemID int) (*shop.Item, error) {
+	item, err := s.items.GetItemByID(ctx, itemID)
+	if err != nil || item.ShopID != shopID {
+		return nil, ErrItemNotFound
+	}
+	return item, nil
+}
+
+func (s *Service) GetSettings(ctx context.Context, ownerID int) (*Settings, error) {
+	return s.repo.GetSettings(ctx, ownerID)
+}
+
+func (s *Service) SaveSettings(ctx context.Context, ownerID int, st *Settings) error {
+	if st.HoldHours <= 0 || st.HoldHours > maxHoldHours {
+		return fmt.Errorf("срок резерва должен быть от 1 до %d часов", maxHoldHours)
+	}
+	st.OwnerID = ownerID
+	return s.repo.SaveSettings(ctx, st)
+}
+
+// AddEntry ставит покупателя в очередь; если товар уже есть в наличии, он сразу откладывается
+func (s *Service) AddEntry(ctx context.Context, userID, shopID int, e *Entry) error {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return err
+	}
+	if err := e.validate(); err != nil {
+		return err
+	}
+	if _, err := s.shopItem(ctx, shopID, e.ItemID); err != nil {
+		return err
+	}
+	ok, err := s.customers.BelongsTo(ctx, e.CustomerID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return customer.ErrCustomerNotFound
+	}
+
+	e.OwnerID = ownerID
+	e.ShopID = shopID
+	e.CreatedBy = userID
+	if err := s.repo.CreateEntry(ctx, e); err != nil {
+		return err
+	}
+	if _, err := s.allocate(ctx, ownerID, e.ItemID); err != nil {
+		return err
+	}
+	created, err := s.repo.GetEntry(ctx, shopID, e.ID)
+	if err != nil {
+		return err
+	}
+	*e = *created
+	return nil
+}
+
+func (s *Service) GetEntries(ctx context.Context, userID, shopID, itemID int, status string) ([]Entry, error) {
+	if _, err := s.organisation(ctx, userID, shopID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetEntries(ctx, shopID, itemID, status)
+}
+
+// Close — покупатель выкупил товар (fulfilled) или передумал (cancelled).
+// Отменённый резерв сразу переходит следующему в очереди.
+func (s *Service) Close(ctx context.Context, userID, shopID, id int, status string) (*Entry, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.repo.GetEntry(ctx, shopID, id)
+	if err != nil {
+		return nil, err
+	}
+	if status == StatusFulfilled && e.Status != StatusHeld {
+		return nil, errors.New("выкупить можно только отложенный товар")
+	}
+	ok, err := s.repo.Close(ctx, shopID, id, status)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrEntryClosed
+	}
+	if status == StatusCancelled && e.Status == StatusHeld {
+		if _, err := s.allocate(ctx, ownerID, e.ItemID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.GetEntry(ctx, shopID, id)
+}
+
+// ReceiveStock — приёмка товара. Поступившие единицы в первую очередь откладываются листу ожидания.
+func (s *Service) ReceiveStock(ctx context.Context, userID, shopID, itemID, quantity int) (*ReceiveResult, error) {
+	ownerID, err := s.organisation(ctx, userID, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if quantity <= 0 {
+		return nil, errors.New("количество должно быть больше нуля")
+	}
+	if _, err := s.shopItem(ctx, shopID, itemID); err != nil {
+		return nil, err
+	}
+
+	stock, err := s.items.AddStock(ctx, itemID, quantity)
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := s.allocate(ctx, ownerID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	held, err := s.repo.HeldQuantity(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiveResult{
+		ItemID:    itemID,
+		Stock:     stock,
+		Held:      held,
+		Available: max(stock-held, 0),
+		Allocated: allocated,
+	}, nil
+}
+
+// allocate откладывает товар ожидающим, уведомляет их и ставит задачу сотруднику, который записал покупателя.
+// Ошибка уведомления не отменяет резерв: она сохраняется в записи, а задача всё равно создаётся.
+func (s *Service) allocate(ctx context.Context, ownerID, itemID int) ([]Entry, error) {
+	st, err := s.repo.GetSettings(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	holdUntil := time.Now().Add(time.Duration(st.HoldHours) * time.Hour)
+
+	ids, err := s.repo.Allocate(ctx, itemID, holdUntil)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	entries, err := s.repo.GetEntriesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	customerIDs := make([]int, len(entries))
+	for i, e := range entries {
+		customerIDs[i] = e.CustomerID
+	}
+	customers, err := s.customers.GetCustomersByIDs(ctx, ownerID, customerIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]customer.Customer, len(customers))
+	for _, c := range customers {
+		byID[c.ID] = c
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		c := byID[e.CustomerID]
+		e.NotifyError = s.notify(ctx, e, c, holdUntil)
+		if err := s.repo.MarkNotified(ctx, e.ID, e.NotifyError); err != nil {
+			return nil, err
+		}
+		s.followUp(ctx, e, c, holdUntil)
+	}
+	return entries, nil
+}
+
+func address(c customer.Customer, channel notify.Channel) string {
+	switch channel {
+	case notify.ChannelSMS:
+		return c.Phone
+	case notify.ChannelEmail:
+		return c.Email
+	case notify.ChannelTelegram:
+		return c.TelegramChatID
+	}
+	return ""
+}
+
+// notify отправляет уведомление о поступлении; возвращает текст ошибки или пустую строку
+func (s *Service) notify(ctx context.Context, e *Entry, c customer.Customer, holdUntil time.Time) string {
+	to := address(c, e.Channel)
+	if to == "" {
+		return "нет адреса для канала " + string(e.Channel)
+	}
+	item := e.ItemName
+	if e.Size != "" {
+		item += ", размер " + e.Size
+	}
+	_, err := s.sender.Send(ctx, notify.Message{
+		Channel: e.Channel,
+		To:      to,
+		Subject: "Товар снова в наличии",
+		Body: fmt.Sprintf("%s, «%s» снова в наличии. Мы отложили его для вас до %s.",
+			c.Name, item, holdUntil.Format("02.01.2006 15:04")),
+	})
+	if err != nil {
+		return err.Error()
+	}
+	now := time.Now()
+	e.NotifiedAt = &now
+	return ""
+}
+
+func (s *Service) followUp(ctx context.Context, e *Entry, c customer.Customer, holdUntil time.Time) {
+	customerID, itemID := e.CustomerID, e.ItemID
+	t := &task.Task{
+		ShopID:     e.ShopID,
+		CustomerID: &customerID,
+		ItemID:     &itemID,
+		Title:      fmt.Sprintf("Позвонить %s: поступил %s %s", c.Name, e.ItemName, e.Size),
+		Notes:      e.Notes,
+		DueAt:      &holdUntil,
+		Source:     task.SourceWaitlist,
+	}
+	if e.CreatedBy != 0 {
+		assignee := e.CreatedBy
+		t.AssigneeID = &assignee
+	}
+	if err := s.tasks.CreateFollowUp(ctx, t); err != nil {
+		log.Printf("ошибка создания задачи по листу ожидания ID=%d: %v", e.ID, err)
+	}
+}
+
+// ExpireHolds снимает просроченные резервы и предлагает освободившийся товар следующим в очереди
+func (s *Service) ExpireHolds(ctx context.Context) error {
+	itemIDs, err := s.repo.ExpireHolds(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, itemID := range itemIDs {
+		if err := s.Reallocate(ctx, itemID); err != nil {
+			log.Printf("ошибка резерва товара ID=%d: %v", itemID, err)
+		}
+	}
+	return nil
+}
+
+// Reallocate предлагает освободившиеся единицы товара следующим в очереди —
+// например, когда покупатель отказался от брони
+func (s *Service) Reallocate(ctx context.Context, itemID int) error {
+	item, err := s.items.GetItemByID(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	ownerID, err := s.employees.GetShopOwnerID(ctx, item.ShopID)
+	if err != nil {
+		return err
+	}
+	_, err = s.allocate(ctx, ownerID, itemID)
+	return err
+}
+
+// RunExpiry — фоновое снятие просроченных резервов
+func (s *Service) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ExpireHolds(ctx); err != nil {
+				log.Printf("ошибка снятия резервов листа ожидания: %v", err)
+			}
+		}
+	}
+}
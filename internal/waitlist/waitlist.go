@@ -0,0 +1,92 @@
+package waitlist
+
+import (
+	"crm-backend/internal/notify"
+	"errors"
+	"time"
+)
+
+const (
+	StatusWaiting   = "waiting"
+	StatusHeld      = "held"
+	StatusFulfilled = "fulfilled"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+)
+
+const defaultHoldHours = 24
+
+var (
+	ErrAccessDenied  = errors.New("доступ запрещён: нет доступа к магазину")
+	ErrEntryNotFound = errors.New("запись листа ожидания не найдена")
+	ErrEntryClosed   = errors.New("запись листа ожидания уже закрыта")
+	ErrItemNotFound  = errors.New("товар не найден в магазине")
+)
+
+// Entry — покупатель ждёт конкретный товар (размер) в магазине.
+// Когда товар поступает, ожидающие получают его по очереди: единицы откладываются
+// на HoldUntil, покупателю уходит уведомление, а сотруднику — задача перезвонить.
+type Entry struct {
+	ID         int            `json:"id"`
+	OwnerID    int            `json:"owner_id"`
+	ShopID     int            `json:"shop_id"`
+	ItemID     int            `json:"item_id"`
+	ItemName   string         `json:"item_name,omitempty"`
+	Size       string         `json:"size,omitempty"`
+	CustomerID int            `json:"customer_id"`
+	Quantity   int            `json:"quantity"`
+	Channel    notify.Channel `json:"channel"`
+	Notes      string         `json:"notes,omitempty"`
+	Status     string         `json:"status"`
+	// Position — место в очереди для ожидающих (с 1)
+	Position    int        `json:"position,omitempty"`
+	CreatedBy   int        `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	NotifiedAt  *time.Time `json:"notified_at,omitempty"`
+	NotifyError string     `json:"notify_error,omitempty"`
+	HoldUntil   *time.Time `json:"hold_until,omitempty"`
+	ClosedAt    *time.Time `json:"closed_at,omitempty"`
+}
+
+func (e *Entry) validate() error {
+	if e.ItemID == 0 {
+		return errors.New("укажите товар")
+	}
+	if e.CustomerID == 0 {
+		return errors.New("укажите покупателя")
+	}
+	if e.Quantity <= 0 {
+		e.Quantity = 1
+	}
+	if e.Channel == "" {
+		e.Channel = notify.ChannelSMS
+	}
+	if !e.Channel.Valid() {
+		return errors.New("неизвестный канал: " + string(e.Channel))
+	}
+	return nil
+}
+
+// Settings — настройки листа ожидания организации
+type Settings struct {
+	OwnerID int `json:"owner_id"`
+	// HoldHours — сколько часов поступивший товар отложен для покупателя
+	HoldHours int `json:"hold_hours"`
+}
+
+func DefaultSettings(ownerID int) *Settings {
+	return &Settings{OwnerID: ownerID, HoldHours: defaultHoldHours}
+}
+
+type ReceiveRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// ReceiveResult — остаток после приёмки и кому из листа ожидания отложен товар
+type ReceiveResult struct {
+	ItemID    int     `json:"item_id"`
+	Stock     int     `json:"stock"`
+	Held      int     `json:"held"`
+	Available int     `json:"available"`
+	Allocated []Entry `json:"allocated"`
+}